@@ -14,7 +14,7 @@ cqlStatement
 
 // INSERT
 
-// note: JSON INSERT not supported
+// note: JSON INSERT not supported, it is recognized by inspectInsertJsonStatement in proxy/pkg/zdmproxy/queryinspector.go
 insertStatement
     : K_INSERT K_INTO tableName
       '(' identifiers ')' K_VALUES '(' terms ')'
@@ -57,9 +57,16 @@ func Create(name string, version string, isDse bool) (string, error) {
 
 }
 
-func Add(seed bool, address string, remoteDebugPort int, jmxPort int, name string, isDse bool) (string, error) {
+// Add adds a node to the current cluster, dataCenter and rack are optional (empty strings use the defaults of ccm).
+func Add(seed bool, address string, remoteDebugPort int, jmxPort int, name string, isDse bool, dataCenter string, rack string) (string, error) {
 	var addArgs = []string{
 		"-i", address, "-r", fmt.Sprintf("%d", remoteDebugPort), "-j", fmt.Sprintf("%d", jmxPort), name}
+	if dataCenter != "" {
+		addArgs = append(addArgs, "-d", dataCenter)
+	}
+	if rack != "" {
+		addArgs = append(addArgs, "--rack", rack)
+	}
 	if isDse {
 		addArgs = append(addArgs, "--dse")
 	}
@@ -12,27 +12,33 @@ type Cluster struct {
 	initialContactPoint string
 	isDse               bool
 	numberOfSeedNodes   int
+	topology            env.ClusterTopology
 
 	startNodeIndex int
 	session        *gocql.Session
 }
 
-func newCluster(name string, version string, isDse bool, startNodeIndex int, numberOfSeedNodes int) *Cluster {
+func newCluster(name string, version string, isDse bool, startNodeIndex int, topology env.ClusterTopology) *Cluster {
 	return &Cluster{
 		name:                name,
 		version:             version,
 		initialContactPoint: fmt.Sprintf("127.0.0.%d", startNodeIndex),
 		isDse:               isDse,
-		numberOfSeedNodes:   numberOfSeedNodes,
+		numberOfSeedNodes:   topology.Nodes(),
+		topology:            topology,
 		startNodeIndex:      startNodeIndex,
 		session:             nil,
 	}
 }
 
 func GetNewCluster(id uint64, startNodeIndex int, numberOfNodes int, start bool) (*Cluster, error) {
+	return GetNewClusterWithTopology(id, startNodeIndex, env.ClusterTopology{DcNodes: []int{numberOfNodes}, RacksPerDc: 1}, start)
+}
+
+func GetNewClusterWithTopology(id uint64, startNodeIndex int, topology env.ClusterTopology, start bool) (*Cluster, error) {
 	name := fmt.Sprintf("test_cluster%d", id)
-	cluster := newCluster(name, env.ServerVersion, env.IsDse, startNodeIndex, numberOfNodes)
-	err := cluster.Create(numberOfNodes, start)
+	cluster := newCluster(name, env.ServerVersion, env.IsDse, startNodeIndex, topology)
+	err := cluster.Create(start)
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +65,11 @@ func (ccmCluster *Cluster) GetNumberOfSeedNodes() int {
 	return ccmCluster.numberOfSeedNodes
 }
 
-func (ccmCluster *Cluster) Create(numberOfNodes int, start bool) error {
+func (ccmCluster *Cluster) GetTopology() env.ClusterTopology {
+	return ccmCluster.topology
+}
+
+func (ccmCluster *Cluster) Create(start bool) error {
 	_, err := Create(ccmCluster.name, ccmCluster.version, ccmCluster.isDse)
 
 	if err != nil {
@@ -67,15 +77,18 @@ func (ccmCluster *Cluster) Create(numberOfNodes int, start bool) error {
 		return err
 	}
 
-	for i := 0; i < numberOfNodes; i++ {
+	for i := 0; i < ccmCluster.topology.Nodes(); i++ {
 		nodeIndex := ccmCluster.startNodeIndex + i
+		dataCenter, rack := ccmCluster.nodeLocation(i)
 		_, err = Add(
 			true,
 			fmt.Sprintf("127.0.0.%d", nodeIndex),
 			2000+nodeIndex*100,
 			7000+nodeIndex*100,
 			fmt.Sprintf("node%d", nodeIndex),
-			ccmCluster.isDse)
+			ccmCluster.isDse,
+			dataCenter,
+			rack)
 
 		if err != nil {
 			Remove(ccmCluster.name)
@@ -148,16 +161,33 @@ func (ccmCluster *Cluster) Remove() error {
 func (ccmCluster *Cluster) AddNode(index int) error {
 	ccmCluster.SwitchToThis()
 	nodeIndex := ccmCluster.startNodeIndex + index
+	dataCenter, rack := ccmCluster.nodeLocation(index)
 	_, err := Add(
 		false,
 		fmt.Sprintf("127.0.0.%d", nodeIndex),
 		2000+nodeIndex*100,
 		7000+nodeIndex*100,
 		fmt.Sprintf("node%d", nodeIndex),
-		ccmCluster.isDse)
+		ccmCluster.isDse,
+		dataCenter,
+		rack)
 	return err
 }
 
+// nodeLocation returns the data center and rack of a node, nodes added after the cluster was created (index greater
+// than the number of nodes of the topology) go to the last data center. Both are empty when the cluster has the
+// default topology so that the nodes are added like ccm does by default.
+func (ccmCluster *Cluster) nodeLocation(index int) (string, string) {
+	if ccmCluster.topology.IsDefault() {
+		return "", ""
+	}
+	dataCenter, rack := ccmCluster.topology.NodeLocation(index)
+	if ccmCluster.topology.RacksPerDc <= 1 {
+		rack = ""
+	}
+	return dataCenter, rack
+}
+
 func (ccmCluster *Cluster) StartNode(index int, jvmArgs ...string) error {
 	ccmCluster.SwitchToThis()
 	nodeIndex := ccmCluster.startNodeIndex + index
@@ -0,0 +1,339 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	chaosOriginHost        = "127.0.1.1"
+	chaosTargetHost        = "127.0.1.2"
+	chaosFaultInjectorHost = "127.0.1.12"
+)
+
+// TestChaosProxyShutdownDuringWrites shuts the proxy down while several client connections are sending writes and
+// checks that every write acknowledged to a client was applied on both clusters and that a new proxy instance serves
+// the clients again
+func TestChaosProxyShutdownDuringWrites(t *testing.T) {
+	conf := setup.NewTestConfig(chaosOriginHost, chaosTargetHost)
+	testSetup, origin, target := newChaosTestSetup(t, conf, nil)
+	defer testSetup.Cleanup()
+
+	acked := newChaosWriteSet()
+	wg := &sync.WaitGroup{}
+	for connIdx := 0; connIdx < 4; connIdx++ {
+		clientConn, err := testSetup.Client.CqlClient.ConnectAndInit(
+			context.Background(), primitive.ProtocolVersion4, client.ManagedStreamId)
+		require.Nil(t, err)
+		defer clientConn.Close()
+
+		wg.Add(1)
+		go func(connIdx int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				id := connIdx*1000000 + i
+				if sendChaosWrite(clientConn, id) != nil {
+					return
+				}
+				acked.add(id)
+			}
+		}(connIdx)
+	}
+
+	utils.RequireWithRetries(t, func() (error, bool) {
+		if acked.size() < 100 {
+			return fmt.Errorf("only %v writes were acknowledged", acked.size()), false
+		}
+		return nil, false
+	}, 50, 100*time.Millisecond)
+
+	testSetup.Proxy.Shutdown()
+	testSetup.Proxy = nil
+	wg.Wait()
+
+	for _, id := range acked.ids() {
+		require.True(t, origin.contains(id), "acknowledged write %v was not applied on origin", id)
+		require.True(t, target.contains(id), "acknowledged write %v was not applied on target", id)
+	}
+
+	proxyInstance, err := setup.NewProxyInstanceWithConfig(conf)
+	require.Nil(t, err)
+	testSetup.Proxy = proxyInstance
+
+	clientConn, err := testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), primitive.ProtocolVersion4, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer clientConn.Close()
+	require.Nil(t, sendChaosWrite(clientConn, -1))
+	require.True(t, origin.contains(-1))
+	require.True(t, target.contains(-1))
+}
+
+// TestChaosTargetPartition partitions the proxy from the target cluster and checks that the client connections are
+// closed without losing acknowledged writes, that new client connections are rejected during the partition and that
+// the clients can connect and write to both clusters again once the partition heals
+func TestChaosTargetPartition(t *testing.T) {
+	conf := setup.NewTestConfig(chaosOriginHost, chaosTargetHost)
+	injector, err := env.NewFaultInjector(
+		fmt.Sprintf("%v:%d", chaosFaultInjectorHost, conf.TargetPort), fmt.Sprintf("%v:%d", chaosTargetHost, conf.TargetPort))
+	require.Nil(t, err)
+	defer injector.Close()
+
+	testSetup, origin, target := newChaosTestSetup(t, conf, func(conf *config.Config) {
+		// with host assignment the proxy would connect to the address returned by the target cluster
+		conf.TargetContactPoints = chaosFaultInjectorHost
+		conf.TargetEnableHostAssignment = false
+	})
+	defer testSetup.Cleanup()
+
+	clientConn, err := testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), primitive.ProtocolVersion4, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer clientConn.Close()
+
+	acked := newChaosWriteSet()
+	for id := 0; id < 10; id++ {
+		require.Nil(t, sendChaosWrite(clientConn, id))
+		acked.add(id)
+	}
+
+	injector.Partition()
+
+	// the proxy closes the client connection once it notices that the target connection is gone
+	id := 10
+	utils.RequireWithRetries(t, func() (error, bool) {
+		if sendChaosWrite(clientConn, id) != nil {
+			return nil, false
+		}
+		acked.add(id)
+		id++
+		return fmt.Errorf("write %v was acknowledged during the partition", id-1), false
+	}, 50, 100*time.Millisecond)
+
+	_, err = testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), primitive.ProtocolVersion4, client.ManagedStreamId)
+	require.NotNil(t, err, "client connection should be rejected during the partition")
+
+	injector.Heal()
+
+	utils.RequireWithRetries(t, func() (error, bool) {
+		newClientConn, err := testSetup.Client.CqlClient.ConnectAndInit(
+			context.Background(), primitive.ProtocolVersion4, client.ManagedStreamId)
+		if err != nil {
+			return err, false
+		}
+		defer newClientConn.Close()
+		err = sendChaosWrite(newClientConn, -1)
+		if err != nil {
+			return err, false
+		}
+		acked.add(-1)
+		return nil, false
+	}, 50, 200*time.Millisecond)
+
+	for _, id := range acked.ids() {
+		require.True(t, origin.contains(id), "acknowledged write %v was not applied on origin", id)
+		require.True(t, target.contains(id), "acknowledged write %v was not applied on target", id)
+	}
+}
+
+// TestChaosTargetDroppedDuringJournalReplay journals the writes that fail on the target cluster, drops the target
+// connection of tools/zdm-replay while it replays the journal and checks that replaying the journal again applies all
+// the journaled writes on the target cluster
+func TestChaosTargetDroppedDuringJournalReplay(t *testing.T) {
+	conf := setup.NewTestConfig(chaosOriginHost, chaosTargetHost)
+	conf.DualWritesFailures = config.WriteFailuresJournal
+	conf.DualWritesJournalFile = filepath.Join(t.TempDir(), "journal")
+	injector, err := env.NewFaultInjector(
+		fmt.Sprintf("%v:%d", chaosFaultInjectorHost, conf.TargetPort), fmt.Sprintf("%v:%d", chaosTargetHost, conf.TargetPort))
+	require.Nil(t, err)
+	defer injector.Close()
+
+	testSetup, origin, target := newChaosTestSetup(t, conf, nil)
+	defer testSetup.Cleanup()
+
+	clientConn, err := testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), primitive.ProtocolVersion4, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer clientConn.Close()
+
+	writes := 20
+	target.setFailWrites(true)
+	for id := 0; id < writes; id++ {
+		require.Nil(t, sendChaosWrite(clientConn, id))
+	}
+	require.Equal(t, writes, origin.size())
+	require.Equal(t, 0, target.size())
+
+	// the journal is flushed when the proxy shuts down
+	testSetup.Proxy.Shutdown()
+	testSetup.Proxy = nil
+	target.setFailWrites(false)
+
+	replayTool := filepath.Join(t.TempDir(), "zdm-replay")
+	out, err := exec.Command("go", "build", "-o", replayTool, "../tools/zdm-replay").CombinedOutput()
+	require.Nil(t, err, "could not build zdm-replay: %s", out)
+	replay := func() {
+		out, err := exec.Command(replayTool, "-file", conf.DualWritesJournalFile, "-address", injector.Address(),
+			"-username", conf.TargetUsername, "-password", conf.TargetPassword, "-speed", "0").CombinedOutput()
+		require.Nil(t, err, "zdm-replay failed: %s", out)
+	}
+
+	target.setOnWrite(func(applied int) {
+		if applied == writes/4 {
+			injector.DropConnections()
+		}
+	})
+	replay()
+	require.Less(t, target.size(), writes, "the replay should have lost the writes sent after the connection was dropped")
+
+	target.setOnWrite(nil)
+	replay()
+	for id := 0; id < writes; id++ {
+		require.True(t, target.contains(id), "journaled write %v was not applied on target", id)
+	}
+}
+
+// newChaosTestSetup starts origin and target CQL servers that record the writes they apply and a proxy, updateConf
+// can change the configuration of the proxy after the CQL servers are created.
+func newChaosTestSetup(
+	t *testing.T, conf *config.Config, updateConf func(conf *config.Config)) (*setup.CqlServerTestSetup, *chaosWriteSet, *chaosWriteSet) {
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+
+	origin := newChaosWriteSet()
+	target := newChaosWriteSet()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), origin.handleWrites}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc1"), target.handleWrites}
+
+	if updateConf != nil {
+		updateConf(conf)
+	}
+	err = testSetup.Start(conf, false, primitive.ProtocolVersion4)
+	if err != nil {
+		testSetup.Cleanup()
+		require.Nil(t, err)
+	}
+	return testSetup, origin, target
+}
+
+func sendChaosWrite(clientConn *client.CqlClientConnection, id int) error {
+	request := frame.NewFrame(primitive.ProtocolVersion4, client.ManagedStreamId,
+		&message.Query{Query: fmt.Sprintf("INSERT INTO ks1.t1 (id) VALUES (%d)", id)})
+	response, err := clientConn.SendAndReceive(request)
+	if err != nil {
+		return err
+	}
+	if _, ok := response.Body.Message.(*message.VoidResult); !ok {
+		return fmt.Errorf("expected void result but got %v", response.Body.Message)
+	}
+	return nil
+}
+
+// chaosWriteSet is a set of write ids, it is used to record the writes acknowledged to the clients and the writes
+// applied by the CQL servers.
+type chaosWriteSet struct {
+	lock       sync.Mutex
+	writes     map[int]bool
+	failWrites bool
+	onWrite    func(applied int)
+}
+
+func newChaosWriteSet() *chaosWriteSet {
+	return &chaosWriteSet{writes: make(map[int]bool)}
+}
+
+func (recv *chaosWriteSet) add(id int) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.writes[id] = true
+}
+
+func (recv *chaosWriteSet) contains(id int) bool {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return recv.writes[id]
+}
+
+func (recv *chaosWriteSet) size() int {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return len(recv.writes)
+}
+
+func (recv *chaosWriteSet) ids() []int {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	ids := make([]int, 0, len(recv.writes))
+	for id := range recv.writes {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (recv *chaosWriteSet) setFailWrites(failWrites bool) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.failWrites = failWrites
+}
+
+func (recv *chaosWriteSet) setOnWrite(onWrite func(applied int)) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.onWrite = onWrite
+}
+
+// handleWrites applies the writes sent by sendChaosWrite, or returns a WRITE_TIMEOUT error if failWrites is set.
+func (recv *chaosWriteSet) handleWrites(
+	request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) (response *frame.Frame) {
+	query, ok := request.Body.Message.(*message.Query)
+	if !ok || !strings.HasPrefix(query.Query, "INSERT INTO ks1.t1") {
+		return nil
+	}
+	var id int
+	_, err := fmt.Sscanf(query.Query, "INSERT INTO ks1.t1 (id) VALUES (%d)", &id)
+	if err != nil {
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId,
+			&message.Invalid{ErrorMessage: err.Error()})
+	}
+
+	recv.lock.Lock()
+	if recv.failWrites {
+		recv.lock.Unlock()
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.WriteTimeout{
+			ErrorMessage: "write timeout",
+			Consistency:  primitive.ConsistencyLevelLocalQuorum,
+			Received:     0,
+			BlockFor:     2,
+			WriteType:    primitive.WriteTypeSimple,
+		})
+	}
+	recv.writes[id] = true
+	applied := len(recv.writes)
+	onWrite := recv.onWrite
+	recv.lock.Unlock()
+
+	if onWrite != nil {
+		onWrite(applied)
+	}
+	return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+}
@@ -0,0 +1,65 @@
+package integration_tests
+
+import (
+	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestDataConsistencyDuringMigration simulates a migration: existing rows are written to origin only, then new rows
+// and updates of existing rows are written through the proxy while the existing rows are copied to target. At the
+// end both clusters must have exactly the rows whose writes were acknowledged.
+func TestDataConsistencyDuringMigration(t *testing.T) {
+	if !env.RunCcmTests {
+		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
+	}
+
+	originCluster, targetCluster, err := SetupOrGetGlobalCcmClusters()
+	require.Nil(t, err)
+	originSession := originCluster.GetSession()
+	targetSession := targetCluster.GetSession()
+
+	dataset := utils.NewDataset(setup.TestKeyspace, "consistency")
+	err = dataset.CreateTable(originSession, targetSession)
+	require.Nil(t, err)
+
+	existingRows := make(map[int]string)
+	for id := 0; id < 500; id++ {
+		existingRows[id] = fmt.Sprintf("existing%d", id)
+	}
+	err = dataset.Write(originSession, 10, existingRows)
+	require.Nil(t, err)
+
+	proxyInstance, err := NewProxyInstanceForGlobalCcmClusters()
+	require.Nil(t, err)
+	defer proxyInstance.Shutdown()
+
+	proxy, err := utils.ConnectToCluster("127.0.0.1", "", "", 14002)
+	require.Nil(t, err)
+	defer proxy.Close()
+
+	// half of the existing rows are updated and 500 rows are inserted
+	proxyRows := make(map[int]string)
+	for id := 0; id < 250; id++ {
+		proxyRows[id] = fmt.Sprintf("updated%d", id)
+	}
+	for id := 500; id < 1000; id++ {
+		proxyRows[id] = fmt.Sprintf("new%d", id)
+	}
+
+	proxyErr := make(chan error, 1)
+	go func() {
+		proxyErr <- dataset.Write(proxy, 10, proxyRows)
+	}()
+	err = dataset.CopyRows(originSession, targetSession)
+	require.Nil(t, err)
+	require.Nil(t, <-proxyErr)
+
+	// the copy preserves the write timestamps so the updates written through the proxy after the copy read the rows
+	// from origin are not overwritten with the existing values in target
+	dataset.RequireWritten(t, originSession, targetSession)
+	utils.RequireConsistentClusters(t, originSession, targetSession, dataset.Keyspace, dataset.Table, "id")
+}
@@ -0,0 +1,82 @@
+package env
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DockerCompose runs the services of a Docker Compose file, it is used instead of CCM to run the test clusters when
+// CLUSTER_ENV is docker. It requires Docker with the Compose plugin (docker compose v2).
+type DockerCompose struct {
+	file    string
+	project string
+	vars    []string
+}
+
+// NewDockerCompose returns a DockerCompose for the provided file and project name, vars are variables (NAME=value)
+// that the compose file can use (e.g. ${CASSANDRA_VERSION}).
+func NewDockerCompose(file string, project string, vars ...string) *DockerCompose {
+	return &DockerCompose{file: file, project: project, vars: vars}
+}
+
+// Up starts the provided services (all services if none are provided) and waits until their health checks pass.
+func (recv *DockerCompose) Up(ctx context.Context, services ...string) error {
+	args := []string{"up", "--detach", "--wait"}
+	if deadline, ok := ctx.Deadline(); ok {
+		args = append(args, "--wait-timeout", fmt.Sprintf("%d", int(time.Until(deadline).Seconds())))
+	}
+	_, err := recv.exec(ctx, append(args, services...)...)
+	return err
+}
+
+// Remove stops and removes the container of a service.
+func (recv *DockerCompose) Remove(ctx context.Context, service string) error {
+	_, err := recv.exec(ctx, "rm", "--stop", "--force", "--volumes", service)
+	return err
+}
+
+// Down removes the containers, networks and volumes of the project.
+func (recv *DockerCompose) Down(ctx context.Context) error {
+	_, err := recv.exec(ctx, "down", "--volumes", "--remove-orphans")
+	return err
+}
+
+func (recv *DockerCompose) exec(ctx context.Context, args ...string) (string, error) {
+	args = append([]string{"compose", "--file", recv.file, "--project-name", recv.project}, args...)
+	log.Infof("Executing docker %v", strings.Join(args, " "))
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = append(os.Environ(), recv.vars...)
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	if err != nil {
+		return output.String(), fmt.Errorf("docker %v failed: %w, output: %v", strings.Join(args, " "), err, output.String())
+	}
+	log.Debugf("docker compose output: %v", output.String())
+	return output.String(), nil
+}
+
+// WaitForPort waits until a TCP connection to the provided address (host:port) can be opened.
+func WaitForPort(ctx context.Context, address string) error {
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v is not reachable: %w", address, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
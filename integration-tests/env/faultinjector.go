@@ -0,0 +1,176 @@
+package env
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// FaultInjector is a TCP proxy that sits between a client (e.g. the ZDM proxy) and a server (e.g. a cluster) and
+// injects network faults on demand, like toxiproxy does: it can drop the open connections, partition the client from
+// the server (the open connections are dropped and new connections are closed as soon as they are accepted) and delay
+// the data sent in both directions.
+type FaultInjector struct {
+	listener        net.Listener
+	upstreamAddress string
+
+	lock        sync.Mutex
+	connections map[net.Conn]bool
+	partitioned bool
+	latency     time.Duration
+	closed      bool
+
+	wg sync.WaitGroup
+}
+
+// NewFaultInjector starts listening on listenAddress (host:port) and forwards the accepted connections to
+// upstreamAddress.
+func NewFaultInjector(listenAddress string, upstreamAddress string) (*FaultInjector, error) {
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+	injector := &FaultInjector{
+		listener:        listener,
+		upstreamAddress: upstreamAddress,
+		connections:     make(map[net.Conn]bool),
+	}
+	injector.wg.Add(1)
+	go injector.acceptLoop()
+	return injector, nil
+}
+
+// Address returns the address on which the fault injector accepts connections.
+func (recv *FaultInjector) Address() string {
+	return recv.listener.Addr().String()
+}
+
+// DropConnections closes the open connections, new connections are still accepted.
+func (recv *FaultInjector) DropConnections() {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.closeConnections()
+}
+
+// Partition closes the open connections and closes new connections as soon as they are accepted until Heal is called.
+func (recv *FaultInjector) Partition() {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.partitioned = true
+	recv.closeConnections()
+}
+
+// Heal ends a partition started with Partition.
+func (recv *FaultInjector) Heal() {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.partitioned = false
+}
+
+// SetLatency delays every chunk of data forwarded in either direction by the provided duration, 0 removes the delay.
+func (recv *FaultInjector) SetLatency(latency time.Duration) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.latency = latency
+}
+
+// Close stops accepting connections, closes the open connections and waits until they are done.
+func (recv *FaultInjector) Close() error {
+	recv.lock.Lock()
+	recv.closed = true
+	recv.closeConnections()
+	recv.lock.Unlock()
+
+	err := recv.listener.Close()
+	recv.wg.Wait()
+	return err
+}
+
+// closeConnections must be called with the lock held.
+func (recv *FaultInjector) closeConnections() {
+	for conn := range recv.connections {
+		_ = conn.Close()
+	}
+	recv.connections = make(map[net.Conn]bool)
+}
+
+func (recv *FaultInjector) acceptLoop() {
+	defer recv.wg.Done()
+	for {
+		clientConn, err := recv.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Warnf("Fault injector %v stopped accepting connections: %v", recv.Address(), err)
+			}
+			return
+		}
+
+		recv.lock.Lock()
+		rejected := recv.partitioned || recv.closed
+		recv.lock.Unlock()
+		if rejected {
+			_ = clientConn.Close()
+			continue
+		}
+
+		upstreamConn, err := net.Dial("tcp", recv.upstreamAddress)
+		if err != nil {
+			log.Warnf("Fault injector %v could not connect to %v: %v", recv.Address(), recv.upstreamAddress, err)
+			_ = clientConn.Close()
+			continue
+		}
+
+		recv.lock.Lock()
+		if recv.partitioned || recv.closed {
+			recv.lock.Unlock()
+			_ = clientConn.Close()
+			_ = upstreamConn.Close()
+			continue
+		}
+		recv.connections[clientConn] = true
+		recv.connections[upstreamConn] = true
+		recv.lock.Unlock()
+
+		recv.wg.Add(2)
+		go recv.forward(clientConn, upstreamConn)
+		go recv.forward(upstreamConn, clientConn)
+	}
+}
+
+// forward copies the data read from src to dst, both connections are closed when either of them fails.
+func (recv *FaultInjector) forward(src net.Conn, dst net.Conn) {
+	defer recv.wg.Done()
+	defer func() {
+		recv.lock.Lock()
+		delete(recv.connections, src)
+		delete(recv.connections, dst)
+		recv.lock.Unlock()
+		_ = src.Close()
+		_ = dst.Close()
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			recv.lock.Lock()
+			latency := recv.latency
+			recv.lock.Unlock()
+			if latency > 0 {
+				time.Sleep(latency)
+			}
+			if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Debugf("Fault injector connection %v closed: %v", src.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
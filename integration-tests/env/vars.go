@@ -2,6 +2,7 @@ package env
 
 import (
 	"flag"
+	"fmt"
 	"math/rand"
 	"os"
 	"strconv"
@@ -9,9 +10,79 @@ import (
 	"time"
 )
 
+// MaxNodesPerCluster is the maximum number of nodes of a CCM test cluster, node addresses are allocated from a range of
+// 10 addresses per cluster and some tests add a couple of nodes to the clusters.
+const MaxNodesPerCluster = 8
+
+// ClusterTopology describes the layout of a CCM test cluster.
+type ClusterTopology struct {
+	// DcNodes is the number of nodes of each data center, data centers are named dc1, dc2, ...
+	DcNodes []int
+	// RacksPerDc is the number of racks of each data center, the nodes of a data center are spread over its racks.
+	RacksPerDc int
+}
+
+// Nodes returns the total number of nodes of the cluster.
+func (recv ClusterTopology) Nodes() int {
+	total := 0
+	for _, nodes := range recv.DcNodes {
+		total += nodes
+	}
+	return total
+}
+
+// IsDefault returns true if the cluster has a single data center with a single rack, in which case the nodes are added
+// to CCM without data center and rack.
+func (recv ClusterTopology) IsDefault() bool {
+	return len(recv.DcNodes) <= 1 && recv.RacksPerDc <= 1
+}
+
+// NodeLocation returns the data center and rack names of the node with the provided index (starting at 0).
+func (recv ClusterTopology) NodeLocation(index int) (string, string) {
+	dcIndex := 0
+	for dcIndex < len(recv.DcNodes)-1 && index >= recv.DcNodes[dcIndex] {
+		index -= recv.DcNodes[dcIndex]
+		dcIndex++
+	}
+	racks := recv.RacksPerDc
+	if racks < 1 {
+		racks = 1
+	}
+	return fmt.Sprintf("dc%d", dcIndex+1), fmt.Sprintf("rack%d", index%racks+1)
+}
+
+// ParseClusterTopology parses a number of nodes per data center separated by colons (e.g. "3" or "2:1", like
+// "ccm populate -n") and a number of racks per data center.
+func ParseClusterTopology(nodes string, racksPerDc string) (ClusterTopology, error) {
+	var dcNodes []int
+	for _, dc := range strings.Split(nodes, ":") {
+		n, err := strconv.Atoi(strings.TrimSpace(dc))
+		if err != nil || n < 1 {
+			return ClusterTopology{}, fmt.Errorf("invalid number of nodes per data center %q, "+
+				"expected positive numbers separated by colons (e.g. 3 or 2:1)", nodes)
+		}
+		dcNodes = append(dcNodes, n)
+	}
+
+	racks, err := strconv.Atoi(strings.TrimSpace(racksPerDc))
+	if err != nil || racks < 1 {
+		return ClusterTopology{}, fmt.Errorf("invalid number of racks per data center %q, expected a positive number", racksPerDc)
+	}
+
+	topology := ClusterTopology{DcNodes: dcNodes, RacksPerDc: racks}
+	if topology.Nodes() > MaxNodesPerCluster {
+		return ClusterTopology{}, fmt.Errorf("invalid number of nodes %q, clusters can not have more than %d nodes",
+			nodes, MaxNodesPerCluster)
+	}
+	return topology, nil
+}
+
 const (
-	OriginNodes = 1
-	TargetNodes = 1
+	// ClusterEnvCcm runs the test clusters with CCM.
+	ClusterEnvCcm = "ccm"
+	// ClusterEnvDocker runs the global origin and target test clusters with Docker Compose (see DockerComposeFile),
+	// the tests that create their own CCM clusters are skipped.
+	ClusterEnvDocker = "docker"
 )
 
 var Rand = rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
@@ -23,8 +94,15 @@ var RunCcmTests bool
 var RunMockTests bool
 var RunAllTlsTests bool
 var Debug bool
+var ClusterEnv string
+var DockerComposeFile string
 
-func InitGlobalVars() {
+var OriginTopology = ClusterTopology{DcNodes: []int{1}, RacksPerDc: 1}
+var TargetTopology = ClusterTopology{DcNodes: []int{1}, RacksPerDc: 1}
+var OriginNodes = 1
+var TargetNodes = 1
+
+func InitGlobalVars() error {
 	flags := map[string]interface{}{
 		"CASSANDRA_VERSION": flag.String(
 			"CASSANDRA_VERSION",
@@ -51,6 +129,36 @@ func InitGlobalVars() {
 			getEnvironmentVariableOrDefault("RUN_ALL_TLS_TESTS", "false"),
 			"RUN_ALL_TLS_TESTS"),
 
+		"ORIGIN_NODES": flag.String(
+			"ORIGIN_NODES",
+			getEnvironmentVariableOrDefault("ORIGIN_NODES", "1"),
+			"ORIGIN_NODES, number of nodes per data center of the origin CCM cluster (e.g. 3 or 2:1)"),
+
+		"TARGET_NODES": flag.String(
+			"TARGET_NODES",
+			getEnvironmentVariableOrDefault("TARGET_NODES", "1"),
+			"TARGET_NODES, number of nodes per data center of the target CCM cluster (e.g. 3 or 2:1)"),
+
+		"ORIGIN_RACKS": flag.String(
+			"ORIGIN_RACKS",
+			getEnvironmentVariableOrDefault("ORIGIN_RACKS", "1"),
+			"ORIGIN_RACKS, number of racks per data center of the origin CCM cluster"),
+
+		"TARGET_RACKS": flag.String(
+			"TARGET_RACKS",
+			getEnvironmentVariableOrDefault("TARGET_RACKS", "1"),
+			"TARGET_RACKS, number of racks per data center of the target CCM cluster"),
+
+		"CLUSTER_ENV": flag.String(
+			"CLUSTER_ENV",
+			getEnvironmentVariableOrDefault("CLUSTER_ENV", ClusterEnvCcm),
+			"CLUSTER_ENV, environment of the test clusters used when RUN_CCMTESTS is true: ccm or docker"),
+
+		"DOCKER_COMPOSE_FILE": flag.String(
+			"DOCKER_COMPOSE_FILE",
+			getEnvironmentVariableOrDefault("DOCKER_COMPOSE_FILE", "docker-compose.yml"),
+			"DOCKER_COMPOSE_FILE, Docker Compose file of the test clusters when CLUSTER_ENV is docker"),
+
 		"DEBUG": flag.Bool(
 			"DEBUG",
 			getEnvironmentVariableBoolOrDefault("DEBUG", false),
@@ -65,6 +173,23 @@ func InitGlobalVars() {
 	runMockTests := *flags["RUN_MOCKTESTS"].(*string)
 	runAllTlsTests := *flags["RUN_ALL_TLS_TESTS"].(*string)
 	Debug = *flags["DEBUG"].(*bool)
+	ClusterEnv = strings.ToLower(*flags["CLUSTER_ENV"].(*string))
+	DockerComposeFile = *flags["DOCKER_COMPOSE_FILE"].(*string)
+	if ClusterEnv != ClusterEnvCcm && ClusterEnv != ClusterEnvDocker {
+		return fmt.Errorf("invalid CLUSTER_ENV %q, expected %v or %v", ClusterEnv, ClusterEnvCcm, ClusterEnvDocker)
+	}
+
+	var err error
+	OriginTopology, err = ParseClusterTopology(*flags["ORIGIN_NODES"].(*string), *flags["ORIGIN_RACKS"].(*string))
+	if err != nil {
+		return fmt.Errorf("invalid origin cluster topology: %w", err)
+	}
+	TargetTopology, err = ParseClusterTopology(*flags["TARGET_NODES"].(*string), *flags["TARGET_RACKS"].(*string))
+	if err != nil {
+		return fmt.Errorf("invalid target cluster topology: %w", err)
+	}
+	OriginNodes = OriginTopology.Nodes()
+	TargetNodes = TargetTopology.Nodes()
 
 	if DseVersion != "" {
 		IsDse = true
@@ -85,6 +210,8 @@ func InitGlobalVars() {
 	if strings.ToLower(runAllTlsTests) == "true" {
 		RunAllTlsTests = true
 	}
+
+	return nil
 }
 
 func CompareServerVersion(version string) int {
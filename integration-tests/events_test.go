@@ -110,6 +110,7 @@ func TestTopologyStatusEvents(t *testing.T) {
 	if !env.RunCcmTests {
 		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
 	}
+	setup.SkipUnlessCcm(t)
 
 	tempCcmSetup, err := setup.NewTemporaryCcmTestSetup(true, false)
 	require.Nil(t, err)
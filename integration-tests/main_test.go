@@ -1,7 +1,6 @@
 package integration_tests
 
 import (
-	"github.com/datastax/zdm-proxy/integration-tests/ccm"
 	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
@@ -12,7 +11,10 @@ import (
 )
 
 func TestMain(m *testing.M) {
-	env.InitGlobalVars()
+	err := env.InitGlobalVars()
+	if err != nil {
+		log.Fatalf("invalid test configuration: %v", err)
+	}
 
 	gocql.TimeoutLimit = 5
 	if env.Debug {
@@ -24,7 +26,7 @@ func TestMain(m *testing.M) {
 	os.Exit(RunTests(m))
 }
 
-func SetupOrGetGlobalCcmClusters() (*ccm.Cluster, *ccm.Cluster, error) {
+func SetupOrGetGlobalCcmClusters() (setup.GlobalTestCluster, setup.GlobalTestCluster, error) {
 	originCluster, err := setup.GetGlobalTestClusterOrigin()
 	if err != nil {
 		return nil, nil, err
@@ -60,11 +60,33 @@ var proxyMetrics = []metrics.Metric{
 	metrics.ProxyReadsOriginDuration,
 	metrics.ProxyWritesDuration,
 
+	metrics.ProxyReadsTargetOverhead,
+	metrics.ProxyReadsOriginOverhead,
+	metrics.ProxyWritesOverhead,
+
 	metrics.InFlightReadsTarget,
 	metrics.InFlightReadsOrigin,
 	metrics.InFlightWrites,
 
 	metrics.OpenClientConnections,
+
+	metrics.DualReadsMatches,
+	metrics.DualReadsMismatches,
+
+	metrics.RateLimitedRequests,
+
+	metrics.DryRunSkippedWrites,
+
+	metrics.UnrecognizedStatements,
+	metrics.RequestDecodeErrors,
+
+	metrics.OpenedClientConnections,
+	metrics.RejectedClientConnections,
+	metrics.ClientConnectionsClosedByClient,
+	metrics.ClientConnectionsClosedByIdleTimeout,
+	metrics.ClientConnectionsClosedByShutdown,
+	metrics.ClientConnectionsClosedByError,
+	metrics.ClientConnectionDuration,
 }
 
 var allMetrics = append(proxyMetrics, nodeMetrics...)
@@ -0,0 +1,123 @@
+package integration_tests
+
+import (
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const multiNodeKeyspace = "multinode"
+
+// TestMultiNodeOriginWithNodeDown tests that reads and writes through the proxy keep working against a 3 node origin
+// cluster when one of its nodes is down, as long as the consistency level can still be met
+func TestMultiNodeOriginWithNodeDown(t *testing.T) {
+	if !env.RunCcmTests {
+		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
+	}
+	setup.SkipUnlessCcm(t)
+
+	tempCcmSetup, err := setup.NewTemporaryCcmTestSetupWithTopology(
+		true, false,
+		env.ClusterTopology{DcNodes: []int{3}, RacksPerDc: 1},
+		env.ClusterTopology{DcNodes: []int{1}, RacksPerDc: 1})
+	require.Nil(t, err)
+	defer tempCcmSetup.Cleanup()
+
+	originSession := tempCcmSetup.Origin.GetSession()
+	targetSession := tempCcmSetup.Target.GetSession()
+	for _, s := range []struct {
+		session           *gocql.Session
+		replicationFactor int
+	}{{originSession, 3}, {targetSession, 1}} {
+		err = s.session.Query(fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = "+
+			"{'class':'SimpleStrategy', 'replication_factor':%d};", multiNodeKeyspace, s.replicationFactor)).Exec()
+		require.Nil(t, err)
+		err = s.session.Query(fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s.kv (id int PRIMARY KEY, value text);", multiNodeKeyspace)).Exec()
+		require.Nil(t, err)
+	}
+
+	// the last node is stopped, the first one is the contact point of the proxy
+	stoppedNodeIndex := tempCcmSetup.Origin.GetTopology().Nodes() - 1
+
+	tests := []struct {
+		name                  string
+		stopNodeBeforeStartup bool
+	}{
+		{
+			name:                  "node stopped while the proxy is connected",
+			stopNodeBeforeStartup: false,
+		},
+		{
+			name:                  "node stopped before the proxy starts",
+			stopNodeBeforeStartup: true,
+		},
+	}
+
+	for testIdx, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.stopNodeBeforeStartup {
+				err = tempCcmSetup.Origin.StopNode(stoppedNodeIndex)
+				require.Nil(t, err)
+			}
+
+			testConfig := setup.NewTestConfig(tempCcmSetup.Origin.GetInitialContactPoint(), tempCcmSetup.Target.GetInitialContactPoint())
+			// with host assignment some client connections would be opened to the stopped node
+			testConfig.OriginEnableHostAssignment = false
+			testConfig.TargetEnableHostAssignment = false
+			proxyInstance, err := setup.NewProxyInstanceWithConfig(testConfig)
+			require.Nil(t, err)
+			defer proxyInstance.Shutdown()
+
+			proxy, err := utils.ConnectToCluster("127.0.0.1", "", "", 14002)
+			require.Nil(t, err)
+			defer proxy.Close()
+
+			if !tt.stopNodeBeforeStartup {
+				err = tempCcmSetup.Origin.StopNode(stoppedNodeIndex)
+				require.Nil(t, err)
+			}
+			defer func() {
+				err := tempCcmSetup.Origin.StartNode(stoppedNodeIndex)
+				require.Nil(t, err)
+			}()
+
+			id := testIdx
+			value := fmt.Sprintf("value%d", testIdx)
+			insert := fmt.Sprintf("INSERT INTO %s.kv (id, value) VALUES (?, ?)", multiNodeKeyspace)
+			selectQuery := fmt.Sprintf("SELECT value FROM %s.kv WHERE id = ?", multiNodeKeyspace)
+
+			for _, consistency := range []gocql.Consistency{gocql.One, gocql.Quorum} {
+				err = proxy.Query(insert, id, value).Consistency(consistency).Exec()
+				require.Nil(t, err, "write with consistency %v failed", consistency)
+			}
+
+			var readValue string
+			err = proxy.Query(selectQuery, id).Consistency(gocql.Quorum).Scan(&readValue)
+			require.Nil(t, err)
+			require.Equal(t, value, readValue)
+
+			err = targetSession.Query(selectQuery, id).Consistency(gocql.One).Scan(&readValue)
+			require.Nil(t, err)
+			require.Equal(t, value, readValue)
+
+			// the other nodes might not have marked the stopped node as down yet
+			utils.RequireWithRetries(t, func() (error, bool) {
+				err := proxy.Query(insert, id, value).Consistency(gocql.All).Exec()
+				var unavailableErr *gocql.RequestErrUnavailable
+				if !errors.As(err, &unavailableErr) {
+					return fmt.Errorf("expected unavailable error for write with consistency ALL but got %v", err), false
+				}
+				require.Equal(t, 3, unavailableErr.Required)
+				require.Equal(t, 2, unavailableErr.Alive)
+				return nil, false
+			}, 10, 1*time.Second)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	dockerComposeProject     = "zdm-proxy-tests"
+	dockerComposeUpTimeout   = 5 * time.Minute
+	dockerOriginService      = "origin"
+	dockerOriginContactPoint = "127.0.0.1"
+	dockerTargetService      = "target"
+	dockerTargetContactPoint = "127.0.0.10"
+)
+
+var globalDockerCompose *env.DockerCompose
+
+// DockerCluster is a single node test cluster run by Docker Compose. Its CQL port is published on the same address as
+// the first node of the equivalent CCM cluster so the tests don't depend on CLUSTER_ENV.
+type DockerCluster struct {
+	compose             *env.DockerCompose
+	service             string
+	initialContactPoint string
+	session             *gocql.Session
+}
+
+func (recv *DockerCluster) GetInitialContactPoint() string {
+	return recv.initialContactPoint
+}
+
+func (recv *DockerCluster) GetSession() *gocql.Session {
+	return recv.session
+}
+
+func (recv *DockerCluster) Remove() error {
+	if recv.session != nil {
+		recv.session.Close()
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelFn()
+	return recv.compose.Remove(ctx, recv.service)
+}
+
+// createDockerClusters starts the origin and target services of the Docker Compose file DOCKER_COMPOSE_FILE.
+func createDockerClusters() (GlobalTestCluster, GlobalTestCluster, error) {
+	if env.IsDse {
+		return nil, nil, errors.New("DSE clusters can not run with CLUSTER_ENV=docker")
+	}
+	if !env.OriginTopology.IsDefault() || !env.TargetTopology.IsDefault() ||
+		env.OriginTopology.Nodes() != 1 || env.TargetTopology.Nodes() != 1 {
+		return nil, nil, errors.New("the Docker Compose clusters have a single node, " +
+			"ORIGIN_NODES, TARGET_NODES, ORIGIN_RACKS and TARGET_RACKS are not supported with CLUSTER_ENV=docker")
+	}
+
+	compose := env.NewDockerCompose(
+		env.DockerComposeFile, dockerComposeProject, fmt.Sprintf("CASSANDRA_VERSION=%v", env.CassandraVersion))
+	ctx, cancelFn := context.WithTimeout(context.Background(), dockerComposeUpTimeout)
+	defer cancelFn()
+	err := compose.Up(ctx, dockerOriginService, dockerTargetService)
+	if err != nil {
+		downErr := compose.Down(context.Background())
+		if downErr != nil {
+			log.Warnf("could not remove docker compose clusters after start failed: %v", downErr)
+		}
+		return nil, nil, err
+	}
+	globalDockerCompose = compose
+
+	origin := &DockerCluster{compose: compose, service: dockerOriginService, initialContactPoint: dockerOriginContactPoint}
+	target := &DockerCluster{compose: compose, service: dockerTargetService, initialContactPoint: dockerTargetContactPoint}
+	for _, cluster := range []*DockerCluster{origin, target} {
+		err = env.WaitForPort(ctx, fmt.Sprintf("%v:9042", cluster.initialContactPoint))
+		if err == nil {
+			cluster.session, err = gocql.NewCluster(cluster.initialContactPoint).CreateSession()
+		}
+		if err != nil {
+			origin.Remove()
+			target.Remove()
+			return nil, nil, fmt.Errorf("could not connect to docker compose cluster %v: %w", cluster.service, err)
+		}
+	}
+	return origin, target, nil
+}
+
+// cleanUpDockerCompose removes the network created by Docker Compose once the clusters are removed.
+func cleanUpDockerCompose() {
+	if globalDockerCompose == nil {
+		return
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelFn()
+	err := globalDockerCompose.Down(ctx)
+	if err != nil {
+		log.Errorf("remove docker compose clusters error: %s", err)
+	}
+	globalDockerCompose = nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/gocql/gocql"
 	log "github.com/sirupsen/logrus"
 	"math"
 	"sync"
@@ -20,22 +21,29 @@ type TestCluster interface {
 	Remove() error
 }
 
+// GlobalTestCluster is a test cluster shared by all the tests, it is a CCM cluster or a Docker Compose cluster
+// depending on CLUSTER_ENV.
+type GlobalTestCluster interface {
+	TestCluster
+	GetSession() *gocql.Session
+}
+
 var mux = &sync.Mutex{}
 
 var createdGlobalClusters = false
 
-var globalCcmClusterOrigin *ccm.Cluster
-var globalCcmClusterTarget *ccm.Cluster
+var globalClusterOrigin GlobalTestCluster
+var globalClusterTarget GlobalTestCluster
 
-func GetGlobalTestClusterOrigin() (*ccm.Cluster, error) {
+func GetGlobalTestClusterOrigin() (GlobalTestCluster, error) {
 	if createdGlobalClusters {
-		return globalCcmClusterOrigin, nil
+		return globalClusterOrigin, nil
 	}
 
 	mux.Lock()
 	defer mux.Unlock()
 	if createdGlobalClusters {
-		return globalCcmClusterOrigin, nil
+		return globalClusterOrigin, nil
 	}
 
 	err := createClusters()
@@ -44,18 +52,18 @@ func GetGlobalTestClusterOrigin() (*ccm.Cluster, error) {
 		return nil, err
 	}
 
-	return globalCcmClusterOrigin, nil
+	return globalClusterOrigin, nil
 }
 
-func GetGlobalTestClusterTarget() (*ccm.Cluster, error) {
+func GetGlobalTestClusterTarget() (GlobalTestCluster, error) {
 	if createdGlobalClusters {
-		return globalCcmClusterTarget, nil
+		return globalClusterTarget, nil
 	}
 
 	mux.Lock()
 	defer mux.Unlock()
 	if createdGlobalClusters {
-		return globalCcmClusterTarget, nil
+		return globalClusterTarget, nil
 	}
 
 	err := createClusters()
@@ -64,42 +72,37 @@ func GetGlobalTestClusterTarget() (*ccm.Cluster, error) {
 		return nil, err
 	}
 
-	return globalCcmClusterTarget, nil
+	return globalClusterTarget, nil
 }
 
 func createClusters() error {
 	// assuming we have the lock already
 
 	var err error
-
-	firstClusterId := env.Rand.Uint64() % (math.MaxUint64 - 1)
-	globalCcmClusterOrigin, err = ccm.GetNewCluster(firstClusterId, 1, env.OriginNodes, true)
-	if err != nil {
-		return err
+	if env.ClusterEnv == env.ClusterEnvDocker {
+		globalClusterOrigin, globalClusterTarget, err = createDockerClusters()
+	} else {
+		globalClusterOrigin, globalClusterTarget, err = createCcmClusters()
 	}
-
-	secondClusterId := firstClusterId + 1
-	globalCcmClusterTarget, err = ccm.GetNewCluster(secondClusterId, 10, env.TargetNodes, true)
 	if err != nil {
-		globalCcmClusterOrigin.Remove()
 		return err
 	}
 
-	sourceSession := globalCcmClusterOrigin.GetSession()
-	destSession := globalCcmClusterTarget.GetSession()
+	sourceSession := globalClusterOrigin.GetSession()
+	destSession := globalClusterTarget.GetSession()
 
 	// Seed originCluster and targetCluster with keyspace
 	err = SeedKeyspace(sourceSession)
 	if err != nil {
-		globalCcmClusterOrigin.Remove()
-		globalCcmClusterTarget.Remove()
+		globalClusterOrigin.Remove()
+		globalClusterTarget.Remove()
 		return err
 	}
 
 	err = SeedKeyspace(destSession)
 	if err != nil {
-		globalCcmClusterOrigin.Remove()
-		globalCcmClusterTarget.Remove()
+		globalClusterOrigin.Remove()
+		globalClusterTarget.Remove()
 		return err
 	}
 
@@ -107,13 +110,38 @@ func createClusters() error {
 	return nil
 }
 
+func createCcmClusters() (GlobalTestCluster, GlobalTestCluster, error) {
+	firstClusterId := env.Rand.Uint64() % (math.MaxUint64 - 1)
+	origin, err := ccm.GetNewClusterWithTopology(firstClusterId, 1, env.OriginTopology, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secondClusterId := firstClusterId + 1
+	target, err := ccm.GetNewClusterWithTopology(secondClusterId, 10, env.TargetTopology, true)
+	if err != nil {
+		origin.Remove()
+		return nil, nil, err
+	}
+	return origin, target, nil
+}
+
 func CleanUpClusters() {
 	if !createdGlobalClusters {
 		return
 	}
 
-	globalCcmClusterTarget.Remove()
-	globalCcmClusterOrigin.Remove()
+	globalClusterTarget.Remove()
+	globalClusterOrigin.Remove()
+	cleanUpDockerCompose()
+}
+
+// SkipUnlessCcm skips tests that create their own CCM clusters (e.g. to stop nodes or change their configuration)
+// when the test clusters are not run with CCM.
+func SkipUnlessCcm(t *testing.T) {
+	if env.ClusterEnv != env.ClusterEnvCcm {
+		t.Skipf("Test requires CCM clusters, it can not run with CLUSTER_ENV=%v", env.ClusterEnv)
+	}
 }
 
 type SimulacronTestSetup struct {
@@ -199,14 +227,21 @@ type CcmTestSetup struct {
 }
 
 func NewTemporaryCcmTestSetup(start bool, createProxy bool) (*CcmTestSetup, error) {
+	return NewTemporaryCcmTestSetupWithTopology(start, createProxy, env.OriginTopology, env.TargetTopology)
+}
+
+// NewTemporaryCcmTestSetupWithTopology creates origin and target CCM clusters with the provided topologies instead of
+// the ones configured with the ORIGIN_NODES, TARGET_NODES, ORIGIN_RACKS and TARGET_RACKS flags.
+func NewTemporaryCcmTestSetupWithTopology(
+	start bool, createProxy bool, originTopology env.ClusterTopology, targetTopology env.ClusterTopology) (*CcmTestSetup, error) {
 	firstClusterId := env.Rand.Uint64() % (math.MaxUint64 - 1)
-	origin, err := ccm.GetNewCluster(firstClusterId, 20, env.OriginNodes, start)
+	origin, err := ccm.GetNewClusterWithTopology(firstClusterId, 20, originTopology, start)
 	if err != nil {
 		return nil, err
 	}
 
 	secondClusterId := firstClusterId + 1
-	target, err := ccm.GetNewCluster(secondClusterId, 30, env.TargetNodes, start)
+	target, err := ccm.GetNewClusterWithTopology(secondClusterId, 30, targetTopology, start)
 	if err != nil {
 		origin.Remove()
 		return nil, err
@@ -426,6 +461,7 @@ func NewTestConfig(originHost string, targetHost string) *config.Config {
 	conf.MetricsOriginLatencyBucketsMs = "1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000"
 	conf.MetricsTargetLatencyBucketsMs = "1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000"
 	conf.MetricsAsyncReadLatencyBucketsMs = "1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000"
+	conf.MetricsProxyOverheadBucketsMs = "0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100"
 
 	conf.RequestWriteQueueSizeFrames = 128
 	conf.RequestWriteBufferSizeBytes = 4096
@@ -437,9 +473,14 @@ func NewTestConfig(originHost string, targetHost string) *config.Config {
 
 	conf.ProxyMaxClientConnections = 1000
 	conf.ProxyMaxStreamIds = 2048
+	conf.ProxyMaxPreparedStatements = 5000
+	conf.ProxyMaxFrameSizeBytes = 16 * 1024 * 1024
+	conf.ProxyTcpKeepAliveMs = 15000
+	conf.ProxyCaptureSamplePercent = 100
 
 	conf.RequestResponseMaxWorkers = -1
 	conf.WriteMaxWorkers = -1
+	conf.DualWritesMaxWorkers = -1
 	conf.ReadMaxWorkers = -1
 	conf.ListenerMaxWorkers = -1
 
@@ -450,13 +491,17 @@ func NewTestConfig(originHost string, targetHost string) *config.Config {
 
 	conf.PrimaryCluster = config.PrimaryClusterOrigin
 	conf.ReadMode = config.ReadModePrimaryOnly
+	conf.DualReadsSamplePercent = 100
+	conf.DualWritesFailures = config.WriteFailuresFatal
 	conf.SystemQueriesMode = config.SystemQueriesModeOrigin
 	conf.AsyncHandshakeTimeoutMs = 4000
 	conf.ControlConnMaxProtocolVersion = "DseV2"
 
 	conf.ProxyRequestTimeoutMs = 10000
+	conf.ProxyShutdownGracePeriodMs = 30000
 
 	conf.LogLevel = "INFO"
+	conf.LogFormat = config.LogFormatText
 
 	return conf
 }
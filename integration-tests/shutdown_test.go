@@ -9,9 +9,12 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/integration-tests/client"
+	"github.com/datastax/zdm-proxy/integration-tests/cqlserver"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/health"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
 	"github.com/rs/zerolog"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
@@ -441,3 +444,98 @@ func TestStressShutdown(t *testing.T) {
 		})
 	}
 }
+
+// TestShutdownGracePeriod checks that Shutdown waits for the in flight requests within the shutdown grace period and
+// closes the client connections (returning zdmproxy.DrainTimeoutErr) when the requests take longer than that.
+func TestShutdownGracePeriod(t *testing.T) {
+	oldZeroLogLevel := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(oldZeroLogLevel)
+
+	testDef := []struct {
+		name          string
+		queryDelay    time.Duration
+		gracePeriodMs int
+		expectedErr   error
+	}{
+		{
+			name:          "Drained",
+			queryDelay:    1 * time.Second,
+			gracePeriodMs: 10000,
+			expectedErr:   nil,
+		},
+		{
+			name:          "Drain timed out",
+			queryDelay:    8 * time.Second,
+			gracePeriodMs: 1000,
+			expectedErr:   zdmproxy.DrainTimeoutErr,
+		},
+	}
+	for _, test := range testDef {
+		t.Run(test.name, func(t *testing.T) {
+			conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			conf.ProxyRequestTimeoutMs = 30000
+			conf.ProxyShutdownGracePeriodMs = test.gracePeriodMs
+			testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+
+			slowQueryHandler := func(
+				request *frame.Frame, _ *client2.CqlServerConnection, _ client2.RequestHandlerContext) *frame.Frame {
+				if query, ok := request.Body.Message.(*message.Query); ok && query.Query == "SELECT * FROM slow" {
+					time.Sleep(test.queryDelay)
+					return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+				}
+				return nil
+			}
+			for _, cluster := range []*cqlserver.Cluster{testSetup.Origin, testSetup.Target} {
+				cluster.CqlServer.RequestHandlers = []client2.RequestHandler{
+					client2.RegisterHandler, client2.HeartbeatHandler, client2.HandshakeHandler,
+					client2.NewSystemTablesHandler("cluster", "dc1"), slowQueryHandler}
+			}
+			err = testSetup.Start(conf, false, primitive.ProtocolVersion4)
+			require.Nil(t, err)
+			proxy := testSetup.Proxy
+			testSetup.Proxy = nil
+
+			cqlClient := client2.NewCqlClient("127.0.0.1:14002", &client2.AuthCredentials{
+				Username: conf.OriginUsername, Password: conf.OriginPassword})
+			cqlConn, err := cqlClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 0)
+			require.Nil(t, err)
+			defer cqlConn.Close()
+
+			inflightRequest, err := cqlConn.Send(frame.NewFrame(
+				primitive.ProtocolVersion4, 2, &message.Query{Query: "SELECT * FROM slow"}))
+			require.Nil(t, err)
+			time.Sleep(200 * time.Millisecond)
+
+			beginTimestamp := time.Now()
+			shutdownErr := make(chan error, 1)
+			go func() {
+				shutdownErr <- proxy.Shutdown()
+			}()
+
+			require.Eventually(t, func() bool {
+				return health.PerformHealthCheck(proxy).Status == health.DRAINING
+			}, 5*time.Second, 10*time.Millisecond)
+
+			select {
+			case err = <-shutdownErr:
+				require.Equal(t, test.expectedErr, err)
+			case <-time.After(15 * time.Second):
+				t.Fatalf("proxy shutdown timed out")
+			}
+
+			if test.expectedErr == nil {
+				rsp, ok := <-inflightRequest.Incoming()
+				require.True(t, ok)
+				require.Equal(t, primitive.OpCodeResult, rsp.Header.OpCode)
+			} else {
+				// the connection was closed before the response was received
+				require.Less(t, time.Since(beginTimestamp), test.queryDelay/2)
+				_, ok := <-inflightRequest.Incoming()
+				require.False(t, ok)
+			}
+		})
+	}
+}
@@ -15,6 +15,8 @@ type When interface {
 	ThenServerError(serverError ServerError, message string) Then
 	ThenWriteTimeout(consistencyLevel gocql.Consistency, received int, blockFor int, writeType WriteType) Then
 	ThenReadTimeout(consistencyLevel gocql.Consistency, received int, blockFor int, dataPresent bool) Then
+	ThenUnavailable(consistencyLevel gocql.Consistency, required int, alive int) Then
+	ThenCloseConnection(scope CloseScope, closeType CloseType) Then
 	ThenNoResult() Then
 }
 
@@ -27,6 +29,10 @@ type DataType string
 
 type WriteType string
 
+type CloseScope string
+
+type CloseType string
+
 const (
 	OverloadedError     = ServerError("overloaded")
 	IsBootstrapping     = ServerError("is_bootstrapping")
@@ -255,6 +261,19 @@ func (when *baseWhen) render() map[string]interface{} {
 	return when.out
 }
 
+const (
+	CloseScopeConnection = CloseScope("connection")
+	CloseScopeNode       = CloseScope("node")
+	CloseScopeDataCenter = CloseScope("data_center")
+	CloseScopeCluster    = CloseScope("cluster")
+)
+
+const (
+	CloseTypeDisconnect    = CloseType("disconnect")
+	CloseTypeShutdownRead  = CloseType("shutdown_read")
+	CloseTypeShutdownWrite = CloseType("shutdown_write")
+)
+
 type Then interface {
 	render() map[string]interface{}
 	WithIgnoreOnPrepare(ignoreOnPrepare bool) Then
@@ -357,6 +376,23 @@ func (when *baseWhen) ThenReadTimeout(
 	})
 }
 
+func (when *baseWhen) ThenUnavailable(consistencyLevel gocql.Consistency, required int, alive int) Then {
+	return when.then(map[string]interface{}{
+		"result":            "unavailable",
+		"consistency_level": consistencyLevel,
+		"required":          required,
+		"alive":             alive,
+	})
+}
+
+func (when *baseWhen) ThenCloseConnection(scope CloseScope, closeType CloseType) Then {
+	return when.then(map[string]interface{}{
+		"result":     "close_connection",
+		"scope":      string(scope),
+		"close_type": string(closeType),
+	})
+}
+
 func (when *baseWhen) ThenAlreadyExists(keyspace string, table string) Then {
 	return when.then(map[string]interface{}{
 		"result":   "already_exists",
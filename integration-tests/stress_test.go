@@ -21,6 +21,7 @@ func TestSimultaneousConnections(t *testing.T) {
 	if !env.RunCcmTests {
 		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
 	}
+	setup.SkipUnlessCcm(t)
 	ccmSetup, err := setup.NewTemporaryCcmTestSetup(false, false)
 	require.Nil(t, err)
 	defer ccmSetup.Cleanup()
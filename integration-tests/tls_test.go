@@ -906,6 +906,7 @@ func setupOriginAndTargetClusters(clusterConf clusterTlsConfiguration, t *testin
 	if !env.RunCcmTests {
 		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
 	}
+	setup.SkipUnlessCcm(t)
 
 	ccmSetup, err := setup.NewTemporaryCcmTestSetup(false, false)
 	if ccmSetup == nil {
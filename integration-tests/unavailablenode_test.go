@@ -8,6 +8,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/integration-tests/client"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/datastax/zdm-proxy/integration-tests/utils"
 	"github.com/stretchr/testify/require"
 	"strings"
@@ -98,3 +99,63 @@ func TestUnavailableNode(t *testing.T) {
 		})
 	}
 }
+
+// TestClusterClosesConnectionDuringWrite tests if the proxy closes the client connection when either cluster node
+// closes the connection instead of responding to a write
+func TestClusterClosesConnectionDuringWrite(t *testing.T) {
+	clusters := []string{"origin", "target"}
+	for _, clusterClosingConnection := range clusters {
+		t.Run(clusterClosingConnection, func(t *testing.T) {
+			simulacronSetup, err := setup.NewSimulacronTestSetup(t)
+			require.Nil(t, err)
+			defer simulacronSetup.Cleanup()
+
+			testClient, err := client.NewTestClient(context.Background(), "127.0.0.1:14002")
+			require.True(t, err == nil, "testClient setup failed: %s", err)
+			defer testClient.Shutdown()
+
+			err = testClient.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, false)
+			require.True(t, err == nil, "No-auth handshake failed: %s", err)
+
+			insert := "INSERT INTO myks.users (name) VALUES ('john')"
+			when := simulacron.WhenQuery(insert, simulacron.NewWhenQueryOptions())
+			closePrime := when.ThenCloseConnection(simulacron.CloseScopeConnection, simulacron.CloseTypeDisconnect)
+			switch clusterClosingConnection {
+			case "origin":
+				require.Nil(t, simulacronSetup.Origin.Prime(closePrime))
+				require.Nil(t, simulacronSetup.Target.Prime(when.ThenSuccess()))
+			case "target":
+				require.Nil(t, simulacronSetup.Origin.Prime(when.ThenSuccess()))
+				require.Nil(t, simulacronSetup.Target.Prime(closePrime))
+			}
+
+			response, _, err := testClient.SendMessage(context.Background(), primitive.ProtocolVersion4, &message.Query{
+				Query:   insert,
+				Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+			})
+			if response != nil {
+				responseError, ok := response.Body.Message.(*message.Overloaded)
+				require.True(t, ok, "response should be nil or OVERLOADED (shutdown): %v", response.Body.Message)
+				require.Equal(t, "Shutting down, please retry on next host.", responseError.ErrorMessage)
+			} else {
+				require.NotNil(t, err, "no error has been received, but the request should have failed")
+				require.True(t, strings.Contains(err.Error(), "response channel closed"),
+					"the connection should have been closed at client level, but it didn't, got: %v", err)
+			}
+
+			// open new connection to verify that the same proxy instance continues working normally
+			newTestClient, err := client.NewTestClient(context.Background(), "127.0.0.1:14002")
+			require.True(t, err == nil, "newTestClient setup failed: %s", err)
+			defer newTestClient.Shutdown()
+
+			err = newTestClient.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, false)
+			require.True(t, err == nil, "No-auth handshake failed: %s", err)
+
+			response, _, err = newTestClient.SendMessage(context.Background(), primitive.ProtocolVersion4, &message.Query{
+				Query: "SELECT * FROM system.peers",
+			})
+			require.True(t, err == nil, "Query failed: %v", err)
+			require.Equal(t, primitive.OpCodeResult, response.Body.Message.GetOpCode(), "expected result but got %v", response.Body.Message)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// maxReportedDifferences is the number of row differences included in the failure message of the consistency
+// assertions.
+const maxReportedDifferences = 20
+
+// TableRows are the rows of a table, keyed by their formatted primary key. Every row is formatted as its columns
+// sorted by name, e.g. "id=1, value=a".
+type TableRows map[string]string
+
+// RowDifference is a row that is not the same in the expected and the actual rows: Expected is empty for unexpected
+// rows (e.g. a write applied to the wrong table or a row that should have been deleted) and Actual is empty for
+// missing rows (i.e. lost writes).
+type RowDifference struct {
+	Key      string
+	Expected string
+	Actual   string
+}
+
+func (d RowDifference) String() string {
+	switch {
+	case d.Expected == "":
+		return fmt.Sprintf("unexpected row [%v]: %v", d.Key, d.Actual)
+	case d.Actual == "":
+		return fmt.Sprintf("missing row [%v]: %v", d.Key, d.Expected)
+	default:
+		return fmt.Sprintf("different row [%v]: expected %v but got %v", d.Key, d.Expected, d.Actual)
+	}
+}
+
+// ReadTableRows reads all the rows of a table, keyColumns are the primary key columns of the table.
+func ReadTableRows(session *gocql.Session, keyspace string, table string, keyColumns ...string) (TableRows, error) {
+	rows := make(TableRows)
+	iter := session.Query(fmt.Sprintf("SELECT * FROM %s.%s", keyspace, table)).Consistency(gocql.All).Iter()
+	for {
+		row := make(map[string]interface{})
+		if !iter.MapScan(row) {
+			break
+		}
+		keyValues := make([]string, 0, len(keyColumns))
+		for _, column := range keyColumns {
+			keyValues = append(keyValues, fmt.Sprint(row[column]))
+		}
+		key := strings.Join(keyValues, ", ")
+		if _, exists := rows[key]; exists {
+			return nil, fmt.Errorf("duplicated primary key [%v] in %s.%s, check the key columns %v", key, keyspace, table, keyColumns)
+		}
+		rows[key] = formatRow(row)
+	}
+	err := iter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the rows of %s.%s: %w", keyspace, table, err)
+	}
+	return rows, nil
+}
+
+func formatRow(row map[string]interface{}) string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for i, column := range columns {
+		columns[i] = fmt.Sprintf("%s=%v", column, row[column])
+	}
+	return strings.Join(columns, ", ")
+}
+
+// DiffTableRows returns the rows that are missing, unexpected or different in actual, sorted by key.
+func DiffTableRows(expected TableRows, actual TableRows) []RowDifference {
+	var differences []RowDifference
+	for key, expectedRow := range expected {
+		if actualRow := actual[key]; actualRow != expectedRow {
+			differences = append(differences, RowDifference{Key: key, Expected: expectedRow, Actual: actualRow})
+		}
+	}
+	for key, actualRow := range actual {
+		if _, ok := expected[key]; !ok {
+			differences = append(differences, RowDifference{Key: key, Actual: actualRow})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Key < differences[j].Key
+	})
+	return differences
+}
+
+// RequireSameRows fails the test if the rows are not the same, description identifies the compared rows in the
+// failure message (e.g. "origin and target").
+func RequireSameRows(t *testing.T, description string, expected TableRows, actual TableRows) {
+	differences := DiffTableRows(expected, actual)
+	if len(differences) == 0 {
+		return
+	}
+	sb := &strings.Builder{}
+	for i, d := range differences {
+		if i == maxReportedDifferences {
+			_, _ = fmt.Fprintf(sb, "\n... and %d more", len(differences)-i)
+			break
+		}
+		_, _ = fmt.Fprintf(sb, "\n%v", d)
+	}
+	require.FailNowf(t, "rows are not the same",
+		"%d of %d rows are different (%v):%v", len(differences), len(expected), description, sb.String())
+}
+
+// RequireConsistentClusters fails the test if a table does not have the same rows in origin and target.
+func RequireConsistentClusters(
+	t *testing.T, origin *gocql.Session, target *gocql.Session, keyspace string, table string, keyColumns ...string) {
+	originRows, err := ReadTableRows(origin, keyspace, table, keyColumns...)
+	require.Nil(t, err)
+	targetRows, err := ReadTableRows(target, keyspace, table, keyColumns...)
+	require.Nil(t, err)
+	RequireSameRows(t, fmt.Sprintf("%s.%s in origin and target", keyspace, table), originRows, targetRows)
+}
+
+// Dataset is a known set of rows of a (id int PRIMARY KEY, value text) table. Tests write it to the clusters (directly
+// or through the proxy) during a simulated migration and then check that both clusters have exactly the rows whose
+// writes were acknowledged, i.e. that no write was lost or applied to one of the clusters out of order (e.g. a
+// duplicated older write that overwrote a newer one).
+type Dataset struct {
+	Keyspace string
+	Table    string
+
+	lock sync.Mutex
+	rows map[int]string
+}
+
+func NewDataset(keyspace string, table string) *Dataset {
+	return &Dataset{Keyspace: keyspace, Table: table, rows: make(map[int]string)}
+}
+
+// CreateTable drops and creates the table of the dataset with the provided sessions (e.g. origin and target).
+func (recv *Dataset) CreateTable(sessions ...*gocql.Session) error {
+	for _, session := range sessions {
+		err := session.Query(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", recv.Keyspace, recv.Table)).Exec()
+		if err != nil {
+			return fmt.Errorf("could not drop table %s.%s: %w", recv.Keyspace, recv.Table, err)
+		}
+		err = session.Query(fmt.Sprintf(
+			"CREATE TABLE %s.%s (id int PRIMARY KEY, value text)", recv.Keyspace, recv.Table)).Exec()
+		if err != nil {
+			return fmt.Errorf("could not create table %s.%s: %w", recv.Keyspace, recv.Table, err)
+		}
+	}
+	return nil
+}
+
+// Write inserts the rows with the provided session using concurrency goroutines. The rows whose writes succeed are
+// added to the expected rows of the dataset, the first error is returned after all the rows were sent. Every id must
+// only be written by one call at a time, otherwise the expected value of that row is undefined.
+func (recv *Dataset) Write(session *gocql.Session, concurrency int, rows map[int]string) error {
+	ids := make(chan int, len(rows))
+	for id := range rows {
+		ids <- id
+	}
+	close(ids)
+
+	insert := fmt.Sprintf("INSERT INTO %s.%s (id, value) VALUES (?, ?)", recv.Keyspace, recv.Table)
+	errs := make(chan error, len(rows))
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				err := session.Query(insert, id, rows[id]).Exec()
+				if err != nil {
+					errs <- fmt.Errorf("could not write row %d: %w", id, err)
+					continue
+				}
+				recv.lock.Lock()
+				recv.rows[id] = rows[id]
+				recv.lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// CopyRows copies the rows of source to destination preserving their write timestamps, like the data migration tools
+// (DSBulk, Cassandra Data Migrator) do: the rows written to both clusters through the proxy while the copy is running
+// are not overwritten with older values.
+func (recv *Dataset) CopyRows(source *gocql.Session, destination *gocql.Session) error {
+	iter := source.Query(fmt.Sprintf(
+		"SELECT id, value, WRITETIME(value) FROM %s.%s", recv.Keyspace, recv.Table)).Consistency(gocql.All).Iter()
+	insert := fmt.Sprintf("INSERT INTO %s.%s (id, value) VALUES (?, ?) USING TIMESTAMP ?", recv.Keyspace, recv.Table)
+	var id int
+	var value string
+	var writeTime int64
+	for iter.Scan(&id, &value, &writeTime) {
+		err := destination.Query(insert, id, value, writeTime).Exec()
+		if err != nil {
+			_ = iter.Close()
+			return fmt.Errorf("could not copy row %d: %w", id, err)
+		}
+	}
+	err := iter.Close()
+	if err != nil {
+		return fmt.Errorf("could not read the rows to copy from %s.%s: %w", recv.Keyspace, recv.Table, err)
+	}
+	return nil
+}
+
+// ExpectedRows returns the rows that the clusters are expected to have, in the format of ReadTableRows.
+func (recv *Dataset) ExpectedRows() TableRows {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	rows := make(TableRows, len(recv.rows))
+	for id, value := range recv.rows {
+		rows[fmt.Sprint(id)] = formatRow(map[string]interface{}{"id": id, "value": value})
+	}
+	return rows
+}
+
+// RequireWritten fails the test if the table of the dataset does not have exactly the expected rows in every cluster.
+func (recv *Dataset) RequireWritten(t *testing.T, origin *gocql.Session, target *gocql.Session) {
+	expected := recv.ExpectedRows()
+	for _, cluster := range []struct {
+		name    string
+		session *gocql.Session
+	}{{"origin", origin}, {"target", target}} {
+		actual, err := ReadTableRows(cluster.session, recv.Keyspace, recv.Table, "id")
+		require.Nil(t, err)
+		RequireSameRows(t, fmt.Sprintf("%s.%s in %s", recv.Keyspace, recv.Table, cluster.name), expected, actual)
+	}
+}
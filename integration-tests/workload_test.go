@@ -0,0 +1,108 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/workload"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+// TestWorkloadThroughProxy sends a mixed workload (simple and prepared reads, writes and batches) through the proxy
+// and checks that every request succeeds, that writes reach both clusters and that reads only reach origin.
+func TestWorkloadThroughProxy(t *testing.T) {
+	oldZeroLogLevel := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(oldZeroLogLevel)
+
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	origin := newWorkloadRecorder()
+	target := newWorkloadRecorder()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), origin.handle}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc1"), target.handle}
+	err = testSetup.Start(conf, false, primitive.ProtocolVersion4)
+	require.Nil(t, err)
+
+	workloadConf := workload.NewConfig()
+	workloadConf.Requests = 2000
+	workloadConf.ReadPercent = 40
+	workloadConf.PreparedPercent = 50
+	workloadConf.BatchPercent = 20
+	workloadConf.Connections = 2
+	workloadConf.ConcurrencyPerConn = 4
+
+	proxyAddress := fmt.Sprintf("%v:%d", conf.ProxyListenAddress, conf.ProxyListenPort)
+	result, err := workload.Run(context.Background(), client.NewCqlClient(proxyAddress, &client.AuthCredentials{
+		Username: conf.OriginUsername, Password: conf.OriginPassword}), workloadConf)
+	require.Nil(t, err)
+	t.Logf("Workload result: %v", result)
+	require.Nil(t, result.FirstError)
+	require.Equal(t, workloadConf.Requests, result.TotalRequests())
+
+	writes := result.Requests[workload.OperationWrite] + result.Requests[workload.OperationBatch]
+	require.Equal(t, result.Requests[workload.OperationRead], origin.count("SELECT"))
+	require.Equal(t, int64(0), target.count("SELECT"))
+	require.Equal(t, writes, origin.count("INSERT"))
+	require.Equal(t, writes, target.count("INSERT"))
+}
+
+// workloadRecorder counts the reads and writes (batches included) received by a CQL server.
+type workloadRecorder struct {
+	lock   sync.Mutex
+	counts map[string]int64
+}
+
+func newWorkloadRecorder() *workloadRecorder {
+	return &workloadRecorder{counts: make(map[string]int64)}
+}
+
+func (recv *workloadRecorder) handle(
+	request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+
+	var query string
+	switch msg := request.Body.Message.(type) {
+	case *message.Prepare:
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.PreparedResult{
+			PreparedQueryId:   []byte(msg.Query),
+			VariablesMetadata: &message.VariablesMetadata{},
+			ResultMetadata:    &message.RowsMetadata{},
+		})
+	case *message.Query:
+		query = msg.Query
+	case *message.Execute:
+		// the prepared ids are the queries
+		query = string(msg.QueryId)
+	case *message.Batch:
+		query = "INSERT"
+	default:
+		return nil
+	}
+	if len(query) < 6 {
+		return nil
+	}
+	recv.counts[query[:6]]++
+	return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+}
+
+func (recv *workloadRecorder) count(statementType string) int64 {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return recv.counts[statementType]
+}
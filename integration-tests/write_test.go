@@ -7,6 +7,7 @@ import (
 	"github.com/gocql/gocql"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestBothWriteTimeout(t *testing.T) {
@@ -207,3 +208,75 @@ func TestWriteSuccessful(t *testing.T) {
 		t.Fatal("query failed: ", err.Error())
 	}
 }
+
+func TestWriteUnavailable(t *testing.T) {
+	testSetup, err := setup.NewSimulacronTestSetup(t)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	proxy, err := utils.ConnectToCluster("127.0.0.1", "", "", 14002)
+	require.Nil(t, err)
+	defer proxy.Close()
+
+	when := simulacron.WhenQuery(
+		"INSERT INTO myks.users (name) VALUES (?)",
+		simulacron.NewWhenQueryOptions().WithPositionalParameter(simulacron.DataTypeText, "john"))
+
+	tests := []struct {
+		name              string
+		originUnavailable bool
+		targetUnavailable bool
+		expectedRequired  int
+	}{
+		{"origin", true, false, 2},
+		{"target", false, true, 3},
+		{"both returns origin error", true, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Nil(t, testSetup.Origin.ClearPrimes())
+			require.Nil(t, testSetup.Target.ClearPrimes())
+			originPrime := when.ThenSuccess()
+			if tt.originUnavailable {
+				originPrime = when.ThenUnavailable(gocql.Quorum, 2, 1)
+			}
+			targetPrime := when.ThenSuccess()
+			if tt.targetUnavailable {
+				targetPrime = when.ThenUnavailable(gocql.Quorum, 3, 1)
+			}
+			require.Nil(t, testSetup.Origin.Prime(originPrime))
+			require.Nil(t, testSetup.Target.Prime(targetPrime))
+
+			err := proxy.Query("INSERT INTO myks.users (name) VALUES (?)", "john").Exec()
+			require.NotNil(t, err, "query should have failed but it didn't")
+			errUnavailable, ok := err.(*gocql.RequestErrUnavailable)
+			require.True(t, ok, "error is not Unavailable: ", err.Error())
+			require.Equal(t, tt.expectedRequired, errUnavailable.Required)
+			require.Equal(t, 1, errUnavailable.Alive)
+		})
+	}
+}
+
+// TestSlowTargetWrite tests that the proxy waits for the response of a slow target node before returning the
+// response of a write to the client
+func TestSlowTargetWrite(t *testing.T) {
+	testSetup, err := setup.NewSimulacronTestSetup(t)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	proxy, err := utils.ConnectToCluster("127.0.0.1", "", "", 14002)
+	require.Nil(t, err)
+	defer proxy.Close()
+
+	when := simulacron.WhenQuery(
+		"INSERT INTO myks.users (name) VALUES (?)",
+		simulacron.NewWhenQueryOptions().WithPositionalParameter(simulacron.DataTypeText, "john"))
+	delay := 500 * time.Millisecond
+	require.Nil(t, testSetup.Origin.Prime(when.ThenSuccess()))
+	require.Nil(t, testSetup.Target.Prime(when.ThenSuccess().WithDelay(delay)))
+
+	start := time.Now()
+	err = proxy.Query("INSERT INTO myks.users (name) VALUES (?)", "john").Exec()
+	require.Nil(t, err)
+	require.GreaterOrEqual(t, time.Since(start), delay)
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/datastax/zdm-proxy/proxy/pkg/runner"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"os/signal"
@@ -15,8 +17,14 @@ import (
 // TODO: to be managed externally
 const ZdmVersionString = "2.3.0"
 
+// drainTimeoutExitCode is the exit code of the proxy when the client connections were not drained within the shutdown
+// grace period (ZDM_PROXY_SHUTDOWN_GRACE_PERIOD_MS).
+const drainTimeoutExitCode = 3
+
 var displayVersion = flag.Bool("version", false, "display the ZDM proxy version and exit")
 var configFile = flag.String("config", "", "specify path to ZDM configuration file")
+var validateConfig = flag.Bool("validate-config", false, "validate the ZDM configuration (credentials, TLS files, "+
+	"reachability of the clusters and ports), print a report and exit with a non-zero code if a check failed")
 
 func runSignalListener(cancelFunc context.CancelFunc) {
 	sigCh := make(chan os.Signal, 1)
@@ -37,6 +45,10 @@ func launchProxy(profilingSupported bool) {
 		return
 	}
 
+	if *validateConfig {
+		os.Exit(runConfigValidation())
+	}
+
 	// Always record version information (very) early in the log
 	log.Infof("Starting ZDM proxy version %v", ZdmVersionString)
 
@@ -54,6 +66,12 @@ func launchProxy(profilingSupported bool) {
 	}
 	log.SetLevel(logLevel)
 
+	err = zdmproxy.ConfigureLogFormat(conf)
+	if err != nil {
+		log.Errorf("Error loading log format configuration: %v. Aborting startup.", err)
+		os.Exit(-1)
+	}
+
 	if profilingSupported {
 		log.Debugf("Proxy built with profiling support")
 	} else {
@@ -65,5 +83,31 @@ func launchProxy(profilingSupported bool) {
 	log.Info("SIGINT/SIGTERM listener started.")
 
 	metricsHandler, readinessHandler := runner.SetupHandlers()
-	runner.RunMain(conf, ctx, metricsHandler, readinessHandler)
+	err = runner.RunMain(conf, ctx, metricsHandler, readinessHandler)
+	if errors.Is(err, zdmproxy.DrainTimeoutErr) {
+		log.Errorf("Proxy shutdown did not complete gracefully: %v.", err)
+		os.Exit(drainTimeoutExitCode)
+	}
+}
+
+// runConfigValidation loads and checks the configuration, prints the report to stdout and returns the exit code.
+func runConfigValidation() int {
+	var report *zdmproxy.ConfigReport
+	conf, err := config.New().LoadConfig(*configFile)
+	if err != nil {
+		report = &zdmproxy.ConfigReport{}
+		report.Add("configuration", zdmproxy.ConfigCheckFailed, "%v", err)
+	} else {
+		report = zdmproxy.CheckConfig(context.Background(), conf)
+	}
+
+	err = report.Write(os.Stdout)
+	if err != nil {
+		log.Errorf("Could not write the configuration report: %v", err)
+		return 1
+	}
+	if report.Failed() {
+		return 1
+	}
+	return 0
 }
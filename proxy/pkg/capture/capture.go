@@ -0,0 +1,185 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileHeader is written at the start of every capture file so that the replay tool can reject files
+// that were not written by the proxy (or that were written with an incompatible format).
+var fileHeader = []byte("ZDMCAPTURE1\n")
+
+const (
+	recordHeaderLength = 20
+	flushInterval      = time.Second
+)
+
+var codec = frame.NewRawCodec()
+
+// Record is a single request frame sent by a client.
+//
+// Frames are stored uncompressed and without the protocol v5 segment framing, i.e. exactly as the proxy sees them
+// after decoding them.
+type Record struct {
+	Timestamp    time.Time
+	ConnectionId uint64
+	Frame        *frame.RawFrame
+}
+
+// Writer writes records to a capture file. It is safe for concurrent use.
+//
+// Records are buffered and flushed to the file at most once per second (and when the writer is closed) so
+// capturing traffic doesn't add a write system call to every request.
+type Writer struct {
+	lock      *sync.Mutex
+	file      *os.File
+	buf       *bufio.Writer
+	lastFlush time.Time
+	closed    bool
+}
+
+// NewWriter creates (or truncates) the file at the provided path and writes the capture file header.
+func NewWriter(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open capture file %v: %w", path, err)
+	}
+	return newWriter(path, file, true)
+}
+
+// NewAppendWriter opens the file at the provided path and appends records to it. The file is created (with the capture
+// file header) if it doesn't exist, an existing file must be a capture file.
+func NewAppendWriter(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open capture file %v: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("could not open capture file %v: %w", path, err)
+	}
+	if info.Size() > 0 {
+		_, err = NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("could not append to %v: %w", path, err)
+		}
+	}
+	return newWriter(path, file, info.Size() == 0)
+}
+
+func newWriter(path string, file *os.File, writeHeader bool) (*Writer, error) {
+	buf := bufio.NewWriter(file)
+	if writeHeader {
+		_, err := buf.Write(fileHeader)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("could not write capture file header to %v: %w", path, err)
+		}
+	}
+	return &Writer{
+		lock:      &sync.Mutex{},
+		file:      file,
+		buf:       buf,
+		lastFlush: time.Now(),
+		closed:    false,
+	}, nil
+}
+
+func (recv *Writer) Write(record *Record) error {
+	encodedFrame := &bytes.Buffer{}
+	err := codec.EncodeRawFrame(record.Frame, encodedFrame)
+	if err != nil {
+		return fmt.Errorf("could not encode frame: %w", err)
+	}
+
+	header := make([]byte, recordHeaderLength)
+	binary.BigEndian.PutUint64(header[0:8], uint64(record.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint64(header[8:16], record.ConnectionId)
+	binary.BigEndian.PutUint32(header[16:20], uint32(encodedFrame.Len()))
+
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.closed {
+		return errors.New("capture file is closed")
+	}
+	_, err = recv.buf.Write(header)
+	if err == nil {
+		_, err = recv.buf.Write(encodedFrame.Bytes())
+	}
+	if err == nil && time.Since(recv.lastFlush) >= flushInterval {
+		err = recv.buf.Flush()
+		recv.lastFlush = time.Now()
+	}
+	return err
+}
+
+// Close flushes the buffered records and closes the file.
+func (recv *Writer) Close() error {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.closed {
+		return nil
+	}
+	recv.closed = true
+	err := recv.buf.Flush()
+	closeErr := recv.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// Reader reads the records of a capture file in the order in which they were written.
+type Reader struct {
+	reader *bufio.Reader
+}
+
+// NewReader checks the capture file header and returns a Reader positioned at the first record.
+func NewReader(source io.Reader) (*Reader, error) {
+	reader := bufio.NewReader(source)
+	header := make([]byte, len(fileHeader))
+	_, err := io.ReadFull(reader, header)
+	if err != nil || !bytes.Equal(header, fileHeader) {
+		return nil, errors.New("not a capture file written by the ZDM proxy")
+	}
+	return &Reader{reader: reader}, nil
+}
+
+// Read returns the next record or io.EOF when there are no more records.
+func (recv *Reader) Read() (*Record, error) {
+	header := make([]byte, recordHeaderLength)
+	_, err := io.ReadFull(recv.reader, header)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated record header: %w", err)
+		}
+		return nil, err
+	}
+
+	encodedFrame := make([]byte, binary.BigEndian.Uint32(header[16:20]))
+	_, err = io.ReadFull(recv.reader, encodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("truncated record: %w", err)
+	}
+
+	f, err := codec.DecodeRawFrame(bytes.NewReader(encodedFrame))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode frame: %w", err)
+	}
+
+	return &Record{
+		Timestamp:    time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8]))),
+		ConnectionId: binary.BigEndian.Uint64(header[8:16]),
+		Frame:        f,
+	}, nil
+}
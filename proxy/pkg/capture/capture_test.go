@@ -0,0 +1,94 @@
+package capture
+
+import (
+	"bytes"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriterReader_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.bin")
+	writer, err := NewWriter(path)
+	require.Nil(t, err)
+
+	var records []*Record
+	for i := 0; i < 3; i++ {
+		f, err := codec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, int16(i), &message.Query{
+			Query:   "SELECT * FROM ks.tb",
+			Options: &message.QueryOptions{},
+		}))
+		require.Nil(t, err)
+		record := &Record{
+			Timestamp:    time.Unix(0, int64(1000+i)),
+			ConnectionId: uint64(i % 2),
+			Frame:        f,
+		}
+		records = append(records, record)
+		require.Nil(t, writer.Write(record))
+	}
+	require.Nil(t, writer.Close())
+	require.NotNil(t, writer.Write(records[0]))
+
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+
+	reader, err := NewReader(file)
+	require.Nil(t, err)
+	for _, expected := range records {
+		record, err := reader.Read()
+		require.Nil(t, err)
+		require.Equal(t, expected.Timestamp.UnixNano(), record.Timestamp.UnixNano())
+		require.Equal(t, expected.ConnectionId, record.ConnectionId)
+		require.Equal(t, expected.Frame, record.Frame)
+	}
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestNewReader_InvalidHeader(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("not a capture file")))
+	require.NotNil(t, err)
+}
+
+func TestNewAppendWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.bin")
+	f, err := codec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{
+		Query:   "INSERT INTO ks.tb (a) VALUES (1)",
+		Options: &message.QueryOptions{},
+	}))
+	require.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		writer, err := NewAppendWriter(path)
+		require.Nil(t, err)
+		require.Nil(t, writer.Write(&Record{Timestamp: time.Unix(0, int64(i)), ConnectionId: uint64(i), Frame: f}))
+		require.Nil(t, writer.Close())
+	}
+
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+	reader, err := NewReader(file)
+	require.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		record, err := reader.Read()
+		require.Nil(t, err)
+		require.Equal(t, uint64(i), record.ConnectionId)
+		require.Equal(t, f, record.Frame)
+	}
+	_, err = reader.Read()
+	require.Equal(t, io.EOF, err)
+
+	invalidPath := filepath.Join(t.TempDir(), "invalid.bin")
+	require.Nil(t, os.WriteFile(invalidPath, []byte("not a capture file"), 0600))
+	_, err = NewAppendWriter(invalidPath)
+	require.NotNil(t, err)
+}
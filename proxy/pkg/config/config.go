@@ -9,6 +9,7 @@ import (
 	def "github.com/mcuadros/go-defaults"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
+	"math"
 	"net"
 	"os"
 	"strconv"
@@ -20,12 +21,34 @@ type Config struct {
 
 	// Global bucket
 
-	PrimaryCluster                string `default:"ORIGIN" split_words:"true" yaml:"primary_cluster"`
-	ReadMode                      string `default:"PRIMARY_ONLY" split_words:"true" yaml:"read_mode"`
-	ReplaceCqlFunctions           bool   `default:"false" split_words:"true" yaml:"replace_cql_functions"`
-	AsyncHandshakeTimeoutMs       int    `default:"4000" split_words:"true" yaml:"async_handshake_timeout_ms"`
-	LogLevel                      string `default:"INFO" split_words:"true" yaml:"log_level"`
-	ControlConnMaxProtocolVersion string `default:"DseV2" split_words:"true" yaml:"control_conn_max_protocol_version"` // Numeric Cassandra OSS protocol version or DseV1 / DseV2
+	PrimaryCluster                  string `default:"ORIGIN" split_words:"true" yaml:"primary_cluster"`
+	PrimaryClusterKeyspaceOverrides string `split_words:"true" yaml:"primary_cluster_keyspace_overrides"` // comma separated list of keyspace:cluster pairs
+	IncludedTables                  string `split_words:"true" yaml:"included_tables"`                    // comma separated list of keyspace or keyspace.table names
+	ExcludedTables                  string `split_words:"true" yaml:"excluded_tables"`                    // comma separated list of keyspace or keyspace.table names
+	TableWritePolicies              string `split_words:"true" yaml:"table_write_policies"`               // comma separated list of name:clusters[:failures] entries
+	TableRequestTimeoutsMs          string `split_words:"true" yaml:"table_request_timeouts_ms"`          // comma separated list of name:timeout pairs
+	DualWritesResponseCluster       string `split_words:"true" yaml:"dual_writes_response_cluster"`
+	DualWritesFailures              string `default:"FATAL" split_words:"true" yaml:"dual_writes_failures"`
+	DualWritesJournalFile           string `split_words:"true" yaml:"dual_writes_journal_file"`
+	IdempotencyOverrides            string `split_words:"true" yaml:"idempotency_overrides"` // comma separated list of name:idempotency pairs
+	DryRun                          bool   `default:"false" split_words:"true" yaml:"dry_run"`
+	TargetDuplicateWritesWindowMs   int    `default:"0" split_words:"true" yaml:"target_duplicate_writes_window_ms"`
+	TargetWritesAdaptivePacing      bool   `default:"false" split_words:"true" yaml:"target_writes_adaptive_pacing"`
+	TargetConsistencyLevelMapping   string `split_words:"true" yaml:"target_consistency_level_mapping"` // comma separated list of from:to consistency level pairs
+	TargetQualifyTableNames         bool   `default:"false" split_words:"true" yaml:"target_qualify_table_names"`
+	ReadMode                        string `default:"PRIMARY_ONLY" split_words:"true" yaml:"read_mode"`
+	DualReadsSamplePercent          int    `default:"100" split_words:"true" yaml:"dual_reads_sample_percent"`
+	DualReadsCompareResults         bool   `default:"false" split_words:"true" yaml:"dual_reads_compare_results"`
+	TargetReadsCanaryPercent        int    `default:"0" split_words:"true" yaml:"target_reads_canary_percent"`
+	TargetReadsCanaryPerConnection  bool   `default:"false" split_words:"true" yaml:"target_reads_canary_per_connection"`
+	TargetReadYourWrites            bool   `default:"false" split_words:"true" yaml:"target_read_your_writes"`
+	ReplaceCqlFunctions             bool   `default:"false" split_words:"true" yaml:"replace_cql_functions"`
+	AsyncHandshakeTimeoutMs         int    `default:"4000" split_words:"true" yaml:"async_handshake_timeout_ms"`
+	LogLevel                        string `default:"INFO" split_words:"true" yaml:"log_level"`
+	LogFormat                       string `default:"TEXT" split_words:"true" yaml:"log_format"`
+	LogFramesSampleConnections      int    `default:"0" split_words:"true" yaml:"log_frames_sample_connections"`
+	LogLevelEndpointEnabled         bool   `default:"false" split_words:"true" yaml:"log_level_endpoint_enabled"`
+	ControlConnMaxProtocolVersion   string `default:"DseV2" split_words:"true" yaml:"control_conn_max_protocol_version"` // Numeric Cassandra OSS protocol version or DseV1 / DseV2
 
 	// Proxy Topology (also known as system.peers "virtualization") bucket
 
@@ -40,7 +63,8 @@ type Config struct {
 	OriginSecureConnectBundlePath string `split_words:"true" yaml:"origin_secure_connect_bundle_path"`
 	OriginLocalDatacenter         string `split_words:"true" yaml:"origin_local_datacenter"`
 	OriginUsername                string `required:"true" split_words:"true" yaml:"origin_username"`
-	OriginPassword                string `required:"true" split_words:"true" json:"-" yaml:"origin_password"`
+	OriginPassword                string `split_words:"true" json:"-" yaml:"origin_password"`
+	OriginPasswordFile            string `split_words:"true" yaml:"origin_password_file"`
 	OriginConnectionTimeoutMs     int    `default:"30000" split_words:"true" yaml:"origin_connection_timeout_ms"`
 
 	OriginTlsServerCaPath   string `split_words:"true" yaml:"origin_tls_server_ca_path"`
@@ -54,7 +78,8 @@ type Config struct {
 	TargetSecureConnectBundlePath string `split_words:"true" yaml:"target_secure_connect_bundle_path"`
 	TargetLocalDatacenter         string `split_words:"true" yaml:"target_local_datacenter"`
 	TargetUsername                string `required:"true" split_words:"true" yaml:"target_username"`
-	TargetPassword                string `required:"true" split_words:"true" json:"-" yaml:"target_password"`
+	TargetPassword                string `split_words:"true" json:"-" yaml:"target_password"`
+	TargetPasswordFile            string `split_words:"true" yaml:"target_password_file"`
 	TargetConnectionTimeoutMs     int    `default:"30000" split_words:"true" yaml:"target_connection_timeout_ms"`
 
 	TargetTlsServerCaPath   string `split_words:"true" yaml:"target_tls_server_ca_path"`
@@ -63,11 +88,25 @@ type Config struct {
 
 	// Proxy bucket
 
-	ProxyListenAddress        string `default:"localhost" split_words:"true" yaml:"proxy_listen_address"`
-	ProxyListenPort           int    `default:"14002" split_words:"true" yaml:"proxy_listen_port"`
-	ProxyRequestTimeoutMs     int    `default:"10000" split_words:"true" yaml:"proxy_request_timeout_ms"`
-	ProxyMaxClientConnections int    `default:"1000" split_words:"true" yaml:"proxy_max_client_connections"`
-	ProxyMaxStreamIds         int    `default:"2048" split_words:"true" yaml:"proxy_max_stream_ids"`
+	ProxyInstanceName               string `split_words:"true" yaml:"proxy_instance_name"`
+	ProxyListenAddress              string `default:"localhost" split_words:"true" yaml:"proxy_listen_address"`
+	ProxyListenPort                 int    `default:"14002" split_words:"true" yaml:"proxy_listen_port"`
+	ProxyAdditionalListeners        string `split_words:"true" yaml:"proxy_additional_listeners"` // comma separated list of port[:option=value...] entries
+	ProxyRequestTimeoutMs           int    `default:"10000" split_words:"true" yaml:"proxy_request_timeout_ms"`
+	ProxyMaxClientConnections       int    `default:"1000" split_words:"true" yaml:"proxy_max_client_connections"`
+	ProxyMaxClientRequestsPerSecond int    `default:"0" split_words:"true" yaml:"proxy_max_client_requests_per_second"`
+	ProxyClientIdleTimeoutMs        int    `default:"0" split_words:"true" yaml:"proxy_client_idle_timeout_ms"`
+	ProxyShutdownGracePeriodMs      int    `default:"30000" split_words:"true" yaml:"proxy_shutdown_grace_period_ms"`
+	ProxyTcpKeepAliveMs             int    `default:"15000" split_words:"true" yaml:"proxy_tcp_keep_alive_ms"`
+	ProxyMaxFrameSizeBytes          int    `default:"16777216" split_words:"true" yaml:"proxy_max_frame_size_bytes"`
+	ProxyEnableProxyProtocol        bool   `default:"false" split_words:"true" yaml:"proxy_enable_proxy_protocol"`
+	ProxyClientAllowList            string `split_words:"true" yaml:"proxy_client_allow_list"` // comma separated list of IP addresses or CIDR ranges
+	ProxyClientDenyList             string `split_words:"true" yaml:"proxy_client_deny_list"`  // comma separated list of IP addresses or CIDR ranges
+	ProxyMaxStreamIds               int    `default:"2048" split_words:"true" yaml:"proxy_max_stream_ids"`
+	ProxyMaxPreparedStatements      int    `default:"5000" split_words:"true" yaml:"proxy_max_prepared_statements"`
+	ProxyStripCompression           bool   `default:"false" split_words:"true" yaml:"proxy_strip_compression"`
+	ProxyCaptureFile                string `split_words:"true" yaml:"proxy_capture_file"`
+	ProxyCaptureSamplePercent       int    `default:"100" split_words:"true" yaml:"proxy_capture_sample_percent"`
 
 	ProxyTlsCaPath            string `split_words:"true" yaml:"proxy_tls_ca_path"`
 	ProxyTlsCertPath          string `split_words:"true" yaml:"proxy_tls_cert_path"`
@@ -84,6 +123,9 @@ type Config struct {
 	MetricsOriginLatencyBucketsMs    string `default:"1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000" split_words:"true" yaml:"metrics_origin_latency_buckets_ms"`
 	MetricsTargetLatencyBucketsMs    string `default:"1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000" split_words:"true" yaml:"metrics_target_latency_buckets_ms"`
 	MetricsAsyncReadLatencyBucketsMs string `default:"1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000" split_words:"true" yaml:"metrics_async_read_latency_buckets_ms"`
+	MetricsProxyOverheadBucketsMs    string `default:"0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100" split_words:"true" yaml:"metrics_proxy_overhead_buckets_ms"`
+
+	MetricsTopStatements int `default:"0" split_words:"true" yaml:"metrics_top_statements"`
 
 	// Heartbeat bucket
 
@@ -94,6 +136,8 @@ type Config struct {
 	HeartbeatRetryBackoffFactor float64 `default:"2" split_words:"true" yaml:"heartbeat_retry_backoff_factor"`
 	HeartbeatFailureThreshold   int     `default:"1" split_words:"true" yaml:"heartbeat_failure_threshold"`
 
+	ContactPointsRefreshIntervalMs int `default:"0" split_words:"true" yaml:"contact_points_refresh_interval_ms"`
+
 	//////////////////////////////////////////////////////////////////////
 	/// THE SETTINGS BELOW AREN'T SUPPORTED AND MAY CHANGE AT ANY TIME ///
 	//////////////////////////////////////////////////////////////////////
@@ -105,6 +149,8 @@ type Config struct {
 	OriginEnableHostAssignment bool `default:"true" split_words:"true" yaml:"origin_enable_host_assignment"`
 	TargetEnableHostAssignment bool `default:"true" split_words:"true" yaml:"target_enable_host_assignment"`
 
+	EnableProtocolV5 bool `default:"false" split_words:"true" yaml:"enable_protocol_v5"` // client connections only, control connections are limited by ControlConnMaxProtocolVersion
+
 	//////////////////////////////////////////////////////////////////////////////////////////////////////////
 	/// THE SETTINGS BELOW ARE FOR PERFORMANCE TUNING; THEY AREN'T SUPPORTED AND MAY CHANGE AT ANY TIME //////
 	//////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -118,6 +164,7 @@ type Config struct {
 	ResponseReadBufferSizeBytes  int `default:"32768" split_words:"true" yaml:"response_read_buffer_size_bytes"`
 
 	RequestResponseMaxWorkers int `default:"-1" split_words:"true" yaml:"request_response_max_workers"`
+	DualWritesMaxWorkers      int `default:"-1" split_words:"true" yaml:"dual_writes_max_workers"`
 	WriteMaxWorkers           int `default:"-1" split_words:"true" yaml:"write_max_workers"`
 	ReadMaxWorkers            int `default:"-1" split_words:"true" yaml:"read_max_workers"`
 	ListenerMaxWorkers        int `default:"-1" split_words:"true" yaml:"listener_max_workers"`
@@ -186,7 +233,9 @@ func (c *Config) LoadConfig(configFile string) (*Config, error) {
 	return c, nil
 }
 
-func lookupFirstIp4(host string) (net.IP, error) {
+// lookupFirstIp returns the first IPv4 address of host or, if host only resolves to IPv6 addresses, the first IPv6
+// address.
+func lookupFirstIp(host string) (net.IP, error) {
 	ips, err := net.LookupIP(host)
 	if err != nil {
 		return nil, err
@@ -197,7 +246,10 @@ func lookupFirstIp4(host string) (net.IP, error) {
 			return ip4, nil
 		}
 	}
-	return nil, fmt.Errorf("could not resolve %v to an ipv4 address", host)
+	if len(ips) > 0 {
+		return ips[0], nil
+	}
+	return nil, fmt.Errorf("could not resolve %v to an ip address", host)
 }
 
 func (c *Config) ParseTopologyConfig() (*common.TopologyConfig, error) {
@@ -206,9 +258,9 @@ func (c *Config) ParseTopologyConfig() (*common.TopologyConfig, error) {
 	if isNotDefined(c.ProxyTopologyAddresses) {
 		log.Debugf("[TopologyConfig] Proxy Topology Addresses not defined, attempting to use proxy listen address for system.local: %v.", c.ProxyListenAddress)
 		if isDefined(c.ProxyListenAddress) {
-			parsedListenAddress, err := lookupFirstIp4(c.ProxyListenAddress)
+			parsedListenAddress, err := lookupFirstIp(c.ProxyListenAddress)
 			if err != nil {
-				log.Debugf("[TopologyConfig] Could not resolve Proxy Listen Address to an IPv4 address: %v. Falling back to default: %v.", err, defaultLocalIp4Addr.String())
+				log.Debugf("[TopologyConfig] Could not resolve Proxy Listen Address to an IP address: %v. Falling back to default: %v.", err, defaultLocalIp4Addr.String())
 			} else {
 				proxyAddressesTyped = []net.IP{parsedListenAddress}
 			}
@@ -256,12 +308,23 @@ func (c *Config) ParseTopologyConfig() (*common.TopologyConfig, error) {
 	}, nil
 }
 
+// GetProxyInstanceName returns ZDM_PROXY_INSTANCE_NAME, the name that identifies this proxy instance in the logs and
+// metrics of multi-proxy deployments. It is empty if the name is not configured.
+func (c *Config) GetProxyInstanceName() string {
+	return strings.TrimSpace(c.ProxyInstanceName)
+}
+
 func (c *Config) Validate() error {
 	_, err := c.ParseLogLevel()
 	if err != nil {
 		return fmt.Errorf("invalid log level: %w", err)
 	}
 
+	_, err = c.ParseLogFormat()
+	if err != nil {
+		return err
+	}
+
 	_, err = c.ParseTargetContactPoints()
 	if err != nil {
 		return fmt.Errorf("invalid target configuration: %w", err)
@@ -282,11 +345,26 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("could not parse target buckets: %v", err)
 	}
 
+	_, err = c.ParseProxyOverheadBuckets()
+	if err != nil {
+		return fmt.Errorf("could not parse proxy overhead buckets: %v", err)
+	}
+
 	_, err = c.ParseTopologyConfig()
 	if err != nil {
 		return err
 	}
 
+	_, err = c.ParseOriginPassword()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseTargetPassword()
+	if err != nil {
+		return err
+	}
+
 	_, err = c.ParseOriginTlsConfig(false)
 	if err != nil {
 		return err
@@ -307,6 +385,74 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	_, err = c.ParsePrimaryClusterKeyspaceOverrides()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseIncludedTables()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseExcludedTables()
+	if err != nil {
+		return err
+	}
+
+	writePolicies, err := c.ParseTableWritePolicies()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseTableRequestTimeouts()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseDualWritesResponseCluster()
+	if err != nil {
+		return err
+	}
+
+	dualWritesFailures, err := c.ParseDualWritesFailures()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseIdempotencyOverrides()
+	if err != nil {
+		return err
+	}
+
+	if c.DualWritesJournalFile == "" {
+		if dualWritesFailures == WriteFailuresJournal {
+			return fmt.Errorf("ZDM_DUAL_WRITES_JOURNAL_FILE is required when ZDM_DUAL_WRITES_FAILURES is %v",
+				WriteFailuresJournal)
+		}
+		for name, policy := range writePolicies {
+			if policy.Failures == WriteFailuresJournal {
+				return fmt.Errorf("ZDM_DUAL_WRITES_JOURNAL_FILE is required when ZDM_TABLE_WRITE_POLICIES "+
+					"has %v entries (%v)", WriteFailuresJournal, name)
+			}
+		}
+	}
+
+	_, err = c.ParseTargetConsistencyLevelMapping()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseProxyClientAllowList()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseProxyClientDenyList()
+	if err != nil {
+		return err
+	}
+
 	_, err = c.ParseSystemQueriesMode()
 	if err != nil {
 		return err
@@ -317,6 +463,71 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if c.DualReadsSamplePercent < 0 || c.DualReadsSamplePercent > 100 {
+		return fmt.Errorf("invalid value for ZDM_DUAL_READS_SAMPLE_PERCENT (%v); it must be between 0 and 100",
+			c.DualReadsSamplePercent)
+	}
+
+	if c.TargetReadsCanaryPercent < 0 || c.TargetReadsCanaryPercent > 100 {
+		return fmt.Errorf("invalid value for ZDM_TARGET_READS_CANARY_PERCENT (%v); it must be between 0 and 100",
+			c.TargetReadsCanaryPercent)
+	}
+
+	if c.ProxyMaxClientRequestsPerSecond < 0 {
+		return fmt.Errorf("invalid value for ZDM_PROXY_MAX_CLIENT_REQUESTS_PER_SECOND (%v); it must not be negative",
+			c.ProxyMaxClientRequestsPerSecond)
+	}
+
+	_, err = c.ParseProxyListeners()
+	if err != nil {
+		return err
+	}
+
+	if c.LogFramesSampleConnections < 0 {
+		return fmt.Errorf("invalid value for ZDM_LOG_FRAMES_SAMPLE_CONNECTIONS (%v); it must not be negative",
+			c.LogFramesSampleConnections)
+	}
+
+	if c.MetricsTopStatements < 0 {
+		return fmt.Errorf("invalid value for ZDM_METRICS_TOP_STATEMENTS (%v); it must not be negative",
+			c.MetricsTopStatements)
+	}
+
+	if c.ProxyMaxPreparedStatements < 0 {
+		return fmt.Errorf("invalid value for ZDM_PROXY_MAX_PREPARED_STATEMENTS (%v); it must not be negative",
+			c.ProxyMaxPreparedStatements)
+	}
+
+	if c.ContactPointsRefreshIntervalMs < 0 {
+		return fmt.Errorf("invalid value for ZDM_CONTACT_POINTS_REFRESH_INTERVAL_MS (%v); it must not be negative",
+			c.ContactPointsRefreshIntervalMs)
+	}
+
+	if c.ProxyClientIdleTimeoutMs < 0 {
+		return fmt.Errorf("invalid value for ZDM_PROXY_CLIENT_IDLE_TIMEOUT_MS (%v); it must not be negative",
+			c.ProxyClientIdleTimeoutMs)
+	}
+
+	if c.TargetDuplicateWritesWindowMs < 0 {
+		return fmt.Errorf("invalid value for ZDM_TARGET_DUPLICATE_WRITES_WINDOW_MS (%v); it must not be negative",
+			c.TargetDuplicateWritesWindowMs)
+	}
+
+	if c.ProxyShutdownGracePeriodMs < 0 {
+		return fmt.Errorf("invalid value for ZDM_PROXY_SHUTDOWN_GRACE_PERIOD_MS (%v); it must not be negative",
+			c.ProxyShutdownGracePeriodMs)
+	}
+
+	if c.ProxyMaxFrameSizeBytes <= 0 || c.ProxyMaxFrameSizeBytes > math.MaxInt32 {
+		return fmt.Errorf("invalid value for ZDM_PROXY_MAX_FRAME_SIZE_BYTES (%v); it must be between 1 and %v",
+			c.ProxyMaxFrameSizeBytes, math.MaxInt32)
+	}
+
+	if c.ProxyCaptureSamplePercent < 0 || c.ProxyCaptureSamplePercent > 100 {
+		return fmt.Errorf("invalid value for ZDM_PROXY_CAPTURE_SAMPLE_PERCENT (%v); it must be between 0 and 100",
+			c.ProxyCaptureSamplePercent)
+	}
+
 	_, err = c.ParseControlConnMaxProtocolVersion()
 	if err != nil {
 		return err
@@ -348,34 +559,500 @@ const (
 )
 
 func (c *Config) ParsePrimaryCluster() (common.ClusterType, error) {
-	switch strings.ToUpper(c.PrimaryCluster) {
+	primaryCluster, ok := parsePrimaryCluster(c.PrimaryCluster)
+	if !ok {
+		return common.ClusterTypeNone, fmt.Errorf("invalid value for ZDM_PRIMARY_CLUSTER; possible values are: %v and %v",
+			PrimaryClusterOrigin, PrimaryClusterTarget)
+	}
+	return primaryCluster, nil
+}
+
+func parsePrimaryCluster(value string) (common.ClusterType, bool) {
+	switch strings.ToUpper(value) {
 	case PrimaryClusterOrigin:
-		return common.ClusterTypeOrigin, nil
+		return common.ClusterTypeOrigin, true
 	case PrimaryClusterTarget:
-		return common.ClusterTypeTarget, nil
+		return common.ClusterTypeTarget, true
 	default:
-		return common.ClusterTypeNone, fmt.Errorf("invalid value for ZDM_PRIMARY_CLUSTER; possible values are: %v and %v",
-			PrimaryClusterOrigin, PrimaryClusterTarget)
+		return common.ClusterTypeNone, false
 	}
 }
 
+// ParsePrimaryClusterKeyspaceOverrides parses ZDM_PRIMARY_CLUSTER_KEYSPACE_OVERRIDES, a comma separated list of
+// keyspace:cluster pairs (e.g. "ks1:TARGET,ks2:ORIGIN"), into a map of keyspace names to the cluster that
+// reads on that keyspace are sent to.
+func (c *Config) ParsePrimaryClusterKeyspaceOverrides() (map[string]common.ClusterType, error) {
+	overrides := make(map[string]common.ClusterType)
+	if isNotDefined(strings.TrimSpace(c.PrimaryClusterKeyspaceOverrides)) {
+		return overrides, nil
+	}
+
+	for _, override := range strings.Split(c.PrimaryClusterKeyspaceOverrides, ",") {
+		keyspaceAndCluster := strings.Split(strings.TrimSpace(override), ":")
+		if len(keyspaceAndCluster) != 2 || isNotDefined(strings.TrimSpace(keyspaceAndCluster[0])) {
+			return nil, fmt.Errorf("invalid value for ZDM_PRIMARY_CLUSTER_KEYSPACE_OVERRIDES (%v); "+
+				"expected a comma separated list of keyspace:cluster pairs", c.PrimaryClusterKeyspaceOverrides)
+		}
+
+		keyspace := strings.TrimSpace(keyspaceAndCluster[0])
+		var cluster common.ClusterType
+		switch strings.ToUpper(strings.TrimSpace(keyspaceAndCluster[1])) {
+		case PrimaryClusterOrigin:
+			cluster = common.ClusterTypeOrigin
+		case PrimaryClusterTarget:
+			cluster = common.ClusterTypeTarget
+		default:
+			return nil, fmt.Errorf("invalid cluster for keyspace %v in ZDM_PRIMARY_CLUSTER_KEYSPACE_OVERRIDES; "+
+				"possible values are: %v and %v", keyspace, PrimaryClusterOrigin, PrimaryClusterTarget)
+		}
+
+		if _, exists := overrides[keyspace]; exists {
+			return nil, fmt.Errorf("duplicate keyspace %v in ZDM_PRIMARY_CLUSTER_KEYSPACE_OVERRIDES", keyspace)
+		}
+		overrides[keyspace] = cluster
+	}
+	return overrides, nil
+}
+
+// ParseTargetConsistencyLevelMapping parses ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING, a comma separated list of
+// from:to consistency level pairs (e.g. "LOCAL_ONE:LOCAL_QUORUM,SERIAL:LOCAL_SERIAL"), into a map of the consistency
+// levels sent by clients to the consistency levels used for the writes sent to the target cluster. Serial consistency
+// levels can only be mapped to serial consistency levels and vice versa.
+func (c *Config) ParseTargetConsistencyLevelMapping() (map[primitive.ConsistencyLevel]primitive.ConsistencyLevel, error) {
+	mapping := make(map[primitive.ConsistencyLevel]primitive.ConsistencyLevel)
+	if isNotDefined(strings.TrimSpace(c.TargetConsistencyLevelMapping)) {
+		return mapping, nil
+	}
+
+	for _, entry := range strings.Split(c.TargetConsistencyLevelMapping, ",") {
+		fromAndTo := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fromAndTo) != 2 {
+			return nil, fmt.Errorf("invalid value for ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING (%v); "+
+				"expected a comma separated list of from:to consistency level pairs", c.TargetConsistencyLevelMapping)
+		}
+
+		from, err := parseConsistencyLevel(fromAndTo[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING: %w", err)
+		}
+		to, err := parseConsistencyLevel(fromAndTo[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING: %w", err)
+		}
+		if from.IsSerial() != to.IsSerial() {
+			return nil, fmt.Errorf("invalid mapping %v:%v in ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING; "+
+				"serial consistency levels can only be mapped to serial consistency levels",
+				consistencyLevelNames[from], consistencyLevelNames[to])
+		}
+
+		if _, exists := mapping[from]; exists {
+			return nil, fmt.Errorf("duplicate consistency level %v in ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING",
+				consistencyLevelNames[from])
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
+
+var consistencyLevelNames = map[primitive.ConsistencyLevel]string{
+	primitive.ConsistencyLevelAny:         "ANY",
+	primitive.ConsistencyLevelOne:         "ONE",
+	primitive.ConsistencyLevelTwo:         "TWO",
+	primitive.ConsistencyLevelThree:       "THREE",
+	primitive.ConsistencyLevelQuorum:      "QUORUM",
+	primitive.ConsistencyLevelAll:         "ALL",
+	primitive.ConsistencyLevelLocalQuorum: "LOCAL_QUORUM",
+	primitive.ConsistencyLevelEachQuorum:  "EACH_QUORUM",
+	primitive.ConsistencyLevelSerial:      "SERIAL",
+	primitive.ConsistencyLevelLocalSerial: "LOCAL_SERIAL",
+	primitive.ConsistencyLevelLocalOne:    "LOCAL_ONE",
+}
+
+func parseConsistencyLevel(name string) (primitive.ConsistencyLevel, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	for level, levelName := range consistencyLevelNames {
+		if levelName == name {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown consistency level '%v'", name)
+}
+
+func (c *Config) ParseIncludedTables() ([]string, error) {
+	return parseTableList(c.IncludedTables, "ZDM_INCLUDED_TABLES")
+}
+
+func (c *Config) ParseExcludedTables() ([]string, error) {
+	return parseTableList(c.ExcludedTables, "ZDM_EXCLUDED_TABLES")
+}
+
+// parseTableList parses a comma separated list of keyspace and keyspace.table names (e.g. "ks1,ks2.tb1").
+func parseTableList(value string, envVarName string) ([]string, error) {
+	tables := make([]string, 0)
+	if isNotDefined(strings.TrimSpace(value)) {
+		return tables, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.Split(entry, ".")
+		if len(parts) > 2 || isNotDefined(parts[0]) || (len(parts) == 2 && isNotDefined(parts[1])) {
+			return nil, fmt.Errorf("invalid entry in %v (%v); expected a keyspace or keyspace.table name", envVarName, entry)
+		}
+		tables = append(tables, entry)
+	}
+	return tables, nil
+}
+
+const (
+	WritePolicyBoth   = "BOTH"
+	WritePolicyOrigin = "ORIGIN"
+	WritePolicyTarget = "TARGET"
+
+	WriteFailuresFatal      = "FATAL"
+	WriteFailuresBestEffort = "BEST_EFFORT"
+	WriteFailuresJournal    = "JOURNAL"
+)
+
+const (
+	Idempotent    = "IDEMPOTENT"
+	NonIdempotent = "NON_IDEMPOTENT"
+)
+
+// TableWritePolicy is the write policy of a keyspace or table, see ParseTableWritePolicies.
+type TableWritePolicy struct {
+	Clusters string // WritePolicyBoth, WritePolicyOrigin or WritePolicyTarget
+	Failures string // only for WritePolicyBoth: WriteFailuresFatal, WriteFailuresBestEffort, WriteFailuresJournal or
+	// empty if the entry doesn't have failures, in which case ZDM_DUAL_WRITES_FAILURES applies
+}
+
+// ParseTableWritePolicies parses ZDM_TABLE_WRITE_POLICIES, a comma separated list of name:clusters[:failures] entries
+// (e.g. "ks1:ORIGIN,ks2.tb1:BOTH:BEST_EFFORT") into a map of keyspace and keyspace.table names to their write policy.
+// Clusters is one of BOTH, ORIGIN and TARGET, failures is one of FATAL, BEST_EFFORT and JOURNAL (defaults to
+// ZDM_DUAL_WRITES_FAILURES).
+func (c *Config) ParseTableWritePolicies() (map[string]TableWritePolicy, error) {
+	policies := make(map[string]TableWritePolicy)
+	if isNotDefined(strings.TrimSpace(c.TableWritePolicies)) {
+		return policies, nil
+	}
+
+	for _, entry := range strings.Split(c.TableWritePolicies, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid value for ZDM_TABLE_WRITE_POLICIES (%v); "+
+				"expected a comma separated list of name:clusters[:failures] entries", c.TableWritePolicies)
+		}
+
+		names, err := parseTableList(parts[0], "ZDM_TABLE_WRITE_POLICIES")
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 1 {
+			return nil, fmt.Errorf("invalid entry in ZDM_TABLE_WRITE_POLICIES (%v); "+
+				"expected a keyspace or keyspace.table name", entry)
+		}
+		name := names[0]
+
+		var policy TableWritePolicy
+		switch clusters := strings.ToUpper(strings.TrimSpace(parts[1])); clusters {
+		case WritePolicyBoth, WritePolicyOrigin, WritePolicyTarget:
+			policy.Clusters = clusters
+		default:
+			return nil, fmt.Errorf("invalid clusters for %v in ZDM_TABLE_WRITE_POLICIES; possible values are: %v, %v and %v",
+				name, WritePolicyBoth, WritePolicyOrigin, WritePolicyTarget)
+		}
+
+		if len(parts) == 3 {
+			switch failures := strings.ToUpper(strings.TrimSpace(parts[2])); failures {
+			case WriteFailuresFatal, WriteFailuresBestEffort, WriteFailuresJournal:
+				policy.Failures = failures
+			default:
+				return nil, fmt.Errorf("invalid failures for %v in ZDM_TABLE_WRITE_POLICIES; possible values are: %v, %v and %v",
+					name, WriteFailuresFatal, WriteFailuresBestEffort, WriteFailuresJournal)
+			}
+			if policy.Failures != WriteFailuresFatal && policy.Clusters != WritePolicyBoth {
+				return nil, fmt.Errorf("invalid policy for %v in ZDM_TABLE_WRITE_POLICIES; %v is only valid with %v",
+					name, policy.Failures, WritePolicyBoth)
+			}
+		}
+
+		if _, exists := policies[name]; exists {
+			return nil, fmt.Errorf("duplicate entry %v in ZDM_TABLE_WRITE_POLICIES", name)
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// ParseTableRequestTimeouts parses ZDM_TABLE_REQUEST_TIMEOUTS_MS, a comma separated list of name:timeout pairs (e.g.
+// "ks1:2000,ks1.tb1:30000") into a map of keyspace and keyspace.table names to the timeout in milliseconds of the
+// requests on them, which overrides ZDM_PROXY_REQUEST_TIMEOUT_MS.
+func (c *Config) ParseTableRequestTimeouts() (map[string]int, error) {
+	timeouts := make(map[string]int)
+	if isNotDefined(strings.TrimSpace(c.TableRequestTimeoutsMs)) {
+		return timeouts, nil
+	}
+
+	for _, entry := range strings.Split(c.TableRequestTimeoutsMs, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid value for ZDM_TABLE_REQUEST_TIMEOUTS_MS (%v); "+
+				"expected a comma separated list of name:timeout pairs", c.TableRequestTimeoutsMs)
+		}
+
+		names, err := parseTableList(parts[0], "ZDM_TABLE_REQUEST_TIMEOUTS_MS")
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 1 {
+			return nil, fmt.Errorf("invalid entry in ZDM_TABLE_REQUEST_TIMEOUTS_MS (%v); "+
+				"expected a keyspace or keyspace.table name", entry)
+		}
+		name := names[0]
+
+		timeoutMs, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || timeoutMs <= 0 {
+			return nil, fmt.Errorf("invalid timeout for %v in ZDM_TABLE_REQUEST_TIMEOUTS_MS; "+
+				"expected a positive number of milliseconds", name)
+		}
+
+		if _, exists := timeouts[name]; exists {
+			return nil, fmt.Errorf("duplicate entry %v in ZDM_TABLE_REQUEST_TIMEOUTS_MS", name)
+		}
+		timeouts[name] = timeoutMs
+	}
+	return timeouts, nil
+}
+
+// ParseIdempotencyOverrides parses ZDM_IDEMPOTENCY_OVERRIDES, a comma separated list of name:idempotency pairs
+// (e.g. "ks1:NON_IDEMPOTENT,ks1.tb1:IDEMPOTENT") into a map of keyspace and keyspace.table names to whether the
+// statements on them are idempotent. Idempotency is one of IDEMPOTENT and NON_IDEMPOTENT.
+func (c *Config) ParseIdempotencyOverrides() (map[string]bool, error) {
+	overrides := make(map[string]bool)
+	if isNotDefined(strings.TrimSpace(c.IdempotencyOverrides)) {
+		return overrides, nil
+	}
+
+	for _, entry := range strings.Split(c.IdempotencyOverrides, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid value for ZDM_IDEMPOTENCY_OVERRIDES (%v); "+
+				"expected a comma separated list of name:idempotency pairs", c.IdempotencyOverrides)
+		}
+
+		names, err := parseTableList(parts[0], "ZDM_IDEMPOTENCY_OVERRIDES")
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 1 {
+			return nil, fmt.Errorf("invalid entry in ZDM_IDEMPOTENCY_OVERRIDES (%v); "+
+				"expected a keyspace or keyspace.table name", entry)
+		}
+		name := names[0]
+
+		var idempotent bool
+		switch idempotency := strings.ToUpper(strings.TrimSpace(parts[1])); idempotency {
+		case Idempotent:
+			idempotent = true
+		case NonIdempotent:
+			idempotent = false
+		default:
+			return nil, fmt.Errorf("invalid idempotency for %v in ZDM_IDEMPOTENCY_OVERRIDES; possible values are: %v and %v",
+				name, Idempotent, NonIdempotent)
+		}
+
+		if _, exists := overrides[name]; exists {
+			return nil, fmt.Errorf("duplicate entry %v in ZDM_IDEMPOTENCY_OVERRIDES", name)
+		}
+		overrides[name] = idempotent
+	}
+	return overrides, nil
+}
+
+// ParseDualWritesResponseCluster parses ZDM_DUAL_WRITES_RESPONSE_CLUSTER, the cluster whose response is returned to the
+// client for writes sent to both clusters. Returns common.ClusterTypeNone if it is not set, in which case a failure on
+// either cluster is returned.
+func (c *Config) ParseDualWritesResponseCluster() (common.ClusterType, error) {
+	if isNotDefined(strings.TrimSpace(c.DualWritesResponseCluster)) {
+		return common.ClusterTypeNone, nil
+	}
+	cluster, ok := parsePrimaryCluster(strings.TrimSpace(c.DualWritesResponseCluster))
+	if !ok {
+		return common.ClusterTypeNone, fmt.Errorf(
+			"invalid value for ZDM_DUAL_WRITES_RESPONSE_CLUSTER (%v); possible values are: %v and %v",
+			c.DualWritesResponseCluster, PrimaryClusterOrigin, PrimaryClusterTarget)
+	}
+	return cluster, nil
+}
+
+// ParseDualWritesFailures parses ZDM_DUAL_WRITES_FAILURES, how failures on the cluster that is not the primary cluster
+// are handled for writes sent to both clusters on tables without failures in ZDM_TABLE_WRITE_POLICIES.
+// Returns one of WriteFailuresFatal, WriteFailuresBestEffort and WriteFailuresJournal.
+func (c *Config) ParseDualWritesFailures() (string, error) {
+	switch failures := strings.ToUpper(strings.TrimSpace(c.DualWritesFailures)); failures {
+	case WriteFailuresFatal, WriteFailuresBestEffort, WriteFailuresJournal:
+		return failures, nil
+	case "":
+		return WriteFailuresFatal, nil
+	default:
+		return "", fmt.Errorf("invalid value for ZDM_DUAL_WRITES_FAILURES (%v); possible values are: %v, %v and %v",
+			c.DualWritesFailures, WriteFailuresFatal, WriteFailuresBestEffort, WriteFailuresJournal)
+	}
+}
+
+func (c *Config) ParseProxyClientAllowList() ([]*net.IPNet, error) {
+	return parseIpNetworks(c.ProxyClientAllowList, "ZDM_PROXY_CLIENT_ALLOW_LIST")
+}
+
+func (c *Config) ParseProxyClientDenyList() ([]*net.IPNet, error) {
+	return parseIpNetworks(c.ProxyClientDenyList, "ZDM_PROXY_CLIENT_DENY_LIST")
+}
+
+// parseIpNetworks parses a comma separated list of IP addresses and CIDR ranges (e.g. "10.0.0.0/8,192.168.1.10"),
+// an IP address is parsed as a range that only contains that address.
+func parseIpNetworks(value string, envVarName string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	if isNotDefined(strings.TrimSpace(value)) {
+		return networks, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range in %v: %v", envVarName, entry)
+			}
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address in %v: %v", envVarName, entry)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+	return networks, nil
+}
+
 const (
 	ReadModePrimaryOnly          = "PRIMARY_ONLY"
 	ReadModeDualAsyncOnSecondary = "DUAL_ASYNC_ON_SECONDARY"
 )
 
 func (c *Config) ParseReadMode() (common.ReadMode, error) {
-	switch strings.ToUpper(c.ReadMode) {
+	readMode, ok := parseReadMode(c.ReadMode)
+	if !ok {
+		return common.ReadModeUndefined, fmt.Errorf("invalid value for ZDM_READ_MODE; possible values are: %v and %v",
+			ReadModePrimaryOnly, ReadModeDualAsyncOnSecondary)
+	}
+	return readMode, nil
+}
+
+func parseReadMode(value string) (common.ReadMode, bool) {
+	switch strings.ToUpper(value) {
 	case ReadModePrimaryOnly:
-		return common.ReadModePrimaryOnly, nil
+		return common.ReadModePrimaryOnly, true
 	case ReadModeDualAsyncOnSecondary:
-		return common.ReadModeDualAsyncOnSecondary, nil
+		return common.ReadModeDualAsyncOnSecondary, true
 	default:
-		return common.ReadModeUndefined, fmt.Errorf("invalid value for ZDM_READ_MODE; possible values are: %v and %v",
-			ReadModePrimaryOnly, ReadModeDualAsyncOnSecondary)
+		return common.ReadModeUndefined, false
 	}
 }
 
+const (
+	ListenerOptionPrimaryCluster             = "primary_cluster"
+	ListenerOptionReadMode                   = "read_mode"
+	ListenerOptionMaxClientRequestsPerSecond = "max_client_requests_per_second"
+)
+
+// ListenerConfig is the configuration of a client listener, see ParseProxyListeners.
+type ListenerConfig struct {
+	Port                       int
+	PrimaryCluster             common.ClusterType
+	ReadMode                   common.ReadMode
+	MaxClientRequestsPerSecond int
+}
+
+// ParseProxyListeners returns the listener of ZDM_PROXY_LISTEN_PORT followed by the listeners of
+// ZDM_PROXY_ADDITIONAL_LISTENERS, a comma separated list of port[:option=value...] entries
+// (e.g. "9043,9044:primary_cluster=TARGET:max_client_requests_per_second=500"). All listeners use
+// ZDM_PROXY_LISTEN_ADDRESS, the options override ZDM_PRIMARY_CLUSTER, ZDM_READ_MODE and
+// ZDM_PROXY_MAX_CLIENT_REQUESTS_PER_SECOND for the client connections accepted by that listener.
+func (c *Config) ParseProxyListeners() ([]*ListenerConfig, error) {
+	primaryCluster, err := c.ParsePrimaryCluster()
+	if err != nil {
+		return nil, err
+	}
+	readMode, err := c.ParseReadMode()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := []*ListenerConfig{{
+		Port:                       c.ProxyListenPort,
+		PrimaryCluster:             primaryCluster,
+		ReadMode:                   readMode,
+		MaxClientRequestsPerSecond: c.ProxyMaxClientRequestsPerSecond,
+	}}
+	if isNotDefined(strings.TrimSpace(c.ProxyAdditionalListeners)) {
+		return listeners, nil
+	}
+
+	ports := map[int]bool{c.ProxyListenPort: true}
+	for _, entry := range strings.Split(c.ProxyAdditionalListeners, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		port, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid value for ZDM_PROXY_ADDITIONAL_LISTENERS (%v); "+
+				"expected a comma separated list of port[:option=value...] entries", c.ProxyAdditionalListeners)
+		}
+		if ports[port] {
+			return nil, fmt.Errorf("duplicate port %v in ZDM_PROXY_ADDITIONAL_LISTENERS", port)
+		}
+		ports[port] = true
+
+		listener := &ListenerConfig{
+			Port:                       port,
+			PrimaryCluster:             primaryCluster,
+			ReadMode:                   readMode,
+			MaxClientRequestsPerSecond: c.ProxyMaxClientRequestsPerSecond,
+		}
+		for _, option := range parts[1:] {
+			keyValue := strings.SplitN(option, "=", 2)
+			if len(keyValue) != 2 {
+				return nil, fmt.Errorf("invalid option %v for port %v in ZDM_PROXY_ADDITIONAL_LISTENERS; "+
+					"expected option=value", option, port)
+			}
+			key, value := strings.ToLower(strings.TrimSpace(keyValue[0])), strings.TrimSpace(keyValue[1])
+			var ok bool
+			switch key {
+			case ListenerOptionPrimaryCluster:
+				listener.PrimaryCluster, ok = parsePrimaryCluster(value)
+			case ListenerOptionReadMode:
+				listener.ReadMode, ok = parseReadMode(value)
+			case ListenerOptionMaxClientRequestsPerSecond:
+				listener.MaxClientRequestsPerSecond, err = strconv.Atoi(value)
+				ok = err == nil && listener.MaxClientRequestsPerSecond >= 0
+			default:
+				return nil, fmt.Errorf("invalid option %v for port %v in ZDM_PROXY_ADDITIONAL_LISTENERS; "+
+					"possible options are: %v, %v and %v", key, port,
+					ListenerOptionPrimaryCluster, ListenerOptionReadMode, ListenerOptionMaxClientRequestsPerSecond)
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid value for option %v of port %v in ZDM_PROXY_ADDITIONAL_LISTENERS (%v)",
+					key, port, value)
+			}
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
 func (c *Config) ParseControlConnMaxProtocolVersion() (primitive.ProtocolVersion, error) {
 	if strings.EqualFold(c.ControlConnMaxProtocolVersion, "DseV2") {
 		return primitive.ProtocolVersionDse2, nil
@@ -394,6 +1071,43 @@ func (c *Config) ParseControlConnMaxProtocolVersion() (primitive.ProtocolVersion
 	return primitive.ProtocolVersion(ver), nil
 }
 
+// ParseOriginPassword returns the origin password, reading it from ZDM_ORIGIN_PASSWORD_FILE if that is set.
+func (c *Config) ParseOriginPassword() (string, error) {
+	return parsePassword(c.OriginUsername, c.OriginPassword, c.OriginPasswordFile, "ORIGIN")
+}
+
+// ParseTargetPassword returns the target password, reading it from ZDM_TARGET_PASSWORD_FILE if that is set.
+func (c *Config) ParseTargetPassword() (string, error) {
+	return parsePassword(c.TargetUsername, c.TargetPassword, c.TargetPasswordFile, "TARGET")
+}
+
+// parsePassword reads the password from passwordFile if it is defined. This allows secrets to be provided by
+// the tooling that manages them (Vault Agent, Kubernetes secrets, AWS/GCP secrets store CSI drivers, etc.)
+// without exposing them in environment variables or in the configuration file.
+//
+// Exactly one of password and passwordFile is required when username is defined, without a username the cluster is
+// not using authentication.
+func parsePassword(username string, password string, passwordFile string, clusterType string) (string, error) {
+	if isNotDefined(passwordFile) {
+		if isDefined(username) && isNotDefined(password) {
+			return "", fmt.Errorf("invalid %v credentials configuration: ZDM_%v_PASSWORD or ZDM_%v_PASSWORD_FILE "+
+				"is required when ZDM_%v_USERNAME is set", strings.ToLower(clusterType), clusterType, clusterType, clusterType)
+		}
+		return password, nil
+	}
+
+	if isDefined(password) {
+		return "", fmt.Errorf("invalid %v credentials configuration: ZDM_%v_PASSWORD and ZDM_%v_PASSWORD_FILE "+
+			"are mutually exclusive, please specify only one of them", strings.ToLower(clusterType), clusterType, clusterType)
+	}
+
+	content, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read ZDM_%v_PASSWORD_FILE: %w", clusterType, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
 func (c *Config) ParseLogLevel() (log.Level, error) {
 	level, err := log.ParseLevel(strings.TrimSpace(c.LogLevel))
 	if err != nil {
@@ -405,6 +1119,24 @@ func (c *Config) ParseLogLevel() (log.Level, error) {
 	return level, nil
 }
 
+const (
+	LogFormatText = "TEXT"
+	LogFormatJson = "JSON"
+)
+
+// ParseLogFormat returns the logrus formatter of ZDM_LOG_FORMAT: TEXT or JSON.
+func (c *Config) ParseLogFormat() (log.Formatter, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.LogFormat)) {
+	case LogFormatText:
+		return &log.TextFormatter{}, nil
+	case LogFormatJson:
+		return &log.JSONFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("invalid value for ZDM_LOG_FORMAT (%v); valid values are %v and %v",
+			c.LogFormat, LogFormatText, LogFormatJson)
+	}
+}
+
 func (c *Config) ParseOriginBuckets() ([]float64, error) {
 	return c.parseBuckets(c.MetricsOriginLatencyBucketsMs)
 }
@@ -417,6 +1149,10 @@ func (c *Config) ParseAsyncBuckets() ([]float64, error) {
 	return c.parseBuckets(c.MetricsAsyncReadLatencyBucketsMs)
 }
 
+func (c *Config) ParseProxyOverheadBuckets() ([]float64, error) {
+	return c.parseBuckets(c.MetricsProxyOverheadBucketsMs)
+}
+
 func (c *Config) parseBuckets(bucketsConfigStr string) ([]float64, error) {
 	var bucketsArr []float64
 	bucketsStrArr := strings.Split(bucketsConfigStr, ",")
@@ -504,8 +1240,16 @@ func (c *Config) ParseTargetContactPoints() ([]string, error) {
 	return nil, nil
 }
 
+// parseContactPoints splits the comma separated contact points, IPv6 addresses can be written with or without
+// brackets (e.g. "[::1]" or "::1").
 func parseContactPoints(setting string) []string {
-	return strings.Split(strings.ReplaceAll(setting, " ", ""), ",")
+	contactPoints := strings.Split(strings.ReplaceAll(setting, " ", ""), ",")
+	for i, contactPoint := range contactPoints {
+		if strings.HasPrefix(contactPoint, "[") && strings.HasSuffix(contactPoint, "]") {
+			contactPoints[i] = contactPoint[1 : len(contactPoint)-1]
+		}
+	}
+	return contactPoints
 }
 
 func (c *Config) ParseOriginTlsConfig(displayLogMessages bool) (*common.ClusterTlsConfig, error) {
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_ParsePasswordFile(t *testing.T) {
+	defer clearAllEnvVars()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.Nil(t, os.WriteFile(passwordFile, []byte("secretPassword\n"), 0600))
+
+	type test struct {
+		name                   string
+		envVars                []envVar
+		expectedOriginPassword string
+		expectedTargetPassword string
+		errExpected            bool
+		errMsg                 string
+	}
+
+	tests := []test{
+		{
+			name: "Valid: passwords from env vars",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_PASSWORD", "targetPassword"},
+			},
+			expectedOriginPassword: "originPassword",
+			expectedTargetPassword: "targetPassword",
+		},
+		{
+			name: "Valid: origin password from file",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PASSWORD_FILE", passwordFile},
+				{"ZDM_TARGET_PASSWORD", "targetPassword"},
+			},
+			expectedOriginPassword: "secretPassword",
+			expectedTargetPassword: "targetPassword",
+		},
+		{
+			name: "Valid: target password from file",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_PASSWORD_FILE", passwordFile},
+			},
+			expectedOriginPassword: "originPassword",
+			expectedTargetPassword: "secretPassword",
+		},
+		{
+			name: "Invalid: both password and password file",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_ORIGIN_PASSWORD_FILE", passwordFile},
+			},
+			errExpected: true,
+			errMsg: "invalid origin credentials configuration: ZDM_ORIGIN_PASSWORD and ZDM_ORIGIN_PASSWORD_FILE " +
+				"are mutually exclusive, please specify only one of them",
+		},
+		{
+			name: "Invalid: password file does not exist",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing")},
+			},
+			errExpected: true,
+			errMsg:      "could not read ZDM_TARGET_PASSWORD_FILE",
+		},
+		{
+			name: "Invalid: no password nor password file",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PASSWORD", "originPassword"},
+			},
+			errExpected: true,
+			errMsg: "invalid target credentials configuration: ZDM_TARGET_PASSWORD or ZDM_TARGET_PASSWORD_FILE " +
+				"is required when ZDM_TARGET_USERNAME is set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			// set other general env vars
+			setEnvVar("ZDM_ORIGIN_USERNAME", "originUser")
+			setEnvVar("ZDM_TARGET_USERNAME", "targetUser")
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.Nil(t, err)
+
+			originPassword, err := conf.ParseOriginPassword()
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedOriginPassword, originPassword)
+
+			targetPassword, err := conf.ParseTargetPassword()
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedTargetPassword, targetPassword)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -231,3 +233,525 @@ proxy_listen_port: 39042
 	require.Equal(t, 39042, c.ProxyListenPort)
 	require.Equal(t, 4000, c.AsyncHandshakeTimeoutMs) // verify that defaults were applied
 }
+
+func TestConfig_ParsePrimaryClusterKeyspaceOverrides(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    map[string]common.ClusterType
+		errExpected bool
+	}{
+		{"unset", "", map[string]common.ClusterType{}, false},
+		{"single override", "ks1:TARGET", map[string]common.ClusterType{"ks1": common.ClusterTypeTarget}, false},
+		{"multiple overrides", " ks1 : target, Ks2:ORIGIN ", map[string]common.ClusterType{
+			"ks1": common.ClusterTypeTarget, "Ks2": common.ClusterTypeOrigin}, false},
+		{"missing cluster", "ks1", nil, true},
+		{"missing keyspace", ":TARGET", nil, true},
+		{"invalid cluster", "ks1:ASYNC", nil, true},
+		{"duplicate keyspace", "ks1:TARGET,ks1:ORIGIN", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_PRIMARY_CLUSTER_KEYSPACE_OVERRIDES", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_PRIMARY_CLUSTER_KEYSPACE_OVERRIDES")
+				return
+			}
+			require.Nil(t, err)
+
+			overrides, err := conf.ParsePrimaryClusterKeyspaceOverrides()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, overrides)
+		})
+	}
+}
+
+func TestConfig_ParseTableWritePolicies(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    map[string]TableWritePolicy
+		errExpected bool
+	}{
+		{"unset", "", map[string]TableWritePolicy{}, false},
+		{"keyspace", "ks1:origin", map[string]TableWritePolicy{
+			"ks1": {Clusters: WritePolicyOrigin}}, false},
+		{"multiple entries", " ks1.tb1:TARGET , ks2:BOTH:BEST_EFFORT, ks3:BOTH:FATAL", map[string]TableWritePolicy{
+			"ks1.tb1": {Clusters: WritePolicyTarget},
+			"ks2":     {Clusters: WritePolicyBoth, Failures: WriteFailuresBestEffort},
+			"ks3":     {Clusters: WritePolicyBoth, Failures: WriteFailuresFatal}}, false},
+		{"journal", "ks1:BOTH:journal", map[string]TableWritePolicy{
+			"ks1": {Clusters: WritePolicyBoth, Failures: WriteFailuresJournal}}, false},
+		{"missing clusters", "ks1", nil, true},
+		{"missing name", ":ORIGIN", nil, true},
+		{"invalid name", "ks1.tb1.c1:ORIGIN", nil, true},
+		{"invalid clusters", "ks1:ASYNC", nil, true},
+		{"invalid failures", "ks1:BOTH:IGNORE", nil, true},
+		{"best effort single cluster", "ks1:ORIGIN:BEST_EFFORT", nil, true},
+		{"journal single cluster", "ks1:TARGET:JOURNAL", nil, true},
+		{"duplicate entry", "ks1:ORIGIN,ks1:TARGET", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_TABLE_WRITE_POLICIES", tt.value)
+			setEnvVar("ZDM_DUAL_WRITES_JOURNAL_FILE", "journal")
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_TABLE_WRITE_POLICIES")
+				return
+			}
+			require.Nil(t, err)
+
+			policies, err := conf.ParseTableWritePolicies()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, policies)
+		})
+	}
+}
+
+func TestConfig_ParseTableRequestTimeouts(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    map[string]int
+		errExpected bool
+	}{
+		{"unset", "", map[string]int{}, false},
+		{"multiple entries", " ks1:2000 , ks1.tb1: 30000", map[string]int{"ks1": 2000, "ks1.tb1": 30000}, false},
+		{"missing timeout", "ks1", nil, true},
+		{"missing name", ":2000", nil, true},
+		{"invalid timeout", "ks1:2s", nil, true},
+		{"zero timeout", "ks1:0", nil, true},
+		{"duplicate entry", "ks1:2000,ks1:3000", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_TABLE_REQUEST_TIMEOUTS_MS", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_TABLE_REQUEST_TIMEOUTS_MS")
+				return
+			}
+			require.Nil(t, err)
+
+			timeouts, err := conf.ParseTableRequestTimeouts()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, timeouts)
+		})
+	}
+}
+
+func TestConfig_ParseIdempotencyOverrides(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    map[string]bool
+		errExpected bool
+	}{
+		{"unset", "", map[string]bool{}, false},
+		{"multiple entries", " ks1:non_idempotent , ks1.tb1:IDEMPOTENT", map[string]bool{"ks1": false, "ks1.tb1": true}, false},
+		{"missing idempotency", "ks1", nil, true},
+		{"missing name", ":IDEMPOTENT", nil, true},
+		{"invalid idempotency", "ks1:TRUE", nil, true},
+		{"duplicate entry", "ks1:IDEMPOTENT,ks1:NON_IDEMPOTENT", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_IDEMPOTENCY_OVERRIDES", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_IDEMPOTENCY_OVERRIDES")
+				return
+			}
+			require.Nil(t, err)
+
+			overrides, err := conf.ParseIdempotencyOverrides()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, overrides)
+		})
+	}
+}
+
+func TestConfig_ParseDualWritesResponseCluster(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    common.ClusterType
+		errExpected bool
+	}{
+		{"unset", "", common.ClusterTypeNone, false},
+		{"origin", "ORIGIN", common.ClusterTypeOrigin, false},
+		{"target lowercase", "target", common.ClusterTypeTarget, false},
+		{"invalid", "BOTH", common.ClusterTypeNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_DUAL_WRITES_RESPONSE_CLUSTER", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_DUAL_WRITES_RESPONSE_CLUSTER")
+				return
+			}
+			require.Nil(t, err)
+
+			cluster, err := conf.ParseDualWritesResponseCluster()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, cluster)
+		})
+	}
+}
+
+func TestConfig_ParseDualWritesFailures(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name          string
+		failures      string
+		writePolicies string
+		journalFile   string
+		expected      string
+		errExpected   string
+	}{
+		{"default", "", "", "", WriteFailuresFatal, ""},
+		{"best effort", "best_effort", "", "", WriteFailuresBestEffort, ""},
+		{"journal", "JOURNAL", "", "journal", WriteFailuresJournal, ""},
+		{"invalid", "IGNORE", "", "", "", "ZDM_DUAL_WRITES_FAILURES"},
+		{"journal without file", "JOURNAL", "", "", "", "ZDM_DUAL_WRITES_JOURNAL_FILE"},
+		{"table journal without file", "", "ks1:BOTH:JOURNAL", "", "", "ZDM_DUAL_WRITES_JOURNAL_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_DUAL_WRITES_FAILURES", tt.failures)
+			setEnvVar("ZDM_TABLE_WRITE_POLICIES", tt.writePolicies)
+			setEnvVar("ZDM_DUAL_WRITES_JOURNAL_FILE", tt.journalFile)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errExpected)
+				return
+			}
+			require.Nil(t, err)
+
+			failures, err := conf.ParseDualWritesFailures()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, failures)
+		})
+	}
+}
+
+func TestConfig_ParseTargetConsistencyLevelMapping(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    map[primitive.ConsistencyLevel]primitive.ConsistencyLevel
+		errExpected bool
+	}{
+		{"unset", "", map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{}, false},
+		{"single mapping", "LOCAL_ONE:LOCAL_QUORUM", map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{
+			primitive.ConsistencyLevelLocalOne: primitive.ConsistencyLevelLocalQuorum}, false},
+		{"multiple mappings", " one : quorum, SERIAL:LOCAL_SERIAL ", map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{
+			primitive.ConsistencyLevelOne:    primitive.ConsistencyLevelQuorum,
+			primitive.ConsistencyLevelSerial: primitive.ConsistencyLevelLocalSerial}, false},
+		{"missing target level", "ONE", nil, true},
+		{"unknown level", "ONE:FOUR", nil, true},
+		{"serial to non serial", "SERIAL:QUORUM", nil, true},
+		{"non serial to serial", "QUORUM:LOCAL_SERIAL", nil, true},
+		{"duplicate level", "ONE:QUORUM,ONE:ALL", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING")
+				return
+			}
+			require.Nil(t, err)
+
+			mapping, err := conf.ParseTargetConsistencyLevelMapping()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, mapping)
+		})
+	}
+}
+
+func TestConfig_ParseLogFormat(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    log.Formatter
+		errExpected bool
+	}{
+		{"default", "", &log.TextFormatter{}, false},
+		{"text", "TEXT", &log.TextFormatter{}, false},
+		{"json", " json ", &log.JSONFormatter{}, false},
+		{"invalid", "XML", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			if tt.value != "" {
+				setEnvVar("ZDM_LOG_FORMAT", tt.value)
+			}
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_LOG_FORMAT")
+				return
+			}
+			require.Nil(t, err)
+
+			formatter, err := conf.ParseLogFormat()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, formatter)
+		})
+	}
+}
+
+func TestConfig_GetProxyInstanceName(t *testing.T) {
+	defer clearAllEnvVars()
+
+	clearAllEnvVars()
+	setOriginCredentialsEnvVars()
+	setTargetCredentialsEnvVars()
+	setOriginContactPointsAndPortEnvVars()
+	setTargetContactPointsAndPortEnvVars()
+	conf, err := New().LoadConfig("")
+	require.Nil(t, err)
+	require.Equal(t, "", conf.GetProxyInstanceName())
+
+	setEnvVar("ZDM_PROXY_INSTANCE_NAME", " proxy-1 ")
+	conf, err = New().LoadConfig("")
+	require.Nil(t, err)
+	require.Equal(t, "proxy-1", conf.GetProxyInstanceName())
+}
+
+func TestConfig_ParseProxyClientAllowList(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    []string
+		errExpected bool
+	}{
+		{"unset", "", []string{}, false},
+		{"ipv4 address", "10.0.0.1", []string{"10.0.0.1/32"}, false},
+		{"ipv6 address", "2001:db8::1", []string{"2001:db8::1/128"}, false},
+		{"cidr ranges", " 10.0.0.0/8 , 2001:db8::/32 ", []string{"10.0.0.0/8", "2001:db8::/32"}, false},
+		{"invalid address", "10.0.0.256", nil, true},
+		{"invalid cidr range", "10.0.0.0/33", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_PROXY_CLIENT_ALLOW_LIST", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_PROXY_CLIENT_ALLOW_LIST")
+				return
+			}
+			require.Nil(t, err)
+
+			networks, err := conf.ParseProxyClientAllowList()
+			require.Nil(t, err)
+			parsed := make([]string, 0, len(networks))
+			for _, network := range networks {
+				parsed = append(parsed, network.String())
+			}
+			require.Equal(t, tt.expected, parsed)
+		})
+	}
+}
+
+func TestConfig_ParseExcludedTables(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    []string
+		errExpected bool
+	}{
+		{"unset", "", []string{}, false},
+		{"keyspaces and tables", " ks1, ks2.tb1 ", []string{"ks1", "ks2.tb1"}, false},
+		{"missing table", "ks1.", nil, true},
+		{"missing keyspace", ".tb1", nil, true},
+		{"too many parts", "ks1.tb1.col1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_EXCLUDED_TABLES", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "ZDM_EXCLUDED_TABLES")
+				return
+			}
+			require.Nil(t, err)
+
+			tables, err := conf.ParseExcludedTables()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, tables)
+		})
+	}
+}
+
+func TestConfig_ParseOriginContactPointsIpv6(t *testing.T) {
+	defer clearAllEnvVars()
+
+	clearAllEnvVars()
+	setOriginCredentialsEnvVars()
+	setTargetCredentialsEnvVars()
+	setTargetContactPointsAndPortEnvVars()
+	setEnvVar("ZDM_ORIGIN_CONTACT_POINTS", "::1, [fd00::2], 10.0.0.3")
+	setEnvVar("ZDM_ORIGIN_PORT", "9042")
+
+	conf, err := New().LoadConfig("")
+	require.Nil(t, err)
+
+	contactPoints, err := conf.ParseOriginContactPoints()
+	require.Nil(t, err)
+	require.Equal(t, []string{"::1", "fd00::2", "10.0.0.3"}, contactPoints)
+}
+
+func TestConfig_ParseProxyListeners(t *testing.T) {
+	defer clearAllEnvVars()
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    []*ListenerConfig
+		errExpected string
+	}{
+		{"unset", "", []*ListenerConfig{
+			{Port: 14002, PrimaryCluster: common.ClusterTypeOrigin, ReadMode: common.ReadModePrimaryOnly, MaxClientRequestsPerSecond: 100},
+		}, ""},
+		{"additional ports with options", " 9043, 9044:primary_cluster=target:read_mode=DUAL_ASYNC_ON_SECONDARY:max_client_requests_per_second=0", []*ListenerConfig{
+			{Port: 14002, PrimaryCluster: common.ClusterTypeOrigin, ReadMode: common.ReadModePrimaryOnly, MaxClientRequestsPerSecond: 100},
+			{Port: 9043, PrimaryCluster: common.ClusterTypeOrigin, ReadMode: common.ReadModePrimaryOnly, MaxClientRequestsPerSecond: 100},
+			{Port: 9044, PrimaryCluster: common.ClusterTypeTarget, ReadMode: common.ReadModeDualAsyncOnSecondary, MaxClientRequestsPerSecond: 0},
+		}, ""},
+		{"invalid port", "abc", nil, "expected a comma separated list of port[:option=value...] entries"},
+		{"duplicate of the listen port", "14002", nil, "duplicate port 14002"},
+		{"duplicate port", "9043,9043", nil, "duplicate port 9043"},
+		{"unknown option", "9043:foo=bar", nil, "invalid option foo for port 9043"},
+		{"missing value", "9043:read_mode", nil, "invalid option read_mode for port 9043"},
+		{"invalid primary cluster", "9043:primary_cluster=BOTH", nil, "invalid value for option primary_cluster of port 9043"},
+		{"negative rate", "9043:max_client_requests_per_second=-1", nil, "invalid value for option max_client_requests_per_second of port 9043"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_PROXY_MAX_CLIENT_REQUESTS_PER_SECOND", "100")
+			setEnvVar("ZDM_PROXY_ADDITIONAL_LISTENERS", tt.value)
+
+			conf, err := New().LoadConfig("")
+			if tt.errExpected != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errExpected)
+				return
+			}
+			require.Nil(t, err)
+
+			listeners, err := conf.ParseProxyListeners()
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, listeners)
+		})
+	}
+}
@@ -29,9 +29,10 @@ type ControlConnStatus struct {
 type Status string
 
 const (
-	UP      = Status("UP")
-	DOWN    = Status("DOWN")
-	STARTUP = Status("STARTUP")
+	UP       = Status("UP")
+	DOWN     = Status("DOWN")
+	STARTUP  = Status("STARTUP")
+	DRAINING = Status("DRAINING")
 )
 
 func ReadinessHandler(proxy *zdmproxy.ZdmProxy) http.Handler {
@@ -85,7 +86,10 @@ func PerformHealthCheck(proxy *zdmproxy.ZdmProxy) *StatusReport {
 	originControlConnStatus := newControlConnStatus(originControlConn, proxy.Conf.HeartbeatFailureThreshold)
 	targetControlConnStatus := newControlConnStatus(targetControlConn, proxy.Conf.HeartbeatFailureThreshold)
 	status := UP
-	if originControlConnStatus.Status != UP || targetControlConnStatus.Status != UP {
+	if proxy.IsShuttingDown() {
+		// stop receiving new client connections from load balancers while the open ones are drained
+		status = DRAINING
+	} else if originControlConnStatus.Status != UP || targetControlConnStatus.Status != UP {
 		status = DOWN
 	}
 	return &StatusReport{
@@ -0,0 +1,49 @@
+package httpzdmproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+type LogLevelReport struct {
+	Level string `json:"level"`
+}
+
+// LogLevelHandler returns the current log level on GET requests and changes it on PUT requests with a level query
+// parameter (e.g. PUT /debug/log-level?level=debug). The change is not persisted, the proxy uses the configured log
+// level again when it restarts. POST requests are not accepted because browsers send them cross-origin without a
+// preflight request, so a web page could change the log level.
+func LogLevelHandler() http.Handler {
+	return http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			level, err := log.ParseLevel(strings.TrimSpace(req.URL.Query().Get("level")))
+			if err != nil {
+				http.Error(rsp, fmt.Sprintf("Invalid log level: %v", err), http.StatusBadRequest)
+				return
+			}
+			if level != log.GetLevel() {
+				log.Infof("Changing log level from %v to %v.", log.GetLevel(), level)
+				log.SetLevel(level)
+			}
+		default:
+			http.NotFound(rsp, req)
+			return
+		}
+
+		bytes, err := json.Marshal(&LogLevelReport{Level: log.GetLevel().String()})
+		if err != nil {
+			log.Errorf("Could not encode log level: %v", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		rsp.Header().Set("Content-Type", "application/json")
+		rsp.WriteHeader(http.StatusOK)
+		rsp.Write(bytes)
+	})
+}
@@ -0,0 +1,45 @@
+package httpzdmproxy
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+	handler := LogLevelHandler()
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		status   int
+		expected log.Level
+	}{
+		{"get", http.MethodGet, "/debug/log-level", http.StatusOK, log.InfoLevel},
+		{"put", http.MethodPut, "/debug/log-level?level=debug", http.StatusOK, log.DebugLevel},
+		{"put upper case", http.MethodPut, "/debug/log-level?level=WARN", http.StatusOK, log.WarnLevel},
+		{"post", http.MethodPost, "/debug/log-level?level=info", http.StatusNotFound, log.WarnLevel},
+		{"invalid level", http.MethodPut, "/debug/log-level?level=verbose", http.StatusBadRequest, log.WarnLevel},
+		{"missing level", http.MethodPut, "/debug/log-level", http.StatusBadRequest, log.WarnLevel},
+		{"delete", http.MethodDelete, "/debug/log-level", http.StatusNotFound, log.WarnLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, httptest.NewRequest(tt.method, tt.url, nil))
+			require.Equal(t, tt.status, rsp.Code)
+			require.Equal(t, tt.expected, log.GetLevel())
+			if tt.status == http.StatusOK {
+				var report LogLevelReport
+				require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &report))
+				require.Equal(t, tt.expected.String(), report.Level)
+			}
+		})
+	}
+}
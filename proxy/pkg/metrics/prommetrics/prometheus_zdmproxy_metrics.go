@@ -33,6 +33,19 @@ func NewPrometheusMetricFactory(registerer prometheus.Registerer, metricsPrefix
 	return m
 }
 
+// ProxyInstanceLabel is the label with the name of the proxy instance (ZDM_PROXY_INSTANCE_NAME) that is added to every
+// metric by the registerer of NewProxyInstanceRegisterer.
+const ProxyInstanceLabel = "proxy_instance"
+
+// NewProxyInstanceRegisterer returns a registerer that adds the proxy_instance label to every metric registered with
+// it, the provided registerer is returned as is if instanceName is empty.
+func NewProxyInstanceRegisterer(registerer prometheus.Registerer, instanceName string) prometheus.Registerer {
+	if instanceName == "" {
+		return registerer
+	}
+	return prometheus.WrapRegistererWith(prometheus.Labels{ProxyInstanceLabel: instanceName}, registerer)
+}
+
 /***
 	Methods for adding metrics
  ***/
@@ -335,6 +335,36 @@ func TestPrometheusZdmProxyMetrics_UnregisterAllMetrics(t *testing.T) {
 	assert.Len(t, gather, 0)
 }
 
+func TestPrometheusZdmProxyMetrics_ProxyInstanceLabel(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler := NewPrometheusMetricFactory(NewProxyInstanceRegisterer(registry, "proxy-1"), "zdm")
+	_, err := handler.GetOrCreateCounter(newTestMetric("test_counter"))
+	require.Nil(t, err)
+	_, err = handler.GetOrCreateCounter(newTestMetricWithLabels("test_counter_with_labels", map[string]string{"counter_type": "counter1"}))
+	require.Nil(t, err)
+	_, err = handler.GetOrCreateCounter(newTestMetricWithLabels("test_counter_with_labels", map[string]string{"counter_type": "counter2"}))
+	require.Nil(t, err)
+
+	gather, err := registry.Gather()
+	require.Nil(t, err)
+	require.Len(t, gather, 2)
+	for _, family := range gather {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assert.Equal(t, "proxy-1", labels[ProxyInstanceLabel], family.GetName())
+		}
+	}
+
+	require.Nil(t, handler.UnregisterAllMetrics())
+	gather, err = registry.Gather()
+	require.Nil(t, err)
+	assert.Empty(t, gather)
+
+	assert.Equal(t, prometheus.Registerer(registry), NewProxyInstanceRegisterer(registry, ""))
+}
 func getCounterValue(counter prometheus.Counter) (float64, error) {
 	var m = &dto.Metric{}
 	if err := counter.Write(m); err != nil {
@@ -22,9 +22,30 @@ const (
 	RequestDurationTypeLabel   = "type"
 	requestDurationDescription = "Histogram that tracks the latency of requests at proxy entry point"
 
+	requestOverheadName        = "proxy_request_overhead_seconds"
+	requestOverheadDescription = "Histogram that tracks the latency that the proxy adds to requests: the time since " +
+		"the request was received from the client minus the time spent waiting for the cluster responses"
+
 	inFlightRequestsName        = "proxy_inflight_requests_total"
 	inFlightRequestsTypeLabel   = "type"
 	inFlightRequestsDescription = "Number of requests currently in flight in the proxy"
+
+	dualReadsComparisonsName        = "proxy_dual_reads_comparisons_total"
+	dualReadsComparisonsDescription = "Running total of asynchronous dual read results compared with the primary cluster results"
+	dualReadsComparisonsResultLabel = "result"
+
+	unparseableRequestsName        = "proxy_unparseable_requests_total"
+	unparseableRequestsDescription = "Running total of requests that the proxy could not fully inspect"
+	unparseableRequestsReasonLabel = "reason"
+
+	closedClientConnectionsName        = "proxy_client_connections_closed_total"
+	closedClientConnectionsDescription = "Running total of client connections that were closed"
+	closedClientConnectionsReasonLabel = "reason"
+
+	ClientConnectionCloseReasonClientDisconnected = "client_disconnected"
+	ClientConnectionCloseReasonIdleTimeout        = "idle_timeout"
+	ClientConnectionCloseReasonShutdown           = "shutdown"
+	ClientConnectionCloseReasonError              = "error"
 )
 
 var (
@@ -95,6 +116,28 @@ var (
 		},
 	)
 
+	ProxyReadsOriginOverhead = NewMetricWithLabels(
+		requestOverheadName,
+		requestOverheadDescription,
+		map[string]string{
+			RequestDurationTypeLabel: typeReadsOrigin,
+		},
+	)
+	ProxyReadsTargetOverhead = NewMetricWithLabels(
+		requestOverheadName,
+		requestOverheadDescription,
+		map[string]string{
+			RequestDurationTypeLabel: typeReadsTarget,
+		},
+	)
+	ProxyWritesOverhead = NewMetricWithLabels(
+		requestOverheadName,
+		requestOverheadDescription,
+		map[string]string{
+			RequestDurationTypeLabel: TypeWrites,
+		},
+	)
+
 	InFlightReadsOrigin = NewMetricWithLabels(
 		inFlightRequestsName,
 		inFlightRequestsDescription,
@@ -121,6 +164,94 @@ var (
 		"client_connections_total",
 		"Number of client connections currently open",
 	)
+
+	OpenedClientConnections = NewMetric(
+		"proxy_client_connections_opened_total",
+		"Running total of client connections that were accepted",
+	)
+	RejectedClientConnections = NewMetric(
+		"proxy_client_connections_rejected_total",
+		"Running total of client connections that were refused (max client connections, allow and deny lists or setup errors)",
+	)
+	ClientConnectionsClosedByClient = NewMetricWithLabels(
+		closedClientConnectionsName,
+		closedClientConnectionsDescription,
+		map[string]string{
+			closedClientConnectionsReasonLabel: ClientConnectionCloseReasonClientDisconnected,
+		},
+	)
+	ClientConnectionsClosedByIdleTimeout = NewMetricWithLabels(
+		closedClientConnectionsName,
+		closedClientConnectionsDescription,
+		map[string]string{
+			closedClientConnectionsReasonLabel: ClientConnectionCloseReasonIdleTimeout,
+		},
+	)
+	ClientConnectionsClosedByShutdown = NewMetricWithLabels(
+		closedClientConnectionsName,
+		closedClientConnectionsDescription,
+		map[string]string{
+			closedClientConnectionsReasonLabel: ClientConnectionCloseReasonShutdown,
+		},
+	)
+	ClientConnectionsClosedByError = NewMetricWithLabels(
+		closedClientConnectionsName,
+		closedClientConnectionsDescription,
+		map[string]string{
+			closedClientConnectionsReasonLabel: ClientConnectionCloseReasonError,
+		},
+	)
+	ClientConnectionDuration = NewMetric(
+		"proxy_client_connection_duration_seconds",
+		"Histogram that tracks how long client connections stay open",
+	)
+
+	DualReadsMatches = NewMetricWithLabels(
+		dualReadsComparisonsName,
+		dualReadsComparisonsDescription,
+		map[string]string{
+			dualReadsComparisonsResultLabel: "match",
+		},
+	)
+	DualReadsMismatches = NewMetricWithLabels(
+		dualReadsComparisonsName,
+		dualReadsComparisonsDescription,
+		map[string]string{
+			dualReadsComparisonsResultLabel: "mismatch",
+		},
+	)
+
+	RateLimitedRequests = NewMetric(
+		"proxy_rate_limited_requests_total",
+		"Running total of client requests rejected with an OVERLOADED error because of the per connection request rate limit",
+	)
+	DryRunSkippedWrites = NewMetric(
+		"proxy_dry_run_skipped_writes_total",
+		"Running total of writes that were only sent to ORIGIN because of ZDM_DRY_RUN",
+	)
+	SuppressedDuplicateWrites = NewMetric(
+		"proxy_suppressed_duplicate_writes_total",
+		"Running total of retried writes that were only sent to ORIGIN because of ZDM_TARGET_DUPLICATE_WRITES_WINDOW_MS",
+	)
+	TargetWritePacingRate = NewMetric(
+		"proxy_target_write_pacing_rate",
+		"Maximum number of writes per second sent to both clusters because TARGET is throttling writes, 0 when writes are not paced (see ZDM_TARGET_WRITES_ADAPTIVE_PACING)",
+	)
+
+	UnrecognizedStatements = NewMetricWithLabels(
+		unparseableRequestsName,
+		unparseableRequestsDescription,
+		map[string]string{
+			unparseableRequestsReasonLabel: "unrecognized_statement",
+		},
+	)
+	RequestDecodeErrors = NewMetricWithLabels(
+		unparseableRequestsName,
+		unparseableRequestsDescription,
+		map[string]string{
+			unparseableRequestsReasonLabel: "decode_error",
+		},
+	)
 )
 
 type ProxyMetrics struct {
@@ -137,9 +268,33 @@ type ProxyMetrics struct {
 	ProxyReadsTargetDuration Histogram
 	ProxyWritesDuration      Histogram
 
+	ProxyReadsOriginOverhead Histogram
+	ProxyReadsTargetOverhead Histogram
+	ProxyWritesOverhead      Histogram
+
 	InFlightReadsOrigin Gauge
 	InFlightReadsTarget Gauge
 	InFlightWrites      Gauge
 
 	OpenClientConnections GaugeFunc
+
+	OpenedClientConnections              Counter
+	RejectedClientConnections            Counter
+	ClientConnectionsClosedByClient      Counter
+	ClientConnectionsClosedByIdleTimeout Counter
+	ClientConnectionsClosedByShutdown    Counter
+	ClientConnectionsClosedByError       Counter
+	ClientConnectionDuration             Histogram
+
+	DualReadsMatches    Counter
+	DualReadsMismatches Counter
+
+	RateLimitedRequests Counter
+
+	DryRunSkippedWrites       Counter
+	SuppressedDuplicateWrites Counter
+	TargetWritePacingRate     GaugeFunc
+
+	UnrecognizedStatements Counter
+	RequestDecodeErrors    Counter
 }
@@ -3,7 +3,6 @@ package runner
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/datastax/zdm-proxy/proxy/pkg/health"
 	"github.com/datastax/zdm-proxy/proxy/pkg/httpzdmproxy"
@@ -11,16 +10,21 @@ import (
 	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
 	"github.com/jpillora/backoff"
 	log "github.com/sirupsen/logrus"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
 
 var (
-	metricsHandler   = httpzdmproxy.NewHandlerWithFallback(metrics.DefaultHttpHandler())
-	readinessHandler = httpzdmproxy.NewHandlerWithFallback(health.DefaultReadinessHandler())
-	registerHandler  = &sync.Mutex{}
-	registered       = false
+	metricsHandler             = httpzdmproxy.NewHandlerWithFallback(metrics.DefaultHttpHandler())
+	readinessHandler           = httpzdmproxy.NewHandlerWithFallback(health.DefaultReadinessHandler())
+	unparseableRequestsHandler = httpzdmproxy.NewHandlerWithFallback(zdmproxy.UnparseableRequestsHandler(nil))
+	topStatementsHandler       = httpzdmproxy.NewHandlerWithFallback(zdmproxy.TopStatementsHandler(nil))
+	logLevelHandler            = httpzdmproxy.NewHandlerWithFallback(http.NotFoundHandler())
+	registerHandler            = &sync.Mutex{}
+	registered                 = false
 )
 
 func SetupHandlers() (*httpzdmproxy.HandlerWithFallback, *httpzdmproxy.HandlerWithFallback) {
@@ -33,18 +37,27 @@ func SetupHandlers() (*httpzdmproxy.HandlerWithFallback, *httpzdmproxy.HandlerWi
 	http.Handle("/metrics", metricsHandler.Handler())
 	http.Handle("/health/readiness", readinessHandler.Handler())
 	http.Handle("/health/liveness", health.LivenessHandler())
+	http.Handle("/debug/unparseable-requests", unparseableRequestsHandler.Handler())
+	http.Handle("/debug/top-statements", topStatementsHandler.Handler())
+	http.Handle("/debug/log-level", logLevelHandler.Handler())
 	return metricsHandler, readinessHandler
 }
 
+// RunMain starts the proxy and the http server and shuts them down when ctx is done. It returns
+// zdmproxy.DrainTimeoutErr if the client connections were not drained within the shutdown grace period.
 func RunMain(
 	conf *config.Config,
 	ctx context.Context,
 	metricsHandler *httpzdmproxy.HandlerWithFallback,
-	readinessHandler *httpzdmproxy.HandlerWithFallback) {
+	readinessHandler *httpzdmproxy.HandlerWithFallback) error {
 
 	log.Infof("Starting http server (metrics and health checks) on %v:%d", conf.MetricsAddress, conf.MetricsPort)
 	wg := &sync.WaitGroup{}
-	srv := httpzdmproxy.StartHttpServer(fmt.Sprintf("%s:%d", conf.MetricsAddress, conf.MetricsPort), wg)
+	srv := httpzdmproxy.StartHttpServer(net.JoinHostPort(conf.MetricsAddress, strconv.Itoa(conf.MetricsPort)), wg)
+	if conf.LogLevelEndpointEnabled {
+		logLevelHandler.SetHandler(httpzdmproxy.LogLevelHandler())
+		defer logLevelHandler.ClearHandler()
+	}
 
 	b := &backoff.Backoff{
 		Min:    100 * time.Millisecond,
@@ -55,26 +68,33 @@ func RunMain(
 
 	zdmProxy, err := zdmproxy.RunWithRetries(conf, ctx, b)
 
+	var shutdownErr error
 	if err == nil {
 		metricsHandler.SetHandler(zdmProxy.GetMetricHandler().GetHttpHandler())
 		readinessHandler.SetHandler(health.ReadinessHandler(zdmProxy))
+		unparseableRequestsHandler.SetHandler(zdmProxy.GetUnparseableRequests().GetHttpHandler())
+		topStatementsHandler.SetHandler(zdmProxy.GetTopStatements().GetHttpHandler())
 
 		log.Info("Proxy started. Waiting for SIGINT/SIGTERM to shutdown.")
 		<-ctx.Done()
 
-		zdmProxy.Shutdown()
+		shutdownErr = zdmProxy.Shutdown()
 		metricsHandler.ClearHandler()
 		readinessHandler.ClearHandler()
+		unparseableRequestsHandler.ClearHandler()
+		topStatementsHandler.ClearHandler()
 	} else if !errors.Is(err, zdmproxy.ShutdownErr) {
 		log.Errorf("Error launching proxy: %v", err)
 	}
 
 	log.Info("Shutting down httpzdmproxy server, waiting up to 5 seconds.")
-	srvShutdownCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	srvShutdownCtx, srvShutdownCancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer srvShutdownCancelFn()
 	if err := srv.Shutdown(srvShutdownCtx); err != nil {
 		log.Errorf("Failed to gracefully shutdown httpzdmproxy server: %v", err)
 	}
 
 	wg.Wait()
 	log.Info("Http server shutdown.")
+	return shutdownErr
 }
@@ -0,0 +1,480 @@
+// Package workload generates CQL traffic (a configurable mix of reads, writes and batches sent as simple or prepared
+// statements at a target throughput) and measures its throughput and latencies. It is used by the integration tests
+// and by tools/zdm-loadgen to load and soak test the proxy with reproducible numbers: the sequence of requests sent by
+// every worker only depends on the configuration and its seed.
+package workload
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLatencySamples is the number of latencies kept to compute the percentiles, requests beyond that are sampled.
+const maxLatencySamples = 100000
+
+type Config struct {
+	Keyspace string
+	Table    string
+
+	ReadPercent     int // percentage of the requests that are reads, the other requests are writes
+	PreparedPercent int // percentage of the requests sent as prepared statements (EXECUTE) instead of simple statements
+	BatchPercent    int // percentage of the writes sent as batches of BatchSize inserts
+	BatchSize       int
+
+	Partitions int // number of partition keys that the requests are spread over
+	ValueSize  int // size in bytes of the written values
+
+	Connections          int
+	ConcurrencyPerConn   int     // number of workers per connection, every worker has a single request in flight
+	RequestsPerSecond    float64 // target throughput of all connections, 0 sends requests as fast as possible
+	Duration             time.Duration
+	Requests             int64 // the workload stops after Duration or after sending this many requests, 0 means no limit
+	Seed                 int64
+	ProtocolVersion      primitive.ProtocolVersion
+	Consistency          primitive.ConsistencyLevel
+	StopOnFirstError     bool
+	CreateSchemaIfNeeded bool
+}
+
+// NewConfig returns a configuration with a 50/50 mix of simple reads and writes on 1000 partitions.
+func NewConfig() *Config {
+	return &Config{
+		Keyspace:           "zdm_workload",
+		Table:              "kv",
+		ReadPercent:        50,
+		PreparedPercent:    0,
+		BatchPercent:       0,
+		BatchSize:          5,
+		Partitions:         1000,
+		ValueSize:          100,
+		Connections:        1,
+		ConcurrencyPerConn: 1,
+		RequestsPerSecond:  0,
+		Duration:           10 * time.Second,
+		Requests:           0,
+		Seed:               1,
+		ProtocolVersion:    primitive.ProtocolVersion4,
+		Consistency:        primitive.ConsistencyLevelLocalQuorum,
+	}
+}
+
+func (c *Config) Validate() error {
+	for name, percent := range map[string]int{
+		"read percent": c.ReadPercent, "prepared percent": c.PreparedPercent, "batch percent": c.BatchPercent} {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("invalid %v %v, it must be between 0 and 100", name, percent)
+		}
+	}
+	if c.Keyspace == "" || c.Table == "" {
+		return errors.New("the keyspace and table are required")
+	}
+	if c.BatchPercent > 0 && c.BatchSize < 1 {
+		return fmt.Errorf("invalid batch size %v, it must be positive", c.BatchSize)
+	}
+	if c.Partitions < 1 {
+		return fmt.Errorf("invalid number of partitions %v, it must be positive", c.Partitions)
+	}
+	if c.ValueSize < 0 {
+		return fmt.Errorf("invalid value size %v, it must not be negative", c.ValueSize)
+	}
+	if c.Connections < 1 || c.ConcurrencyPerConn < 1 {
+		return fmt.Errorf("invalid number of connections (%v) or concurrency per connection (%v), they must be positive",
+			c.Connections, c.ConcurrencyPerConn)
+	}
+	if c.RequestsPerSecond < 0 {
+		return fmt.Errorf("invalid requests per second %v, it must not be negative", c.RequestsPerSecond)
+	}
+	if c.Duration <= 0 && c.Requests <= 0 {
+		return errors.New("a duration or a number of requests is required")
+	}
+	return nil
+}
+
+type OperationType int
+
+const (
+	OperationRead = OperationType(iota)
+	OperationWrite
+	OperationBatch
+)
+
+func (o OperationType) String() string {
+	switch o {
+	case OperationRead:
+		return "READ"
+	case OperationWrite:
+		return "WRITE"
+	case OperationBatch:
+		return "BATCH"
+	}
+	return fmt.Sprintf("OperationType(%d)", int(o))
+}
+
+// Result holds the numbers of a workload run.
+type Result struct {
+	Duration   time.Duration
+	Requests   map[OperationType]int64
+	Errors     map[OperationType]int64
+	FirstError error
+
+	latencies      []time.Duration
+	latencySamples int64
+}
+
+func (r *Result) TotalRequests() int64 {
+	total := int64(0)
+	for _, n := range r.Requests {
+		total += n
+	}
+	return total
+}
+
+func (r *Result) TotalErrors() int64 {
+	total := int64(0)
+	for _, n := range r.Errors {
+		total += n
+	}
+	return total
+}
+
+// Throughput returns the number of requests per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.TotalRequests()) / r.Duration.Seconds()
+}
+
+// Percentile returns the latency of the provided percentile (between 0 and 100) of the successful requests.
+func (r *Result) Percentile(percentile float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	idx := int(percentile / 100 * float64(len(r.latencies)))
+	if idx >= len(r.latencies) {
+		idx = len(r.latencies) - 1
+	} else if idx < 0 {
+		idx = 0
+	}
+	return r.latencies[idx]
+}
+
+func (r *Result) String() string {
+	sb := &strings.Builder{}
+	_, _ = fmt.Fprintf(sb, "%d requests (%d errors) in %v, %.1f requests/s", r.TotalRequests(), r.TotalErrors(),
+		r.Duration.Round(time.Millisecond), r.Throughput())
+	for _, op := range []OperationType{OperationRead, OperationWrite, OperationBatch} {
+		if r.Requests[op] > 0 {
+			_, _ = fmt.Fprintf(sb, ", %v: %d (%d errors)", op, r.Requests[op], r.Errors[op])
+		}
+	}
+	_, _ = fmt.Fprintf(sb, ", latency p50: %v, p95: %v, p99: %v, max: %v",
+		r.Percentile(50), r.Percentile(95), r.Percentile(99), r.Percentile(100))
+	return sb.String()
+}
+
+// Run sends the workload to the cluster (or proxy) of the provided client and returns its result. The run stops early
+// if the context is cancelled. An error is returned if the connections can not be opened, if the schema can not be
+// created or if the statements can not be prepared; request errors are counted in the result.
+func Run(ctx context.Context, cqlClient *client.CqlClient, conf *Config) (*Result, error) {
+	err := conf.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	conns := make([]*client.CqlClientConnection, 0, conf.Connections)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	for i := 0; i < conf.Connections; i++ {
+		conn, err := cqlClient.ConnectAndInit(ctx, conf.ProtocolVersion, client.ManagedStreamId)
+		if err != nil {
+			return nil, fmt.Errorf("could not open connection %d: %w", i, err)
+		}
+		conns = append(conns, conn)
+	}
+
+	stmts := newStatements(conf)
+	if conf.CreateSchemaIfNeeded {
+		for _, query := range stmts.schema {
+			err = checkResponse(conns[0].SendAndReceive(stmts.newQuery(query, nil, conf.Consistency)))
+			if err != nil {
+				return nil, fmt.Errorf("could not create schema (%v): %w", query, err)
+			}
+		}
+	}
+
+	preparedIds := make([]map[string][]byte, len(conns))
+	if conf.PreparedPercent > 0 {
+		for i, conn := range conns {
+			preparedIds[i], err = stmts.prepare(conn)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	runCtx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	if conf.Duration > 0 {
+		runCtx, cancelFn = context.WithTimeout(runCtx, conf.Duration)
+		defer cancelFn()
+	}
+
+	workers := conf.Connections * conf.ConcurrencyPerConn
+	var interval time.Duration
+	if conf.RequestsPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) * float64(workers) / conf.RequestsPerSecond)
+	}
+
+	aggregator := newResultAggregator(conf.Seed)
+	remaining := &requestBudget{remaining: conf.Requests, unlimited: conf.Requests <= 0}
+	wg := &sync.WaitGroup{}
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		w := &worker{
+			conf:        conf,
+			stmts:       stmts,
+			conn:        conns[i%len(conns)],
+			preparedIds: preparedIds[i%len(conns)],
+			rand:        rand.New(rand.NewSource(conf.Seed + int64(i))),
+			interval:    interval,
+			// spread the first requests of the workers over the first interval
+			next: start.Add(time.Duration(int64(interval) * int64(i) / int64(workers))),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.run(runCtx, cancelFn, remaining, aggregator)
+		}()
+	}
+	wg.Wait()
+
+	return aggregator.result(time.Since(start)), nil
+}
+
+type statements struct {
+	conf   *Config
+	schema []string
+	read   string
+	write  string
+}
+
+func newStatements(conf *Config) *statements {
+	table := fmt.Sprintf("%s.%s", conf.Keyspace, conf.Table)
+	return &statements{
+		conf: conf,
+		schema: []string{
+			fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = "+
+				"{'class': 'SimpleStrategy', 'replication_factor': 1}", conf.Keyspace),
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (pk bigint, ck bigint, value blob, PRIMARY KEY (pk, ck))", table),
+		},
+		read:  fmt.Sprintf("SELECT pk, ck, value FROM %s WHERE pk = ? LIMIT 10", table),
+		write: fmt.Sprintf("INSERT INTO %s (pk, ck, value) VALUES (?, ?, ?)", table),
+	}
+}
+
+func (recv *statements) prepare(conn *client.CqlClientConnection) (map[string][]byte, error) {
+	ids := make(map[string][]byte)
+	for _, query := range []string{recv.read, recv.write} {
+		response, err := conn.SendAndReceive(
+			frame.NewFrame(recv.conf.ProtocolVersion, client.ManagedStreamId, &message.Prepare{Query: query}))
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare %v: %w", query, err)
+		}
+		prepared, ok := response.Body.Message.(*message.PreparedResult)
+		if !ok {
+			return nil, fmt.Errorf("could not prepare %v: %v", query, response.Body.Message)
+		}
+		ids[query] = prepared.PreparedQueryId
+	}
+	return ids, nil
+}
+
+func (recv *statements) newQuery(query string, values []*primitive.Value, consistency primitive.ConsistencyLevel) *frame.Frame {
+	return frame.NewFrame(recv.conf.ProtocolVersion, client.ManagedStreamId, &message.Query{
+		Query:   query,
+		Options: &message.QueryOptions{Consistency: consistency, PositionalValues: values},
+	})
+}
+
+func (recv *statements) newExecute(id []byte, values []*primitive.Value) *frame.Frame {
+	return frame.NewFrame(recv.conf.ProtocolVersion, client.ManagedStreamId, &message.Execute{
+		QueryId: id,
+		Options: &message.QueryOptions{Consistency: recv.conf.Consistency, PositionalValues: values},
+	})
+}
+
+type worker struct {
+	conf        *Config
+	stmts       *statements
+	conn        *client.CqlClientConnection
+	preparedIds map[string][]byte
+	rand        *rand.Rand
+	interval    time.Duration
+	next        time.Time
+}
+
+func (recv *worker) run(
+	ctx context.Context, cancelFn context.CancelFunc, budget *requestBudget, aggregator *resultAggregator) {
+	for budget.take() {
+		if recv.interval > 0 {
+			timer := time.NewTimer(time.Until(recv.next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			recv.next = recv.next.Add(recv.interval)
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		op, request := recv.nextRequest()
+		start := time.Now()
+		err := checkResponse(recv.conn.SendAndReceive(request))
+		if ctx.Err() != nil && err != nil {
+			// the request was interrupted by the end of the run
+			return
+		}
+		aggregator.record(op, time.Since(start), err)
+		if err != nil && recv.conf.StopOnFirstError {
+			cancelFn()
+			return
+		}
+	}
+}
+
+func (recv *worker) nextRequest() (OperationType, *frame.Frame) {
+	prepared := recv.rand.Intn(100) < recv.conf.PreparedPercent
+	if recv.rand.Intn(100) < recv.conf.ReadPercent {
+		values := []*primitive.Value{recv.bigint(recv.rand.Int63n(int64(recv.conf.Partitions)))}
+		if prepared {
+			return OperationRead, recv.stmts.newExecute(recv.preparedIds[recv.stmts.read], values)
+		}
+		return OperationRead, recv.stmts.newQuery(recv.stmts.read, values, recv.conf.Consistency)
+	}
+
+	if recv.rand.Intn(100) < recv.conf.BatchPercent {
+		batch := &message.Batch{Type: primitive.BatchTypeUnlogged, Consistency: recv.conf.Consistency}
+		for i := 0; i < recv.conf.BatchSize; i++ {
+			child := &message.BatchChild{Values: recv.writeValues()}
+			if prepared {
+				child.Id = recv.preparedIds[recv.stmts.write]
+			} else {
+				child.Query = recv.stmts.write
+			}
+			batch.Children = append(batch.Children, child)
+		}
+		return OperationBatch, frame.NewFrame(recv.conf.ProtocolVersion, client.ManagedStreamId, batch)
+	}
+
+	if prepared {
+		return OperationWrite, recv.stmts.newExecute(recv.preparedIds[recv.stmts.write], recv.writeValues())
+	}
+	return OperationWrite, recv.stmts.newQuery(recv.stmts.write, recv.writeValues(), recv.conf.Consistency)
+}
+
+func (recv *worker) writeValues() []*primitive.Value {
+	value := make([]byte, recv.conf.ValueSize)
+	recv.rand.Read(value)
+	return []*primitive.Value{
+		recv.bigint(recv.rand.Int63n(int64(recv.conf.Partitions))),
+		recv.bigint(recv.rand.Int63()),
+		primitive.NewValue(value),
+	}
+}
+
+func (recv *worker) bigint(v int64) *primitive.Value {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(v))
+	return primitive.NewValue(buf)
+}
+
+func checkResponse(response *frame.Frame, err error) error {
+	if err != nil {
+		return err
+	}
+	if errMsg, ok := response.Body.Message.(message.Error); ok {
+		return fmt.Errorf("%v", errMsg)
+	}
+	return nil
+}
+
+type requestBudget struct {
+	lock      sync.Mutex
+	remaining int64
+	unlimited bool
+}
+
+func (recv *requestBudget) take() bool {
+	if recv.unlimited {
+		return true
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.remaining <= 0 {
+		return false
+	}
+	recv.remaining--
+	return true
+}
+
+type resultAggregator struct {
+	lock   sync.Mutex
+	res    *Result
+	sample *rand.Rand
+}
+
+func newResultAggregator(seed int64) *resultAggregator {
+	return &resultAggregator{
+		res: &Result{
+			Requests: make(map[OperationType]int64),
+			Errors:   make(map[OperationType]int64),
+		},
+		sample: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (recv *resultAggregator) record(op OperationType, latency time.Duration, err error) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.res.Requests[op]++
+	if err != nil {
+		recv.res.Errors[op]++
+		if recv.res.FirstError == nil {
+			recv.res.FirstError = err
+		}
+		return
+	}
+
+	// reservoir sampling so that long runs keep a bounded number of latencies
+	recv.res.latencySamples++
+	if len(recv.res.latencies) < maxLatencySamples {
+		recv.res.latencies = append(recv.res.latencies, latency)
+	} else if idx := recv.sample.Int63n(recv.res.latencySamples); idx < maxLatencySamples {
+		recv.res.latencies[idx] = latency
+	}
+}
+
+func (recv *resultAggregator) result(duration time.Duration) *Result {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.res.Duration = duration
+	sort.Slice(recv.res.latencies, func(i, j int) bool { return recv.res.latencies[i] < recv.res.latencies[j] })
+	return recv.res
+}
@@ -0,0 +1,229 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(c *Config)
+		valid  bool
+	}{
+		{"default", func(c *Config) {}, true},
+		{"read percent above 100", func(c *Config) { c.ReadPercent = 101 }, false},
+		{"negative prepared percent", func(c *Config) { c.PreparedPercent = -1 }, false},
+		{"batches without batch size", func(c *Config) { c.BatchPercent = 10; c.BatchSize = 0 }, false},
+		{"no partitions", func(c *Config) { c.Partitions = 0 }, false},
+		{"no connections", func(c *Config) { c.Connections = 0 }, false},
+		{"negative rate", func(c *Config) { c.RequestsPerSecond = -1 }, false},
+		{"no duration and no requests", func(c *Config) { c.Duration = 0 }, false},
+		{"requests without duration", func(c *Config) { c.Duration = 0; c.Requests = 10 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfig()
+			tt.update(conf)
+			err := conf.Validate()
+			if tt.valid {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestRun_Mix(t *testing.T) {
+	server := startTestServer(t)
+
+	conf := NewConfig()
+	conf.Requests = 400
+	conf.ReadPercent = 30
+	conf.PreparedPercent = 50
+	conf.BatchPercent = 20
+	conf.BatchSize = 3
+	conf.Connections = 2
+	conf.ConcurrencyPerConn = 2
+	conf.CreateSchemaIfNeeded = true
+
+	result, err := Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	require.Nil(t, result.FirstError)
+	require.Equal(t, int64(400), result.TotalRequests())
+	require.Equal(t, int64(0), result.TotalErrors())
+	require.Greater(t, result.Requests[OperationRead], int64(0))
+	require.Greater(t, result.Requests[OperationWrite], int64(0))
+	require.Greater(t, result.Requests[OperationBatch], int64(0))
+	require.Greater(t, result.Percentile(99), time.Duration(0))
+
+	counts := server.counts()
+	require.Equal(t, 4, counts[primitive.OpCodePrepare]) // read and write statements on both connections
+	require.Equal(t, int(result.Requests[OperationBatch]), counts[primitive.OpCodeBatch])
+	// 2 schema queries
+	require.Equal(t, 402, counts[primitive.OpCodeQuery]+counts[primitive.OpCodeExecute]+counts[primitive.OpCodeBatch])
+	require.Greater(t, counts[primitive.OpCodeExecute], 0)
+}
+
+func TestRun_Reproducible(t *testing.T) {
+	server := startTestServer(t)
+
+	conf := NewConfig()
+	conf.Requests = 50
+	conf.ReadPercent = 40
+	conf.BatchPercent = 30
+	conf.Seed = 42
+
+	_, err := Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	first := server.takeRequests()
+	require.Len(t, first, 50)
+
+	_, err = Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	require.Equal(t, first, server.takeRequests())
+
+	conf.Seed = 43
+	_, err = Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	require.NotEqual(t, first, server.takeRequests())
+}
+
+func TestRun_RequestsPerSecond(t *testing.T) {
+	server := startTestServer(t)
+
+	conf := NewConfig()
+	conf.Requests = 40
+	conf.RequestsPerSecond = 200
+	conf.Connections = 2
+
+	result, err := Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	require.Equal(t, int64(40), result.TotalRequests())
+	// the last request of each worker is sent 19 intervals (10ms) after its first one
+	require.GreaterOrEqual(t, result.Duration, 180*time.Millisecond)
+}
+
+func TestRun_Errors(t *testing.T) {
+	server := startTestServer(t)
+	server.failWrites = true
+
+	conf := NewConfig()
+	conf.Requests = 100
+	conf.ReadPercent = 50
+
+	result, err := Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	require.Equal(t, int64(100), result.TotalRequests())
+	require.Equal(t, int64(0), result.Errors[OperationRead])
+	require.Equal(t, result.Requests[OperationWrite], result.Errors[OperationWrite])
+	require.NotNil(t, result.FirstError)
+
+	conf.StopOnFirstError = true
+	result, err = Run(context.Background(), client.NewCqlClient(server.address, nil), conf)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), result.TotalErrors())
+}
+
+type testServer struct {
+	address    string
+	failWrites bool
+
+	lock     sync.Mutex
+	opCodes  map[primitive.OpCode]int
+	requests []string
+}
+
+func startTestServer(t *testing.T) *testServer {
+	oldLevel := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	t.Cleanup(func() {
+		zerolog.SetGlobalLevel(oldLevel)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	address := listener.Addr().String()
+	require.Nil(t, listener.Close())
+
+	server := &testServer{address: address, opCodes: make(map[primitive.OpCode]int)}
+	cqlServer := client.NewCqlServer(address, nil)
+	cqlServer.RequestHandlers = []client.RequestHandler{client.HandshakeHandler, client.HeartbeatHandler, server.handle}
+	require.Nil(t, cqlServer.Start(context.Background()))
+	t.Cleanup(func() {
+		_ = cqlServer.Close()
+	})
+	return server
+}
+
+func (recv *testServer) handle(request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.opCodes[request.Header.OpCode]++
+
+	var result message.Message = &message.VoidResult{}
+	switch msg := request.Body.Message.(type) {
+	case *message.Prepare:
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.PreparedResult{
+			PreparedQueryId:   []byte(msg.Query),
+			VariablesMetadata: &message.VariablesMetadata{},
+			ResultMetadata:    &message.RowsMetadata{},
+		})
+	case *message.Query:
+		recv.requests = append(recv.requests, fmt.Sprintf("%v %v", msg.Query, formatValues(msg.Options.PositionalValues)))
+		if recv.failWrites && msg.Query[0] == 'I' {
+			result = &message.Overloaded{ErrorMessage: "overloaded"}
+		}
+	case *message.Execute:
+		recv.requests = append(recv.requests, fmt.Sprintf("%s %v", msg.QueryId, formatValues(msg.Options.PositionalValues)))
+	case *message.Batch:
+		s := ""
+		for _, child := range msg.Children {
+			s += fmt.Sprintf("%v%s %v;", child.Query, child.Id, formatValues(child.Values))
+		}
+		recv.requests = append(recv.requests, s)
+		if recv.failWrites {
+			result = &message.Overloaded{ErrorMessage: "overloaded"}
+		}
+	default:
+		return nil
+	}
+	return frame.NewFrame(request.Header.Version, request.Header.StreamId, result)
+}
+
+func formatValues(values []*primitive.Value) string {
+	s := ""
+	for _, value := range values {
+		s += fmt.Sprintf("%x ", value.Contents)
+	}
+	return s
+}
+
+func (recv *testServer) counts() map[primitive.OpCode]int {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	counts := make(map[primitive.OpCode]int)
+	for k, v := range recv.opCodes {
+		counts[k] = v
+	}
+	return counts
+}
+
+func (recv *testServer) takeRequests() []string {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	requests := recv.requests
+	recv.requests = nil
+	return requests
+}
@@ -0,0 +1,123 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// asyncKeyspaceTracker tracks the keyspace of the async connector's connection. The client's USE requests are also sent
+// to the async connector but they can be discarded (e.g. the async connector is not ready or its write queue is full)
+// or fail on the secondary cluster, in which case the async reads of the client connection would be executed on the
+// wrong keyspace. When the keyspace of the client connection differs from the keyspace of the async connection,
+// the USE request is replayed on the async connection before the next async request.
+type asyncKeyspaceTracker struct {
+	lock           sync.Mutex
+	keyspace       string
+	failedKeyspace string
+}
+
+func newAsyncKeyspaceTracker() *asyncKeyspaceTracker {
+	return &asyncKeyspaceTracker{}
+}
+
+// OnUseSent is called after a USE request of the client was sent to the async connector.
+func (recv *asyncKeyspaceTracker) OnUseSent(keyspace string) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.keyspace = keyspace
+	recv.failedKeyspace = ""
+}
+
+// OnUseFailed is called when a USE request sent to the async connector failed or timed out. The keyspace is not
+// replayed again until the client sends another USE request to avoid replaying it before every async request.
+func (recv *asyncKeyspaceTracker) OnUseFailed(keyspace string) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.keyspace == keyspace {
+		recv.keyspace = ""
+		recv.failedKeyspace = keyspace
+	}
+}
+
+// ShouldReplay returns true if a USE request has to be sent to the async connector before the next async request,
+// the keyspace is then considered to be the keyspace of the async connection until OnUseFailed is called.
+func (recv *asyncKeyspaceTracker) ShouldReplay(clientKeyspace string) bool {
+	if clientKeyspace == "" {
+		return false
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if clientKeyspace == recv.keyspace || clientKeyspace == recv.failedKeyspace {
+		return false
+	}
+	recv.keyspace = clientKeyspace
+	return true
+}
+
+// Keyspace returns the keyspace of the async connection, it is empty if it is not known.
+func (recv *asyncKeyspaceTracker) Keyspace() string {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return recv.keyspace
+}
+
+// asyncUseRequestInfo is used for the USE requests sent to the async connector so that the async connector can
+// update its asyncKeyspaceTracker when they fail.
+type asyncUseRequestInfo struct {
+	RequestInfo
+	keyspace string
+}
+
+func newAsyncUseRequestInfo(requestInfo RequestInfo, keyspace string) *asyncUseRequestInfo {
+	return &asyncUseRequestInfo{RequestInfo: requestInfo, keyspace: keyspace}
+}
+
+func (recv *asyncUseRequestInfo) String() string {
+	return fmt.Sprintf("asyncUseRequestInfo{RequestInfo: %v, keyspace: %v}", recv.RequestInfo, recv.keyspace)
+}
+
+// getUseStatementKeyspace returns the keyspace of the request if it is a USE statement.
+func getUseStatementKeyspace(frameContext *frameDecodeContext) (string, bool) {
+	if frameContext == nil || frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery ||
+		len(frameContext.statementsQueryData) != 1 {
+		return "", false
+	}
+	queryData := frameContext.statementsQueryData[0].queryData
+	if queryData.getStatementType() != statementTypeUse {
+		return "", false
+	}
+	return queryData.getKeyspaceName(), true
+}
+
+// replayAsyncKeyspace sends a USE request to the async connector if the keyspace of its connection differs from the
+// keyspace of the client connection (see asyncKeyspaceTracker).
+func (ch *ClientHandler) replayAsyncKeyspace(version primitive.ProtocolVersion, requestTimeout time.Duration) {
+	keyspace := ch.LoadCurrentKeyspace()
+	tracker := ch.asyncConnector.asyncKeyspace
+	if !tracker.ShouldReplay(keyspace) {
+		return
+	}
+
+	useRawFrame, err := newUseRawFrame(version, keyspace)
+	if err != nil {
+		log.Errorf("Could not replay USE on async connector because convert raw frame failed: %v.", err)
+		tracker.OnUseFailed(keyspace)
+		return
+	}
+
+	log.Debugf("Replaying USE %v on async connector.", keyspace)
+	ch.clientHandlerRequestWaitGroup.Add(1)
+	requestInfo := newAsyncUseRequestInfo(NewGenericRequestInfo(forwardToAsyncOnly, false, false), keyspace)
+	sent := ch.asyncConnector.sendAsyncRequestToCluster(
+		requestInfo, useRawFrame, false, time.Now(), requestTimeout, nil, func() {
+			tracker.OnUseFailed(keyspace)
+			ch.clientHandlerRequestWaitGroup.Done()
+		})
+	if !sent {
+		tracker.OnUseFailed(keyspace)
+		ch.clientHandlerRequestWaitGroup.Done()
+	}
+}
@@ -0,0 +1,32 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAsyncKeyspaceTracker(t *testing.T) {
+	tracker := newAsyncKeyspaceTracker()
+	require.False(t, tracker.ShouldReplay(""))
+
+	// USE sent by the client
+	tracker.OnUseSent("ks1")
+	require.False(t, tracker.ShouldReplay("ks1"))
+	require.Equal(t, "ks1", tracker.Keyspace())
+
+	// client USE was discarded by the async connector
+	require.True(t, tracker.ShouldReplay("ks2"))
+	require.False(t, tracker.ShouldReplay("ks2"))
+
+	// replayed USE failed, it is not replayed again until the client sends another USE
+	tracker.OnUseFailed("ks2")
+	require.False(t, tracker.ShouldReplay("ks2"))
+	require.Equal(t, "", tracker.Keyspace())
+	tracker.OnUseSent("ks2")
+	require.False(t, tracker.ShouldReplay("ks2"))
+
+	// failure of an older USE doesn't reset the keyspace
+	tracker.OnUseFailed("ks1")
+	require.False(t, tracker.ShouldReplay("ks2"))
+	require.True(t, tracker.ShouldReplay("ks3"))
+}
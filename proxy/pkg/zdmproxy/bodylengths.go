@@ -0,0 +1,216 @@
+package zdmproxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// checkRequestBodyLengths checks that the declared length of every [bytes], [long string] and [value] of a request
+// body fits in the rest of the body.
+//
+// The message decoders of the protocol library allocate these values with their declared length before reading them,
+// so a request of a few bytes that declares a value of 2GB would make the proxy allocate 2GB when it decodes it. Only
+// the parts of the body that can declare such lengths are checked, the rest of the body is validated by the decoders.
+func checkRequestBodyLengths(f *frame.RawFrame) error {
+	checker := &requestBodyChecker{body: f.Body, version: f.Header.Version}
+	if f.Header.Flags.Contains(primitive.HeaderFlagCustomPayload) {
+		if err := checker.skipBytesMap("custom payload"); err != nil {
+			return err
+		}
+	}
+	switch f.Header.OpCode {
+	case primitive.OpCodeQuery:
+		if err := checker.skipLongString("query"); err != nil {
+			return err
+		}
+		return checker.checkQueryOptions()
+	case primitive.OpCodePrepare:
+		return checker.skipLongString("query")
+	case primitive.OpCodeExecute:
+		if err := checker.skipShortBytes("query id"); err != nil {
+			return err
+		}
+		if f.Header.Version.SupportsResultMetadataId() {
+			if err := checker.skipShortBytes("result metadata id"); err != nil {
+				return err
+			}
+		}
+		return checker.checkQueryOptions()
+	case primitive.OpCodeBatch:
+		return checker.checkBatchChildren()
+	case primitive.OpCodeAuthResponse:
+		return checker.skipBytes("token")
+	default:
+		return nil
+	}
+}
+
+type requestBodyChecker struct {
+	body    []byte
+	version primitive.ProtocolVersion
+}
+
+func (recv *requestBodyChecker) skip(length int, name string) error {
+	if length > len(recv.body) {
+		return fmt.Errorf("length %d of %v exceeds the remaining %d bytes of the body", length, name, len(recv.body))
+	}
+	recv.body = recv.body[length:]
+	return nil
+}
+
+func (recv *requestBodyChecker) readByte(name string) (byte, error) {
+	if len(recv.body) < 1 {
+		return 0, fmt.Errorf("body is too short to read %v", name)
+	}
+	value := recv.body[0]
+	recv.body = recv.body[1:]
+	return value, nil
+}
+
+func (recv *requestBodyChecker) readShort(name string) (uint16, error) {
+	if len(recv.body) < 2 {
+		return 0, fmt.Errorf("body is too short to read %v", name)
+	}
+	value := binary.BigEndian.Uint16(recv.body)
+	recv.body = recv.body[2:]
+	return value, nil
+}
+
+func (recv *requestBodyChecker) readInt(name string) (int32, error) {
+	if len(recv.body) < 4 {
+		return 0, fmt.Errorf("body is too short to read %v", name)
+	}
+	value := int32(binary.BigEndian.Uint32(recv.body))
+	recv.body = recv.body[4:]
+	return value, nil
+}
+
+// skipShortBytes skips a [short bytes] or a [string].
+func (recv *requestBodyChecker) skipShortBytes(name string) error {
+	length, err := recv.readShort(name)
+	if err != nil {
+		return err
+	}
+	return recv.skip(int(length), name)
+}
+
+// skipBytes skips a [bytes], a [long string] or a [value], a negative length is a null (or unset) value.
+func (recv *requestBodyChecker) skipBytes(name string) error {
+	length, err := recv.readInt(name)
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return nil
+	}
+	return recv.skip(int(length), name)
+}
+
+func (recv *requestBodyChecker) skipLongString(name string) error {
+	return recv.skipBytes(name)
+}
+
+func (recv *requestBodyChecker) skipBytesMap(name string) error {
+	count, err := recv.readShort(name)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		if err = recv.skipShortBytes(name); err != nil {
+			return err
+		}
+		if err = recv.skipBytes(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (recv *requestBodyChecker) skipValues(named bool) error {
+	count, err := recv.readShort("values")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		if named {
+			if err = recv.skipShortBytes("value name"); err != nil {
+				return err
+			}
+		}
+		if err = recv.skipBytes("value"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQueryOptions checks the values and the paging state of the query parameters, the other parameters have a fixed
+// length or a [short] length.
+func (recv *requestBodyChecker) checkQueryOptions() error {
+	if _, err := recv.readShort("consistency"); err != nil {
+		return err
+	}
+	var flags primitive.QueryFlag
+	if recv.version.Uses4BytesQueryFlags() {
+		f, err := recv.readInt("flags")
+		if err != nil {
+			return err
+		}
+		flags = primitive.QueryFlag(f)
+	} else {
+		f, err := recv.readByte("flags")
+		if err != nil {
+			return err
+		}
+		flags = primitive.QueryFlag(f)
+	}
+	if flags.Contains(primitive.QueryFlagValues) {
+		if err := recv.skipValues(flags.Contains(primitive.QueryFlagValueNames)); err != nil {
+			return err
+		}
+	}
+	if flags.Contains(primitive.QueryFlagPageSize) {
+		if _, err := recv.readInt("page size"); err != nil {
+			return err
+		}
+	}
+	if flags.Contains(primitive.QueryFlagPagingState) {
+		return recv.skipBytes("paging state")
+	}
+	return nil
+}
+
+// checkBatchChildren checks the statements of a BATCH, the parameters that follow them have a fixed length or a
+// [short] length.
+func (recv *requestBodyChecker) checkBatchChildren() error {
+	if _, err := recv.readByte("batch type"); err != nil {
+		return err
+	}
+	count, err := recv.readShort("batch statement count")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		childType, err := recv.readByte("batch statement type")
+		if err != nil {
+			return err
+		}
+		switch primitive.BatchChildType(childType) {
+		case primitive.BatchChildTypeQueryString:
+			err = recv.skipLongString("batch query")
+		case primitive.BatchChildTypePreparedId:
+			err = recv.skipShortBytes("batch query id")
+		default:
+			return nil // rejected by the decoder
+		}
+		if err != nil {
+			return err
+		}
+		if err = recv.skipValues(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheckRequestBodyLengths(t *testing.T) {
+	for _, encodedFrame := range fuzzSeedFrames(fuzzSeedRequests()) {
+		rawFrame, err := defaultCodec.DecodeRawFrame(bytes.NewReader(encodedFrame))
+		require.Nil(t, err)
+		require.Nil(t, checkRequestBodyLengths(rawFrame), rawFrame.Header)
+	}
+
+	withLength := func(f []byte, offset int, length int32) []byte {
+		binary.BigEndian.PutUint32(f[offset:], uint32(length))
+		return f
+	}
+	tests := []struct {
+		name    string
+		msg     message.Message
+		corrupt func(body []byte) []byte
+	}{
+		{"query string", &message.Query{Query: "SELECT * FROM ks1.tb1", Options: &message.QueryOptions{}},
+			func(body []byte) []byte { return withLength(body, 0, 0x70000000) }},
+		{"query value", &message.Query{Query: "SELECT * FROM ks1.tb1 WHERE a = ?", Options: &message.QueryOptions{
+			PositionalValues: []*primitive.Value{primitive.NewValue([]byte{1})}}},
+			func(body []byte) []byte { return withLength(body, len(body)-5, 0x70000000) }},
+		{"prepare string", &message.Prepare{Query: "SELECT * FROM ks1.tb1"},
+			func(body []byte) []byte { return withLength(body, 0, 0x70000000) }},
+		{"execute value", &message.Execute{QueryId: []byte{1}, Options: &message.QueryOptions{
+			PositionalValues: []*primitive.Value{primitive.NewValue([]byte{1})}}},
+			func(body []byte) []byte { return withLength(body, len(body)-5, 0x70000000) }},
+		{"batch query", &message.Batch{Children: []*message.BatchChild{{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"}}},
+			func(body []byte) []byte { return withLength(body, 4, 0x70000000) }},
+		{"auth token", &message.AuthResponse{Token: []byte("token")},
+			func(body []byte) []byte { return withLength(body, 0, 0x70000000) }},
+		{"truncated query", &message.Query{Query: "SELECT * FROM ks1.tb1", Options: &message.QueryOptions{}},
+			func(body []byte) []byte { return body[:3] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, tt.msg, primitive.ProtocolVersion4)
+			require.Nil(t, checkRequestBodyLengths(f))
+			f.Body = tt.corrupt(f.Body)
+			f.Header.BodyLength = int32(len(f.Body))
+			require.NotNil(t, checkRequestBodyLengths(f))
+		})
+	}
+}
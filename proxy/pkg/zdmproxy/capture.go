@@ -0,0 +1,87 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/capture"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// trafficCapture records the requests sent by clients to the file configured with ZDM_PROXY_CAPTURE_FILE so that
+// the workload can be replayed later against a test cluster (see tools/zdm-replay).
+//
+// Sampling is done per client connection (ZDM_PROXY_CAPTURE_SAMPLE_PERCENT) instead of per request because a replayed
+// connection needs all of its requests: the USE and PREPARE requests that come before the EXECUTE requests, for example.
+type trafficCapture struct {
+	writer           *capture.Writer
+	samplePercent    int
+	lastConnectionId uint64
+	failed           int32
+}
+
+// newTrafficCapture returns nil if traffic capture is disabled.
+func newTrafficCapture(path string, samplePercent int) (*trafficCapture, error) {
+	if path == "" || samplePercent <= 0 {
+		return nil, nil
+	}
+	writer, err := capture.NewWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Capturing the requests of %v%% of client connections to %v.", samplePercent, path)
+	return &trafficCapture{
+		writer:           writer,
+		samplePercent:    samplePercent,
+		lastConnectionId: 0,
+		failed:           0,
+	}, nil
+}
+
+// NewConnection returns the capture of a new client connection or nil if this connection was not selected
+// (or capture is disabled).
+func (recv *trafficCapture) NewConnection() *connectionCapture {
+	if recv == nil {
+		return nil
+	}
+	if recv.samplePercent < 100 && rand.Intn(100) >= recv.samplePercent {
+		return nil
+	}
+	return &connectionCapture{
+		capture:      recv,
+		connectionId: atomic.AddUint64(&recv.lastConnectionId, 1),
+	}
+}
+
+func (recv *trafficCapture) Close() {
+	if recv == nil {
+		return
+	}
+	err := recv.writer.Close()
+	if err != nil {
+		log.Warnf("Error while closing capture file: %v", err)
+	}
+}
+
+type connectionCapture struct {
+	capture      *trafficCapture
+	connectionId uint64
+}
+
+// Record adds the provided request to the capture file. AUTH_RESPONSE requests are not recorded because they contain
+// the client credentials, the replay tool authenticates with its own credentials instead.
+func (recv *connectionCapture) Record(f *frame.RawFrame) {
+	if recv == nil || f.Header.OpCode == primitive.OpCodeAuthResponse {
+		return
+	}
+	err := recv.capture.writer.Write(&capture.Record{
+		Timestamp:    time.Now(),
+		ConnectionId: recv.connectionId,
+		Frame:        f,
+	})
+	if err != nil && atomic.CompareAndSwapInt32(&recv.capture.failed, 0, 1) {
+		log.Errorf("Could not write request to capture file, the capture is incomplete: %v", err)
+	}
+}
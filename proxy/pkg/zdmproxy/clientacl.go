@@ -0,0 +1,55 @@
+package zdmproxy
+
+import (
+	"net"
+)
+
+// clientAcl decides which client addresses can connect to the proxy. Addresses in the deny list are always refused
+// and, if the allow list is not empty, only the addresses that it contains are accepted.
+type clientAcl struct {
+	allowList []*net.IPNet
+	denyList  []*net.IPNet
+}
+
+func newClientAcl(allowList []*net.IPNet, denyList []*net.IPNet) *clientAcl {
+	return &clientAcl{
+		allowList: allowList,
+		denyList:  denyList,
+	}
+}
+
+func (recv *clientAcl) IsAllowed(addr net.Addr) bool {
+	if len(recv.allowList) == 0 && len(recv.denyList) == 0 {
+		return true
+	}
+
+	ip := getIpFromAddr(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range recv.denyList {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	if len(recv.allowList) == 0 {
+		return true
+	}
+	for _, network := range recv.allowList {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func getIpFromAddr(addr net.Addr) net.IP {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
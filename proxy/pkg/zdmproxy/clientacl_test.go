@@ -0,0 +1,44 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestClientAcl(t *testing.T) {
+	parse := func(cidrs ...string) []*net.IPNet {
+		networks := make([]*net.IPNet, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			require.Nil(t, err)
+			networks = append(networks, network)
+		}
+		return networks
+	}
+	addr := func(ip string) net.Addr {
+		return &net.TCPAddr{IP: net.ParseIP(ip), Port: 9042}
+	}
+
+	tests := []struct {
+		name     string
+		acl      *clientAcl
+		addr     net.Addr
+		expected bool
+	}{
+		{"no lists", newClientAcl(nil, nil), addr("10.0.0.1"), true},
+		{"no lists, not an ip address", newClientAcl(nil, nil), &net.UnixAddr{Name: "pipe"}, true},
+		{"allowed", newClientAcl(parse("10.0.0.0/8"), nil), addr("10.0.0.1"), true},
+		{"not allowed", newClientAcl(parse("10.0.0.0/8"), nil), addr("192.168.0.1"), false},
+		{"denied", newClientAcl(nil, parse("10.0.0.0/24")), addr("10.0.0.1"), false},
+		{"not denied", newClientAcl(nil, parse("10.0.0.0/24")), addr("10.0.1.1"), true},
+		{"deny takes precedence", newClientAcl(parse("10.0.0.0/8"), parse("10.0.0.0/24")), addr("10.0.0.1"), false},
+		{"ipv6", newClientAcl(parse("2001:db8::/32"), nil), addr("2001:db8::1"), true},
+		{"not an ip address", newClientAcl(parse("10.0.0.0/8"), nil), &net.UnixAddr{Name: "pipe"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.acl.IsAllowed(tt.addr))
+		})
+	}
+}
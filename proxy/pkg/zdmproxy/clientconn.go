@@ -3,15 +3,19 @@ package zdmproxy
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
 	log "github.com/sirupsen/logrus"
 	"net"
+	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const ClientConnectorLogPrefix = "CLIENT-CONNECTOR"
@@ -58,6 +62,14 @@ type ClientConnector struct {
 	shutdownRequestCtx context.Context
 
 	minProtoVer primitive.ProtocolVersion
+
+	compression *frameCompression
+	framing     *segmentFraming
+
+	capture       *connectionCapture
+	frameDebugLog *connectionFrameDebugLog
+
+	connMetrics *clientConnectionMetrics
 }
 
 func NewClientConnector(
@@ -74,8 +86,13 @@ func NewClientConnector(
 	writeScheduler *Scheduler,
 	shutdownRequestCtx context.Context,
 	clientHandlerShutdownRequestCancelFn context.CancelFunc,
-	minProtoVer primitive.ProtocolVersion) *ClientConnector {
+	minProtoVer primitive.ProtocolVersion,
+	capture *connectionCapture,
+	frameDebugLog *connectionFrameDebugLog,
+	connMetrics *clientConnectionMetrics) *ClientConnector {
 
+	compression := newFrameCompression()
+	framing := newSegmentFraming(compression)
 	return &ClientConnector{
 		connection:              connection,
 		conf:                    conf,
@@ -92,7 +109,9 @@ func NewClientConnector(
 			ClientConnectorLogPrefix,
 			false,
 			false,
-			writeScheduler),
+			writeScheduler,
+			compression,
+			framing),
 		responsesDoneChan:                    responsesDoneChan,
 		requestsDoneCtx:                      requestsDoneCtx,
 		eventsDoneChan:                       eventsDoneChan,
@@ -101,6 +120,11 @@ func NewClientConnector(
 		shutdownRequestCtx:                   shutdownRequestCtx,
 		clientHandlerShutdownRequestCancelFn: clientHandlerShutdownRequestCancelFn,
 		minProtoVer:                          minProtoVer,
+		compression:                          compression,
+		framing:                              framing,
+		capture:                              capture,
+		frameDebugLog:                        frameDebugLog,
+		connMetrics:                          connMetrics,
 	}
 }
 
@@ -121,7 +145,13 @@ func (cc *ClientConnector) run(activeClients *int32) {
 			"to be terminated.", ClientConnectorLogPrefix, cc.connection.RemoteAddr())
 		cc.clientHandlerCancelFunc()
 
-		log.Infof("[%s] Shutting down client connection to %v", ClientConnectorLogPrefix, cc.connection.RemoteAddr())
+		if cc.connMetrics != nil {
+			reason, duration := cc.connMetrics.TrackClose(cc.shutdownRequestCtx.Err() != nil)
+			log.Infof("[%s] Shutting down client connection to %v (reason: %v, connection duration: %v)",
+				ClientConnectorLogPrefix, cc.connection.RemoteAddr(), reason, duration.Round(time.Millisecond))
+		} else {
+			log.Infof("[%s] Shutting down client connection to %v", ClientConnectorLogPrefix, cc.connection.RemoteAddr())
+		}
 		err := cc.connection.Close()
 		if err != nil {
 			log.Warnf("[%s] Error received while closing connection to %v: %v", ClientConnectorLogPrefix, cc.connection.RemoteAddr(), err)
@@ -173,15 +203,47 @@ func (cc *ClientConnector) listenForRequests() {
 			setDrainModeNowFunc()
 		}()
 
-		bufferedReader := bufio.NewReaderSize(cc.connection, cc.conf.RequestWriteBufferSizeBytes)
+		reader := newFrameReader(bufio.NewReaderSize(cc.connection, cc.conf.RequestWriteBufferSizeBytes), cc.framing, cc.conf.ProxyMaxFrameSizeBytes)
 		connectionAddr := cc.connection.RemoteAddr().String()
 		protocolErrOccurred := false
 		var alreadySentProtocolErr *frame.RawFrame
+		idleTimeout := time.Duration(cc.conf.ProxyClientIdleTimeoutMs) * time.Millisecond
 		for cc.clientHandlerContext.Err() == nil {
-			f, err := readRawFrame(bufferedReader, connectionAddr, cc.clientHandlerContext)
+			if idleTimeout > 0 {
+				_ = cc.connection.SetReadDeadline(time.Now().Add(idleTimeout))
+			}
+			f, err := reader.ReadFrame(connectionAddr, cc.clientHandlerContext)
+			if idleTimeout > 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+				log.Infof("[%s] Closing client connection %v because no request was received in the last %v.",
+					ClientConnectorLogPrefix, connectionAddr, idleTimeout)
+				cc.connMetrics.SetCloseReason(metrics.ClientConnectionCloseReasonIdleTimeout)
+				cc.clientHandlerCancelFunc()
+				break
+			}
+			var frameLengthErr *invalidFrameLengthError
+			if errors.As(err, &frameLengthErr) {
+				log.Warnf("[%s] Closing client connection %v: %v.", ClientConnectorLogPrefix, connectionAddr, err)
+				protocolErrResponseFrame, err := generateProtocolErrorResponseFrame(
+					frameLengthErr.streamId, frameLengthErr.version, frameLengthErr.ProtocolError())
+				if err != nil {
+					log.Errorf("[%s] Could not generate protocol error response for client connection %v: %v",
+						ClientConnectorLogPrefix, connectionAddr, err)
+				} else {
+					cc.sendResponseToClient(protocolErrResponseFrame)
+				}
+				cc.connMetrics.SetCloseReason(metrics.ClientConnectionCloseReasonError)
+				cc.clientHandlerCancelFunc()
+				break
+			}
+			if err == nil {
+				f, err = cc.compression.Decompress(f)
+			}
 
-			protocolErrResponseFrame, err, _ := checkProtocolError(f, cc.minProtoVer, err, protocolErrOccurred, ClientConnectorLogPrefix)
+			protocolErrResponseFrame, err, _ := checkProtocolError(f, cc.minProtoVer, cc.conf.EnableProtocolV5, err, protocolErrOccurred, ClientConnectorLogPrefix)
 			if err != nil {
+				if !errors.Is(err, ShutdownErr) {
+					cc.connMetrics.SetCloseReason(getClientConnectionCloseReason(err))
+				}
 				handleConnectionError(
 					err, cc.clientHandlerContext, cc.clientHandlerCancelFunc, ClientConnectorLogPrefix, "reading", connectionAddr)
 				break
@@ -197,6 +259,31 @@ func (cc *ClientConnector) listenForRequests() {
 				continue
 			}
 
+			if err = checkRequestBodyLengths(f); err != nil {
+				log.Warnf("[%s] Rejecting request with stream id %d of client connection %v because its body is invalid: %v.",
+					ClientConnectorLogPrefix, f.Header.StreamId, connectionAddr, err)
+				protocolErrResponseFrame, err = generateProtocolErrorResponseFrame(f.Header.StreamId, f.Header.Version,
+					&message.ProtocolError{ErrorMessage: fmt.Sprintf("Invalid request body: %v", err)})
+				if err != nil {
+					log.Errorf("[%s] Could not generate protocol error response for client connection %v: %v",
+						ClientConnectorLogPrefix, connectionAddr, err)
+				} else {
+					cc.sendResponseToClient(protocolErrResponseFrame)
+				}
+				continue
+			}
+
+			cc.capture.Record(f)
+			cc.frameDebugLog.LogRequest(f)
+
+			if f.Header.OpCode == primitive.OpCodeStartup {
+				err = cc.compression.SetFromStartup(f)
+				if err != nil {
+					log.Warnf("[%s] Could not set compression from startup request of %v: %v",
+						ClientConnectorLogPrefix, connectionAddr, err)
+				}
+			}
+
 			wg.Add(1)
 			cc.readScheduler.Schedule(func() {
 				defer wg.Done()
@@ -216,8 +303,12 @@ func (cc *ClientConnector) listenForRequests() {
 }
 
 func (cc *ClientConnector) sendOverloadedToClient(request *frame.RawFrame) {
+	cc.sendOverloadedToClientWithMessage(request, "Shutting down, please retry on next host.")
+}
+
+func (cc *ClientConnector) sendOverloadedToClientWithMessage(request *frame.RawFrame, errorMessage string) {
 	msg := &message.Overloaded{
-		ErrorMessage: "Shutting down, please retry on next host.",
+		ErrorMessage: errorMessage,
 	}
 	response := frame.NewFrame(request.Header.Version, request.Header.StreamId, msg)
 	rawResponse, err := defaultCodec.ConvertToRawFrame(response)
@@ -228,7 +319,7 @@ func (cc *ClientConnector) sendOverloadedToClient(request *frame.RawFrame) {
 	}
 }
 
-func checkProtocolError(f *frame.RawFrame, protoVer primitive.ProtocolVersion, connErr error, protocolErrorOccurred bool, prefix string) (protocolErrResponse *frame.RawFrame, fatalErr error, errorCode int8) {
+func checkProtocolError(f *frame.RawFrame, protoVer primitive.ProtocolVersion, protocolV5Enabled bool, connErr error, protocolErrorOccurred bool, prefix string) (protocolErrResponse *frame.RawFrame, fatalErr error, errorCode int8) {
 	var protocolErrMsg *message.ProtocolError
 	var streamId int16
 	var logMsg string
@@ -238,7 +329,7 @@ func checkProtocolError(f *frame.RawFrame, protoVer primitive.ProtocolVersion, c
 		streamId = 0
 		errorCode = ProtocolErrorDecodeError
 	} else {
-		protocolErrMsg = checkProtocolVersion(f.Header.Version)
+		protocolErrMsg = checkProtocolVersion(f.Header.Version, protocolV5Enabled)
 		logMsg = "Protocol v5 detected while decoding a frame."
 		streamId = f.Header.StreamId
 		errorCode = ProtocolErrorUnsupportedVersion
@@ -270,5 +361,6 @@ func generateProtocolErrorResponseFrame(streamId int16, protoVer primitive.Proto
 }
 
 func (cc *ClientConnector) sendResponseToClient(frame *frame.RawFrame) {
+	cc.frameDebugLog.LogResponse(frame)
 	cc.writeCoalescer.Enqueue(frame)
 }
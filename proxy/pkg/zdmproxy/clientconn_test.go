@@ -0,0 +1,40 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientConnector_IdleTimeout(t *testing.T) {
+	conf := config.New()
+	conf.RequestWriteBufferSizeBytes = 4096
+	conf.ProxyClientIdleTimeoutMs = 100
+
+	proxySide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	defer proxySide.Close()
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	wg := &sync.WaitGroup{}
+	scheduler := NewScheduler(1)
+	defer scheduler.Shutdown()
+	connector := NewClientConnector(
+		proxySide, conf, wg, make(chan *frame.RawFrame, 1), ctx, cancelFn, nil, ctx, nil,
+		scheduler, scheduler, context.Background(), func() {}, primitive.ProtocolVersion4, nil, nil, nil)
+
+	connector.listenForRequests()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "idle client connection was not closed")
+	}
+	wg.Wait()
+}
@@ -92,6 +92,8 @@ type ClientHandler struct {
 	requestsDoneCancelFn context.CancelFunc
 
 	requestResponseScheduler  *Scheduler
+	dualWritesQueue           *dualWritesQueue
+	dualWritesWaitGroup       *sync.WaitGroup
 	clientConnectorScheduler  *Scheduler
 	clusterConnectorScheduler *Scheduler
 
@@ -106,10 +108,29 @@ type ClientHandler struct {
 	originObserver *protocolEventObserverImpl
 	targetObserver *protocolEventObserverImpl
 
-	primaryCluster               common.ClusterType
-	forwardSystemQueriesToTarget bool
-	forwardAuthToTarget          bool
-	targetCredsOnClientRequest   bool
+	primaryCluster                  common.ClusterType
+	listenPort                      int // port of the listener of the client connection, advertised in system.local and system.peers
+	primaryClusterKeyspaceOverrides map[string]common.ClusterType
+	tableFilter                     *tableFilter
+	writePolicies                   *tableWritePolicies
+	requestTimeouts                 *tableRequestTimeouts
+	dualWritesResponseCluster       common.ClusterType
+	writeJournal                    *writeJournal
+	idempotencyOverrides            *idempotencyOverrides
+	targetReadsCanary               *targetReadsCanary
+	dryRun                          *dryRun
+	duplicateWrites                 *duplicateWrites
+	targetWritePacer                *targetWritePacer
+	targetConsistencyLevelMapping   *consistencyLevelMapping
+	targetTableNameQualifier        *tableNameQualifier
+	sessionKeyspace                 *sessionKeyspace
+	readYourWrites                  *readYourWrites
+	unparseableRequests             *UnparseableRequests
+	topStatements                   *TopStatements
+	requestRateLimiter              *requestRateLimiter
+	forwardSystemQueriesToTarget    bool
+	forwardAuthToTarget             bool
+	targetCredsOnClientRequest      bool
 
 	queryModifier     *QueryModifier
 	parameterModifier *ParameterModifier
@@ -119,6 +140,8 @@ type ClientHandler struct {
 	clientHandlerShutdownRequestCancelFn context.CancelFunc
 
 	clientHandlerShutdownRequestContext context.Context
+
+	logFields log.Fields // client_address and connection_id, see connectionLogger and requestLogger
 }
 
 func NewClientHandler(
@@ -137,15 +160,34 @@ func NewClientHandler(
 	metricHandler *metrics.MetricHandler,
 	globalClientHandlersWg *sync.WaitGroup,
 	requestResponseScheduler *Scheduler,
+	dualWritesWorkers *dualWritesWorkers,
 	readScheduler *Scheduler,
 	writeScheduler *Scheduler,
 	numWorkers int,
 	globalShutdownRequestCtx context.Context,
+	globalForceShutdownCtx context.Context,
 	originHost *Host,
 	targetHost *Host,
 	timeUuidGenerator TimeUuidGenerator,
 	readMode common.ReadMode,
 	primaryCluster common.ClusterType,
+	maxClientRequestsPerSecond int,
+	listenPort int,
+	primaryClusterKeyspaceOverrides map[string]common.ClusterType,
+	tableFilter *tableFilter,
+	writePolicies *tableWritePolicies,
+	requestTimeouts *tableRequestTimeouts,
+	dualWritesResponseCluster common.ClusterType,
+	writeJournal *writeJournal,
+	idempotencyOverrides *idempotencyOverrides,
+	dryRun *dryRun,
+	duplicateWrites *duplicateWrites,
+	targetWritePacer *targetWritePacer,
+	targetConsistencyLevelMapping *consistencyLevelMapping,
+	unparseableRequests *UnparseableRequests,
+	topStatements *TopStatements,
+	connectionCapture *connectionCapture,
+	frameDebugLog *connectionFrameDebugLog,
 	systemQueriesMode common.SystemQueriesMode) (*ClientHandler, error) {
 
 	originEndpointId := originCassandraConnInfo.endpoint.GetEndpointIdentifier()
@@ -164,7 +206,7 @@ func NewClientHandler(
 		return nil, fmt.Errorf("failed to create node metrics: %w", err)
 	}
 
-	clientHandlerContext, clientHandlerCancelFunc := context.WithCancel(context.Background())
+	clientHandlerContext, clientHandlerCancelFunc := context.WithCancel(globalForceShutdownCtx)
 	clientHandlerShutdownRequestContext, clientHandlerShutdownRequestCancelFn := context.WithCancel(globalShutdownRequestCtx)
 	requestsDoneCtx, requestsDoneCancelFn := context.WithCancel(context.Background())
 
@@ -245,17 +287,24 @@ func NewClientHandler(
 	eventsDoneChan := make(chan bool, 1)
 	requestsChannel := make(chan *frame.RawFrame, numWorkers)
 
+	// without host assignment the cluster connections are opened to a contact point, these client connections only
+	// need an observer if the contact points are refreshed (they are drained when the contact point addresses change)
 	var originObserver, targetObserver *protocolEventObserverImpl
-	if originHost != nil {
+	if originHost != nil || conf.ContactPointsRefreshIntervalMs > 0 {
 		originObserver = NewProtocolEventObserver(clientHandlerShutdownRequestCancelFn, originHost)
 	}
-	if targetHost != nil {
+	if targetHost != nil || conf.ContactPointsRefreshIntervalMs > 0 {
 		targetObserver = NewProtocolEventObserver(clientHandlerShutdownRequestCancelFn, targetHost)
 	}
 
 	forwardAuthToTarget, targetCredsOnClientRequest := forwardAuthToTarget(
 		originControlConn, targetControlConn, conf.ForwardClientCredentialsToOrigin)
 
+	targetReadsCanaryPercent := 0
+	if primaryCluster == common.ClusterTypeOrigin {
+		targetReadsCanaryPercent = conf.TargetReadsCanaryPercent
+	}
+
 	return &ClientHandler{
 		clientConnector: NewClientConnector(
 			clientTcpConn,
@@ -271,7 +320,10 @@ func NewClientHandler(
 			writeScheduler,
 			clientHandlerShutdownRequestContext,
 			clientHandlerShutdownRequestCancelFn,
-			minProtoVer(originCCProtoVer, targetCCProtoVer)),
+			minProtoVer(originCCProtoVer, targetCCProtoVer),
+			connectionCapture,
+			frameDebugLog,
+			newClientConnectionMetrics(metricHandler.GetProxyMetrics())),
 
 		asyncConnector:                       asyncConnector,
 		originCassandraConnector:             originConnector,
@@ -303,6 +355,8 @@ func NewClientHandler(
 		eventsDoneChan:                       eventsDoneChan,
 		requestsDoneCancelFn:                 requestsDoneCancelFn,
 		requestResponseScheduler:             requestResponseScheduler,
+		dualWritesQueue:                      newDualWritesQueue(dualWritesWorkers),
+		dualWritesWaitGroup:                  &sync.WaitGroup{},
 		conf:                                 conf,
 		localClientHandlerWg:                 localClientHandlerWg,
 		topologyConfig:                       topologyConfig,
@@ -311,6 +365,26 @@ func NewClientHandler(
 		originObserver:                       originObserver,
 		targetObserver:                       targetObserver,
 		primaryCluster:                       primaryCluster,
+		listenPort:                           listenPort,
+		primaryClusterKeyspaceOverrides:      primaryClusterKeyspaceOverrides,
+		tableFilter:                          tableFilter,
+		writePolicies:                        writePolicies,
+		requestTimeouts:                      requestTimeouts,
+		dualWritesResponseCluster:            dualWritesResponseCluster,
+		writeJournal:                         writeJournal,
+		idempotencyOverrides:                 idempotencyOverrides,
+		targetReadsCanary:                    newTargetReadsCanary(targetReadsCanaryPercent, conf.TargetReadsCanaryPerConnection),
+		dryRun:                               dryRun,
+		duplicateWrites:                      duplicateWrites,
+		targetWritePacer:                     targetWritePacer,
+		targetConsistencyLevelMapping:        targetConsistencyLevelMapping,
+		targetTableNameQualifier:             newTableNameQualifier(conf.TargetQualifyTableNames),
+		sessionKeyspace:                      newSessionKeyspace(),
+		readYourWrites:                       newReadYourWrites(conf.TargetReadYourWrites),
+		unparseableRequests:                  unparseableRequests,
+		topStatements:                        topStatements,
+		logFields:                            newClientConnectionLogFields(clientTcpConn.RemoteAddr().String()),
+		requestRateLimiter:                   newRequestRateLimiter(maxClientRequestsPerSecond, time.Now),
 		forwardSystemQueriesToTarget:         systemQueriesMode == common.SystemQueriesModeTarget,
 		forwardAuthToTarget:                  forwardAuthToTarget,
 		targetCredsOnClientRequest:           targetCredsOnClientRequest,
@@ -359,6 +433,9 @@ func addObserver(observer *protocolEventObserverImpl, controlConn *ControlConn)
 	if observer != nil {
 		host := observer.GetHost()
 		controlConn.RegisterObserver(observer)
+		if host == nil {
+			return
+		}
 		// check if host was possibly removed before observer was registered
 		hosts, err := controlConn.GetHostsInLocalDatacenter()
 		if err == nil {
@@ -413,19 +490,23 @@ func (ch *ClientHandler) requestLoop() {
 				// Handle client authentication
 				ready, err = ch.handleHandshakeRequest(f, wg)
 				if err != nil && !errors.Is(err, ShutdownErr) {
-					log.Error(err)
+					ch.connectionLogger().Error(err)
 				}
 				if ready {
 					ch.handshakeDone.Store(true)
-					log.Infof(
+					ch.connectionLogger().Infof(
 						"Handshake successful with client %s", connectionAddr)
 				}
 				log.Tracef("ready? %t", ready)
+			} else if !ch.requestRateLimiter.Allow() {
+				ch.metricHandler.GetProxyMetrics().RateLimitedRequests.Add(1)
+				ch.clientConnector.sendOverloadedToClientWithMessage(f, "Request rate limit exceeded, please retry later.")
 			} else {
+				receivedTime := time.Now()
 				wg.Add(1)
 				ch.requestResponseScheduler.Schedule(func() {
 					defer wg.Done()
-					ch.handleRequest(f)
+					ch.handleRequest(f, receivedTime)
 				})
 			}
 		}
@@ -433,6 +514,7 @@ func (ch *ClientHandler) requestLoop() {
 		log.Debugf("Shutting down client handler request listener %v.", connectionAddr)
 
 		wg.Wait()
+		ch.dualWritesWaitGroup.Wait() // the write coalescers are closed after this
 
 		go func() {
 			<-ch.clientHandlerContext.Done()
@@ -442,7 +524,7 @@ func (ch *ClientHandler) requestLoop() {
 				ch.asyncPendingRequests.clear(func(ctx RequestContext) {
 					typedReqCtx, ok := ctx.(*asyncRequestContextImpl)
 					if !ok {
-						log.Errorf("Failed to cancel async request because request context conversion failed. "+
+						ch.connectionLogger().Errorf("Failed to cancel async request because request context conversion failed. "+
 							"This is most likely a bug, please report. AsyncRequestContext: %v", ctx)
 					} else {
 						if !typedReqCtx.expectedResponse {
@@ -469,7 +551,7 @@ func (ch *ClientHandler) clearRequestContexts(contextHoldersMap *sync.Map) {
 		if canceled {
 			typedReqCtx, ok := reqCtx.(*requestContextImpl)
 			if !ok {
-				log.Errorf("Failed to cancel request because request context conversion failed. "+
+				ch.connectionLogger().Errorf("Failed to cancel request because request context conversion failed. "+
 					"This is most likely a bug, please report. RequestContext: %v", reqCtx)
 			} else {
 				ch.cancelRequest(reqCtxHolder, typedReqCtx)
@@ -525,38 +607,11 @@ func (ch *ClientHandler) listenForEventMessages() {
 
 			body, err := defaultCodec.DecodeBody(event.Header, bytes.NewReader(event.Body))
 			if err != nil {
-				log.Warnf("Error decoding event response: %v", err)
+				ch.connectionLogger().Warnf("Error decoding event response: %v", err)
 				continue
 			}
 
-			switch msgType := body.Message.(type) {
-			case *message.ProtocolError:
-				log.Debug("Received protocol error on event body listener, forwarding to client: ", body.Message)
-			case *message.SchemaChangeEvent:
-				if fromTarget {
-					log.Infof("Received schema change event from target, skipping: %v", msgType)
-					continue
-				}
-			case *message.StatusChangeEvent:
-				if ch.topologyConfig.VirtualizationEnabled {
-					log.Infof("Received status change event (fromTarget=%v) but virtualization is enabled, skipping: %v", fromTarget, msgType)
-					continue
-				}
-				if !fromTarget {
-					log.Infof("Received status change event from origin, skipping: %v", msgType)
-					continue
-				}
-			case *message.TopologyChangeEvent:
-				if ch.topologyConfig.VirtualizationEnabled {
-					log.Infof("Received topology change event (fromTarget=%v) but virtualization is enabled, skipping: %v", fromTarget, msgType)
-					continue
-				}
-				if !fromTarget {
-					log.Infof("Received topology change event from origin, skipping: %v", msgType)
-					continue
-				}
-			default:
-				log.Infof("Expected event body (fromTarget: %v) but got: %v", fromTarget, msgType)
+			if !shouldForwardEvent(body.Message, fromTarget, ch.topologyConfig.VirtualizationEnabled) {
 				continue
 			}
 
@@ -567,6 +622,45 @@ func (ch *ClientHandler) listenForEventMessages() {
 	}()
 }
 
+// shouldForwardEvent decides whether an EVENT message received on one of the cluster connections is sent to the client.
+//
+// Schema change events are only forwarded from ORIGIN. Status and topology change events are never forwarded when
+// system.peers virtualization is enabled because the node addresses in them belong to the cluster and not to
+// the proxy instances that the client knows about, otherwise they are only forwarded from TARGET.
+func shouldForwardEvent(msg message.Message, fromTarget bool, virtualizationEnabled bool) bool {
+	switch msgType := msg.(type) {
+	case *message.ProtocolError:
+		log.Debug("Received protocol error on event body listener, forwarding to client: ", msgType)
+	case *message.SchemaChangeEvent:
+		if fromTarget {
+			log.Infof("Received schema change event from target, skipping: %v", msgType)
+			return false
+		}
+	case *message.StatusChangeEvent:
+		if virtualizationEnabled {
+			log.Infof("Received status change event (fromTarget=%v) but virtualization is enabled, skipping: %v", fromTarget, msgType)
+			return false
+		}
+		if !fromTarget {
+			log.Infof("Received status change event from origin, skipping: %v", msgType)
+			return false
+		}
+	case *message.TopologyChangeEvent:
+		if virtualizationEnabled {
+			log.Infof("Received topology change event (fromTarget=%v) but virtualization is enabled, skipping: %v", fromTarget, msgType)
+			return false
+		}
+		if !fromTarget {
+			log.Infof("Received topology change event from origin, skipping: %v", msgType)
+			return false
+		}
+	default:
+		log.Infof("Expected event body (fromTarget: %v) but got: %v", fromTarget, msgType)
+		return false
+	}
+	return true
+}
+
 // Infinite loop that blocks on receiving from the response channel
 // (which is written by both cluster connectors).
 func (ch *ClientHandler) responseLoop() {
@@ -618,8 +712,9 @@ func (ch *ClientHandler) responseLoop() {
 				reqCtx := holder.Get()
 				if reqCtx == nil {
 					if ch.clientHandlerContext.Err() == nil {
-						log.Warnf("Could not find request context for stream id %d received from %v. "+
-							"It either timed out or a protocol error occurred.", streamId, response.connectorType)
+						ch.requestLogger(response.responseFrame, "", "").Warnf(
+							"Could not find request context for stream id %d received from %v. "+
+								"It either timed out or a protocol error occurred.", streamId, response.connectorType)
 					}
 					return
 				}
@@ -628,16 +723,28 @@ func (ch *ClientHandler) responseLoop() {
 				if response.responseFrame == nil {
 					finished = reqCtx.SetTimeout(ch.nodeMetrics, response.requestFrame)
 				} else {
-					finished = reqCtx.SetResponse(ch.nodeMetrics, response.responseFrame, responseClusterType, response.connectorType)
+					responseFrame := response.responseFrame
+					if typedReqCtx, ok := reqCtx.(*requestContextImpl); ok &&
+						response.connectorType == ClusterConnectorTypeTarget {
+						var handled bool
+						responseFrame, handled = ch.handleTargetReprepare(typedReqCtx, responseFrame)
+						if handled {
+							if reqCtx.GetRequestInfo().ShouldBeTrackedInMetrics() {
+								trackClusterErrorMetrics(response.responseFrame, response.connectorType, ch.nodeMetrics)
+							}
+							return
+						}
+					}
+					finished = reqCtx.SetResponse(ch.nodeMetrics, responseFrame, responseClusterType, response.connectorType)
 					if reqCtx.GetRequestInfo().ShouldBeTrackedInMetrics() {
-						trackClusterErrorMetrics(response.responseFrame, response.connectorType, ch.nodeMetrics)
+						trackClusterErrorMetrics(responseFrame, response.connectorType, ch.nodeMetrics)
 					}
 				}
 
 				if finished {
 					typedReqCtx, ok := reqCtx.(*requestContextImpl)
 					if !ok {
-						log.Errorf("Failed to finish request because request context conversion failed. "+
+						ch.connectionLogger().Errorf("Failed to finish request because request context conversion failed. "+
 							"This is most likely a bug, please report. RequestContext: %v", reqCtx)
 					} else {
 						ch.finishRequest(holder, typedReqCtx)
@@ -655,13 +762,13 @@ func (ch *ClientHandler) responseLoop() {
 func (ch *ClientHandler) tryProcessProtocolError(response *Response, protocolErrOccurred *int32) bool {
 	errMsg, err := decodeError(response.responseFrame)
 	if err != nil {
-		log.Errorf("Could not check if error from %v was protocol error: %v, skipping it.",
+		ch.connectionLogger().Errorf("Could not check if error from %v was protocol error: %v, skipping it.",
 			response.connectorType, response.responseFrame.Header)
 		return false
 	} else if errMsg != nil && errMsg.GetErrorCode() == primitive.ErrorCodeProtocolError {
 		if atomic.CompareAndSwapInt32(protocolErrOccurred, 0, 1) {
 			if ch.handshakeDone.Load() != nil {
-				log.Errorf("[ClientHandler] Protocol error detected (%v) on %v, forwarding it to the client.",
+				ch.connectionLogger().Errorf("[ClientHandler] Protocol error detected (%v) on %v, forwarding it to the client.",
 					errMsg, response.connectorType)
 			} else {
 				log.Debugf("[ClientHandler] Protocol version downgrade detected (%v) on %v, forwarding it to the client.",
@@ -703,26 +810,45 @@ func (ch *ClientHandler) finishRequest(holder *requestContextHolder, reqCtx *req
 	if err != nil {
 		log.Debugf("Could not free stream id: %v", err)
 	}
+	ch.readYourWrites.EndWrite(reqCtx.targetWrite)
 
 	if reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
 		proxyMetrics := ch.metricHandler.GetProxyMetrics()
-		switch reqCtx.requestInfo.GetForwardDecision() {
-		case forwardToBoth:
+		switch fwdDecision := reqCtx.requestInfo.GetForwardDecision(); {
+		case isWriteStatement(reqCtx.requestInfo):
 			proxyMetrics.ProxyWritesDuration.Track(reqCtx.startTime)
 			proxyMetrics.InFlightWrites.Subtract(1)
-		case forwardToOrigin:
+		case fwdDecision == forwardToOrigin:
 			proxyMetrics.ProxyReadsOriginDuration.Track(reqCtx.startTime)
 			proxyMetrics.InFlightReadsOrigin.Subtract(1)
-		case forwardToTarget:
+		case fwdDecision == forwardToTarget:
 			proxyMetrics.ProxyReadsTargetDuration.Track(reqCtx.startTime)
 			proxyMetrics.InFlightReadsTarget.Subtract(1)
-		case forwardToAsyncOnly, forwardToNone:
+		case fwdDecision == forwardToAsyncOnly, fwdDecision == forwardToNone:
 		default:
-			log.Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", reqCtx.requestInfo.GetForwardDecision())
+			ch.connectionLogger().Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", fwdDecision)
 		}
 	}
 
+	if ch.topStatements != nil && reqCtx.requestInfo.GetForwardDecision() != forwardToAsyncOnly {
+		write := reqCtx.requestInfo.GetForwardDecision() == forwardToBoth
+		ch.topStatements.Record(reqCtx.statement, write, write && isFailedDualWrite(reqCtx))
+	}
+
+	if reqCtx.duplicateWriteKey != nil && (reqCtx.targetResponse == nil ||
+		(!isResponseSuccessful(reqCtx.targetResponse) && !isUnpreparedResponse(reqCtx.targetResponse))) {
+		// the write didn't reach TARGET so the next attempt of the client has to be sent to TARGET
+		ch.duplicateWrites.Forget(reqCtx.duplicateWriteKey)
+	}
+
+	if reqCtx.requestInfo.GetForwardDecision() == forwardToBoth {
+		ch.targetWritePacer.OnTargetResponse(reqCtx.targetResponse)
+	}
+
 	aggregatedResponse, responseClusterType, err := ch.computeClientResponse(reqCtx)
+	if err == nil && reqCtx.requestInfo.GetForwardDecision() == forwardToBoth {
+		ch.journalTargetWrite(reqCtx, responseClusterType)
+	}
 	finalResponse := aggregatedResponse
 	if err == nil && reqCtx.requestInfo.GetForwardDecision() != forwardToAsyncOnly {
 		// async only requests can't have "PREPARED", "SETKEYSPACE" or "UNPREPARED" responses so skip this
@@ -733,7 +859,8 @@ func (ch *ClientHandler) finishRequest(holder *requestContextHolder, reqCtx *req
 		if reqCtx.customResponseChannel != nil {
 			close(reqCtx.customResponseChannel)
 		}
-		log.Errorf("Error handling request (%v): %v", reqCtx.request.Header, err)
+		ch.requestLogger(reqCtx.request, reqCtx.keyspace, reqCtx.table).Errorf(
+			"Error handling request (%v): %v", reqCtx.request.Header, err)
 		return
 	}
 
@@ -752,6 +879,31 @@ func (ch *ClientHandler) finishRequest(holder *requestContextHolder, reqCtx *req
 	} else {
 		ch.clientConnector.sendResponseToClient(finalResponse)
 	}
+	ch.trackProxyOverhead(reqCtx)
+
+	if reqCtx.dualReadComparison != nil {
+		reqCtx.dualReadComparison.SetPrimaryResult(aggregatedResponse)
+	}
+}
+
+// trackProxyOverhead tracks the latency that the proxy added to the provided request, if it got all of its responses.
+func (ch *ClientHandler) trackProxyOverhead(reqCtx *requestContextImpl) {
+	if !reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
+		return
+	}
+	begin, ok := reqCtx.getProxyOverheadBegin()
+	if !ok {
+		return
+	}
+	proxyMetrics := ch.metricHandler.GetProxyMetrics()
+	switch fwdDecision := reqCtx.requestInfo.GetForwardDecision(); {
+	case isWriteStatement(reqCtx.requestInfo):
+		proxyMetrics.ProxyWritesOverhead.Track(begin)
+	case fwdDecision == forwardToOrigin:
+		proxyMetrics.ProxyReadsOriginOverhead.Track(begin)
+	case fwdDecision == forwardToTarget:
+		proxyMetrics.ProxyReadsTargetOverhead.Track(begin)
+	}
 }
 
 // should only be called after Cancel returns true
@@ -762,19 +914,20 @@ func (ch *ClientHandler) cancelRequest(holder *requestContextHolder, reqCtx *req
 	if err != nil {
 		log.Debugf("Could not free stream id: %v", err)
 	}
+	ch.readYourWrites.EndWrite(reqCtx.targetWrite)
 
 	if reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
 		proxyMetrics := ch.metricHandler.GetProxyMetrics()
-		switch reqCtx.requestInfo.GetForwardDecision() {
-		case forwardToBoth:
+		switch fwdDecision := reqCtx.requestInfo.GetForwardDecision(); {
+		case isWriteStatement(reqCtx.requestInfo):
 			proxyMetrics.InFlightWrites.Subtract(1)
-		case forwardToOrigin:
+		case fwdDecision == forwardToOrigin:
 			proxyMetrics.InFlightReadsOrigin.Subtract(1)
-		case forwardToTarget:
+		case fwdDecision == forwardToTarget:
 			proxyMetrics.InFlightReadsTarget.Subtract(1)
-		case forwardToAsyncOnly, forwardToNone:
+		case fwdDecision == forwardToAsyncOnly, fwdDecision == forwardToNone:
 		default:
-			log.Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", reqCtx.requestInfo.GetForwardDecision())
+			ch.connectionLogger().Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", fwdDecision)
 		}
 	}
 
@@ -799,7 +952,11 @@ func (ch *ClientHandler) computeClientResponse(requestContext *requestContextImp
 			common.ClusterTypeOrigin, requestContext.originResponse.Header.OpCode)
 
 		if requestContext.requestInfo.ShouldBeTrackedInMetrics() && !isResponseSuccessful(requestContext.originResponse) {
-			ch.metricHandler.GetProxyMetrics().FailedReadsOrigin.Add(1)
+			if isSingleClusterWrite(requestContext.requestInfo) {
+				ch.metricHandler.GetProxyMetrics().FailedWritesOnOrigin.Add(1)
+			} else {
+				ch.metricHandler.GetProxyMetrics().FailedReadsOrigin.Add(1)
+			}
 		}
 		return requestContext.originResponse, common.ClusterTypeOrigin, nil
 	case forwardToTarget:
@@ -812,19 +969,16 @@ func (ch *ClientHandler) computeClientResponse(requestContext *requestContextImp
 			common.ClusterTypeTarget, requestContext.targetResponse.Header.OpCode)
 
 		if requestContext.requestInfo.ShouldBeTrackedInMetrics() && !isResponseSuccessful(requestContext.targetResponse) {
-			ch.metricHandler.GetProxyMetrics().FailedReadsTarget.Add(1)
+			if isSingleClusterWrite(requestContext.requestInfo) {
+				ch.metricHandler.GetProxyMetrics().FailedWritesOnTarget.Add(1)
+			} else {
+				ch.metricHandler.GetProxyMetrics().FailedReadsTarget.Add(1)
+			}
 		}
 		return requestContext.targetResponse, common.ClusterTypeTarget, nil
 	case forwardToBoth:
-		if requestContext.originResponse == nil {
-			return nil, common.ClusterTypeNone, fmt.Errorf(
-				"did not receive response from original cassandra channel, stream: %d",
-				requestContext.request.Header.StreamId)
-		}
-		if requestContext.targetResponse == nil {
-			return nil, common.ClusterTypeNone, fmt.Errorf(
-				"did not receive response from target cassandra channel, stream: %d",
-				requestContext.request.Header.StreamId)
+		if requestContext.originResponse == nil || requestContext.targetResponse == nil {
+			return ch.computeTimedOutDualWriteResponse(requestContext)
 		}
 		aggregatedResponse, responseClusterType := ch.aggregateAndTrackResponses(
 			requestContext.requestInfo, requestContext.request, requestContext.originResponse, requestContext.targetResponse)
@@ -850,7 +1004,7 @@ func (ch *ClientHandler) computeClientResponse(requestContext *requestContextImp
 				common.ClusterTypeOrigin, requestContext.originResponse.Header.OpCode)
 			return requestContext.originResponse, common.ClusterTypeOrigin, nil
 		default:
-			log.Errorf("Unknown cluster type: %v. This is a bug, please report.", ch.asyncConnector.clusterType)
+			ch.connectionLogger().Errorf("Unknown cluster type: %v. This is a bug, please report.", ch.asyncConnector.clusterType)
 			return nil, common.ClusterTypeNone, fmt.Errorf("unknown cluster type: %v; this is a bug, please report", ch.asyncConnector.clusterType)
 		}
 	case forwardToNone:
@@ -863,12 +1017,66 @@ func (ch *ClientHandler) computeClientResponse(requestContext *requestContextImp
 	}
 }
 
+// computeTimedOutDualWriteResponse returns the response of a write sent to both clusters that timed out on one of them.
+// A write that timed out only on the cluster that is not its response cluster (see getDualWriteResponseCluster) is
+// handled like a write that failed on that cluster: the response of its response cluster is returned to the client.
+func (ch *ClientHandler) computeTimedOutDualWriteResponse(
+	requestContext *requestContextImpl) (*frame.RawFrame, common.ClusterType, error) {
+	responseCluster := common.ClusterTypeNone
+	switch requestContext.request.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+		// PREPARE and the other requests sent to both clusters need both responses
+		responseCluster = ch.getDualWriteResponseCluster(requestContext.requestInfo)
+	}
+
+	var response *frame.RawFrame
+	var timedOutCluster common.ClusterType
+	switch {
+	case requestContext.originResponse == nil:
+		if responseCluster != common.ClusterTypeTarget || requestContext.targetResponse == nil {
+			return nil, common.ClusterTypeNone, fmt.Errorf(
+				"did not receive response from original cassandra channel, stream: %d",
+				requestContext.request.Header.StreamId)
+		}
+		response, timedOutCluster = requestContext.targetResponse, common.ClusterTypeOrigin
+	default:
+		if responseCluster != common.ClusterTypeOrigin {
+			return nil, common.ClusterTypeNone, fmt.Errorf(
+				"did not receive response from target cassandra channel, stream: %d",
+				requestContext.request.Header.StreamId)
+		}
+		response, timedOutCluster = requestContext.originResponse, common.ClusterTypeTarget
+	}
+
+	if requestContext.requestInfo.ShouldBeTrackedInMetrics() {
+		proxyMetrics := ch.metricHandler.GetProxyMetrics()
+		if !isResponseSuccessful(response) && !isUnpreparedResponse(response) {
+			proxyMetrics.FailedWritesOnBoth.Add(1)
+		} else if timedOutCluster == common.ClusterTypeTarget {
+			proxyMetrics.FailedWritesOnTarget.Add(1)
+		} else {
+			proxyMetrics.FailedWritesOnOrigin.Add(1)
+		}
+	}
+	log.Debugf("Write timed out only on %v, sending back %v response with opcode %d",
+		timedOutCluster, responseCluster, response.Header.OpCode)
+	return response, responseCluster, nil
+}
+
 // Modifies internal state based on the provided aggregated response (e.g. storing prepared IDs)
 func (ch *ClientHandler) processClientResponse(
 	response *frame.RawFrame, responseClusterType common.ClusterType, reqCtx *requestContextImpl) (*frame.RawFrame, error) {
 
 	var newFrame *frame.Frame
 	switch response.Header.OpCode {
+	case primitive.OpCodeSupported:
+		if ch.conf.ProxyStripCompression {
+			decodedFrame, err := defaultCodec.ConvertFromRawFrame(response)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding response: %w", err)
+			}
+			newFrame = stripCompressionFromSupported(decodedFrame)
+		}
 	case primitive.OpCodeResult, primitive.OpCodeError:
 		decodedFrame, err := defaultCodec.ConvertFromRawFrame(response)
 		if err != nil {
@@ -883,7 +1091,7 @@ func (ch *ClientHandler) processClientResponse(
 			}
 		case *message.SetKeyspaceResult:
 			if bodyMsg.Keyspace == "" {
-				log.Warnf("unexpected set keyspace empty")
+				ch.connectionLogger().Warnf("unexpected set keyspace empty")
 			} else {
 				ch.StoreCurrentKeyspace(bodyMsg.Keyspace)
 			}
@@ -911,8 +1119,9 @@ func (ch *ClientHandler) processClientResponse(
 			}
 			newFrame.Body.Message = newUnprepared
 
-			log.Infof("Received UNPREPARED from %v, generating UNPREPARED response with prepared ID %s. "+
-				"Prepared ID in response from %v: %v. Original error: %v",
+			ch.requestLogger(response, reqCtx.keyspace, reqCtx.table).Infof(
+				"Received UNPREPARED from %v, generating UNPREPARED response with prepared ID %s. "+
+					"Prepared ID in response from %v: %v. Original error: %v",
 				responseClusterType, hex.EncodeToString(unpreparedId),
 				responseClusterType, hex.EncodeToString(bodyMsg.Id), bodyMsg.ErrorMessage)
 		}
@@ -937,17 +1146,22 @@ func (ch *ClientHandler) processPreparedResponse(
 		return nil, errors.New("unexpected statement info nil on request context")
 	} else if prepareRequestInfo, ok := reqCtx.requestInfo.(*PrepareRequestInfo); !ok {
 		return nil, errors.New("unexpected request context statement info is not prepared statement info")
-	} else if reqCtx.targetResponse == nil {
+	} else if reqCtx.targetResponse == nil && prepareRequestInfo.GetForwardDecision() != forwardToOrigin {
 		return nil, errors.New("unexpected target response nil")
 	} else {
-		targetBody, err := defaultCodec.DecodeBody(reqCtx.targetResponse.Header, bytes.NewReader(reqCtx.targetResponse.Body))
-		if err != nil {
-			return nil, fmt.Errorf("error decoding target result response: %w", err)
-		}
+		// statements on tables that are out of the scope of the migration are only prepared on ORIGIN
+		targetPreparedResult := bodyMsg
+		if prepareRequestInfo.GetForwardDecision() != forwardToOrigin {
+			targetBody, err := defaultCodec.DecodeBody(reqCtx.targetResponse.Header, bytes.NewReader(reqCtx.targetResponse.Body))
+			if err != nil {
+				return nil, fmt.Errorf("error decoding target result response: %w", err)
+			}
 
-		targetPreparedResult, ok := targetBody.Message.(*message.PreparedResult)
-		if !ok {
-			return nil, fmt.Errorf("expected PREPARED RESULT targetBody in target result response but got %T", targetBody.Message)
+			var ok bool
+			targetPreparedResult, ok = targetBody.Message.(*message.PreparedResult)
+			if !ok {
+				return nil, fmt.Errorf("expected PREPARED RESULT targetBody in target result response but got %T", targetBody.Message)
+			}
 		}
 
 		newResponse := response
@@ -1095,8 +1309,20 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 			}
 		}
 
+		if request.Header.OpCode == primitive.OpCodeStartup && ch.conf.ProxyStripCompression {
+			newStartupFrame, err := stripCompressionFromStartup(request)
+			if err != nil {
+				scheduledTaskChannel <- &handshakeRequestResult{
+					authSuccess: false,
+					err:         err,
+				}
+				return
+			}
+			request = newStartupFrame
+		}
+
 		responseChan := make(chan *customResponse, 1)
-		err := ch.forwardRequest(request, responseChan)
+		err := ch.forwardRequest(request, time.Now(), responseChan)
 		if err != nil {
 			scheduledTaskChannel <- &handshakeRequestResult{
 				authSuccess: false,
@@ -1193,7 +1419,7 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 			if ch.asyncConnector != nil {
 				asyncConnectorHandshakeChannel, err = ch.startSecondaryHandshake(true)
 				if err != nil {
-					log.Errorf("Error occured in async connector (%v) handshake: %v. "+
+					ch.connectionLogger().Errorf("Error occured in async connector (%v) handshake: %v. "+
 						"Async requests will not be forwarded.", ch.asyncConnector.clusterType, err.Error())
 					ch.asyncConnector.Shutdown()
 					asyncConnectorHandshakeChannel = nil
@@ -1242,7 +1468,7 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 		}
 		if handshakeInitiated {
 			if errAsync != nil {
-				log.Errorf("Async connector (%v) handshake failed, async requests will not be forwarded: %s",
+				ch.connectionLogger().Errorf("Async connector (%v) handshake failed, async requests will not be forwarded: %s",
 					ch.asyncConnector.clusterType, errAsync.Error())
 				ch.asyncConnector.Shutdown()
 			}
@@ -1256,7 +1482,7 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 					return
 				}
 
-				log.Errorf("Secondary (%v) handshake failed (client: %v), shutting down the client handler and connectors: %s",
+				ch.connectionLogger().Errorf("Secondary (%v) handshake failed (client: %v), shutting down the client handler and connectors: %s",
 					secondaryClusterType, ch.clientConnector.connection.RemoteAddr().String(), errSecondary.Error())
 				ch.clientHandlerCancelFunc()
 				tempResult.err = fmt.Errorf("handshake failed: %w", ShutdownErr)
@@ -1286,7 +1512,7 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 func (ch *ClientHandler) sendAuthErrorToClient(requestFrame *frame.RawFrame, secondaryClusterType common.ClusterType) error {
 	authErrorResponse, err := ch.buildAuthErrorResponse(requestFrame, ch.authErrorMessage)
 	if err == nil {
-		log.Warnf("Secondary (%v) handshake failed with an auth error, returning %v to client.", secondaryClusterType, ch.authErrorMessage)
+		ch.connectionLogger().Warnf("Secondary (%v) handshake failed with an auth error, returning %v to client.", secondaryClusterType, ch.authErrorMessage)
 		ch.clientConnector.sendResponseToClient(authErrorResponse)
 		return nil
 	} else {
@@ -1298,10 +1524,6 @@ func (ch *ClientHandler) sendAuthErrorToClient(requestFrame *frame.RawFrame, sec
 func (ch *ClientHandler) buildAuthErrorResponse(
 	requestFrame *frame.RawFrame, authenticationError *message.AuthenticationError) (*frame.RawFrame, error) {
 	f := frame.NewFrame(requestFrame.Header.Version, requestFrame.Header.StreamId, authenticationError)
-	if requestFrame.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
-		f.SetCompress(true)
-	}
-
 	return defaultCodec.ConvertToRawFrame(f)
 }
 
@@ -1339,17 +1561,20 @@ func (ch *ClientHandler) startSecondaryHandshake(asyncConnector bool) (chan erro
 
 // Handles a request, see the docs for the forwardRequest() function, as handleRequest is pretty much a wrapper
 // around forwardRequest.
-func (ch *ClientHandler) handleRequest(f *frame.RawFrame) {
-	err := ch.forwardRequest(f, nil)
+func (ch *ClientHandler) handleRequest(f *frame.RawFrame, receivedTime time.Time) {
+	err := ch.forwardRequest(f, receivedTime, nil)
 
 	if err != nil {
-		log.Warnf("error sending request with opcode %02x and streamid %d: %s", f.Header.OpCode, f.Header.StreamId, err.Error())
+		ch.requestLogger(f, "", "").Warnf(
+			"error sending request with opcode %02x and streamid %d: %s", f.Header.OpCode, f.Header.StreamId, err.Error())
 		return
 	}
 }
 
 // Forwards the request, parsing it and enqueuing it to the appropriate cluster connector(s)' write queue(s).
-func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseChannel chan *customResponse) error {
+// The received time is when the request listener received the request from the client connector.
+func (ch *ClientHandler) forwardRequest(
+	request *frame.RawFrame, receivedTime time.Time, customResponseChannel chan *customResponse) error {
 	overallRequestStartTime := time.Now()
 
 	log.Tracef("Request frame: %v", request)
@@ -1367,7 +1592,8 @@ func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseC
 	}
 	requestInfo, err := buildRequestInfo(
 		context, replacedTerms, ch.preparedStatementCache, ch.metricHandler, currentKeyspace, ch.primaryCluster,
-		ch.forwardSystemQueriesToTarget, ch.topologyConfig.VirtualizationEnabled, ch.forwardAuthToTarget, ch.timeUuidGenerator)
+		ch.primaryClusterKeyspaceOverrides, ch.tableFilter, ch.writePolicies, ch.forwardSystemQueriesToTarget, ch.topologyConfig.VirtualizationEnabled,
+		ch.forwardAuthToTarget, ch.timeUuidGenerator)
 	if err != nil {
 		if errVal, ok := err.(*UnpreparedExecuteError); ok {
 			unpreparedFrame, err := createUnpreparedFrame(errVal)
@@ -1383,11 +1609,29 @@ func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseC
 			log.Debugf("Unprepared Response sent, exiting handleRequest now")
 			return nil
 		}
+		if errVal, ok := err.(*MixedBatchError); ok {
+			invalidFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(
+				errVal.Header.Version, errVal.Header.StreamId, &message.Invalid{ErrorMessage: errVal.Error()}))
+			if err != nil {
+				return err
+			}
+			ch.clientConnector.sendResponseToClient(invalidFrame)
+			return nil
+		}
+		ch.unparseableRequests.RecordDecodeError(request.Header.OpCode, err, ch.metricHandler.GetProxyMetrics())
 		return err
 	}
-
-	requestTimeout := time.Duration(ch.conf.ProxyRequestTimeoutMs) * time.Millisecond
-	err = ch.executeRequest(context, requestInfo, currentKeyspace, overallRequestStartTime, customResponseChannel, requestTimeout)
+	ch.unparseableRequests.RecordUnrecognizedStatements(context, ch.metricHandler.GetProxyMetrics())
+	requestInfo = ch.targetReadsCanary.Apply(requestInfo)
+	requestInfo = ch.dryRun.Apply(context, requestInfo, currentKeyspace, ch.timeUuidGenerator, ch.metricHandler.GetProxyMetrics())
+	requestInfo, duplicateWriteKey := ch.duplicateWrites.Apply(
+		request, context, requestInfo, currentKeyspace, ch.idempotencyOverrides, ch.metricHandler.GetProxyMetrics())
+
+	requestTimeout := ch.requestTimeouts.getRequestTimeout(
+		context, requestInfo, currentKeyspace, time.Duration(ch.conf.ProxyRequestTimeoutMs)*time.Millisecond)
+	err = ch.executeRequest(
+		context, requestInfo, currentKeyspace, overallRequestStartTime, receivedTime, customResponseChannel, requestTimeout,
+		duplicateWriteKey)
 	if err != nil {
 		return err
 	}
@@ -1398,7 +1642,8 @@ func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseC
 // that should be sent back to the client.
 func (ch *ClientHandler) executeRequest(
 	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
-	overallRequestStartTime time.Time, customResponseChannel chan *customResponse, requestTimeout time.Duration) error {
+	overallRequestStartTime time.Time, receivedTime time.Time, customResponseChannel chan *customResponse,
+	requestTimeout time.Duration, duplicateWriteKey *duplicateWriteKey) error {
 	fwdDecision := requestInfo.GetForwardDecision()
 	log.Tracef("Opcode: %v, Forward decision: %v", frameContext.GetRawFrame().Header.OpCode, fwdDecision)
 
@@ -1423,6 +1668,29 @@ func (ch *ClientHandler) executeRequest(
 		return err
 	}
 
+	journalWrite := ch.writeJournal != nil && fwdDecision == forwardToBoth &&
+		getWriteFailures(requestInfo) == writeFailuresJournal
+	if journalWrite {
+		originRequest, targetRequest, err = setJournaledWriteTimestamp(originRequest, targetRequest, receivedTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fwdDecision == forwardToBoth {
+		targetRequest, err = ch.targetConsistencyLevelMapping.Apply(targetRequest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fwdDecision == forwardToBoth || fwdDecision == forwardToTarget || requestInfo.ShouldAlsoBeSentAsync() {
+		targetRequest, err = ch.targetTableNameQualifier.Apply(targetRequest, currentKeyspace)
+		if err != nil {
+			return err
+		}
+	}
+
 	if fwdDecision == forwardToNone {
 		if clientResponse == nil {
 			return fmt.Errorf("forwardDecision is NONE but client response is nil")
@@ -1438,6 +1706,25 @@ func (ch *ClientHandler) executeRequest(
 	}
 
 	reqCtx := NewRequestContext(f, requestInfo, overallRequestStartTime, customResponseChannel)
+	reqCtx.receivedTime = receivedTime
+	reqCtx.keyspace, reqCtx.table = getStatementKeyspaceAndTable(frameContext)
+	if fwdDecision == forwardToBoth && isReadYourWritesRequest(f) {
+		reqCtx.targetWrite = ch.readYourWrites.BeginWrite()
+	}
+	if journalWrite {
+		reqCtx.journalRequest = targetRequest
+		reqCtx.journalKeyspace = currentKeyspace
+	}
+	reqCtx.duplicateWriteKey = duplicateWriteKey
+	if ch.topStatements != nil {
+		reqCtx.statement = getStatementForTopStatements(frameContext, requestInfo)
+	}
+	sendAlsoToAsync := requestInfo.ShouldAlsoBeSentAsync() && ch.asyncConnector != nil &&
+		shouldSampleDualRead(requestInfo, ch.conf.DualReadsSamplePercent)
+	if sendAlsoToAsync && ch.conf.DualReadsCompareResults && isDualRead(requestInfo) {
+		reqCtx.dualReadComparison = newDualReadComparison(
+			getQueryForDualReadComparison(frameContext, requestInfo), ch.metricHandler.GetProxyMetrics())
+	}
 	var contextHoldersMap *sync.Map
 	if fwdDecision == forwardToAsyncOnly {
 		contextHoldersMap = ch.asyncRequestContextHolders // different map because of stream id collision
@@ -1446,25 +1733,27 @@ func (ch *ClientHandler) executeRequest(
 	}
 	holder, err := storeRequestContext(contextHoldersMap, reqCtx)
 	if err != nil {
+		ch.readYourWrites.EndWrite(reqCtx.targetWrite)
 		return err
 	}
 
 	if requestInfo.ShouldBeTrackedInMetrics() {
 		proxyMetrics := ch.metricHandler.GetProxyMetrics()
-		switch fwdDecision {
-		case forwardToBoth:
+		switch {
+		case isWriteStatement(requestInfo):
 			proxyMetrics.InFlightWrites.Add(1)
-		case forwardToOrigin:
+		case fwdDecision == forwardToOrigin:
 			proxyMetrics.InFlightReadsOrigin.Add(1)
-		case forwardToTarget:
+		case fwdDecision == forwardToTarget:
 			proxyMetrics.InFlightReadsTarget.Add(1)
-		case forwardToAsyncOnly:
+		case fwdDecision == forwardToAsyncOnly:
 		default:
-			log.Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", fwdDecision)
+			ch.connectionLogger().Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", fwdDecision)
 		}
 	}
 
 	ch.clientHandlerRequestWaitGroup.Add(1)
+	requestDeadline := time.Now().Add(requestTimeout)
 	if fwdDecision != forwardToAsyncOnly {
 		timer := time.AfterFunc(requestTimeout, func() {
 			ch.closedRespChannelLock.RLock()
@@ -1488,20 +1777,56 @@ func (ch *ClientHandler) executeRequest(
 		startupFrameVersion = startupFrameInterface.(*frame.RawFrame).Header.Version
 	}
 
-	sendAlsoToAsync := requestInfo.ShouldAlsoBeSentAsync() && ch.asyncConnector != nil
 	switch fwdDecision {
 	case forwardToBoth:
 		log.Tracef("Forwarding request with opcode %v for stream %v to %v and %v",
 			f.Header.OpCode, f.Header.StreamId, common.ClusterTypeOrigin, common.ClusterTypeTarget)
-		sendErr := ch.originCassandraConnector.sendRequestToCluster(originRequest)
-		if sendErr != nil {
-			ch.handleRequestSendFailure(sendErr, frameContext)
-		} else {
-			ch.targetCassandraConnector.sendRequestToCluster(targetRequest)
+		// the write queues of the cluster connectors can be full when a cluster is slow, queuing the write for the dual
+		// writes workers makes sure that the request / response workers don't wait for them and can forward the reads
+		var sendTime time.Time
+		if isPacedWrite(f) {
+			delay, ok := ch.targetWritePacer.Reserve(requestDeadline)
+			if !ok {
+				// the write would be sent too late to get a response before it times out
+				if reqCtx.Cancel(ch.nodeMetrics) {
+					ch.duplicateWrites.Forget(reqCtx.duplicateWriteKey)
+					ch.cancelRequest(holder, reqCtx)
+					if reqCtx.customResponseChannel == nil {
+						ch.clientConnector.sendOverloadedToClientWithMessage(
+							f, "Writes are paced because the target cluster is throttling them, please retry later.")
+					}
+				}
+				return nil
+			}
+			if delay > 0 {
+				sendTime = time.Now().Add(delay)
+			}
 		}
+		ch.dualWritesWaitGroup.Add(1)
+		ch.dualWritesQueue.Add(sendTime, func() {
+			defer ch.dualWritesWaitGroup.Done()
+			// the write can time out while it is queued, its client stream id is then released and can be used by
+			// another request so the responses of a late write would be set on the wrong request context, the stream
+			// ids of the clusters are only assigned when the write is sent
+			if !reqCtx.markSent(time.Now()) {
+				log.Debugf("Discarding queued write with stream id %v because it is not pending anymore.",
+					f.Header.StreamId)
+				return
+			}
+			queuedOriginRequest, queuedTargetRequest := ch.applySessionKeyspace(
+				frameContext, originRequest, targetRequest, currentKeyspace)
+			reqCtx.setTargetReprepare(queuedTargetRequest, currentKeyspace)
+			sendErr := ch.originCassandraConnector.sendRequestToCluster(queuedOriginRequest)
+			if sendErr != nil {
+				ch.handleRequestSendFailure(sendErr, frameContext)
+			} else {
+				ch.targetCassandraConnector.sendRequestToCluster(queuedTargetRequest)
+			}
+		})
 	case forwardToOrigin:
 		log.Tracef("Forwarding request with opcode %v for stream %v to %v",
 			f.Header.OpCode, f.Header.StreamId, common.ClusterTypeOrigin)
+		reqCtx.sentTime = time.Now()
 		sendErr := ch.originCassandraConnector.sendRequestToCluster(originRequest)
 		if sendErr != nil {
 			ch.handleRequestSendFailure(sendErr, frameContext)
@@ -1510,9 +1835,22 @@ func (ch *ClientHandler) executeRequest(
 	case forwardToTarget:
 		log.Tracef("Forwarding request with opcode %v for stream %v to %v",
 			f.Header.OpCode, f.Header.StreamId, common.ClusterTypeTarget)
-		sendErr := ch.targetCassandraConnector.sendRequestToCluster(targetRequest)
-		if sendErr != nil {
-			ch.handleRequestSendFailure(sendErr, frameContext)
+		sendToTarget := func() {
+			if !reqCtx.markSent(time.Now()) {
+				// the read timed out or was canceled while it was waiting for the writes before it
+				log.Debugf("Discarding delayed read with stream id %v because it is not pending anymore.",
+					f.Header.StreamId)
+				return
+			}
+			sendErr := ch.targetCassandraConnector.sendRequestToCluster(targetRequest)
+			if sendErr != nil {
+				ch.handleRequestSendFailure(sendErr, frameContext)
+			}
+		}
+		if isReadYourWritesRequest(f) {
+			ch.readYourWrites.SendRead(sendToTarget)
+		} else {
+			sendToTarget()
 		}
 		ch.originCassandraConnector.sendHeartbeat(startupFrameVersion, ch.conf.HeartbeatIntervalMs)
 	case forwardToAsyncOnly:
@@ -1542,7 +1880,7 @@ func (ch *ClientHandler) handleRequestSendFailure(err error, frameContext *frame
 		responseFrame, err := generateProtocolErrorResponseFrame(
 			frameContext.frame.Header.StreamId, frameContext.frame.Header.Version, responseMessage)
 		if err != nil {
-			log.Errorf("could not generate protocol error response raw frame (%v): %v", responseMessage, err)
+			ch.connectionLogger().Errorf("could not generate protocol error response raw frame (%v): %v", responseMessage, err)
 		} else {
 			ch.clientConnector.sendResponseToClient(responseFrame)
 		}
@@ -1596,7 +1934,7 @@ func (ch *ClientHandler) handleInterceptedRequest(
 		}
 		interceptedQueryResponse, err = NewSystemPeersResult(prepareRequestInfo, currentKeyspace,
 			typeCodec, f.Header.Version, controlConn.GetSystemPeersColumnNames(), controlConn.GetSystemLocalColumnData(),
-			parsedSelectClause, virtualHosts, controlConn.GetLocalVirtualHostIndex(), ch.conf.ProxyListenPort)
+			parsedSelectClause, virtualHosts, controlConn.GetLocalVirtualHostIndex(), ch.listenPort)
 	case local:
 		parsedSelectClause := interceptedRequestInfo.GetParsedSelectClause()
 		if parsedSelectClause == nil {
@@ -1605,7 +1943,7 @@ func (ch *ClientHandler) handleInterceptedRequest(
 		localVirtualHost := virtualHosts[controlConn.GetLocalVirtualHostIndex()]
 		interceptedQueryResponse, err = NewSystemLocalResult(prepareRequestInfo, currentKeyspace,
 			typeCodec, f.Header.Version, controlConn.GetSystemLocalColumnData(), parsedSelectClause,
-			localVirtualHost, ch.conf.ProxyListenPort)
+			localVirtualHost, ch.listenPort)
 	default:
 		return nil, fmt.Errorf("expected intercepted query type: %v", interceptedQueryType)
 	}
@@ -1737,6 +2075,10 @@ func (ch *ClientHandler) handleExecuteRequest(
 		log.Tracef("Replacing prepared ID %s with %s for target cluster.",
 			hex.EncodeToString(originalQueryId), hex.EncodeToString(newTargetExecuteMsg.QueryId))
 
+		if len(newTargetExecuteMsg.ResultMetadataId) > 0 && len(preparedData.GetTargetResultMetadataId()) > 0 {
+			newTargetExecuteMsg.ResultMetadataId = preparedData.GetTargetResultMetadataId()
+		}
+
 		newTargetRequestRaw, err := defaultCodec.ConvertToRawFrame(newTargetRequest)
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("could not convert target EXECUTE response to raw frame: %w", err)
@@ -1842,14 +2184,26 @@ func (ch *ClientHandler) sendToAsyncConnector(
 		asyncRequest = asyncRequest.DeepCopy() // forwardToAsyncOnly requests don't need to be cloned because they are only sent to 1 connector
 	}
 
+	requestInfo := reqCtx.GetRequestInfo()
+	useKeyspace, isUse := getUseStatementKeyspace(frameContext)
 	if isFireAndForget {
+		if isUse {
+			requestInfo = newAsyncUseRequestInfo(requestInfo, useKeyspace)
+			ch.asyncConnector.asyncKeyspace.OnUseSent(useKeyspace)
+		} else {
+			ch.replayAsyncKeyspace(asyncRequest.Header.Version, requestTimeout)
+		}
 		ch.clientHandlerRequestWaitGroup.Add(1)
 	}
 
 	f := frameContext.GetRawFrame()
 
 	sent := ch.asyncConnector.sendAsyncRequestToCluster(
-		reqCtx.GetRequestInfo(), asyncRequest, !isFireAndForget, overallRequestStartTime, requestTimeout, func() {
+		requestInfo, asyncRequest, !isFireAndForget, overallRequestStartTime, requestTimeout,
+		reqCtx.dualReadComparison, func() {
+			if isUse {
+				ch.asyncConnector.asyncKeyspace.OnUseFailed(useKeyspace)
+			}
 			if !isFireAndForget {
 				ch.closedRespChannelLock.RLock()
 				defer ch.closedRespChannelLock.RUnlock()
@@ -1867,6 +2221,9 @@ func (ch *ClientHandler) sendToAsyncConnector(
 		})
 
 	if !sent {
+		if isUse {
+			ch.asyncConnector.asyncKeyspace.OnUseFailed(useKeyspace)
+		}
 		if !isFireAndForget {
 			if reqCtx.Cancel(ch.nodeMetrics) {
 				ch.cancelRequest(holder, reqCtx)
@@ -1894,6 +2251,8 @@ func (ch *ClientHandler) aggregateAndTrackResponses(
 	log.Tracef("Aggregating responses. %v opcode %d, %v opcode %d",
 		common.ClusterTypeOrigin, originOpCode, common.ClusterTypeTarget, responseFromTargetCassandra.Header.OpCode)
 
+	responseCluster := ch.getDualWriteResponseCluster(requestInfo)
+
 	// aggregate responses and update relevant aggregate metrics for general failed or successful responses
 	if isResponseSuccessful(responseFromOriginCassandra) && isResponseSuccessful(responseFromTargetCassandra) {
 		if originOpCode == primitive.OpCodeSupported {
@@ -1904,7 +2263,8 @@ func (ch *ClientHandler) aggregateAndTrackResponses(
 			// special case for PREPARE requests to always return ORIGIN, even though the default handling for "BOTH" requests would be enough
 			return responseFromOriginCassandra, common.ClusterTypeOrigin
 		} else {
-			if ch.primaryCluster == common.ClusterTypeTarget {
+			if responseCluster == common.ClusterTypeTarget ||
+				(responseCluster == common.ClusterTypeNone && ch.primaryCluster == common.ClusterTypeTarget) {
 				log.Tracef("Aggregated response: both successes, sending back %v response with opcode %d",
 					common.ClusterTypeTarget, responseFromTargetCassandra.Header.OpCode)
 				return responseFromTargetCassandra, common.ClusterTypeTarget
@@ -1916,13 +2276,40 @@ func (ch *ClientHandler) aggregateAndTrackResponses(
 		}
 	}
 
+	// UNPREPARED responses are not tracked as failed writes, the client prepares the statement again and retries it
 	proxyMetrics := ch.metricHandler.GetProxyMetrics()
+	failedOnOrigin := !isResponseSuccessful(responseFromOriginCassandra) && !isUnpreparedResponse(responseFromOriginCassandra)
+	failedOnTarget := !isResponseSuccessful(responseFromTargetCassandra) && !isUnpreparedResponse(responseFromTargetCassandra)
+	if requestInfo.ShouldBeTrackedInMetrics() {
+		if failedOnOrigin && failedOnTarget {
+			proxyMetrics.FailedWritesOnBoth.Add(1)
+		} else if failedOnOrigin {
+			proxyMetrics.FailedWritesOnOrigin.Add(1)
+		} else if failedOnTarget {
+			proxyMetrics.FailedWritesOnTarget.Add(1)
+		}
+	}
+
 	if !isResponseSuccessful(responseFromOriginCassandra) && !isResponseSuccessful(responseFromTargetCassandra) {
+		if responseCluster == common.ClusterTypeTarget {
+			log.Debugf("Aggregated response: both failures, sending back %v response with opcode %d",
+				common.ClusterTypeTarget, responseFromTargetCassandra.Header.OpCode)
+			return responseFromTargetCassandra, common.ClusterTypeTarget
+		}
 		log.Debugf("Aggregated response: both failures, sending back %v response with opcode %d",
 			common.ClusterTypeOrigin, originOpCode)
-		if requestInfo.ShouldBeTrackedInMetrics() {
-			proxyMetrics.FailedWritesOnBoth.Add(1)
-		}
+		return responseFromOriginCassandra, common.ClusterTypeOrigin
+	}
+
+	// best effort writes (see ZDM_DUAL_WRITES_FAILURES) and writes with a response cluster
+	// (see ZDM_DUAL_WRITES_RESPONSE_CLUSTER) don't return the failures of the other cluster
+	if responseCluster == common.ClusterTypeTarget && failedOnOrigin {
+		log.Debugf("Aggregated response: write failed only on %v, sending back %v response with opcode %d",
+			common.ClusterTypeOrigin, common.ClusterTypeTarget, responseFromTargetCassandra.Header.OpCode)
+		return responseFromTargetCassandra, common.ClusterTypeTarget
+	} else if responseCluster == common.ClusterTypeOrigin && failedOnTarget {
+		log.Debugf("Aggregated response: write failed only on %v, sending back %v response with opcode %d",
+			common.ClusterTypeTarget, common.ClusterTypeOrigin, originOpCode)
 		return responseFromOriginCassandra, common.ClusterTypeOrigin
 	}
 
@@ -1930,20 +2317,34 @@ func (ch *ClientHandler) aggregateAndTrackResponses(
 	if !isResponseSuccessful(responseFromOriginCassandra) {
 		log.Debugf("Aggregated response: failure only on %v, sending back %v response with opcode %d",
 			common.ClusterTypeOrigin, common.ClusterTypeOrigin, originOpCode)
-		if requestInfo.ShouldBeTrackedInMetrics() {
-			proxyMetrics.FailedWritesOnOrigin.Add(1)
-		}
 		return responseFromOriginCassandra, common.ClusterTypeOrigin
 	} else {
 		log.Debugf("Aggregated response: failure only on %v, sending back %v response with opcode %d",
 			common.ClusterTypeTarget, common.ClusterTypeTarget, originOpCode)
-		if requestInfo.ShouldBeTrackedInMetrics() {
-			proxyMetrics.FailedWritesOnTarget.Add(1)
-		}
 		return responseFromTargetCassandra, common.ClusterTypeTarget
 	}
 }
 
+// getDualWriteResponseCluster returns the cluster whose response is returned for a write sent to both clusters even if
+// the write failed on the other cluster: ORIGIN for USE requests of keyspaces that are out of the scope of the migration,
+// the cluster of ZDM_DUAL_WRITES_RESPONSE_CLUSTER if set, the primary cluster for best effort writes or
+// common.ClusterTypeNone if a failure on either cluster is returned.
+func (ch *ClientHandler) getDualWriteResponseCluster(requestInfo RequestInfo) common.ClusterType {
+	if _, ok := requestInfo.(*ExcludedKeyspaceUseRequestInfo); ok {
+		return common.ClusterTypeOrigin
+	}
+	if ch.dualWritesResponseCluster != common.ClusterTypeNone {
+		return ch.dualWritesResponseCluster
+	}
+	if isBestEffortWrite(requestInfo) {
+		if ch.primaryCluster == common.ClusterTypeTarget {
+			return common.ClusterTypeTarget
+		}
+		return common.ClusterTypeOrigin
+	}
+	return common.ClusterTypeNone
+}
+
 // Replaces the credentials in the provided auth frame (which are the Target credentials) with
 // the Origin credentials that are provided to the proxy in the configuration.
 func (ch *ClientHandler) handleClientCredentials(f *frame.RawFrame) (*frame.RawFrame, error) {
@@ -2067,6 +2468,29 @@ func isResponseSuccessful(response *frame.RawFrame) bool {
 	return response.Header.OpCode != primitive.OpCodeError
 }
 
+// isFailedDualWrite returns true if a request sent to both clusters failed or timed out on either cluster,
+// UNPREPARED responses are not failures.
+func isFailedDualWrite(reqCtx *requestContextImpl) bool {
+	for _, response := range []*frame.RawFrame{reqCtx.originResponse, reqCtx.targetResponse} {
+		if response == nil || (!isResponseSuccessful(response) && !isUnpreparedResponse(response)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnpreparedResponse(response *frame.RawFrame) bool {
+	if response.Header.OpCode != primitive.OpCodeError {
+		return false
+	}
+	errorMsg, err := decodeErrorResult(response)
+	if err != nil {
+		log.Debugf("Could not decode error response: %v", err)
+		return false
+	}
+	return errorMsg.GetErrorCode() == primitive.ErrorCodeUnprepared
+}
+
 func createUnpreparedFrame(errVal *UnpreparedExecuteError) (*frame.RawFrame, error) {
 	unpreparedMsg := &message.Unprepared{
 		ErrorMessage: fmt.Sprintf("Prepared query with ID %s not found (either the query was not prepared "+
@@ -2213,8 +2637,8 @@ func checkUnsupportedProtocolError(err error) *message.ProtocolError {
 }
 
 // checkProtocolVersion handles the case where the protocol library does not return an error but the proxy does not support a specific version
-func checkProtocolVersion(version primitive.ProtocolVersion) *message.ProtocolError {
-	if version < primitive.ProtocolVersion5 || version.IsDse() {
+func checkProtocolVersion(version primitive.ProtocolVersion, protocolV5Enabled bool) *message.ProtocolError {
+	if version < primitive.ProtocolVersion5 || version.IsDse() || (protocolV5Enabled && version == primitive.ProtocolVersion5) {
 		return nil
 	}
 
@@ -2243,12 +2667,21 @@ func NewProtocolEventObserver(cancelFunc context.CancelFunc, host *Host) *protoc
 }
 
 func (recv *protocolEventObserverImpl) OnHostRemoved(host *Host) {
-	if recv.connectionHost.HostId == host.HostId {
+	if recv.connectionHost != nil && recv.connectionHost.HostId == host.HostId {
 		log.Infof("Host used in connection was removed, closing connection: %v", host)
 		recv.cancelFn()
 	}
 }
 
+// OnContactPointsChanged drains the client connection if its cluster connection was opened to a contact point
+// (i.e. host assignment is disabled).
+func (recv *protocolEventObserverImpl) OnContactPointsChanged() {
+	if recv.connectionHost == nil {
+		log.Infof("Addresses of the contact point used in connection changed, closing connection.")
+		recv.cancelFn()
+	}
+}
+
 func (recv *protocolEventObserverImpl) GetHost() *Host {
 	return recv.connectionHost
 }
@@ -1,10 +1,14 @@
 package zdmproxy
 
 import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/stretchr/testify/require"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestMaxStreamIds(t *testing.T) {
@@ -32,3 +36,153 @@ func TestMaxStreamIds(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldForwardEvent(t *testing.T) {
+	addr := &primitive.Inet{Addr: net.ParseIP("10.0.0.1"), Port: 9042}
+	schemaChange := &message.SchemaChangeEvent{
+		ChangeType: primitive.SchemaChangeTypeCreated, Target: primitive.SchemaChangeTargetKeyspace, Keyspace: "ks"}
+	statusChange := &message.StatusChangeEvent{ChangeType: primitive.StatusChangeTypeDown, Address: addr}
+	topologyChange := &message.TopologyChangeEvent{ChangeType: primitive.TopologyChangeTypeNewNode, Address: addr}
+	tests := []struct {
+		name                  string
+		msg                   message.Message
+		fromTarget            bool
+		virtualizationEnabled bool
+		expected              bool
+	}{
+		{"schema change from origin", schemaChange, false, true, true},
+		{"schema change from target", schemaChange, true, true, false},
+		{"status change with virtualization", statusChange, true, true, false},
+		{"status change from origin", statusChange, false, false, false},
+		{"status change from target", statusChange, true, false, true},
+		{"topology change with virtualization", topologyChange, true, true, false},
+		{"topology change from origin", topologyChange, false, false, false},
+		{"topology change from target", topologyChange, true, false, true},
+		{"protocol error", &message.ProtocolError{ErrorMessage: "err"}, false, true, true},
+		{"not an event", &message.Ready{}, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, shouldForwardEvent(tt.msg, tt.fromTarget, tt.virtualizationEnabled))
+		})
+	}
+}
+
+func TestIsUnpreparedResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      message.Message
+		expected bool
+	}{
+		{"unprepared", &message.Unprepared{ErrorMessage: "unprepared", Id: []byte{1, 2}}, true},
+		{"overloaded", &message.Overloaded{ErrorMessage: "overloaded"}, false},
+		{"void", &message.VoidResult{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, isUnpreparedResponse(mockFrame(t, tt.msg, primitive.ProtocolVersion4)))
+		})
+	}
+}
+
+func TestAggregateDualWriteResponses(t *testing.T) {
+	void := &message.VoidResult{}
+	writeTimeout := &message.WriteTimeout{
+		ErrorMessage: "write timeout", Consistency: primitive.ConsistencyLevelQuorum, WriteType: primitive.WriteTypeSimple}
+	serverError := &message.ServerError{ErrorMessage: "server error"}
+	tests := []struct {
+		name            string
+		primaryCluster  common.ClusterType
+		responseCluster common.ClusterType
+		originResponse  message.Message
+		targetResponse  message.Message
+		expected        common.ClusterType
+	}{
+		{"default both successes", common.ClusterTypeOrigin, common.ClusterTypeNone, void, void, common.ClusterTypeOrigin},
+		{"default both successes target primary", common.ClusterTypeTarget, common.ClusterTypeNone, void, void, common.ClusterTypeTarget},
+		{"default failure on target", common.ClusterTypeOrigin, common.ClusterTypeNone, void, writeTimeout, common.ClusterTypeTarget},
+		{"default failure on origin", common.ClusterTypeTarget, common.ClusterTypeNone, writeTimeout, void, common.ClusterTypeOrigin},
+		{"default both failures", common.ClusterTypeTarget, common.ClusterTypeNone, writeTimeout, serverError, common.ClusterTypeOrigin},
+		{"origin both successes", common.ClusterTypeTarget, common.ClusterTypeOrigin, void, void, common.ClusterTypeOrigin},
+		{"origin failure on target", common.ClusterTypeOrigin, common.ClusterTypeOrigin, void, writeTimeout, common.ClusterTypeOrigin},
+		{"origin failure on origin", common.ClusterTypeOrigin, common.ClusterTypeOrigin, writeTimeout, void, common.ClusterTypeOrigin},
+		{"target both successes", common.ClusterTypeOrigin, common.ClusterTypeTarget, void, void, common.ClusterTypeTarget},
+		{"target failure on origin", common.ClusterTypeOrigin, common.ClusterTypeTarget, writeTimeout, void, common.ClusterTypeTarget},
+		{"target failure on target", common.ClusterTypeOrigin, common.ClusterTypeTarget, void, writeTimeout, common.ClusterTypeTarget},
+		{"target both failures", common.ClusterTypeOrigin, common.ClusterTypeTarget, writeTimeout, serverError, common.ClusterTypeTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{
+				metricHandler:             newFakeMetricHandler(),
+				primaryCluster:            tt.primaryCluster,
+				dualWritesResponseCluster: tt.responseCluster,
+			}
+			request := mockFrame(t, &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"}, primitive.ProtocolVersion4)
+			originResponse := mockFrame(t, tt.originResponse, primitive.ProtocolVersion4)
+			targetResponse := mockFrame(t, tt.targetResponse, primitive.ProtocolVersion4)
+			response, cluster := ch.aggregateAndTrackResponses(
+				NewGenericRequestInfo(forwardToBoth, false, true), request, originResponse, targetResponse)
+			require.Equal(t, tt.expected, cluster)
+			if tt.expected == common.ClusterTypeTarget {
+				require.Same(t, targetResponse, response)
+			} else {
+				require.Same(t, originResponse, response)
+			}
+		})
+	}
+}
+
+func TestComputeTimedOutDualWriteResponse(t *testing.T) {
+	void := &message.VoidResult{}
+	tests := []struct {
+		name            string
+		responseCluster common.ClusterType
+		request         message.Message
+		originResponse  message.Message
+		targetResponse  message.Message
+		expected        common.ClusterType // ClusterTypeNone if no response is returned to the client
+	}{
+		{"default timeout on target", common.ClusterTypeNone, &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			void, nil, common.ClusterTypeNone},
+		{"origin timeout on target", common.ClusterTypeOrigin, &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			void, nil, common.ClusterTypeOrigin},
+		{"origin timeout on origin", common.ClusterTypeOrigin, &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			nil, void, common.ClusterTypeNone},
+		{"target timeout on origin", common.ClusterTypeTarget, &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			nil, void, common.ClusterTypeTarget},
+		{"target timeout on both", common.ClusterTypeTarget, &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			nil, nil, common.ClusterTypeNone},
+		{"origin prepare timeout on target", common.ClusterTypeOrigin, &message.Prepare{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			&message.PreparedResult{PreparedQueryId: []byte("id")}, nil, common.ClusterTypeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{
+				metricHandler:             newFakeMetricHandler(),
+				primaryCluster:            common.ClusterTypeOrigin,
+				dualWritesResponseCluster: tt.responseCluster,
+			}
+			reqCtx := NewRequestContext(mockFrame(t, tt.request, primitive.ProtocolVersion4),
+				NewGenericRequestInfo(forwardToBoth, false, true), time.Now(), nil)
+			if tt.originResponse != nil {
+				reqCtx.originResponse = mockFrame(t, tt.originResponse, primitive.ProtocolVersion4)
+			}
+			if tt.targetResponse != nil {
+				reqCtx.targetResponse = mockFrame(t, tt.targetResponse, primitive.ProtocolVersion4)
+			}
+			response, cluster, err := ch.computeClientResponse(reqCtx)
+			require.Equal(t, tt.expected, cluster)
+			switch tt.expected {
+			case common.ClusterTypeNone:
+				require.NotNil(t, err)
+			case common.ClusterTypeOrigin:
+				require.Nil(t, err)
+				require.Same(t, reqCtx.originResponse, response)
+			case common.ClusterTypeTarget:
+				require.Nil(t, err)
+				require.Same(t, reqCtx.targetResponse, response)
+			}
+		})
+	}
+}
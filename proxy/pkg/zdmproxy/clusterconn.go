@@ -70,6 +70,9 @@ type ClusterConnector struct {
 	asyncConnector       bool
 	asyncConnectorState  ConnectorState
 	asyncPendingRequests *pendingRequests
+	asyncKeyspace        *asyncKeyspaceTracker
+
+	targetTableNameQualifier *tableNameQualifier
 
 	readScheduler *Scheduler
 
@@ -77,6 +80,9 @@ type ClusterConnector struct {
 	lastHeartbeatLock sync.Mutex
 
 	ccProtoVer primitive.ProtocolVersion
+
+	compression *frameCompression
+	framing     *segmentFraming
 }
 
 func NewClusterConnectionInfo(connConfig ConnectionConfig, endpointConfig Endpoint, isOriginCassandra bool) *ClusterConnectionInfo {
@@ -120,13 +126,8 @@ func NewClusterConnector(
 		connectorType = ClusterConnectorTypeAsync
 	}
 
-	conn, timeoutCtx, err := openConnectionToCluster(connInfo, clientHandlerContext, connectorType, nodeMetrics)
+	conn, err := openConnectionToCluster(connInfo, clientHandlerContext, connectorType, nodeMetrics)
 	if err != nil {
-		if errors.Is(err, ShutdownErr) {
-			if timeoutCtx.Err() != nil {
-				return nil, fmt.Errorf("%s context timed out or cancelled while opening connection to %v: %w", connectorType, clusterType, timeoutCtx.Err())
-			}
-		}
 		return nil, fmt.Errorf("%s could not open connection to %v: %w", connectorType, clusterType, err)
 	}
 
@@ -143,15 +144,20 @@ func NewClusterConnector(
 
 	cancelFn := clusterConnCancelFn
 	var clusterConnEventsChan chan *frame.RawFrame
+	var asyncKeyspace *asyncKeyspaceTracker
 	if !asyncConnector {
 		cancelFn = clientHandlerCancelFunc
 		clusterConnEventsChan = make(chan *frame.RawFrame, conf.EventQueueSizeFrames)
+	} else {
+		asyncKeyspace = newAsyncKeyspaceTracker()
 	}
 
 	// Initialize heartbeat time
 	lastHeartbeatTime := &atomic.Value{}
 	lastHeartbeatTime.Store(time.Now())
 
+	compression := newFrameCompression()
+	framing := newSegmentFraming(compression)
 	return &ClusterConnector{
 		conf:                   conf,
 		connection:             conn,
@@ -173,7 +179,9 @@ func NewClusterConnector(
 			string(connectorType),
 			true,
 			asyncConnector,
-			writeScheduler),
+			writeScheduler,
+			compression,
+			framing),
 		responseChan:                responseChan,
 		frameProcessor:              frameProcessor,
 		responseReadBufferSizeBytes: conf.ResponseReadBufferSizeBytes,
@@ -182,9 +190,13 @@ func NewClusterConnector(
 		asyncConnector:              asyncConnector,
 		asyncConnectorState:         ConnectorStateHandshake,
 		asyncPendingRequests:        asyncPendingRequests,
+		asyncKeyspace:               asyncKeyspace,
+		targetTableNameQualifier:    newTableNameQualifier(conf.TargetQualifyTableNames),
 		handshakeDone:               handshakeDone,
 		lastHeartbeatTime:           lastHeartbeatTime,
 		ccProtoVer:                  ccProtoVer,
+		compression:                 compression,
+		framing:                     framing,
 	}, nil
 }
 
@@ -193,12 +205,12 @@ func (cc *ClusterConnector) run() {
 	cc.writeCoalescer.RunWriteQueueLoop()
 }
 
-func openConnectionToCluster(connInfo *ClusterConnectionInfo, context context.Context, connectorType ClusterConnectorType, nodeMetrics *metrics.NodeMetrics) (net.Conn, context.Context, error) {
+func openConnectionToCluster(connInfo *ClusterConnectionInfo, context context.Context, connectorType ClusterConnectorType, nodeMetrics *metrics.NodeMetrics) (net.Conn, error) {
 	clusterType := connInfo.connConfig.GetClusterType()
 	log.Infof("[%s] Opening request connection to %v (%v).", connectorType, clusterType, connInfo.endpoint.GetEndpointIdentifier())
-	conn, timeoutCtx, err := openConnection(connInfo.connConfig, connInfo.endpoint, context, true)
+	conn, err := openConnection(connInfo.connConfig, connInfo.endpoint, context, true)
 	if err != nil {
-		return nil, timeoutCtx, err
+		return nil, err
 	}
 
 	nodeMetricsInstance, err := GetNodeMetricsByClusterConnector(nodeMetrics, connectorType)
@@ -209,7 +221,7 @@ func openConnectionToCluster(connInfo *ClusterConnectionInfo, context context.Co
 	}
 
 	log.Infof("[%s] Request connection to %v (%v) has been opened.", connectorType, clusterType, conn.RemoteAddr())
-	return conn, timeoutCtx, nil
+	return conn, nil
 }
 
 func closeConnectionToCluster(conn net.Conn, clusterType common.ClusterType, connectorClusterType ClusterConnectorType, nodeMetrics *metrics.NodeMetrics) {
@@ -244,14 +256,17 @@ func (cc *ClusterConnector) runResponseListeningLoop() {
 		defer close(cc.doneChan)
 		defer atomic.StoreInt32(&cc.asyncConnectorState, ConnectorStateShutdown)
 
-		bufferedReader := bufio.NewReaderSize(cc.connection, cc.responseReadBufferSizeBytes)
+		reader := newFrameReader(bufio.NewReaderSize(cc.connection, cc.responseReadBufferSizeBytes), cc.framing, 0)
 		connectionAddr := cc.connection.RemoteAddr().String()
 		wg := &sync.WaitGroup{}
 		defer wg.Wait()
 		protocolErrOccurred := false
 		for {
-			response, err := readRawFrame(bufferedReader, connectionAddr, cc.clusterConnContext)
-			protocolErrResponseFrame, err, errCode := checkProtocolError(response, cc.ccProtoVer, err, protocolErrOccurred, string(cc.connectorType))
+			response, err := reader.ReadFrame(connectionAddr, cc.clusterConnContext)
+			if err == nil {
+				response, err = cc.compression.Decompress(response)
+			}
+			protocolErrResponseFrame, err, errCode := checkProtocolError(response, cc.ccProtoVer, cc.conf.EnableProtocolV5, err, protocolErrOccurred, string(cc.connectorType))
 
 			if err != nil {
 				handleConnectionError(
@@ -348,6 +363,9 @@ func (cc *ClusterConnector) handleAsyncResponse(response *frame.RawFrame) *frame
 			return response
 		} else {
 			callDone := true
+			if useRequestInfo, ok := reqCtx.GetRequestInfo().(*asyncUseRequestInfo); ok && errMsg != nil {
+				cc.asyncKeyspace.OnUseFailed(useRequestInfo.keyspace)
+			}
 			if errMsg != nil {
 				if reqCtx.GetRequestInfo().ShouldBeTrackedInMetrics() {
 					trackClusterErrorMetricsFromErrorMessage(errMsg, cc.connectorType, cc.nodeMetrics)
@@ -371,12 +389,17 @@ func (cc *ClusterConnector) handleAsyncResponse(response *frame.RawFrame) *frame
 						}
 						prepareFrame := frame.NewFrame(response.Header.Version, response.Header.StreamId, prepare)
 						prepareRawFrame, err := defaultCodec.ConvertToRawFrame(prepareFrame)
+						if err == nil && cc.clusterType == common.ClusterTypeTarget {
+							// the prepared id is the one of the PREPARE request with qualified table names
+							prepareRawFrame, err = cc.targetTableNameQualifier.Apply(prepareRawFrame, cc.asyncKeyspace.Keyspace())
+						}
 						if err != nil {
-							log.Errorf("Could not send async PREPARE because convert raw frame failed: %v.", err.Error())
+							log.Errorf("Could not send async PREPARE: %v.", err.Error())
 						} else {
 							sent := cc.sendAsyncRequestToCluster(
 								preparedData.GetPrepareRequestInfo(), prepareRawFrame, false, time.Now(),
 								time.Duration(cc.conf.ProxyRequestTimeoutMs)*time.Millisecond,
+								nil,
 								func() {
 									cc.clientHandlerRequestWg.Done()
 								})
@@ -390,6 +413,10 @@ func (cc *ClusterConnector) handleAsyncResponse(response *frame.RawFrame) *frame
 				}
 			}
 
+			if _, unprepared := errMsg.(*message.Unprepared); !unprepared && typedReqCtx.dualReadComparison != nil {
+				typedReqCtx.dualReadComparison.SetSecondaryResult(response)
+			}
+
 			if callDone {
 				cc.clientHandlerRequestWg.Done()
 			}
@@ -471,12 +498,14 @@ func (cc *ClusterConnector) sendAsyncRequestToCluster(
 	expectedResponse bool,
 	overallRequestStartTime time.Time,
 	requestTimeout time.Duration,
+	dualReadComparison *dualReadComparison,
 	onTimeout func()) bool {
 
 	if !cc.validateAsyncStateForRequest(asyncRequest) {
 		return false
 	}
-	asyncReqCtx := NewAsyncRequestContext(requestInfo, asyncRequest.Header.StreamId, expectedResponse, overallRequestStartTime)
+	asyncReqCtx := NewAsyncRequestContext(
+		requestInfo, asyncRequest.Header.StreamId, expectedResponse, overallRequestStartTime, dualReadComparison)
 
 	var err error
 	asyncRequest, err = cc.frameProcessor.AssignUniqueId(asyncRequest)
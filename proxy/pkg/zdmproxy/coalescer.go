@@ -32,6 +32,9 @@ type writeCoalescer struct {
 	writeBufferSizeBytes int
 
 	scheduler *Scheduler
+
+	compression *frameCompression
+	framing     *segmentFraming
 }
 
 func NewWriteCoalescer(
@@ -43,7 +46,9 @@ func NewWriteCoalescer(
 	logPrefix string,
 	isRequest bool,
 	isAsync bool,
-	scheduler *Scheduler) *writeCoalescer {
+	scheduler *Scheduler,
+	compression *frameCompression,
+	framing *segmentFraming) *writeCoalescer {
 
 	writeQueueSizeFrames := conf.RequestWriteQueueSizeFrames
 	if !isRequest {
@@ -71,6 +76,8 @@ func NewWriteCoalescer(
 		waitGroup:              &sync.WaitGroup{},
 		writeBufferSizeBytes:   writeBufferSizeBytes,
 		scheduler:              scheduler,
+		compression:            compression,
+		framing:                framing,
 	}
 }
 
@@ -137,7 +144,16 @@ func (recv *writeCoalescer) RunWriteQueueLoop() {
 					}
 
 					log.Tracef("[%v] Writing %v on %v", recv.logPrefix, f.Header, connectionAddr)
-					err := writeRawFrame(tempBuffer, connectionAddr, recv.shutdownContext, f)
+					err := recv.compression.SetFromStartup(f)
+					if err != nil {
+						log.Warnf("[%v] Could not set compression from startup request, "+
+							"frames will not be compressed on %v: %v", recv.logPrefix, connectionAddr, err)
+					}
+					f, err = recv.compression.Compress(f)
+					if err == nil {
+						err = writeRawFrame(tempBuffer, connectionAddr, recv.shutdownContext, f, recv.framing)
+					}
+					recv.framing.Update(f)
 					if err != nil {
 						tempDraining = true
 						handleConnectionError(err, recv.shutdownContext, recv.cancelFunc, recv.logPrefix, "writing", connectionAddr)
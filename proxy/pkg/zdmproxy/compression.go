@@ -0,0 +1,179 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/compression/lz4"
+	"github.com/datastax/go-cassandra-native-protocol/compression/snappy"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync/atomic"
+)
+
+// frameCompression keeps track of the body compression algorithm that was negotiated on a single connection
+// with the COMPRESSION option of the STARTUP request.
+//
+// Frames are decompressed right after they are read from the connection and compressed right before they are written
+// to it so the rest of the proxy (query inspection, stream id mapping, prepared statement handling, etc.)
+// only ever deals with uncompressed frame bodies.
+type frameCompression struct {
+	compressor *atomic.Value
+}
+
+type bodyCompressorHolder struct {
+	algorithm  primitive.Compression
+	compressor frame.BodyCompressor
+}
+
+func newFrameCompression() *frameCompression {
+	compressor := &atomic.Value{}
+	compressor.Store(&bodyCompressorHolder{algorithm: primitive.CompressionNone, compressor: nil})
+	return &frameCompression{compressor: compressor}
+}
+
+func (recv *frameCompression) GetAlgorithm() primitive.Compression {
+	return recv.compressor.Load().(*bodyCompressorHolder).algorithm
+}
+
+func (recv *frameCompression) getCompressor() frame.BodyCompressor {
+	return recv.compressor.Load().(*bodyCompressorHolder).compressor
+}
+
+// SetFromStartup sets the compression algorithm of this connection based on the COMPRESSION option of the provided
+// STARTUP request. Frames with other opcodes are ignored.
+//
+// An unknown algorithm returns an error and leaves the connection uncompressed, the cluster will reject
+// that STARTUP request anyway.
+func (recv *frameCompression) SetFromStartup(startup *frame.RawFrame) error {
+	if startup.Header.OpCode != primitive.OpCodeStartup {
+		return nil
+	}
+
+	body, err := defaultCodec.DecodeBody(startup.Header, bytes.NewReader(startup.Body))
+	if err != nil {
+		return fmt.Errorf("could not decode startup body: %w", err)
+	}
+
+	startupMsg, ok := body.Message.(*message.Startup)
+	if !ok {
+		return fmt.Errorf("expected startup message but got %T", body.Message)
+	}
+
+	algorithm := primitive.Compression(strings.ToUpper(string(startupMsg.GetCompression())))
+	compressor, err := newBodyCompressor(algorithm)
+	if err != nil {
+		return err
+	}
+
+	recv.compressor.Store(&bodyCompressorHolder{algorithm: algorithm, compressor: compressor})
+	return nil
+}
+
+// Decompress returns an uncompressed copy of the provided frame or the frame itself if it is not compressed.
+func (recv *frameCompression) Decompress(f *frame.RawFrame) (*frame.RawFrame, error) {
+	if !f.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
+		return f, nil
+	}
+
+	compressor := recv.getCompressor()
+	if compressor == nil {
+		return nil, fmt.Errorf("received compressed frame (%v) but no compression algorithm was negotiated", f.Header)
+	}
+
+	decompressedBody := &bytes.Buffer{}
+	err := compressor.DecompressWithLength(bytes.NewReader(f.Body), decompressedBody)
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress body of frame (%v) using %v: %w", f.Header, recv.GetAlgorithm(), err)
+	}
+
+	newHeader := f.Header.DeepCopy()
+	newHeader.Flags = newHeader.Flags.Remove(primitive.HeaderFlagCompressed)
+	newHeader.BodyLength = int32(decompressedBody.Len())
+	return &frame.RawFrame{
+		Header: newHeader,
+		Body:   decompressedBody.Bytes(),
+	}, nil
+}
+
+// Compress returns a compressed copy of the provided frame if a compression algorithm was negotiated on this
+// connection, otherwise it returns the frame itself. STARTUP requests are never compressed and neither are protocol v5
+// frames because compression is applied to the segments that wrap them (see segmentFraming).
+func (recv *frameCompression) Compress(f *frame.RawFrame) (*frame.RawFrame, error) {
+	compressor := recv.getCompressor()
+	if compressor == nil ||
+		protocolUsesSegments(f.Header.Version) ||
+		f.Header.OpCode == primitive.OpCodeStartup ||
+		f.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
+		return f, nil
+	}
+
+	compressedBody := &bytes.Buffer{}
+	err := compressor.CompressWithLength(bytes.NewReader(f.Body), compressedBody)
+	if err != nil {
+		return nil, fmt.Errorf("could not compress body of frame (%v) using %v: %w", f.Header, recv.GetAlgorithm(), err)
+	}
+
+	newHeader := f.Header.DeepCopy()
+	newHeader.Flags = newHeader.Flags.Add(primitive.HeaderFlagCompressed)
+	newHeader.BodyLength = int32(compressedBody.Len())
+	return &frame.RawFrame{
+		Header: newHeader,
+		Body:   compressedBody.Bytes(),
+	}, nil
+}
+
+func newBodyCompressor(algorithm primitive.Compression) (frame.BodyCompressor, error) {
+	switch algorithm {
+	case primitive.CompressionLz4:
+		return lz4.Compressor{}, nil
+	case primitive.CompressionSnappy:
+		return snappy.Compressor{}, nil
+	case primitive.CompressionNone, "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %v", algorithm)
+	}
+}
+
+// stripCompressionFromStartup returns a copy of the provided STARTUP request without the COMPRESSION option or
+// the request itself if the option is not there.
+func stripCompressionFromStartup(startup *frame.RawFrame) (*frame.RawFrame, error) {
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(startup)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode startup request: %w", err)
+	}
+
+	startupMsg, ok := decodedFrame.Body.Message.(*message.Startup)
+	if !ok {
+		return nil, fmt.Errorf("expected startup message but got %T", decodedFrame.Body.Message)
+	}
+
+	if _, found := startupMsg.Options[message.StartupOptionCompression]; !found {
+		return startup, nil
+	}
+
+	log.Debugf("Removing %v=%v from startup request.",
+		message.StartupOptionCompression, startupMsg.Options[message.StartupOptionCompression])
+	delete(startupMsg.Options, message.StartupOptionCompression)
+	return defaultCodec.ConvertToRawFrame(decodedFrame)
+}
+
+// stripCompressionFromSupported returns a copy of the provided SUPPORTED response without the COMPRESSION option
+// or nil if the option is not there.
+func stripCompressionFromSupported(decodedFrame *frame.Frame) *frame.Frame {
+	supportedMsg, ok := decodedFrame.Body.Message.(*message.Supported)
+	if !ok {
+		return nil
+	}
+
+	if _, found := supportedMsg.Options[message.StartupOptionCompression]; !found {
+		return nil
+	}
+
+	newFrame := decodedFrame.DeepCopy()
+	delete(newFrame.Body.Message.(*message.Supported).Options, message.StartupOptionCompression)
+	return newFrame
+}
@@ -0,0 +1,125 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFrameCompression_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+	}{
+		{"lz4", "lz4"},
+		{"LZ4", "LZ4"},
+		{"snappy", "snappy"},
+		{"SNAPPY", "SNAPPY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compression := newFrameCompression()
+			startup := buildStartupRawFrame(t, tt.compression)
+
+			err := compression.SetFromStartup(startup)
+			require.Nil(t, err)
+			require.NotEqual(t, primitive.CompressionNone, compression.GetAlgorithm())
+
+			compressedStartup, err := compression.Compress(startup)
+			require.Nil(t, err)
+			require.Same(t, startup, compressedStartup)
+
+			query := buildQueryRawFrame(t, "SELECT * FROM ks.tb WHERE key = 'abcdefghijklmnopqrstuvwxyz'")
+			compressed, err := compression.Compress(query)
+			require.Nil(t, err)
+			require.True(t, compressed.Header.Flags.Contains(primitive.HeaderFlagCompressed))
+			require.False(t, query.Header.Flags.Contains(primitive.HeaderFlagCompressed))
+			require.Equal(t, int32(len(compressed.Body)), compressed.Header.BodyLength)
+
+			decompressed, err := compression.Decompress(compressed)
+			require.Nil(t, err)
+			require.False(t, decompressed.Header.Flags.Contains(primitive.HeaderFlagCompressed))
+			require.Equal(t, query.Body, decompressed.Body)
+			require.Equal(t, int32(len(query.Body)), decompressed.Header.BodyLength)
+
+			decoded, err := defaultCodec.ConvertFromRawFrame(decompressed)
+			require.Nil(t, err)
+			require.Equal(t, "SELECT * FROM ks.tb WHERE key = 'abcdefghijklmnopqrstuvwxyz'", decoded.Body.Message.(*message.Query).Query)
+		})
+	}
+}
+
+func TestFrameCompression_NotNegotiated(t *testing.T) {
+	compression := newFrameCompression()
+	err := compression.SetFromStartup(buildStartupRawFrame(t, ""))
+	require.Nil(t, err)
+	require.Equal(t, primitive.CompressionNone, compression.GetAlgorithm())
+
+	query := buildQueryRawFrame(t, "SELECT * FROM ks.tb")
+	notCompressed, err := compression.Compress(query)
+	require.Nil(t, err)
+	require.Same(t, query, notCompressed)
+
+	compressedQuery := query.DeepCopy()
+	compressedQuery.Header.Flags = compressedQuery.Header.Flags.Add(primitive.HeaderFlagCompressed)
+	_, err = compression.Decompress(compressedQuery)
+	require.NotNil(t, err)
+}
+
+func TestFrameCompression_UnknownAlgorithm(t *testing.T) {
+	compression := newFrameCompression()
+	err := compression.SetFromStartup(buildStartupRawFrame(t, "deflate"))
+	require.NotNil(t, err)
+	require.Equal(t, primitive.CompressionNone, compression.GetAlgorithm())
+}
+
+func buildStartupRawFrame(t *testing.T, compression string) *frame.RawFrame {
+	startup := message.NewStartup()
+	if compression != "" {
+		startup.Options[message.StartupOptionCompression] = compression
+	}
+	f := frame.NewFrame(primitive.ProtocolVersion4, 0, startup)
+	rawFrame, err := defaultCodec.ConvertToRawFrame(f)
+	require.Nil(t, err)
+	return rawFrame
+}
+
+func buildQueryRawFrame(t *testing.T, query string) *frame.RawFrame {
+	f := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{Query: query, Options: &message.QueryOptions{}})
+	rawFrame, err := defaultCodec.ConvertToRawFrame(f)
+	require.Nil(t, err)
+	return rawFrame
+}
+
+func TestStripCompressionFromStartup(t *testing.T) {
+	startup := buildStartupRawFrame(t, "lz4")
+	stripped, err := stripCompressionFromStartup(startup)
+	require.Nil(t, err)
+	decoded, err := defaultCodec.ConvertFromRawFrame(stripped)
+	require.Nil(t, err)
+	startupMsg := decoded.Body.Message.(*message.Startup)
+	require.Equal(t, primitive.CompressionNone, startupMsg.GetCompression())
+	require.Equal(t, "3.0.0", startupMsg.Options[message.StartupOptionCqlVersion])
+
+	startup = buildStartupRawFrame(t, "")
+	stripped, err = stripCompressionFromStartup(startup)
+	require.Nil(t, err)
+	require.Same(t, startup, stripped)
+}
+
+func TestStripCompressionFromSupported(t *testing.T) {
+	supported := frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Supported{
+		Options: map[string][]string{
+			"CQL_VERSION":                    {"3.4.5"},
+			message.StartupOptionCompression: {"lz4", "snappy"},
+		},
+	})
+	stripped := stripCompressionFromSupported(supported)
+	require.NotNil(t, stripped)
+	require.Equal(t, map[string][]string{"CQL_VERSION": {"3.4.5"}}, stripped.Body.Message.(*message.Supported).Options)
+	require.Contains(t, supported.Body.Message.(*message.Supported).Options, message.StartupOptionCompression)
+
+	require.Nil(t, stripCompressionFromSupported(stripped))
+}
@@ -0,0 +1,326 @@
+package zdmproxy
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ConfigCheckStatus is the result of a check of the configuration validation mode (--validate-config).
+type ConfigCheckStatus string
+
+const (
+	ConfigCheckOk      = ConfigCheckStatus("OK")
+	ConfigCheckWarning = ConfigCheckStatus("WARNING")
+	ConfigCheckFailed  = ConfigCheckStatus("FAILED")
+	ConfigCheckSkipped = ConfigCheckStatus("SKIPPED")
+)
+
+const (
+	// certificateExpiryWarningPeriod is how long before their expiration the TLS certificates get a warning.
+	certificateExpiryWarningPeriod = 30 * 24 * time.Hour
+
+	// maxConfigCheckDialTimeout is the maximum time to wait for a TCP connection to a contact point, the connection
+	// timeout of the cluster is used if it is lower.
+	maxConfigCheckDialTimeout = 5 * time.Second
+)
+
+type ConfigCheck struct {
+	Name    string
+	Status  ConfigCheckStatus
+	Details string
+}
+
+// ConfigReport is the report of the configuration validation mode, it has one entry per check.
+type ConfigReport struct {
+	Checks []*ConfigCheck
+}
+
+func (recv *ConfigReport) Add(name string, status ConfigCheckStatus, format string, args ...interface{}) {
+	recv.Checks = append(recv.Checks, &ConfigCheck{Name: name, Status: status, Details: fmt.Sprintf(format, args...)})
+}
+
+// Failed returns true if at least one check failed, warnings are not failures.
+func (recv *ConfigReport) Failed() bool {
+	for _, check := range recv.Checks {
+		if check.Status == ConfigCheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the report as a table followed by a summary line.
+func (recv *ConfigReport) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tCHECK\tDETAILS")
+	counts := make(map[ConfigCheckStatus]int)
+	for _, check := range recv.Checks {
+		counts[check.Status]++
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\n", check.Status, check.Name, check.Details)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+	if recv.Failed() {
+		_, err = fmt.Fprintf(w, "\nThe configuration is NOT valid: %d failed checks, %d warnings.\n",
+			counts[ConfigCheckFailed], counts[ConfigCheckWarning])
+	} else {
+		_, err = fmt.Fprintf(w, "\nThe configuration is valid: %d warnings.\n", counts[ConfigCheckWarning])
+	}
+	return err
+}
+
+// CheckConfig checks a loaded (and therefore syntactically valid) configuration against the environment the proxy
+// runs in: the format of the credentials, the TLS files, the reachability of the contact points and the ports that the
+// proxy listens on. This catches the problems that would otherwise only show up when the proxy starts or when the first
+// client connects.
+func CheckConfig(ctx context.Context, conf *config.Config) *ConfigReport {
+	report := &ConfigReport{}
+	report.Add("configuration", ConfigCheckOk, "loaded and validated")
+
+	checkCredentials(report, common.ClusterTypeOrigin, conf.OriginUsername, conf.ParseOriginPassword)
+	checkCredentials(report, common.ClusterTypeTarget, conf.TargetUsername, conf.ParseTargetPassword)
+
+	originTlsConfig := checkClusterTls(report, common.ClusterTypeOrigin, conf.ParseOriginTlsConfig)
+	targetTlsConfig := checkClusterTls(report, common.ClusterTypeTarget, conf.ParseTargetTlsConfig)
+	checkProxyTls(report, conf)
+
+	originContactPoints, err := conf.ParseOriginContactPoints()
+	if err == nil {
+		checkClusterReachability(ctx, report, common.ClusterTypeOrigin, originTlsConfig, originContactPoints,
+			conf.OriginPort, conf.OriginConnectionTimeoutMs, conf.ProxyTcpKeepAliveMs, conf.OriginLocalDatacenter)
+	}
+	targetContactPoints, err := conf.ParseTargetContactPoints()
+	if err == nil {
+		checkClusterReachability(ctx, report, common.ClusterTypeTarget, targetTlsConfig, targetContactPoints,
+			conf.TargetPort, conf.TargetConnectionTimeoutMs, conf.ProxyTcpKeepAliveMs, conf.TargetLocalDatacenter)
+	}
+
+	checkPorts(report, conf)
+	return report
+}
+
+func checkCredentials(
+	report *ConfigReport, clusterType common.ClusterType, username string, parsePassword func() (string, error)) {
+	name := fmt.Sprintf("%v credentials", strings.ToLower(string(clusterType)))
+	password, err := parsePassword()
+	switch {
+	case err != nil:
+		report.Add(name, ConfigCheckFailed, "%v", err)
+	case username != strings.TrimSpace(username):
+		report.Add(name, ConfigCheckWarning, "the username has leading or trailing whitespace")
+	case password != strings.TrimSpace(password):
+		report.Add(name, ConfigCheckWarning, "the password has leading or trailing whitespace")
+	case password == "":
+		report.Add(name, ConfigCheckWarning, "the password is empty")
+	default:
+		report.Add(name, ConfigCheckOk, "username %v with a password", username)
+	}
+}
+
+// checkClusterTls loads the TLS files (or the secure connect bundle) of a cluster, it returns nil if the TLS
+// configuration is not valid.
+func checkClusterTls(report *ConfigReport, clusterType common.ClusterType,
+	parseTlsConfig func(displayLogMessages bool) (*common.ClusterTlsConfig, error)) *common.ClusterTlsConfig {
+	name := fmt.Sprintf("%v TLS", strings.ToLower(string(clusterType)))
+	tlsConfig, err := parseTlsConfig(false)
+	if err != nil {
+		report.Add(name, ConfigCheckFailed, "%v", err)
+		return nil
+	}
+	if !tlsConfig.TlsEnabled {
+		report.Add(name, ConfigCheckOk, "disabled")
+		return tlsConfig
+	}
+
+	var caCert, clientCert []byte
+	if tlsConfig.SecureConnectBundlePath != "" {
+		fileMap, err := extractFilesFromZipArchive(tlsConfig.SecureConnectBundlePath)
+		if err != nil {
+			report.Add(name, ConfigCheckFailed, "could not read the secure connect bundle: %v", err)
+			return nil
+		}
+		hostName, _, err := parseHostAndPortFromSCBConfig(fileMap["config.json"])
+		if err == nil {
+			_, err = initializeTlsConfigurationFromSecureConnectBundle(fileMap, hostName, clusterType)
+		}
+		if err != nil {
+			report.Add(name, ConfigCheckFailed, "invalid secure connect bundle: %v", err)
+			return nil
+		}
+		caCert, clientCert = fileMap["ca.crt"], fileMap["cert"]
+	} else {
+		_, err = getClientSideTlsConfigFromProxyClusterTlsConfig(tlsConfig, clusterType)
+		if err != nil {
+			report.Add(name, ConfigCheckFailed, "%v", err)
+			return nil
+		}
+		caCert, _ = loadTlsFile(tlsConfig.ServerCaPath)
+		clientCert, _ = loadTlsFile(tlsConfig.ClientCertPath)
+	}
+
+	status, details := checkCertificatesExpiry(time.Now(), caCert, clientCert)
+	report.Add(name, status, "%v", details)
+	if status == ConfigCheckFailed {
+		return nil
+	}
+	return tlsConfig
+}
+
+func checkProxyTls(report *ConfigReport, conf *config.Config) {
+	name := "proxy TLS"
+	proxyTlsConfig, err := conf.ParseProxyTlsConfig(false)
+	if err != nil {
+		report.Add(name, ConfigCheckFailed, "%v", err)
+		return
+	}
+	if !proxyTlsConfig.TlsEnabled {
+		report.Add(name, ConfigCheckOk, "disabled")
+		return
+	}
+	_, err = getServerSideTlsConfigFromProxyClusterTlsConfig(proxyTlsConfig)
+	if err != nil {
+		report.Add(name, ConfigCheckFailed, "%v", err)
+		return
+	}
+	caCert, _ := loadTlsFile(proxyTlsConfig.ProxyCaPath)
+	cert, _ := loadTlsFile(proxyTlsConfig.ProxyCertPath)
+	status, details := checkCertificatesExpiry(time.Now(), caCert, cert)
+	report.Add(name, status, "%v", details)
+}
+
+// checkCertificatesExpiry returns FAILED if one of the certificates of the PEM files is expired (or not valid yet) and
+// WARNING if one of them expires within certificateExpiryWarningPeriod.
+func checkCertificatesExpiry(now time.Time, pemFiles ...[]byte) (ConfigCheckStatus, string) {
+	var firstExpiring *x509.Certificate
+	for _, pemFile := range pemFiles {
+		for block, rest := pem.Decode(pemFile); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return ConfigCheckFailed, fmt.Sprintf("could not parse certificate: %v", err)
+			}
+			if now.Before(cert.NotBefore) {
+				return ConfigCheckFailed, fmt.Sprintf("certificate %v is not valid before %v",
+					cert.Subject, cert.NotBefore.Format(time.RFC3339))
+			}
+			if firstExpiring == nil || cert.NotAfter.Before(firstExpiring.NotAfter) {
+				firstExpiring = cert
+			}
+		}
+	}
+	switch {
+	case firstExpiring == nil:
+		return ConfigCheckOk, "enabled"
+	case now.After(firstExpiring.NotAfter):
+		return ConfigCheckFailed, fmt.Sprintf("certificate %v expired on %v",
+			firstExpiring.Subject, firstExpiring.NotAfter.Format(time.RFC3339))
+	case now.Add(certificateExpiryWarningPeriod).After(firstExpiring.NotAfter):
+		return ConfigCheckWarning, fmt.Sprintf("certificate %v expires on %v",
+			firstExpiring.Subject, firstExpiring.NotAfter.Format(time.RFC3339))
+	default:
+		return ConfigCheckOk, fmt.Sprintf("enabled, certificates valid until %v",
+			firstExpiring.NotAfter.Format(time.RFC3339))
+	}
+}
+
+// checkClusterReachability opens a TCP connection to every contact point of a cluster (for Astra clusters the metadata
+// service is queried first to get the contact points). It fails if none of the contact points is reachable.
+func checkClusterReachability(ctx context.Context, report *ConfigReport, clusterType common.ClusterType,
+	tlsConfig *common.ClusterTlsConfig, contactPoints []string, port int, connTimeoutMs int, tcpKeepAliveMs int,
+	datacenter string) {
+	name := fmt.Sprintf("%v reachability", strings.ToLower(string(clusterType)))
+	if tlsConfig == nil {
+		report.Add(name, ConfigCheckSkipped, "the TLS configuration is not valid")
+		return
+	}
+
+	timeout := time.Duration(connTimeoutMs) * time.Millisecond
+	if timeout <= 0 || timeout > maxConfigCheckDialTimeout {
+		timeout = maxConfigCheckDialTimeout
+	}
+	timeoutCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	connConfig, err := InitializeConnectionConfig(
+		tlsConfig, contactPoints, port, connTimeoutMs, tcpKeepAliveMs, clusterType, datacenter, timeoutCtx)
+	if err != nil {
+		report.Add(name, ConfigCheckFailed, "%v", err)
+		return
+	}
+
+	var reachable, unreachable []string
+	dialer := &net.Dialer{Timeout: timeout}
+	for _, endpoint := range connConfig.GetContactPoints() {
+		address := endpoint.GetSocketEndpoint()
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("%v (%v)", address, err))
+			continue
+		}
+		_ = conn.Close()
+		reachable = append(reachable, address)
+	}
+	switch {
+	case len(reachable) == 0:
+		report.Add(name, ConfigCheckFailed, "no contact point is reachable: %v", strings.Join(unreachable, ", "))
+	case len(unreachable) > 0:
+		report.Add(name, ConfigCheckWarning, "reachable: %v, not reachable: %v",
+			strings.Join(reachable, ", "), strings.Join(unreachable, ", "))
+	default:
+		report.Add(name, ConfigCheckOk, "reachable: %v", strings.Join(reachable, ", "))
+	}
+}
+
+// checkPorts checks that the client listeners and the metrics endpoint don't use the same port and that the proxy can
+// listen on all of them (e.g. they are not used by another process).
+func checkPorts(report *ConfigReport, conf *config.Config) {
+	name := "ports"
+	listeners, err := conf.ParseProxyListeners()
+	if err != nil {
+		report.Add(name, ConfigCheckFailed, "%v", err)
+		return
+	}
+
+	addresses := make([]string, 0, len(listeners)+1)
+	for _, listener := range listeners {
+		if listener.Port == conf.MetricsPort && listenAddressesOverlap(conf.ProxyListenAddress, conf.MetricsAddress) {
+			report.Add(name, ConfigCheckFailed, "port %d is used by a client listener and by the metrics endpoint",
+				listener.Port)
+			return
+		}
+		addresses = append(addresses, net.JoinHostPort(conf.ProxyListenAddress, strconv.Itoa(listener.Port)))
+	}
+	addresses = append(addresses, net.JoinHostPort(conf.MetricsAddress, strconv.Itoa(conf.MetricsPort)))
+
+	for _, address := range addresses {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			report.Add(name, ConfigCheckFailed, "could not listen on %v: %v", address, err)
+			return
+		}
+		_ = l.Close()
+	}
+	report.Add(name, ConfigCheckOk, "client listeners on %v, metrics endpoint on %v",
+		strings.Join(addresses[:len(addresses)-1], ", "), addresses[len(addresses)-1])
+}
+
+// listenAddressesOverlap returns true if listening on the same port of both addresses would conflict.
+func listenAddressesOverlap(address1 string, address2 string) bool {
+	isWildcard := func(address string) bool {
+		return address == "" || address == "0.0.0.0" || address == "::"
+	}
+	return address1 == address2 || isWildcard(address1) || isWildcard(address2)
+}
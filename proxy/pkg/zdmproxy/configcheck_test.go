@@ -0,0 +1,154 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		err      error
+		expected ConfigCheckStatus
+	}{
+		{"valid", "user", "s3cr3t", nil, ConfigCheckOk},
+		{"password file error", "user", "", errors.New("could not read ZDM_ORIGIN_PASSWORD_FILE"), ConfigCheckFailed},
+		{"username whitespace", "user ", "s3cr3t", nil, ConfigCheckWarning},
+		{"password whitespace", "user", "s3cr3t\t", nil, ConfigCheckWarning},
+		{"empty password", "user", "", nil, ConfigCheckWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &ConfigReport{}
+			checkCredentials(report, common.ClusterTypeOrigin, tt.username, func() (string, error) {
+				return tt.password, tt.err
+			})
+			require.Len(t, report.Checks, 1)
+			require.Equal(t, "origin credentials", report.Checks[0].Name)
+			require.Equal(t, tt.expected, report.Checks[0].Status)
+			require.NotContains(t, report.Checks[0].Details, "s3cr3t")
+		})
+	}
+}
+
+func TestCheckCertificatesExpiry(t *testing.T) {
+	now := time.Now()
+	valid := newTestCertificatePem(t, now.Add(-time.Hour), now.Add(365*24*time.Hour))
+	expiringSoon := newTestCertificatePem(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	expired := newTestCertificatePem(t, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	notYetValid := newTestCertificatePem(t, now.Add(time.Hour), now.Add(48*time.Hour))
+
+	status, _ := checkCertificatesExpiry(now, valid)
+	require.Equal(t, ConfigCheckOk, status)
+	status, _ = checkCertificatesExpiry(now, valid, expiringSoon)
+	require.Equal(t, ConfigCheckWarning, status)
+	status, details := checkCertificatesExpiry(now, expired, valid)
+	require.Equal(t, ConfigCheckFailed, status)
+	require.Contains(t, details, "expired")
+	status, _ = checkCertificatesExpiry(now, notYetValid)
+	require.Equal(t, ConfigCheckFailed, status)
+	status, _ = checkCertificatesExpiry(now, nil, nil)
+	require.Equal(t, ConfigCheckOk, status)
+}
+
+func TestCheckClusterReachability(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	// a closed port to get a connection refused error
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	require.Nil(t, closed.Close())
+
+	tlsConfig := &common.ClusterTlsConfig{TlsEnabled: false}
+	report := &ConfigReport{}
+	checkClusterReachability(context.Background(), report, common.ClusterTypeOrigin, tlsConfig,
+		[]string{"127.0.0.1"}, port, 1000, 0, "")
+	checkClusterReachability(context.Background(), report, common.ClusterTypeTarget, tlsConfig,
+		[]string{"127.0.0.1"}, closedPort, 1000, 0, "")
+	checkClusterReachability(context.Background(), report, common.ClusterTypeTarget, nil,
+		[]string{"127.0.0.1"}, port, 1000, 0, "")
+
+	require.Len(t, report.Checks, 3)
+	require.Equal(t, ConfigCheckOk, report.Checks[0].Status)
+	require.Equal(t, "origin reachability", report.Checks[0].Name)
+	require.Equal(t, ConfigCheckFailed, report.Checks[1].Status)
+	require.Equal(t, ConfigCheckSkipped, report.Checks[2].Status)
+}
+
+func TestCheckPorts(t *testing.T) {
+	conf := config.New()
+	conf.ProxyListenAddress = "127.0.0.1"
+	conf.ProxyListenPort = 14002
+	conf.ProxyAdditionalListeners = "14003"
+	conf.MetricsAddress = "0.0.0.0"
+	conf.MetricsPort = 14003
+	conf.PrimaryCluster = config.PrimaryClusterOrigin
+	conf.ReadMode = config.ReadModePrimaryOnly
+
+	report := &ConfigReport{}
+	checkPorts(report, conf)
+	require.Equal(t, ConfigCheckFailed, report.Checks[0].Status)
+	require.Contains(t, report.Checks[0].Details, "port 14003")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	conf.ProxyAdditionalListeners = strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	conf.MetricsAddress = "127.0.0.1"
+	report = &ConfigReport{}
+	checkPorts(report, conf)
+	require.Equal(t, ConfigCheckFailed, report.Checks[0].Status)
+	require.Contains(t, report.Checks[0].Details, "could not listen")
+}
+
+func TestConfigReport_Write(t *testing.T) {
+	report := &ConfigReport{}
+	report.Add("configuration", ConfigCheckOk, "loaded and validated")
+	report.Add("target credentials", ConfigCheckWarning, "the password is empty")
+	require.False(t, report.Failed())
+	buf := &bytes.Buffer{}
+	require.Nil(t, report.Write(buf))
+	require.Contains(t, buf.String(), "WARNING  target credentials  the password is empty")
+	require.Contains(t, buf.String(), "The configuration is valid: 1 warnings.")
+
+	report.Add("ports", ConfigCheckFailed, "could not listen on %v", "localhost:14002")
+	require.True(t, report.Failed())
+	buf.Reset()
+	require.Nil(t, report.Write(buf))
+	require.Contains(t, buf.String(), "The configuration is NOT valid: 1 failed checks, 1 warnings.")
+}
+
+func newTestCertificatePem(t *testing.T, notBefore time.Time, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "zdm-test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -3,6 +3,7 @@ package zdmproxy
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/jpillora/backoff"
 	log "github.com/sirupsen/logrus"
@@ -10,33 +11,35 @@ import (
 	"time"
 )
 
-func openConnection(cc ConnectionConfig, ec Endpoint, ctx context.Context, useBackoff bool) (net.Conn, context.Context, error) {
+func openConnection(cc ConnectionConfig, ec Endpoint, ctx context.Context, useBackoff bool) (net.Conn, error) {
 	var connection net.Conn
 	var err error
 
 	timeout := time.Duration(cc.GetConnectionTimeoutMs()) * time.Millisecond
-	openConnectionTimeoutCtx, _ := context.WithTimeout(ctx, timeout)
+	openConnectionTimeoutCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	dialer := net.Dialer{KeepAlive: cc.GetTcpKeepAlive()}
 
 	if cc.GetTlsConfig() != nil {
 		// open connection using TLS
-		connection, err = openTLSConnection(ec, openConnectionTimeoutCtx, useBackoff)
-		if err != nil {
-			return nil, openConnectionTimeoutCtx, err
-		}
-		return connection, openConnectionTimeoutCtx, nil
-	}
-
-	// open plain TCP connection using contact points
-	if useBackoff {
-		connection, err = openTCPConnectionWithBackoff(ec.GetSocketEndpoint(), openConnectionTimeoutCtx)
+		connection, err = openTLSConnection(dialer, ec, openConnectionTimeoutCtx, useBackoff)
+	} else if useBackoff {
+		// open plain TCP connection using contact points
+		connection, err = openTCPConnectionWithBackoff(dialer, ec.GetSocketEndpoint(), openConnectionTimeoutCtx)
 	} else {
-		connection, err = openTCPConnection(ec.GetSocketEndpoint(), openConnectionTimeoutCtx)
+		connection, err = openTCPConnection(dialer, ec.GetSocketEndpoint(), openConnectionTimeoutCtx)
 	}
 
-	return connection, openConnectionTimeoutCtx, err
+	if err != nil {
+		if errors.Is(err, ShutdownErr) && openConnectionTimeoutCtx.Err() != nil {
+			return nil, fmt.Errorf("context timed out or cancelled while opening connection (%v): %w", openConnectionTimeoutCtx.Err(), err)
+		}
+		return nil, err
+	}
+	return connection, nil
 }
 
-func openTCPConnectionWithBackoff(addr string, ctx context.Context) (net.Conn, error) {
+func openTCPConnectionWithBackoff(dialer net.Dialer, addr string, ctx context.Context) (net.Conn, error) {
 	b := &backoff.Backoff{
 		Min:    100 * time.Millisecond,
 		Max:    10 * time.Second,
@@ -45,7 +48,6 @@ func openTCPConnectionWithBackoff(addr string, ctx context.Context) (net.Conn, e
 	}
 
 	log.Debugf("[openTCPConnectionWithBackoff] Attempting to connect to %v...", addr)
-	dialer := net.Dialer{}
 	for {
 		conn, err := dialer.DialContext(ctx, "tcp", addr)
 		if err != nil {
@@ -54,7 +56,13 @@ func openTCPConnectionWithBackoff(addr string, ctx context.Context) (net.Conn, e
 			}
 			nextDuration := b.Duration()
 			log.Errorf("[openTCPConnectionWithBackoff] Couldn't connect to %v, retrying in %v...", addr, nextDuration)
-			time.Sleep(nextDuration)
+			timer := time.NewTimer(nextDuration)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ShutdownErr
+			}
 			continue
 		}
 		log.Debugf("[openTCPConnectionWithBackoff] Successfully established connection with %v", conn.RemoteAddr())
@@ -62,11 +70,10 @@ func openTCPConnectionWithBackoff(addr string, ctx context.Context) (net.Conn, e
 	}
 }
 
-func openTCPConnection(addr string, ctx context.Context) (net.Conn, error) {
+func openTCPConnection(dialer net.Dialer, addr string, ctx context.Context) (net.Conn, error) {
 	log.Infof("[openTCPConnection] Opening connection to %v", addr)
 
 	// Wait until the source database is up and ready to accept TCP connections.
-	dialer := net.Dialer{}
 	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		if ctx.Err() == context.Canceled {
@@ -79,14 +86,14 @@ func openTCPConnection(addr string, ctx context.Context) (net.Conn, error) {
 	return conn, nil
 }
 
-func openTLSConnection(endpoint Endpoint, ctx context.Context, useBackoff bool) (*tls.Conn, error) {
+func openTLSConnection(dialer net.Dialer, endpoint Endpoint, ctx context.Context, useBackoff bool) (*tls.Conn, error) {
 
 	var tcpConn net.Conn
 	var err error
 	if useBackoff {
-		tcpConn, err = openTCPConnectionWithBackoff(endpoint.GetSocketEndpoint(), ctx)
+		tcpConn, err = openTCPConnectionWithBackoff(dialer, endpoint.GetSocketEndpoint(), ctx)
 	} else {
-		tcpConn, err = openTCPConnection(endpoint.GetSocketEndpoint(), ctx)
+		tcpConn, err = openTCPConnection(dialer, endpoint.GetSocketEndpoint(), ctx)
 	}
 	if err != nil {
 		return nil, err
@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"net"
 	"sync"
+	"time"
 )
 
 type ConnectionConfig interface {
@@ -16,19 +17,20 @@ type ConnectionConfig interface {
 	GetTlsConfig() *tls.Config
 	UsesSNI() bool
 	GetConnectionTimeoutMs() int
+	GetTcpKeepAlive() time.Duration
 	GetContactPoints() []Endpoint
 	RefreshContactPoints(ctx context.Context) ([]Endpoint, error)
 	CreateEndpoint(h *Host) Endpoint
 }
 
 func InitializeConnectionConfig(clusterTlsConfig *common.ClusterTlsConfig, contactPointsFromConfig []string, port int,
-	connTimeoutInMs int, clusterType common.ClusterType, datacenterFromConfig string, ctx context.Context) (ConnectionConfig, error) {
+	connTimeoutInMs int, tcpKeepAliveMs int, clusterType common.ClusterType, datacenterFromConfig string, ctx context.Context) (ConnectionConfig, error) {
 
 	var tlsConfig *tls.Config
 	var err error
 	if clusterTlsConfig.TlsEnabled {
 		if clusterTlsConfig.SecureConnectBundlePath != "" {
-			return initializeAstraConnectionConfig(connTimeoutInMs, clusterType, clusterTlsConfig.SecureConnectBundlePath, ctx)
+			return initializeAstraConnectionConfig(connTimeoutInMs, tcpKeepAliveMs, clusterType, clusterTlsConfig.SecureConnectBundlePath, ctx)
 		} else {
 			tlsConfig, err = getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig, clusterType)
 			if err != nil {
@@ -41,21 +43,23 @@ func InitializeConnectionConfig(clusterTlsConfig *common.ClusterTlsConfig, conta
 	for _, contactPoint := range contactPointsFromConfig {
 		contactPoints = append(contactPoints, NewDefaultEndpoint(contactPoint, port, tlsConfig))
 	}
-	return newGenericConnectionConfig(tlsConfig, connTimeoutInMs, clusterType, datacenterFromConfig, contactPoints), nil
+	return newGenericConnectionConfig(tlsConfig, connTimeoutInMs, tcpKeepAliveMs, clusterType, datacenterFromConfig, contactPoints), nil
 
 }
 
 type baseConnectionConfig struct {
 	tlsConfig           *tls.Config
 	connectionTimeoutMs int
+	tcpKeepAliveMs      int
 	clusterType         common.ClusterType
 }
 
 func newBaseConnectionConfig(
-	tlsConfig *tls.Config, connectionTimeoutMs int, clusterType common.ClusterType) *baseConnectionConfig {
+	tlsConfig *tls.Config, connectionTimeoutMs int, tcpKeepAliveMs int, clusterType common.ClusterType) *baseConnectionConfig {
 	return &baseConnectionConfig{
 		tlsConfig:           tlsConfig,
 		connectionTimeoutMs: connectionTimeoutMs,
+		tcpKeepAliveMs:      tcpKeepAliveMs,
 		clusterType:         clusterType,
 	}
 }
@@ -64,6 +68,12 @@ func (cc *baseConnectionConfig) GetConnectionTimeoutMs() int {
 	return cc.connectionTimeoutMs
 }
 
+// GetTcpKeepAlive returns the keep-alive period of the TCP connections to the cluster, zero means that the
+// default period is used and a negative value disables keep-alives (see net.Dialer).
+func (cc *baseConnectionConfig) GetTcpKeepAlive() time.Duration {
+	return time.Duration(cc.tcpKeepAliveMs) * time.Millisecond
+}
+
 func (cc *baseConnectionConfig) GetTlsConfig() *tls.Config {
 	return cc.tlsConfig
 }
@@ -79,9 +89,9 @@ type genericConnectionConfig struct {
 }
 
 func newGenericConnectionConfig(
-	tlsConfig *tls.Config, connectionTimeoutMs int, clusterType common.ClusterType, datacenter string, contactPoints []Endpoint) *genericConnectionConfig {
+	tlsConfig *tls.Config, connectionTimeoutMs int, tcpKeepAliveMs int, clusterType common.ClusterType, datacenter string, contactPoints []Endpoint) *genericConnectionConfig {
 	return &genericConnectionConfig{
-		baseConnectionConfig: newBaseConnectionConfig(tlsConfig, connectionTimeoutMs, clusterType),
+		baseConnectionConfig: newBaseConnectionConfig(tlsConfig, connectionTimeoutMs, tcpKeepAliveMs, clusterType),
 		datacenter:           datacenter,
 		contactPoints:        contactPoints,
 	}
@@ -127,7 +137,7 @@ type astraConnectionConfigImpl struct {
 }
 
 func initializeAstraConnectionConfig(
-	connectionTimeoutMs int, clusterType common.ClusterType, secureConnectBundlePath string, ctx context.Context) (*astraConnectionConfigImpl, error) {
+	connectionTimeoutMs int, tcpKeepAliveMs int, clusterType common.ClusterType, secureConnectBundlePath string, ctx context.Context) (*astraConnectionConfigImpl, error) {
 	fileMap, err := extractFilesFromZipArchive(secureConnectBundlePath)
 	if err != nil {
 		return nil, err
@@ -148,7 +158,7 @@ func initializeAstraConnectionConfig(
 	}
 
 	connConfig := &astraConnectionConfigImpl{
-		baseConnectionConfig: newBaseConnectionConfig(tlsConfig, connectionTimeoutMs, clusterType),
+		baseConnectionConfig: newBaseConnectionConfig(tlsConfig, connectionTimeoutMs, tcpKeepAliveMs, clusterType),
 		datacenter:           "",
 		metadataServiceName:  metadataServiceHostName,
 		metadataServicePort:  metadataServicePort,
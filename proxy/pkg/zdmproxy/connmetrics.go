@@ -0,0 +1,93 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"io"
+	"sync"
+	"time"
+)
+
+// clientConnectionDurationBucketsSeconds are the buckets of the proxy_client_connection_duration_seconds histogram,
+// client connections are usually long lived so the buckets go from 1 second up to 1 day.
+var clientConnectionDurationBucketsSeconds = []float64{1, 10, 60, 300, 1800, 3600, 21600, 86400}
+
+// clientConnectionMetrics tracks the lifecycle of a single client connection: how long it stayed open and why it was
+// closed (see the proxy_client_connections_closed_total and proxy_client_connection_duration_seconds metrics).
+type clientConnectionMetrics struct {
+	proxyMetrics *metrics.ProxyMetrics
+	openedAt     time.Time
+
+	lock        *sync.Mutex
+	closeReason string
+	closed      bool
+}
+
+// newClientConnectionMetrics returns nil if proxyMetrics is nil.
+func newClientConnectionMetrics(proxyMetrics *metrics.ProxyMetrics) *clientConnectionMetrics {
+	if proxyMetrics == nil {
+		return nil
+	}
+	return &clientConnectionMetrics{
+		proxyMetrics: proxyMetrics,
+		openedAt:     time.Now(),
+		lock:         &sync.Mutex{},
+	}
+}
+
+// SetCloseReason records the reason why the connection is being closed, only the first reason is kept.
+func (recv *clientConnectionMetrics) SetCloseReason(reason string) {
+	if recv == nil {
+		return
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.closeReason == "" {
+		recv.closeReason = reason
+	}
+}
+
+// TrackClose updates the metrics of a connection that was closed and returns the close reason and how long the
+// connection was open. If no reason was set then the connection was closed because the proxy is shutting down
+// (if shutdownRequested is true) or because of an error (e.g. a cluster connection was lost).
+func (recv *clientConnectionMetrics) TrackClose(shutdownRequested bool) (string, time.Duration) {
+	if recv == nil {
+		return "", 0
+	}
+	recv.lock.Lock()
+	if recv.closed {
+		recv.lock.Unlock()
+		return recv.closeReason, time.Since(recv.openedAt)
+	}
+	recv.closed = true
+	if recv.closeReason == "" {
+		if shutdownRequested {
+			recv.closeReason = metrics.ClientConnectionCloseReasonShutdown
+		} else {
+			recv.closeReason = metrics.ClientConnectionCloseReasonError
+		}
+	}
+	reason := recv.closeReason
+	recv.lock.Unlock()
+
+	switch reason {
+	case metrics.ClientConnectionCloseReasonClientDisconnected:
+		recv.proxyMetrics.ClientConnectionsClosedByClient.Add(1)
+	case metrics.ClientConnectionCloseReasonIdleTimeout:
+		recv.proxyMetrics.ClientConnectionsClosedByIdleTimeout.Add(1)
+	case metrics.ClientConnectionCloseReasonShutdown:
+		recv.proxyMetrics.ClientConnectionsClosedByShutdown.Add(1)
+	default:
+		recv.proxyMetrics.ClientConnectionsClosedByError.Add(1)
+	}
+	recv.proxyMetrics.ClientConnectionDuration.Track(recv.openedAt)
+	return reason, time.Since(recv.openedAt)
+}
+
+// getClientConnectionCloseReason returns the close reason of a connection whose read loop failed with err.
+func getClientConnectionCloseReason(err error) string {
+	if errors.Is(err, io.EOF) || IsPeerDisconnect(err) || IsClosingErr(err) {
+		return metrics.ClientConnectionCloseReasonClientDisconnected
+	}
+	return metrics.ClientConnectionCloseReasonError
+}
@@ -0,0 +1,91 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+	"time"
+)
+
+type countingCounter struct {
+	value int
+}
+
+func (recv *countingCounter) Add(valueToAdd int) {
+	recv.value += valueToAdd
+}
+
+type countingHistogram struct {
+	count int
+}
+
+func (recv *countingHistogram) Track(begin time.Time) {
+	recv.count++
+}
+
+func TestClientConnectionMetrics(t *testing.T) {
+	require.Nil(t, newClientConnectionMetrics(nil))
+	var nilMetrics *clientConnectionMetrics
+	nilMetrics.SetCloseReason(metrics.ClientConnectionCloseReasonIdleTimeout)
+	reason, _ := nilMetrics.TrackClose(false)
+	require.Equal(t, "", reason)
+
+	tests := []struct {
+		name              string
+		reasons           []string
+		shutdownRequested bool
+		expectedReason    string
+	}{
+		{"client disconnected", []string{metrics.ClientConnectionCloseReasonClientDisconnected}, false, metrics.ClientConnectionCloseReasonClientDisconnected},
+		{"first reason wins", []string{metrics.ClientConnectionCloseReasonIdleTimeout, metrics.ClientConnectionCloseReasonClientDisconnected}, true, metrics.ClientConnectionCloseReasonIdleTimeout},
+		{"shutdown", nil, true, metrics.ClientConnectionCloseReasonShutdown},
+		{"error", nil, false, metrics.ClientConnectionCloseReasonError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closedByClient, closedByIdleTimeout := &countingCounter{}, &countingCounter{}
+			closedByShutdown, closedByError := &countingCounter{}, &countingCounter{}
+			duration := &countingHistogram{}
+			proxyMetrics := newFakeProxyMetrics()
+			proxyMetrics.ClientConnectionsClosedByClient = closedByClient
+			proxyMetrics.ClientConnectionsClosedByIdleTimeout = closedByIdleTimeout
+			proxyMetrics.ClientConnectionsClosedByShutdown = closedByShutdown
+			proxyMetrics.ClientConnectionsClosedByError = closedByError
+			proxyMetrics.ClientConnectionDuration = duration
+
+			connMetrics := newClientConnectionMetrics(proxyMetrics)
+			for _, reason := range tt.reasons {
+				connMetrics.SetCloseReason(reason)
+			}
+			reason, _ := connMetrics.TrackClose(tt.shutdownRequested)
+			require.Equal(t, tt.expectedReason, reason)
+
+			// closing twice doesn't update the metrics again
+			reason, _ = connMetrics.TrackClose(!tt.shutdownRequested)
+			require.Equal(t, tt.expectedReason, reason)
+
+			counts := map[string]int{
+				metrics.ClientConnectionCloseReasonClientDisconnected: closedByClient.value,
+				metrics.ClientConnectionCloseReasonIdleTimeout:        closedByIdleTimeout.value,
+				metrics.ClientConnectionCloseReasonShutdown:           closedByShutdown.value,
+				metrics.ClientConnectionCloseReasonError:              closedByError.value,
+			}
+			for countReason, count := range counts {
+				if countReason == tt.expectedReason {
+					require.Equal(t, 1, count, countReason)
+				} else {
+					require.Equal(t, 0, count, countReason)
+				}
+			}
+			require.Equal(t, 1, duration.count)
+		})
+	}
+}
+
+func TestGetClientConnectionCloseReason(t *testing.T) {
+	require.Equal(t, metrics.ClientConnectionCloseReasonClientDisconnected, getClientConnectionCloseReason(io.EOF))
+	require.Equal(t, metrics.ClientConnectionCloseReasonError, getClientConnectionCloseReason(errors.New("decode error")))
+}
@@ -0,0 +1,87 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// consistencyLevelMapping replaces the consistency levels of the writes sent to the target cluster according to
+// ZDM_TARGET_CONSISTENCY_LEVEL_MAPPING. This is useful when the two clusters have different topologies, e.g. a client
+// that writes with LOCAL_ONE on a single datacenter origin cluster can write with LOCAL_QUORUM on the target cluster.
+//
+// The mapping is applied to the consistency level and to the serial consistency level (for conditional writes) of
+// QUERY, EXECUTE and BATCH requests that are sent to both clusters. Requests sent to a single cluster are not modified.
+type consistencyLevelMapping struct {
+	levels map[primitive.ConsistencyLevel]primitive.ConsistencyLevel
+}
+
+// newConsistencyLevelMapping returns nil if the provided mapping is empty.
+func newConsistencyLevelMapping(levels map[primitive.ConsistencyLevel]primitive.ConsistencyLevel) *consistencyLevelMapping {
+	if len(levels) == 0 {
+		return nil
+	}
+	return &consistencyLevelMapping{levels: levels}
+}
+
+// Apply returns a copy of the provided request with the consistency levels replaced or the provided request if none
+// of its consistency levels are mapped.
+func (recv *consistencyLevelMapping) Apply(f *frame.RawFrame) (*frame.RawFrame, error) {
+	if recv == nil {
+		return f, nil
+	}
+	switch f.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return f, nil
+	}
+
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %v request to replace consistency level: %w", f.Header.OpCode, err)
+	}
+
+	var consistency *primitive.ConsistencyLevel
+	var serialConsistency *primitive.ConsistencyLevel
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		if msg.Options != nil {
+			consistency = &msg.Options.Consistency
+			serialConsistency = msg.Options.SerialConsistency
+		}
+	case *message.Execute:
+		if msg.Options != nil {
+			consistency = &msg.Options.Consistency
+			serialConsistency = msg.Options.SerialConsistency
+		}
+	case *message.Batch:
+		consistency = &msg.Consistency
+		serialConsistency = msg.SerialConsistency
+	}
+
+	replaced := recv.replace(consistency)
+	replaced = recv.replace(serialConsistency) || replaced
+	if !replaced {
+		return f, nil
+	}
+
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert %v request with replaced consistency level to raw frame: %w",
+			f.Header.OpCode, err)
+	}
+	return newRawFrame, nil
+}
+
+func (recv *consistencyLevelMapping) replace(consistency *primitive.ConsistencyLevel) bool {
+	if consistency == nil {
+		return false
+	}
+	newConsistency, ok := recv.levels[*consistency]
+	if !ok || newConsistency == *consistency {
+		return false
+	}
+	*consistency = newConsistency
+	return true
+}
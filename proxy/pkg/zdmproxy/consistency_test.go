@@ -0,0 +1,78 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConsistencyLevelMapping_Apply(t *testing.T) {
+	mapping := newConsistencyLevelMapping(map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{
+		primitive.ConsistencyLevelLocalOne: primitive.ConsistencyLevelLocalQuorum,
+		primitive.ConsistencyLevelSerial:   primitive.ConsistencyLevelLocalSerial,
+	})
+	serial := primitive.ConsistencyLevelSerial
+	localSerial := primitive.ConsistencyLevelLocalSerial
+
+	tests := []struct {
+		name                      string
+		msg                       message.Message
+		expectedConsistency       primitive.ConsistencyLevel
+		expectedSerialConsistency *primitive.ConsistencyLevel
+		replaced                  bool
+	}{
+		{"query", &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)", Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelLocalOne}}, primitive.ConsistencyLevelLocalQuorum, nil, true},
+		{"query not mapped", &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)", Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelQuorum}}, primitive.ConsistencyLevelQuorum, nil, false},
+		{"conditional query", &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1) IF NOT EXISTS", Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelQuorum, SerialConsistency: &serial}},
+			primitive.ConsistencyLevelQuorum, &localSerial, true},
+		{"execute", &message.Execute{QueryId: []byte{1}, Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelLocalOne}}, primitive.ConsistencyLevelLocalQuorum, nil, true},
+		{"batch", &message.Batch{Children: []*message.BatchChild{{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"}},
+			Consistency: primitive.ConsistencyLevelLocalOne, SerialConsistency: &serial},
+			primitive.ConsistencyLevelLocalQuorum, &localSerial, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, tt.msg, primitive.ProtocolVersion4)
+			actual, err := mapping.Apply(f)
+			require.Nil(t, err)
+			if !tt.replaced {
+				require.Same(t, f, actual)
+			}
+
+			decoded, err := defaultCodec.ConvertFromRawFrame(actual)
+			require.Nil(t, err)
+			var consistency primitive.ConsistencyLevel
+			var serialConsistency *primitive.ConsistencyLevel
+			switch msg := decoded.Body.Message.(type) {
+			case *message.Query:
+				consistency, serialConsistency = msg.Options.Consistency, msg.Options.SerialConsistency
+			case *message.Execute:
+				consistency, serialConsistency = msg.Options.Consistency, msg.Options.SerialConsistency
+			case *message.Batch:
+				consistency, serialConsistency = msg.Consistency, msg.SerialConsistency
+			}
+			require.Equal(t, tt.expectedConsistency, consistency)
+			require.Equal(t, tt.expectedSerialConsistency, serialConsistency)
+		})
+	}
+}
+
+func TestConsistencyLevelMapping_Disabled(t *testing.T) {
+	mapping := newConsistencyLevelMapping(map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{})
+	require.Nil(t, mapping)
+	f := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+	actual, err := mapping.Apply(f)
+	require.Nil(t, err)
+	require.Same(t, f, actual)
+
+	prepare := mockPrepareFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (?)")
+	actual, err = newConsistencyLevelMapping(map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{
+		primitive.ConsistencyLevelOne: primitive.ConsistencyLevelQuorum}).Apply(prepare)
+	require.Nil(t, err)
+	require.Same(t, prepare, actual)
+}
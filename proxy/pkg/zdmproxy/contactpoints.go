@@ -0,0 +1,96 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// runContactPointsRefresh refreshes the contact points and resolves their addresses every interval until the control
+// connection is shut down (see ZDM_CONTACT_POINTS_REFRESH_INTERVAL_MS).
+func (cc *ControlConn) runContactPointsRefresh(interval time.Duration) {
+	log.Infof("Refreshing the contact points of %v every %v.", cc.connConfig.GetClusterType(), interval)
+	cc.contactPointAddresses, _ = resolveEndpointAddresses(cc.connConfig.GetContactPoints(), cc.context)
+	for cc.context.Err() == nil {
+		timedOut, _ := sleepWithContext(interval, cc.context, nil)
+		if !timedOut {
+			return
+		}
+		cc.refreshContactPointAddresses()
+	}
+}
+
+// refreshContactPointAddresses resolves the contact points again and, if their addresses changed, requests the control
+// connection to be reopened using the new contact points. The client connections that use a contact point are drained
+// once the control connection is reopened (see ControlConn.notifyContactPointsChanged).
+func (cc *ControlConn) refreshContactPointAddresses() {
+	contactPoints, err := cc.connConfig.RefreshContactPoints(cc.context)
+	if err != nil {
+		if cc.context.Err() == nil {
+			log.Warnf("Failed to refresh the contact points of %v: %v", cc.connConfig.GetClusterType(), err)
+		}
+		return
+	}
+
+	addresses, err := resolveEndpointAddresses(contactPoints, cc.context)
+	if err != nil {
+		if cc.context.Err() == nil {
+			log.Warnf("Failed to resolve the contact points of %v, keeping the current addresses: %v",
+				cc.connConfig.GetClusterType(), err)
+		}
+		return
+	}
+
+	oldAddresses := cc.contactPointAddresses
+	cc.contactPointAddresses = addresses
+	if oldAddresses == nil || strings.Join(oldAddresses, ",") == strings.Join(addresses, ",") {
+		return
+	}
+
+	log.Infof("The addresses of the contact points of %v changed from %v to %v, reopening the control connection.",
+		cc.connConfig.GetClusterType(), oldAddresses, addresses)
+	atomic.StoreInt32(&cc.contactPointsChanged, 1)
+	select {
+	case cc.reconnectCh <- true:
+	default:
+	}
+}
+
+// notifyContactPointsChanged drains the client connections that use a contact point of this cluster.
+func (cc *ControlConn) notifyContactPointsChanged() {
+	cc.topologyLock.RLock()
+	defer cc.topologyLock.RUnlock()
+	for observer := range cc.protocolEventSubscribers {
+		observer.OnContactPointsChanged()
+	}
+}
+
+// resolveEndpointAddresses returns the sorted and deduplicated addresses (ip:port) of the socket endpoints.
+func resolveEndpointAddresses(endpoints []Endpoint, ctx context.Context) ([]string, error) {
+	addressSet := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		host, port, err := net.SplitHostPort(endpoint.GetSocketEndpoint())
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %v: %w", endpoint.GetSocketEndpoint(), err)
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve %v: %w", host, err)
+		}
+		for _, ip := range ips {
+			addressSet[net.JoinHostPort(ip.IP.String(), port)] = true
+		}
+	}
+
+	addresses := make([]string, 0, len(addressSet))
+	for address := range addressSet {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
@@ -0,0 +1,83 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type fakeContactPointsObserver struct {
+	contactPointsChanged int
+}
+
+func (recv *fakeContactPointsObserver) OnHostRemoved(host *Host) {
+}
+
+func (recv *fakeContactPointsObserver) OnContactPointsChanged() {
+	recv.contactPointsChanged++
+}
+
+func TestResolveEndpointAddresses(t *testing.T) {
+	addresses, err := resolveEndpointAddresses([]Endpoint{
+		NewDefaultEndpoint("127.0.0.2", 9042, nil),
+		NewDefaultEndpoint("::1", 9042, nil),
+		NewDefaultEndpoint("127.0.0.1", 9042, nil),
+		NewDefaultEndpoint("127.0.0.2", 9042, nil),
+	}, context.Background())
+	require.Nil(t, err)
+	require.Equal(t, []string{"127.0.0.1:9042", "127.0.0.2:9042", "[::1]:9042"}, addresses)
+
+	_, err = resolveEndpointAddresses([]Endpoint{&DefaultEndpoint{socketEndpoint: "127.0.0.1"}}, context.Background())
+	require.NotNil(t, err)
+}
+
+func TestControlConn_RefreshContactPointAddresses(t *testing.T) {
+	connConfig := newGenericConnectionConfig(nil, 1000, 0, common.ClusterTypeOrigin, "",
+		[]Endpoint{NewDefaultEndpoint("127.0.0.1", 9042, nil)})
+	cc := &ControlConn{
+		context:                  context.Background(),
+		connConfig:               connConfig,
+		topologyLock:             &sync.RWMutex{},
+		reconnectCh:              make(chan bool, 1),
+		protocolEventSubscribers: map[ProtocolEventObserver]interface{}{},
+	}
+	observer := &fakeContactPointsObserver{}
+	cc.protocolEventSubscribers[observer] = nil
+
+	// first resolution only stores the addresses
+	cc.refreshContactPointAddresses()
+	require.Equal(t, []string{"127.0.0.1:9042"}, cc.contactPointAddresses)
+	require.Equal(t, int32(0), atomic.LoadInt32(&cc.contactPointsChanged))
+
+	cc.refreshContactPointAddresses()
+	require.Equal(t, int32(0), atomic.LoadInt32(&cc.contactPointsChanged))
+	require.Equal(t, 0, len(cc.reconnectCh))
+
+	connConfig.contactPoints = []Endpoint{NewDefaultEndpoint("127.0.0.2", 9042, nil)}
+	cc.refreshContactPointAddresses()
+	require.Equal(t, []string{"127.0.0.2:9042"}, cc.contactPointAddresses)
+	require.Equal(t, int32(1), atomic.LoadInt32(&cc.contactPointsChanged))
+	require.Equal(t, 1, len(cc.reconnectCh))
+
+	// client connections are drained once the control connection is reopened
+	require.Equal(t, 0, observer.contactPointsChanged)
+	cc.notifyContactPointsChanged()
+	require.Equal(t, 1, observer.contactPointsChanged)
+}
+
+func TestProtocolEventObserver_OnContactPointsChanged(t *testing.T) {
+	canceled := 0
+	cancelFn := func() { canceled++ }
+
+	NewProtocolEventObserver(cancelFn, &Host{}).OnContactPointsChanged()
+	require.Equal(t, 0, canceled)
+
+	observer := NewProtocolEventObserver(cancelFn, nil)
+	observer.OnHostRemoved(&Host{})
+	require.Equal(t, 0, canceled)
+	observer.OnContactPointsChanged()
+	require.Equal(t, 1, canceled)
+}
@@ -35,7 +35,7 @@ type ControlConn struct {
 	connConfig               ConnectionConfig
 	currentContactPoint      Endpoint
 	username                 string
-	password                 string
+	password                 *PasswordProvider
 	counterLock              *sync.RWMutex
 	consecutiveFailures      int
 	OpenConnectionTimeout    time.Duration
@@ -55,6 +55,8 @@ type ControlConn struct {
 	protocolEventSubscribers map[ProtocolEventObserver]interface{}
 	authEnabled              *atomic.Value
 	metricsHandler           *metrics.MetricHandler
+	contactPointAddresses    []string
+	contactPointsChanged     int32
 }
 
 const ProxyVirtualRack = "rack0"
@@ -63,7 +65,7 @@ const ccWriteTimeout = 5 * time.Second
 const ccReadTimeout = 10 * time.Second
 
 func NewControlConn(ctx context.Context, defaultPort int, connConfig ConnectionConfig,
-	username string, password string, conf *config.Config, topologyConfig *common.TopologyConfig, proxyRand *rand.Rand,
+	username string, password *PasswordProvider, conf *config.Config, topologyConfig *common.TopologyConfig, proxyRand *rand.Rand,
 	metricsHandler *metrics.MetricHandler) *ControlConn {
 	authEnabled := &atomic.Value{}
 	authEnabled.Store(true)
@@ -165,7 +167,11 @@ func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 			conn, _ := cc.GetConnAndContactPoint()
 			if conn == nil {
 				useContactPointsOnly := false
-				if !lastOpenSuccessful {
+				contactPointsChanged := atomic.CompareAndSwapInt32(&cc.contactPointsChanged, 1, 0)
+				if contactPointsChanged {
+					useContactPointsOnly = true
+					log.Infof("Reopening control connection to %v using the refreshed contact points.", cc.connConfig.GetClusterType())
+				} else if !lastOpenSuccessful {
 					useContactPointsOnly = true
 					log.Infof("Refreshing contact points and reopening control connection to %v.", cc.connConfig.GetClusterType())
 					_, err = cc.connConfig.RefreshContactPoints(cc.context)
@@ -181,6 +187,9 @@ func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 					continue
 				}
 				if err != nil {
+					if contactPointsChanged {
+						atomic.StoreInt32(&cc.contactPointsChanged, 1)
+					}
 					lastOpenSuccessful = false
 					timeUntilRetry := cc.retryBackoffPolicy.Duration()
 					log.Errorf("Failed to open control connection to %v, retrying in %v: %v",
@@ -193,6 +202,9 @@ func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 					conn = newConn
 					cc.ResetFailureCounter()
 					cc.retryBackoffPolicy.Reset()
+					if contactPointsChanged {
+						cc.notifyContactPointsChanged()
+					}
 				}
 			}
 
@@ -217,6 +229,14 @@ func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 			}
 		}
 	}()
+
+	if cc.conf.ContactPointsRefreshIntervalMs > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cc.runContactPointsRefresh(time.Duration(cc.conf.ContactPointsRefreshIntervalMs) * time.Millisecond)
+		}()
+	}
 	return nil
 }
 
@@ -372,13 +392,13 @@ func (cc *ControlConn) openInternal(endpoints []Endpoint, ctx context.Context) (
 func (cc *ControlConn) connAndNegotiateProtoVer(endpoint Endpoint, initialProtoVer primitive.ProtocolVersion, ctx context.Context) (CqlConnection, error) {
 	protoVer := initialProtoVer
 	for {
-		tcpConn, _, err := openConnection(cc.connConfig, endpoint, ctx, false)
+		tcpConn, err := openConnection(cc.connConfig, endpoint, ctx, false)
 		if err != nil {
 			log.Warnf("Failed to open control connection to %v using endpoint %v: %v",
 				cc.connConfig.GetClusterType(), endpoint.GetEndpointIdentifier(), err)
 			return nil, err
 		}
-		newConn := NewCqlConnection(endpoint, tcpConn, cc.username, cc.password, ccReadTimeout, ccWriteTimeout, cc.conf, protoVer)
+		newConn := NewCqlConnection(endpoint, tcpConn, cc.username, cc.password.Get(), ccReadTimeout, ccWriteTimeout, cc.conf, protoVer)
 		err = newConn.InitializeContext(protoVer, ctx)
 		var respErr *ResponseError
 		if err != nil && errors.As(err, &respErr) && respErr.IsProtocolError() && strings.Contains(err.Error(), "Invalid or unsupported protocol version") {
@@ -853,4 +873,5 @@ func (recv *VirtualHost) String() string {
 
 type ProtocolEventObserver interface {
 	OnHostRemoved(host *Host)
+	OnContactPointsChanged()
 }
@@ -280,7 +280,8 @@ func (c *cqlConn) sendContext(request *frame.Frame, ctx context.Context) (chan *
 		return nil, fmt.Errorf("cql connection was closed: %w", io.EOF)
 	}
 
-	timeoutCtx, _ := context.WithTimeout(ctx, c.writeTimeout)
+	timeoutCtx, cancelFn := context.WithTimeout(ctx, c.writeTimeout)
+	defer cancelFn()
 
 	var respChan = make(chan *frame.Frame, 1)
 
@@ -325,7 +326,8 @@ func (c *cqlConn) SendAndReceive(request *frame.Frame, ctx context.Context) (*fr
 		return nil, fmt.Errorf("failed to send request frame: %w", err)
 	}
 
-	readTimeoutCtx, _ := context.WithTimeout(ctx, c.readTimeout)
+	readTimeoutCtx, cancelFn := context.WithTimeout(ctx, c.readTimeout)
+	defer cancelFn()
 	select {
 	case response, ok := <-respChan:
 		if !ok {
@@ -45,6 +45,20 @@ type UnpreparedExecuteError struct {
 	preparedId []byte
 }
 
+// MixedBatchError is returned for a BATCH that has statements that are only sent to ORIGIN (tables that are out of the
+// scope of the migration or with an ORIGIN write policy) and statements that are sent to TARGET. Such a batch can't be
+// sent to both clusters because the statements that are only sent to ORIGIN are only prepared on ORIGIN and their
+// tables might not exist on TARGET.
+type MixedBatchError struct {
+	Header *frame.Header
+}
+
+func (recv *MixedBatchError) Error() string {
+	return "BATCH has statements on tables that are only written to the origin cluster " +
+		"(ZDM_EXCLUDED_TABLES, ZDM_INCLUDED_TABLES or ZDM_TABLE_WRITE_POLICIES) and statements on other tables, " +
+		"these statements have to be sent in separate batches"
+}
+
 type statementQueryData struct {
 	statementIndex int
 	queryData      QueryInfo
@@ -66,6 +80,9 @@ func buildRequestInfo(
 	mh *metrics.MetricHandler,
 	currentKeyspaceName string,
 	primaryCluster common.ClusterType,
+	primaryClusterKeyspaceOverrides map[string]common.ClusterType,
+	tableFilter *tableFilter,
+	writePolicies *tableWritePolicies,
 	forwardSystemQueriesToTarget bool,
 	virtualizationEnabled bool,
 	forwardAuthToTarget bool,
@@ -79,7 +96,7 @@ func buildRequestInfo(
 			return nil, fmt.Errorf("could not inspect QUERY frame: %w", err)
 		}
 		return getRequestInfoFromQueryInfo(
-			frameContext.GetRawFrame(), primaryCluster,
+			frameContext.GetRawFrame(), primaryCluster, primaryClusterKeyspaceOverrides, tableFilter, writePolicies,
 			forwardSystemQueriesToTarget, virtualizationEnabled, stmtQueryData.queryData), nil
 	case primitive.OpCodePrepare:
 		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspaceName, timeUuidGenerator)
@@ -95,7 +112,7 @@ func buildRequestInfo(
 			return nil, fmt.Errorf("unexpected message type when decoding PREPARE message: %v", decodedFrame.Body.Message)
 		}
 		baseRequestInfo := getRequestInfoFromQueryInfo(
-			frameContext.GetRawFrame(), primaryCluster,
+			frameContext.GetRawFrame(), primaryCluster, primaryClusterKeyspaceOverrides, tableFilter, writePolicies,
 			forwardSystemQueriesToTarget, virtualizationEnabled, stmtQueryData.queryData)
 		replacedTerms := make([]*term, 0)
 		if len(stmtsReplacedTerms) > 1 {
@@ -114,6 +131,8 @@ func buildRequestInfo(
 			return nil, fmt.Errorf("could not convert message with batch op code to batch type, got %v instead", decodedFrame.Body.Message)
 		}
 		preparedDataByStmtIdxMap := make(map[int]PreparedData)
+		inspectChildren := tableFilter != nil || writePolicies != nil
+		batchPolicy := newBatchWritePolicy()
 		for childIdx, child := range batchMsg.Children {
 			if child.Id != nil {
 				preparedData, err := getPreparedData(psCache, mh, child.Id, primitive.OpCodeBatch, decodedFrame)
@@ -122,7 +141,36 @@ func buildRequestInfo(
 				} else {
 					preparedDataByStmtIdxMap[childIdx] = preparedData
 				}
+				batchPolicy.add(preparedData.GetPrepareRequestInfo().GetBaseRequestInfo())
+			}
+		}
+		if inspectChildren {
+			stmtsQueryData, err := frameContext.GetOrInspectAllStatements(currentKeyspaceName, timeUuidGenerator)
+			if err != nil {
+				return nil, fmt.Errorf("could not inspect BATCH frame: %w", err)
 			}
+			for _, stmtQueryData := range stmtsQueryData {
+				if isExcludedStatement(stmtQueryData.queryData, tableFilter) {
+					batchPolicy.add(NewExcludedTableRequestInfo())
+				} else if writePolicyRequestInfo := writePolicies.getRequestInfo(stmtQueryData.queryData); writePolicyRequestInfo != nil {
+					batchPolicy.add(writePolicyRequestInfo)
+				} else {
+					batchPolicy.add(nil)
+				}
+			}
+		}
+		if batchPolicy.originOnly && batchPolicy.decision != forwardToOrigin {
+			return nil, &MixedBatchError{Header: decodedFrame.Header}
+		}
+		if !inspectChildren || batchPolicy.empty {
+			return NewBatchRequestInfo(preparedDataByStmtIdxMap), nil
+		}
+		if batchPolicy.decision == forwardToOrigin {
+			return NewOriginOnlyBatchRequestInfo(preparedDataByStmtIdxMap), nil
+		}
+		if writePolicies != nil {
+			return NewTableWritePolicyBatchRequestInfo(
+				preparedDataByStmtIdxMap, batchPolicy.decision, batchPolicy.failures), nil
 		}
 		return NewBatchRequestInfo(preparedDataByStmtIdxMap), nil
 	case primitive.OpCodeExecute:
@@ -174,10 +222,34 @@ func getPreparedData(
 func getRequestInfoFromQueryInfo(
 	f *frame.RawFrame,
 	primaryCluster common.ClusterType,
+	primaryClusterKeyspaceOverrides map[string]common.ClusterType,
+	tableFilter *tableFilter,
+	writePolicies *tableWritePolicies,
 	forwardSystemQueriesToTarget bool,
 	virtualizationEnabled bool,
 	queryInfo QueryInfo) RequestInfo {
 
+	if isExcludedStatement(queryInfo, tableFilter) {
+		log.Tracef("Detected query on a table that is out of the scope of the migration: %v with stream id: %v",
+			queryInfo.getQuery(), f.Header.StreamId)
+		if isWriteQuery(queryInfo) {
+			return NewExcludedTableWriteRequestInfo()
+		}
+		return NewExcludedTableRequestInfo()
+	}
+
+	if queryInfo.getStatementType() == statementTypeUse && tableFilter.IsKeyspaceExcluded(queryInfo.getKeyspaceName()) {
+		log.Tracef("Detected USE of a keyspace that is out of the scope of the migration: %v with stream id: %v",
+			queryInfo.getQuery(), f.Header.StreamId)
+		return NewExcludedKeyspaceUseRequestInfo()
+	}
+
+	if writePolicyRequestInfo := writePolicies.getRequestInfo(queryInfo); writePolicyRequestInfo != nil {
+		log.Tracef("Detected write on a table with a write policy: %v with stream id: %v, %v",
+			queryInfo.getQuery(), f.Header.StreamId, writePolicyRequestInfo)
+		return writePolicyRequestInfo
+	}
+
 	var sendAlsoToAsync bool
 	forwardDecision := forwardToBoth
 	if queryInfo.getStatementType() == statementTypeSelect {
@@ -205,6 +277,13 @@ func getRequestInfoFromQueryInfo(
 			}
 		} else {
 			sendAlsoToAsync = true
+			if keyspacePrimaryCluster, ok := primaryClusterKeyspaceOverrides[queryInfo.getApplicableKeyspace()]; ok &&
+				keyspacePrimaryCluster != primaryCluster {
+				log.Tracef("Using primary cluster override %v for keyspace %v", keyspacePrimaryCluster, queryInfo.getApplicableKeyspace())
+				primaryCluster = keyspacePrimaryCluster
+				// the async connector is connected to the cluster that is now the primary cluster for this read
+				sendAlsoToAsync = false
+			}
 			if primaryCluster == common.ClusterTypeTarget {
 				forwardDecision = forwardToTarget
 			} else {
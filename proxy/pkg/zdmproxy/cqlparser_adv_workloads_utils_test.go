@@ -27,7 +27,7 @@ func getGeneralParamsForTests(t *testing.T) params {
 	require.Nil(t, err)
 
 	return params{
-		psCache:                      NewPreparedStatementCache(),
+		psCache:                      NewPreparedStatementCache(0),
 		mh:                           newFakeMetricHandler(),
 		kn:                           "",
 		primaryCluster:               common.ClusterTypeOrigin,
@@ -80,6 +80,9 @@ func parseEncodedRequestForTests(queryRawFrame *frame.RawFrame, t *testing.T) (R
 		generalParams.mh,
 		generalParams.kn,
 		generalParams.primaryCluster,
+		nil,
+		nil,
+		nil,
 		generalParams.forwardSystemQueriesToTarget,
 		generalParams.virtualizationEnabled,
 		generalParams.forwardAuthToTarget,
@@ -57,7 +57,7 @@ func TestInspectFrame(t *testing.T) {
 		targetPreparedId:   []byte("LOCAL"),
 		prepareRequestInfo: NewPrepareRequestInfo(NewInterceptedRequestInfo(local, newStarSelectClause()), nil, false, "SELECT * FROM system.local", ""),
 	}
-	psCache := NewPreparedStatementCache()
+	psCache := NewPreparedStatementCache(0)
 	psCache.cache["BOTH"] = bothCacheEntry
 	psCache.cache["ORIGIN"] = originCacheEntry
 	psCache.cache["TARGET"] = targetCacheEntry
@@ -138,7 +138,7 @@ func TestInspectFrame(t *testing.T) {
 			actual, err := buildRequestInfo(&frameDecodeContext{frame: tt.args.f}, []*statementReplacedTerms{{
 				statementIndex: 0,
 				replacedTerms:  tt.args.replacedTerms,
-			}}, psCache, mh, km, tt.args.primaryCluster, tt.args.forwardSystemQueriesToTarget, true, tt.args.forwardAuthToTarget, timeUuidGenerator)
+			}}, psCache, mh, km, tt.args.primaryCluster, nil, nil, nil, tt.args.forwardSystemQueriesToTarget, true, tt.args.forwardAuthToTarget, timeUuidGenerator)
 			if err != nil {
 				if !reflect.DeepEqual(err.Error(), tt.expected) {
 					t.Errorf("buildRequestInfo() actual = %v, expected %v", err, tt.expected)
@@ -166,7 +166,7 @@ func mockPrepareFrameWithKeyspace(t *testing.T, query string, ks string) *frame.
 	return mockFrame(t, prepareMsg, primitive.ProtocolVersionDse2)
 }
 
-func mockQueryFrame(t *testing.T, query string) *frame.RawFrame {
+func mockQueryFrame(t testing.TB, query string) *frame.RawFrame {
 	queryMsg := &message.Query{
 		Query: query,
 	}
@@ -209,7 +209,7 @@ func mockAuthResponse(t *testing.T) *frame.RawFrame {
 	return mockFrame(t, &message.AuthResponse{Token: token}, primitive.ProtocolVersion4)
 }
 
-func mockFrame(t *testing.T, message message.Message, version primitive.ProtocolVersion) *frame.RawFrame {
+func mockFrame(t testing.TB, message message.Message, version primitive.ProtocolVersion) *frame.RawFrame {
 	f := frame.NewFrame(version, 1, message)
 	rawFrame, err := defaultCodec.ConvertToRawFrame(f)
 	require.Nil(t, err)
@@ -222,20 +222,39 @@ func newFakeMetricHandler() *metrics.MetricHandler {
 
 func newFakeProxyMetrics() *metrics.ProxyMetrics {
 	return &metrics.ProxyMetrics{
-		FailedReadsOrigin:        newFakeCounter(),
-		FailedReadsTarget:        newFakeCounter(),
-		FailedWritesOnOrigin:     newFakeCounter(),
-		FailedWritesOnTarget:     newFakeCounter(),
-		FailedWritesOnBoth:       newFakeCounter(),
-		PSCacheSize:              newFakeGaugeFunc(),
-		PSCacheMissCount:         newFakeCounter(),
-		ProxyReadsOriginDuration: newFakeHistogram(),
-		ProxyReadsTargetDuration: newFakeHistogram(),
-		ProxyWritesDuration:      newFakeHistogram(),
-		InFlightReadsOrigin:      newFakeGauge(),
-		InFlightReadsTarget:      newFakeGauge(),
-		InFlightWrites:           newFakeGauge(),
-		OpenClientConnections:    newFakeGaugeFunc(),
+		FailedReadsOrigin:         newFakeCounter(),
+		FailedReadsTarget:         newFakeCounter(),
+		FailedWritesOnOrigin:      newFakeCounter(),
+		FailedWritesOnTarget:      newFakeCounter(),
+		FailedWritesOnBoth:        newFakeCounter(),
+		PSCacheSize:               newFakeGaugeFunc(),
+		PSCacheMissCount:          newFakeCounter(),
+		ProxyReadsOriginDuration:  newFakeHistogram(),
+		ProxyReadsTargetDuration:  newFakeHistogram(),
+		ProxyWritesDuration:       newFakeHistogram(),
+		ProxyReadsOriginOverhead:  newFakeHistogram(),
+		ProxyReadsTargetOverhead:  newFakeHistogram(),
+		ProxyWritesOverhead:       newFakeHistogram(),
+		InFlightReadsOrigin:       newFakeGauge(),
+		InFlightReadsTarget:       newFakeGauge(),
+		InFlightWrites:            newFakeGauge(),
+		OpenClientConnections:     newFakeGaugeFunc(),
+		DualReadsMatches:          newFakeCounter(),
+		DualReadsMismatches:       newFakeCounter(),
+		RateLimitedRequests:       newFakeCounter(),
+		DryRunSkippedWrites:       newFakeCounter(),
+		SuppressedDuplicateWrites: newFakeCounter(),
+		TargetWritePacingRate:     newFakeGaugeFunc(),
+		UnrecognizedStatements:    newFakeCounter(),
+		RequestDecodeErrors:       newFakeCounter(),
+
+		OpenedClientConnections:              newFakeCounter(),
+		RejectedClientConnections:            newFakeCounter(),
+		ClientConnectionsClosedByClient:      newFakeCounter(),
+		ClientConnectionsClosedByIdleTimeout: newFakeCounter(),
+		ClientConnectionsClosedByShutdown:    newFakeCounter(),
+		ClientConnectionsClosedByError:       newFakeCounter(),
+		ClientConnectionDuration:             newFakeHistogram(),
 	}
 }
 
@@ -286,3 +305,135 @@ func (recv *fakeMetric) WithLabels(map[string]string) metrics.Metric {
 func newFakeMetric() metrics.Metric {
 	return &fakeMetric{}
 }
+
+func TestGetRequestInfoFromQueryInfo_PrimaryClusterKeyspaceOverrides(t *testing.T) {
+	overrides := map[string]common.ClusterType{
+		"ks1": common.ClusterTypeTarget,
+		"ks2": common.ClusterTypeOrigin,
+	}
+	tests := []struct {
+		name            string
+		query           string
+		keyspace        string
+		primaryCluster  common.ClusterType
+		expectedForward forwardDecision
+		expectedAsync   bool
+	}{
+		{"overridden keyspace", "SELECT * FROM ks1.tb", "", common.ClusterTypeOrigin, forwardToTarget, false},
+		{"overridden current keyspace", "SELECT * FROM tb", "ks1", common.ClusterTypeOrigin, forwardToTarget, false},
+		{"override equal to primary cluster", "SELECT * FROM ks2.tb", "", common.ClusterTypeOrigin, forwardToOrigin, true},
+		{"override to origin", "SELECT * FROM ks2.tb", "", common.ClusterTypeTarget, forwardToOrigin, false},
+		{"keyspace without override", "SELECT * FROM ks3.tb", "", common.ClusterTypeOrigin, forwardToOrigin, true},
+		{"writes are not affected", "INSERT INTO ks1.tb (a) VALUES (1)", "", common.ClusterTypeOrigin, forwardToBoth, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+			require.Nil(t, err)
+			f := mockQueryFrame(t, tt.query)
+			queryInfo := inspectCqlQuery(tt.query, tt.keyspace, timeUuidGenerator)
+			actual := getRequestInfoFromQueryInfo(f, tt.primaryCluster, overrides, nil, nil, false, true, queryInfo)
+			require.Equal(t, NewGenericRequestInfo(tt.expectedForward, tt.expectedAsync, true), actual)
+		})
+	}
+}
+
+func TestGetRequestInfoFromQueryInfo_ExcludedTables(t *testing.T) {
+	filter := newTableFilter(nil, []string{"ks1", "ks2.tb1"})
+	tests := []struct {
+		name     string
+		query    string
+		keyspace string
+		expected RequestInfo
+	}{
+		{"read on excluded keyspace", "SELECT * FROM ks1.tb", "", NewExcludedTableRequestInfo()},
+		{"write on excluded keyspace", "INSERT INTO ks1.tb (a) VALUES (1)", "", NewExcludedTableWriteRequestInfo()},
+		{"write on excluded current keyspace", "UPDATE tb SET a = 1 WHERE b = 2", "ks1", NewExcludedTableWriteRequestInfo()},
+		{"delete on excluded table", "DELETE FROM ks2.tb1 WHERE a = 1", "", NewExcludedTableWriteRequestInfo()},
+		{"read on included table", "SELECT * FROM ks2.tb2", "", nil},
+		{"use keyspace with excluded table", "USE ks2", "", nil},
+		{"system query", "SELECT * FROM system.local", "ks1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+			require.Nil(t, err)
+			f := mockQueryFrame(t, tt.query)
+			queryInfo := inspectCqlQuery(tt.query, tt.keyspace, timeUuidGenerator)
+			actual := getRequestInfoFromQueryInfo(f, common.ClusterTypeOrigin, nil, filter, nil, false, true, queryInfo)
+			if tt.expected != nil {
+				require.Equal(t, tt.expected, actual)
+			} else {
+				require.NotEqual(t, NewExcludedTableRequestInfo(), actual)
+				require.NotEqual(t, NewExcludedTableWriteRequestInfo(), actual)
+			}
+		})
+	}
+
+	use := getRequestInfoFromQueryInfo(mockQueryFrame(t, "USE ks1"), common.ClusterTypeTarget, nil, filter, nil, false,
+		true, inspectCqlQuery("USE ks1", "", nil))
+	require.Equal(t, NewExcludedKeyspaceUseRequestInfo(), use)
+	require.Equal(t, forwardToBoth, use.GetForwardDecision())
+	require.Equal(t, common.ClusterTypeOrigin, (&ClientHandler{primaryCluster: common.ClusterTypeTarget}).
+		getDualWriteResponseCluster(use))
+
+	prepare := NewPrepareRequestInfo(NewExcludedTableRequestInfo(), nil, false, "SELECT * FROM ks1.tb", "")
+	require.Equal(t, forwardToOrigin, prepare.GetForwardDecision())
+	require.False(t, prepare.ShouldAlsoBeSentAsync())
+}
+
+func TestBuildRequestInfo_ExcludedTablesBatch(t *testing.T) {
+	filter := newTableFilter(nil, []string{"ks1"})
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	psCache := NewPreparedStatementCache(0)
+	excludedPrepare := NewPrepareRequestInfo(NewExcludedTableWriteRequestInfo(), nil, false, "INSERT INTO ks1.tb (a) VALUES (?)", "")
+	psCache.Store(
+		&message.PreparedResult{PreparedQueryId: []byte("excluded")},
+		&message.PreparedResult{PreparedQueryId: []byte("excluded")},
+		excludedPrepare)
+
+	psCache.Store(
+		&message.PreparedResult{PreparedQueryId: []byte("included")},
+		&message.PreparedResult{PreparedQueryId: []byte("included-target")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false,
+			"INSERT INTO ks2.tb (a) VALUES (?)", ""))
+
+	tests := []struct {
+		name     string
+		children []*message.BatchChild
+		expected forwardDecision // forwardToNone if the batch is rejected
+	}{
+		{"excluded tables only", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb (a) VALUES (1)"},
+			{Id: []byte("excluded")},
+		}, forwardToOrigin},
+		{"included tables only", []*message.BatchChild{
+			{Query: "INSERT INTO ks2.tb (a) VALUES (1)"},
+			{Id: []byte("included")},
+		}, forwardToBoth},
+		{"excluded and included tables", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb (a) VALUES (1)"},
+			{Query: "INSERT INTO ks2.tb (a) VALUES (1)"},
+		}, forwardToNone},
+		{"excluded and included prepared statements", []*message.BatchChild{
+			{Id: []byte("excluded")},
+			{Id: []byte("included")},
+		}, forwardToNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, &message.Batch{Children: tt.children}, primitive.ProtocolVersion4)
+			actual, err := buildRequestInfo(
+				&frameDecodeContext{frame: f}, nil, psCache, newFakeMetricHandler(), "", common.ClusterTypeOrigin,
+				nil, filter, nil, false, true, false, timeUuidGenerator)
+			if tt.expected == forwardToNone {
+				require.IsType(t, &MixedBatchError{}, err)
+				return
+			}
+			require.Nil(t, err)
+			require.IsType(t, &BatchRequestInfo{}, actual)
+			require.Equal(t, tt.expected, actual.GetForwardDecision())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// passwordRefreshInterval is the minimum interval between two reads of a password file.
+const passwordRefreshInterval = 10 * time.Second
+
+// PasswordProvider returns the password that the proxy uses to authenticate with a cluster.
+//
+// The password is resolved again when it is needed to open a new connection and it was last resolved more than
+// passwordRefreshInterval ago, so a password file that is rewritten when the password is rotated is picked up without
+// restarting the proxy and without reading the file for every client connection. Connections that are already
+// authenticated (and the client sessions that use them) are not affected.
+type PasswordProvider struct {
+	clusterType common.ClusterType
+	parseFunc   func() (string, error)
+	now         func() time.Time
+
+	lock        sync.Mutex
+	password    string
+	refreshTime time.Time
+}
+
+func NewPasswordProvider(clusterType common.ClusterType, parseFunc func() (string, error)) (*PasswordProvider, error) {
+	return newPasswordProvider(clusterType, parseFunc, time.Now)
+}
+
+func newPasswordProvider(
+	clusterType common.ClusterType, parseFunc func() (string, error), now func() time.Time) (*PasswordProvider, error) {
+	password, err := parseFunc()
+	if err != nil {
+		return nil, err
+	}
+	return &PasswordProvider{
+		clusterType: clusterType,
+		parseFunc:   parseFunc,
+		now:         now,
+		password:    password,
+		refreshTime: now(),
+	}, nil
+}
+
+// Get returns the current password. If it can not be resolved (e.g. the password file is being replaced)
+// the last known password is returned until the next refresh.
+func (recv *PasswordProvider) Get() string {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	now := recv.now()
+	if now.Sub(recv.refreshTime) < passwordRefreshInterval {
+		return recv.password
+	}
+	recv.refreshTime = now
+	password, err := recv.parseFunc()
+	if err != nil {
+		log.Warnf("Could not resolve %v password, using the last known password: %v", recv.clusterType, err)
+		return recv.password
+	}
+	if password != recv.password {
+		log.Infof("%v password changed, it will be used for new connections.", recv.clusterType)
+		recv.password = password
+	}
+	return password
+}
@@ -0,0 +1,47 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPasswordProvider_Rotation(t *testing.T) {
+	password := "password1"
+	var parseErr error
+	parseCount := 0
+	now := time.Now()
+	provider, err := newPasswordProvider(common.ClusterTypeOrigin, func() (string, error) {
+		parseCount++
+		return password, parseErr
+	}, func() time.Time {
+		return now
+	})
+	require.Nil(t, err)
+	require.Equal(t, "password1", provider.Get())
+
+	// password is cached until the refresh interval elapsed
+	password = "password2"
+	require.Equal(t, "password1", provider.Get())
+	require.Equal(t, 1, parseCount)
+	now = now.Add(passwordRefreshInterval)
+	require.Equal(t, "password2", provider.Get())
+	require.Equal(t, "password2", provider.Get())
+	require.Equal(t, 2, parseCount)
+
+	password = ""
+	parseErr = errors.New("file not found")
+	now = now.Add(passwordRefreshInterval)
+	require.Equal(t, "password2", provider.Get())
+	require.Equal(t, "password2", provider.Get())
+	require.Equal(t, 3, parseCount)
+}
+
+func TestPasswordProvider_InitialError(t *testing.T) {
+	_, err := NewPasswordProvider(common.ClusterTypeTarget, func() (string, error) {
+		return "", errors.New("file not found")
+	})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,191 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dryRunReportInterval        = time.Minute
+	dryRunUnrecognizedStatement = "<unrecognized statement>"
+)
+
+// dryRun sends the writes that would be sent to both clusters only to ORIGIN when ZDM_DRY_RUN is enabled and keeps
+// a report of the tables that they would have written to on TARGET.
+//
+// Everything else is handled as usual: reads follow the read mode and primary cluster settings, and the handshake,
+// USE and PREPARE requests are still sent to both clusters so that EXECUTE requests are routed exactly like they would
+// be without ZDM_DRY_RUN.
+//
+// The report is logged every minute (if it changed) and when the proxy shuts down. Writes that the CQL parser of the
+// proxy doesn't recognize (e.g. DDL statements) are reported separately because they can't be associated with a table.
+type dryRun struct {
+	lock           *sync.Mutex
+	writesByTable  map[string]int64
+	changed        bool
+	lastReport     time.Time
+	preparedTables map[string]string
+}
+
+// newDryRun returns nil if ZDM_DRY_RUN is disabled.
+func newDryRun(enabled bool) *dryRun {
+	if !enabled {
+		return nil
+	}
+	log.Infof("Dry run mode is enabled, writes are not sent to TARGET.")
+	return &dryRun{
+		lock:           &sync.Mutex{},
+		writesByTable:  make(map[string]int64),
+		changed:        false,
+		lastReport:     time.Now(),
+		preparedTables: make(map[string]string),
+	}
+}
+
+// Apply returns a request info that sends the provided request only to ORIGIN if it is a write that would be sent
+// to both clusters, otherwise it returns the provided request info.
+func (recv *dryRun) Apply(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator, proxyMetrics *metrics.ProxyMetrics) RequestInfo {
+	if recv == nil || !isDryRunWrite(frameContext, requestInfo, currentKeyspace, timeUuidGenerator) {
+		return requestInfo
+	}
+
+	proxyMetrics.DryRunSkippedWrites.Add(1)
+	recv.record(recv.getWriteTables(frameContext, requestInfo, currentKeyspace, timeUuidGenerator))
+
+	return newOriginOnlyRequestInfo(requestInfo)
+}
+
+// isDryRunWrite returns true for QUERY, EXECUTE and BATCH requests that are sent to both clusters, except USE queries.
+func isDryRunWrite(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string, timeUuidGenerator TimeUuidGenerator) bool {
+	if requestInfo.GetForwardDecision() != forwardToBoth {
+		return false
+	}
+	switch frameContext.GetRawFrame().Header.OpCode {
+	case primitive.OpCodeQuery:
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		return err != nil || stmtQueryData.queryData.getStatementType() != statementTypeUse
+	case primitive.OpCodeExecute, primitive.OpCodeBatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// getWriteTables returns the tables that the provided write modifies. The tables of prepared statements are cached
+// so that the query of a prepared statement is only parsed again the first time that it is executed.
+func (recv *dryRun) getWriteTables(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) []string {
+	var tables []string
+	var preparedDataByStmtIdx map[int]PreparedData
+	if batchRequestInfo, ok := requestInfo.(*BatchRequestInfo); ok {
+		preparedDataByStmtIdx = batchRequestInfo.GetPreparedDataByStmtIdx()
+	}
+
+	if executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo); ok {
+		preparedDataByStmtIdx = map[int]PreparedData{0: executeRequestInfo.GetPreparedData()}
+	} else {
+		stmtsQueryData, err := frameContext.GetOrInspectAllStatements(currentKeyspace, timeUuidGenerator)
+		if err != nil {
+			log.Debugf("Could not inspect write for dry run report: %v", err)
+			return []string{dryRunUnrecognizedStatement}
+		}
+		for _, stmtQueryData := range stmtsQueryData {
+			tables = append(tables, getDryRunTableName(stmtQueryData.queryData))
+		}
+	}
+
+	for _, preparedData := range preparedDataByStmtIdx {
+		tables = append(tables, recv.getPreparedTable(preparedData.GetPrepareRequestInfo(), currentKeyspace, timeUuidGenerator))
+	}
+	return tables
+}
+
+func (recv *dryRun) getPreparedTable(
+	prepareRequestInfo *PrepareRequestInfo, currentKeyspace string, timeUuidGenerator TimeUuidGenerator) string {
+	keyspace := prepareRequestInfo.GetKeyspace()
+	if keyspace == "" {
+		keyspace = currentKeyspace
+	}
+	key := keyspace + "|" + prepareRequestInfo.GetQuery()
+
+	recv.lock.Lock()
+	table, ok := recv.preparedTables[key]
+	recv.lock.Unlock()
+	if ok {
+		return table
+	}
+
+	table = getDryRunTableName(inspectCqlQuery(prepareRequestInfo.GetQuery(), keyspace, timeUuidGenerator))
+	recv.lock.Lock()
+	recv.preparedTables[key] = table
+	recv.lock.Unlock()
+	return table
+}
+
+func getDryRunTableName(queryInfo QueryInfo) string {
+	switch queryInfo.getStatementType() {
+	case statementTypeInsert, statementTypeUpdate, statementTypeDelete, statementTypeBatch:
+		keyspace := queryInfo.getApplicableKeyspace()
+		if keyspace == "" {
+			return queryInfo.getTableName()
+		}
+		return keyspace + "." + queryInfo.getTableName()
+	default:
+		return dryRunUnrecognizedStatement
+	}
+}
+
+func (recv *dryRun) record(tables []string) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	for _, table := range tables {
+		recv.writesByTable[table]++
+	}
+	recv.changed = true
+	if time.Since(recv.lastReport) >= dryRunReportInterval {
+		recv.logReport()
+	}
+}
+
+// LogReport logs the number of writes that were not sent to TARGET for each table.
+func (recv *dryRun) LogReport() {
+	if recv == nil {
+		return
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.logReport()
+}
+
+func (recv *dryRun) logReport() {
+	recv.lastReport = time.Now()
+	if !recv.changed {
+		return
+	}
+	recv.changed = false
+	log.Infof("Dry run report, writes that would have been sent to TARGET since the proxy started: %v.",
+		formatDryRunWrites(recv.writesByTable))
+}
+
+func formatDryRunWrites(writesByTable map[string]int64) string {
+	tables := make([]string, 0, len(writesByTable))
+	for table := range writesByTable {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	entries := make([]string, 0, len(tables))
+	for _, table := range tables {
+		entries = append(entries, fmt.Sprintf("%v=%v", table, writesByTable[table]))
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,71 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDryRun_Apply(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	psCache := NewPreparedStatementCache(0)
+	psCache.Store(
+		&message.PreparedResult{PreparedQueryId: []byte("insert")},
+		&message.PreparedResult{PreparedQueryId: []byte("insert")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "INSERT INTO tb2 (a) VALUES (?)", ""))
+
+	tests := []struct {
+		name             string
+		f                *frameDecodeContext
+		expectedDecision forwardDecision
+		expectedTables   []string
+	}{
+		{"insert", &frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")},
+			forwardToOrigin, []string{"ks1.tb1"}},
+		{"insert json", &frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO ks1.tb1 JSON '{}'")},
+			forwardToOrigin, []string{"ks1.tb1"}},
+		{"create table", &frameDecodeContext{frame: mockQueryFrame(t, "CREATE TABLE ks1.tb1 (a int PRIMARY KEY)")},
+			forwardToOrigin, []string{dryRunUnrecognizedStatement}},
+		{"select", &frameDecodeContext{frame: mockQueryFrame(t, "SELECT * FROM ks1.tb1")},
+			forwardToOrigin, nil},
+		{"use", &frameDecodeContext{frame: mockQueryFrame(t, "USE ks1")},
+			forwardToBoth, nil},
+		{"prepare", &frameDecodeContext{frame: mockPrepareFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (?)")},
+			forwardToBoth, nil},
+		{"execute", &frameDecodeContext{frame: mockExecuteFrame(t, "insert")},
+			forwardToOrigin, []string{"ks2.tb2"}},
+		{"batch", &frameDecodeContext{frame: mockFrame(t, &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"},
+			{Id: []byte("insert")},
+		}}, primitive.ProtocolVersion4)},
+			forwardToOrigin, []string{"ks1.tb1", "ks2.tb2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestInfo, err := buildRequestInfo(
+				tt.f, nil, psCache, newFakeMetricHandler(), "ks2", common.ClusterTypeOrigin,
+				nil, nil, nil, false, true, false, timeUuidGenerator)
+			require.Nil(t, err)
+
+			dryRun := newDryRun(true)
+			actual := dryRun.Apply(tt.f, requestInfo, "ks2", timeUuidGenerator, newFakeProxyMetrics())
+			require.Equal(t, tt.expectedDecision, actual.GetForwardDecision())
+			require.Equal(t, requestInfo.ShouldAlsoBeSentAsync(), actual.ShouldAlsoBeSentAsync())
+			require.Equal(t, len(tt.expectedTables), len(dryRun.writesByTable))
+			for _, table := range tt.expectedTables {
+				require.Equal(t, int64(1), dryRun.writesByTable[table])
+			}
+		})
+	}
+}
+
+func TestDryRun_Disabled(t *testing.T) {
+	dryRun := newDryRun(false)
+	requestInfo := NewGenericRequestInfo(forwardToBoth, false, true)
+	f := &frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")}
+	require.Same(t, requestInfo, dryRun.Apply(f, requestInfo, "", nil, newFakeProxyMetrics()))
+	dryRun.LogReport()
+}
@@ -0,0 +1,154 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// shouldSampleDualRead returns false if a read that would also be sent to the async connector should skip it because
+// it was not selected by ZDM_DUAL_READS_SAMPLE_PERCENT.
+//
+// Only reads are sampled, other requests that are sent to the async connector (USE and PREPARE) are needed
+// to keep the state of the async connection in sync with the primary cluster connection.
+func shouldSampleDualRead(requestInfo RequestInfo, samplePercent int) bool {
+	if !isDualRead(requestInfo) || samplePercent >= 100 {
+		return true
+	}
+	return rand.Intn(100) < samplePercent
+}
+
+func isDualRead(requestInfo RequestInfo) bool {
+	if _, isPrepare := requestInfo.(*PrepareRequestInfo); isPrepare {
+		return false
+	}
+	switch requestInfo.GetForwardDecision() {
+	case forwardToOrigin, forwardToTarget:
+		return true
+	default:
+		return false
+	}
+}
+
+// readResultSummary is a compact representation of a read response that is used to compare the results returned
+// by the primary and secondary clusters without keeping the responses in memory.
+type readResultSummary struct {
+	kind     string
+	rowCount int
+	checksum uint64
+}
+
+func (recv *readResultSummary) String() string {
+	return fmt.Sprintf("%v{rows=%v, checksum=%x}", recv.kind, recv.rowCount, recv.checksum)
+}
+
+// summarizeReadResult computes the row count and checksum of the rows contained in the provided response. The checksum
+// only covers the row values, result metadata (which includes the paging state) is ignored.
+func summarizeReadResult(f *frame.RawFrame) (*readResultSummary, error) {
+	body, err := defaultCodec.DecodeBody(f.Header, bytes.NewReader(f.Body))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode response body: %w", err)
+	}
+
+	switch msg := body.Message.(type) {
+	case *message.RowsResult:
+		hash := fnv.New64a()
+		lengthBuf := make([]byte, 4)
+		for _, row := range msg.Data {
+			for _, column := range row {
+				if column == nil {
+					binary.BigEndian.PutUint32(lengthBuf, 0xFFFFFFFF)
+				} else {
+					binary.BigEndian.PutUint32(lengthBuf, uint32(len(column)))
+				}
+				_, _ = hash.Write(lengthBuf)
+				_, _ = hash.Write(column)
+			}
+		}
+		return &readResultSummary{kind: "ROWS", rowCount: len(msg.Data), checksum: hash.Sum64()}, nil
+	case message.Error:
+		return &readResultSummary{kind: msg.GetErrorCode().String()}, nil
+	default:
+		return &readResultSummary{kind: msg.GetOpCode().String()}, nil
+	}
+}
+
+// dualReadComparison compares the result of a read on the primary cluster with the result of the same read
+// sent asynchronously to the secondary cluster. The comparison happens when both results are available.
+type dualReadComparison struct {
+	lock         *sync.Mutex
+	query        string
+	primary      *readResultSummary
+	secondary    *readResultSummary
+	proxyMetrics *metrics.ProxyMetrics
+}
+
+func newDualReadComparison(query string, proxyMetrics *metrics.ProxyMetrics) *dualReadComparison {
+	return &dualReadComparison{
+		lock:         &sync.Mutex{},
+		query:        query,
+		primary:      nil,
+		secondary:    nil,
+		proxyMetrics: proxyMetrics,
+	}
+}
+
+func (recv *dualReadComparison) SetPrimaryResult(f *frame.RawFrame) {
+	recv.setResult(f, true)
+}
+
+func (recv *dualReadComparison) SetSecondaryResult(f *frame.RawFrame) {
+	recv.setResult(f, false)
+}
+
+func (recv *dualReadComparison) setResult(f *frame.RawFrame, primary bool) {
+	summary, err := summarizeReadResult(f)
+	if err != nil {
+		log.Warnf("Could not compare dual read result (primary=%v): %v", primary, err)
+		return
+	}
+
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if primary {
+		recv.primary = summary
+	} else {
+		recv.secondary = summary
+	}
+
+	if recv.primary == nil || recv.secondary == nil {
+		return
+	}
+
+	if *recv.primary == *recv.secondary {
+		recv.proxyMetrics.DualReadsMatches.Add(1)
+	} else {
+		recv.proxyMetrics.DualReadsMismatches.Add(1)
+		log.Warnf("Dual read mismatch, primary cluster returned %v but secondary cluster returned %v for query: %v",
+			recv.primary, recv.secondary, recv.query)
+	}
+}
+
+// getQueryForDualReadComparison returns the CQL query of a QUERY or EXECUTE request so that it can be logged
+// when the results of a dual read don't match.
+func getQueryForDualReadComparison(frameContext *frameDecodeContext, requestInfo RequestInfo) string {
+	if executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo); ok {
+		return executeRequestInfo.GetPreparedData().GetPrepareRequestInfo().GetQuery()
+	}
+
+	decodedFrame, err := frameContext.GetOrDecodeFrame()
+	if err != nil {
+		return ""
+	}
+	if queryMsg, ok := decodedFrame.Body.Message.(*message.Query); ok {
+		return queryMsg.Query
+	}
+	return ""
+}
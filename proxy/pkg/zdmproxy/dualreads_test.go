@@ -0,0 +1,84 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testCounter struct {
+	value int
+}
+
+func (recv *testCounter) Add(valueToAdd int) {
+	recv.value += valueToAdd
+}
+
+func TestShouldSampleDualRead(t *testing.T) {
+	read := NewGenericRequestInfo(forwardToOrigin, true, true)
+	use := NewGenericRequestInfo(forwardToBoth, true, true)
+	prepare := NewPrepareRequestInfo(read, nil, false, "SELECT * FROM ks.tb", "")
+	for i := 0; i < 100; i++ {
+		require.True(t, shouldSampleDualRead(read, 100))
+		require.False(t, shouldSampleDualRead(read, 0))
+		require.True(t, shouldSampleDualRead(use, 0))
+		require.True(t, shouldSampleDualRead(prepare, 0))
+	}
+}
+
+func TestDualReadComparison(t *testing.T) {
+	rows := func(values ...string) *frame.RawFrame {
+		data := message.RowSet{}
+		for _, value := range values {
+			data = append(data, message.Row{[]byte(value)})
+		}
+		return mockResultFrame(t, &message.RowsResult{
+			Metadata: &message.RowsMetadata{ColumnCount: 1},
+			Data:     data,
+		})
+	}
+	readTimeout := mockResultFrame(t, &message.ReadTimeout{
+		ErrorMessage: "timeout", Consistency: primitive.ConsistencyLevelOne})
+
+	tests := []struct {
+		name      string
+		primary   *frame.RawFrame
+		secondary *frame.RawFrame
+		match     bool
+	}{
+		{"same rows", rows("a", "b"), rows("a", "b"), true},
+		{"no rows", rows(), rows(), true},
+		{"different row count", rows("a", "b"), rows("a"), false},
+		{"different values", rows("a", "b"), rows("a", "c"), false},
+		{"error on secondary", rows("a"), readTimeout, false},
+		{"same error", readTimeout, readTimeout, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyMetrics := newFakeProxyMetrics()
+			matches, mismatches := &testCounter{}, &testCounter{}
+			proxyMetrics.DualReadsMatches = matches
+			proxyMetrics.DualReadsMismatches = mismatches
+
+			comparison := newDualReadComparison("SELECT * FROM ks.tb", proxyMetrics)
+			comparison.SetSecondaryResult(tt.secondary)
+			require.Equal(t, 0, matches.value+mismatches.value)
+			comparison.SetPrimaryResult(tt.primary)
+			if tt.match {
+				require.Equal(t, 1, matches.value)
+				require.Equal(t, 0, mismatches.value)
+			} else {
+				require.Equal(t, 0, matches.value)
+				require.Equal(t, 1, mismatches.value)
+			}
+		})
+	}
+}
+
+func mockResultFrame(t *testing.T, msg message.Message) *frame.RawFrame {
+	rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, msg))
+	require.Nil(t, err)
+	return rawFrame
+}
@@ -0,0 +1,145 @@
+package zdmproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// dualWritesQueueMaxWritesPerTurn is the number of writes that a dual writes worker sends for a client connection
+// before it moves on to the next client connection with queued writes.
+const dualWritesQueueMaxWritesPerTurn = 64
+
+// dualWritesWorkers sends the requests that are sent to both clusters (see ZDM_DUAL_WRITES_MAX_WORKERS). The write
+// queues of the cluster connectors can be full when a cluster is slow, sending on the dual writes workers makes sure
+// that the request / response workers don't wait for them and can keep forwarding reads and processing responses.
+//
+// Every client connection queues its dual writes in its own dualWritesQueue, queuing a write never blocks. A queue with
+// writes waits in a list until a worker takes it, sends some of its writes in order and puts it back at the end of the
+// list, so a client connection uses at most one worker at a time and the other client connections get a turn.
+type dualWritesWorkers struct {
+	lock     *sync.Mutex
+	cond     *sync.Cond
+	ready    []*dualWritesQueue
+	shutdown bool
+	wg       *sync.WaitGroup
+}
+
+func newDualWritesWorkers(workers int) *dualWritesWorkers {
+	lock := &sync.Mutex{}
+	dualWritesWorkers := &dualWritesWorkers{
+		lock: lock,
+		cond: sync.NewCond(lock),
+		wg:   &sync.WaitGroup{},
+	}
+	for i := 0; i < workers; i++ {
+		dualWritesWorkers.wg.Add(1)
+		go func() {
+			defer dualWritesWorkers.wg.Done()
+			for {
+				queue, ok := dualWritesWorkers.next()
+				if !ok {
+					return
+				}
+				queue.sendQueuedWrites()
+			}
+		}()
+	}
+	return dualWritesWorkers
+}
+
+// next blocks until a queue is ready, it returns false when the workers are shut down and no queue is ready.
+func (recv *dualWritesWorkers) next() (*dualWritesQueue, bool) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	for len(recv.ready) == 0 && !recv.shutdown {
+		recv.cond.Wait()
+	}
+	if len(recv.ready) == 0 {
+		return nil, false
+	}
+	queue := recv.ready[0]
+	recv.ready[0] = nil
+	recv.ready = recv.ready[1:]
+	return queue, true
+}
+
+func (recv *dualWritesWorkers) schedule(queue *dualWritesQueue) {
+	recv.lock.Lock()
+	recv.ready = append(recv.ready, queue)
+	recv.lock.Unlock()
+	recv.cond.Signal()
+}
+
+// Shutdown stops the workers once the queues that are ready are sent.
+func (recv *dualWritesWorkers) Shutdown() {
+	recv.lock.Lock()
+	recv.shutdown = true
+	recv.lock.Unlock()
+	recv.cond.Broadcast()
+	recv.wg.Wait()
+}
+
+// dualWritesQueue holds the dual writes of a client connection until a dual writes worker sends them, in the order in
+// which they were added.
+//
+// Requests are handled concurrently by the request workers so two writes that a client sends without waiting for the
+// first response could otherwise be sent to ORIGIN in one order and to TARGET in the other order and, with server side
+// timestamps, the two clusters would end up with different values. The writes of a queue are sent by one worker at a
+// time and each write is queued on both cluster connections before the next one is sent, so they are sent to both
+// clusters in the same order. A worker that waits for the full write queue of a cluster connection only delays the
+// writes of its client connection.
+type dualWritesQueue struct {
+	workers   *dualWritesWorkers
+	lock      *sync.Mutex
+	writes    []*queuedDualWrite
+	scheduled bool // the queue is in the list of ready queues, a worker is sending its writes or it waits for a timer
+}
+
+type queuedDualWrite struct {
+	sendTime time.Time // zero if the write isn't paced (see targetWritePacer)
+	send     func()
+}
+
+func newDualWritesQueue(workers *dualWritesWorkers) *dualWritesQueue {
+	return &dualWritesQueue{
+		workers: workers,
+		lock:    &sync.Mutex{},
+	}
+}
+
+// Add queues a write without waiting for a worker, send is called by a worker after the writes added before it and not
+// before sendTime. Until then, the queue doesn't hold a worker and the writes added after it wait too.
+func (recv *dualWritesQueue) Add(sendTime time.Time, send func()) {
+	recv.lock.Lock()
+	recv.writes = append(recv.writes, &queuedDualWrite{sendTime: sendTime, send: send})
+	schedule := !recv.scheduled
+	recv.scheduled = true
+	recv.lock.Unlock()
+	if schedule {
+		recv.workers.schedule(recv)
+	}
+}
+
+func (recv *dualWritesQueue) sendQueuedWrites() {
+	for i := 0; i < dualWritesQueueMaxWritesPerTurn; i++ {
+		recv.lock.Lock()
+		if len(recv.writes) == 0 {
+			recv.scheduled = false
+			recv.lock.Unlock()
+			return
+		}
+		write := recv.writes[0]
+		if delay := time.Until(write.sendTime); delay > 0 {
+			recv.lock.Unlock()
+			time.AfterFunc(delay, func() {
+				recv.workers.schedule(recv)
+			})
+			return
+		}
+		recv.writes[0] = nil
+		recv.writes = recv.writes[1:]
+		recv.lock.Unlock()
+		write.send()
+	}
+	recv.workers.schedule(recv)
+}
@@ -0,0 +1,330 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestDualWritesClientHandler returns a client handler that forwards requests to the provided write queues of the
+// cluster connectors, a full write queue behaves like a slow cluster.
+func newTestDualWritesClientHandler(
+	t testing.TB, workers *dualWritesWorkers, originWriteQueue chan *frame.RawFrame,
+	targetWriteQueue chan *frame.RawFrame) *ClientHandler {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	clientHandlerContext, cancelFunc := context.WithCancel(context.Background())
+	t.Cleanup(cancelFunc)
+	newClusterConnector := func(clusterType common.ClusterType, writeQueue chan *frame.RawFrame) *ClusterConnector {
+		return &ClusterConnector{
+			clusterType:    clusterType,
+			writeCoalescer: &writeCoalescer{connection: clientConn, writeQueue: writeQueue},
+		}
+	}
+	return &ClientHandler{
+		conf:                          &config.Config{ProxyRequestTimeoutMs: 10000},
+		topologyConfig:                &common.TopologyConfig{},
+		metricHandler:                 newFakeMetricHandler(),
+		clientHandlerContext:          clientHandlerContext,
+		currentKeyspaceName:           &atomic.Value{},
+		startupRequest:                &atomic.Value{},
+		preparedStatementCache:        NewPreparedStatementCache(0),
+		requestContextHolders:         &sync.Map{},
+		clientHandlerRequestWaitGroup: &sync.WaitGroup{},
+		closedRespChannelLock:         &sync.RWMutex{},
+		respChannel:                   make(chan *Response, 1),
+		originCassandraConnector:      newClusterConnector(common.ClusterTypeOrigin, originWriteQueue),
+		targetCassandraConnector:      newClusterConnector(common.ClusterTypeTarget, targetWriteQueue),
+		dualWritesQueue:               newDualWritesQueue(workers),
+		dualWritesWaitGroup:           &sync.WaitGroup{},
+		primaryCluster:                common.ClusterTypeOrigin,
+		targetReadsCanary:             newTargetReadsCanary(0, false),
+		targetTableNameQualifier:      newTableNameQualifier(false),
+		sessionKeyspace:               newSessionKeyspace(),
+	}
+}
+
+// newTestStalledTargetClientHandler returns a client handler whose TARGET write queue is full and never drained and
+// whose ORIGIN requests are sent to the returned channel.
+func newTestStalledTargetClientHandler(t testing.TB, workers *dualWritesWorkers) (*ClientHandler, chan *frame.RawFrame) {
+	originWriteQueue := make(chan *frame.RawFrame, 1024)
+	targetWriteQueue := make(chan *frame.RawFrame, 1)
+	targetWriteQueue <- mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (0)")
+	ch := newTestDualWritesClientHandler(t, workers, originWriteQueue, targetWriteQueue)
+	t.Cleanup(func() {
+		// unblock the dual writes workers
+		for {
+			select {
+			case <-targetWriteQueue:
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	})
+	return ch, originWriteQueue
+}
+
+func TestDualWritesQueue_SendsInOrder(t *testing.T) {
+	workers := newDualWritesWorkers(4)
+	defer workers.Shutdown()
+	queues := []*dualWritesQueue{newDualWritesQueue(workers), newDualWritesQueue(workers)}
+
+	var lock sync.Mutex
+	sent := make([][]int, len(queues))
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 1000; i++ {
+		for queueIdx, queue := range queues {
+			wg.Add(1)
+			queueIdx, write := queueIdx, i
+			queue.Add(time.Time{}, func() {
+				defer wg.Done()
+				lock.Lock()
+				defer lock.Unlock()
+				sent[queueIdx] = append(sent[queueIdx], write)
+			})
+		}
+	}
+	wg.Wait()
+	for _, writes := range sent {
+		require.Equal(t, 1000, len(writes))
+		for i, write := range writes {
+			require.Equal(t, i, write)
+		}
+	}
+}
+
+func TestClientHandler_ReadsDuringDualWritesBacklog(t *testing.T) {
+	workers := newDualWritesWorkers(2)
+	t.Cleanup(workers.Shutdown)
+	ch, originWriteQueue := newTestStalledTargetClientHandler(t, workers)
+
+	// more writes than dual writes workers and request / response workers
+	for i := 0; i < 10; i++ {
+		write := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+		write.Header.StreamId = int16(i + 1)
+		done := make(chan error)
+		go func() {
+			done <- ch.forwardRequest(write, time.Now(), nil)
+		}()
+		select {
+		case err := <-done:
+			require.Nil(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "write blocked the request worker")
+		}
+	}
+
+	read := mockQueryFrame(t, "SELECT * FROM ks1.tb1")
+	read.Header.StreamId = 100
+	require.Nil(t, ch.forwardRequest(read, time.Now(), nil))
+	for {
+		select {
+		case f := <-originWriteQueue:
+			if f.Header.StreamId == read.Header.StreamId {
+				cancelTestRequest(ch, read.Header.StreamId)
+				return
+			}
+		case <-time.After(time.Second):
+			require.Fail(t, "read was not forwarded")
+		}
+	}
+}
+
+func TestClientHandler_DualWritesSameOrderOnBothClusters(t *testing.T) {
+	workers := newDualWritesWorkers(4)
+	t.Cleanup(workers.Shutdown)
+	writes := 500
+	originWriteQueue := make(chan *frame.RawFrame, writes)
+	targetWriteQueue := make(chan *frame.RawFrame, writes)
+	ch := newTestDualWritesClientHandler(t, workers, originWriteQueue, targetWriteQueue)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+		go func(streamId int16) {
+			defer wg.Done()
+			write := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+			write.Header.StreamId = streamId
+			require.Nil(t, ch.forwardRequest(write, time.Now(), nil))
+		}(int16(i + 1))
+	}
+	wg.Wait()
+	ch.dualWritesWaitGroup.Wait()
+
+	require.Equal(t, writes, len(originWriteQueue))
+	require.Equal(t, writes, len(targetWriteQueue))
+	for i := 0; i < writes; i++ {
+		require.Equal(t, (<-originWriteQueue).Header.StreamId, (<-targetWriteQueue).Header.StreamId)
+	}
+}
+
+func TestClientHandler_DualWritesWithStalledCoalescer(t *testing.T) {
+	workers := newDualWritesWorkers(2)
+	t.Cleanup(workers.Shutdown)
+	stalledCh, _ := newTestStalledTargetClientHandler(t, workers)
+	originWriteQueue := make(chan *frame.RawFrame, 10)
+	targetWriteQueue := make(chan *frame.RawFrame, 10)
+	ch := newTestDualWritesClientHandler(t, workers, originWriteQueue, targetWriteQueue)
+
+	// the writes of the stalled client connection, including a USE request and an unparseable write, wait for the
+	// write queue of TARGET on a single worker
+	for i, query := range []string{"USE ks1", "INSERT INTO", "INSERT INTO ks1.tb1 (a) VALUES (1)"} {
+		for j := 0; j < 5; j++ {
+			write := mockQueryFrame(t, query)
+			write.Header.StreamId = int16(i*5 + j + 1)
+			require.Nil(t, stalledCh.forwardRequest(write, time.Now(), nil))
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		write := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+		write.Header.StreamId = int16(i + 1)
+		require.Nil(t, ch.forwardRequest(write, time.Now(), nil))
+	}
+	for i := 0; i < 10; i++ {
+		for _, writeQueue := range []chan *frame.RawFrame{originWriteQueue, targetWriteQueue} {
+			select {
+			case f := <-writeQueue:
+				require.Equal(t, int16(i+1), f.Header.StreamId)
+			case <-time.After(time.Second):
+				require.Fail(t, "write of the other client connection was not sent")
+			}
+		}
+	}
+}
+
+func TestClientHandler_ReadsWhileWritesArePaced(t *testing.T) {
+	workers := newDualWritesWorkers(1)
+	t.Cleanup(workers.Shutdown)
+	originWriteQueue := make(chan *frame.RawFrame, 10)
+	ch := newTestDualWritesClientHandler(t, workers, originWriteQueue, make(chan *frame.RawFrame, 10))
+	ch.conf.ProxyRequestTimeoutMs = 1000
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	clientWriteQueue := make(chan *frame.RawFrame, 10)
+	ch.clientConnector = &ClientConnector{
+		writeCoalescer: &writeCoalescer{connection: clientConn, writeQueue: clientWriteQueue},
+	}
+	otherOriginWriteQueue := make(chan *frame.RawFrame, 10)
+	otherCh := newTestDualWritesClientHandler(t, workers, otherOriginWriteQueue, make(chan *frame.RawFrame, 10))
+
+	// 10 writes per second, a write waits 100ms for the previous one
+	ch.targetWritePacer = newTargetWritePacer(true, time.Now)
+	ch.targetWritePacer.OnTargetResponse(mockResultFrame(t, &message.Overloaded{ErrorMessage: "overloaded"}))
+	require.Equal(t, 10.0, ch.targetWritePacer.GetRate())
+
+	start := time.Now()
+	forwardWrite := func(streamId int16) {
+		write := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+		write.Header.StreamId = streamId
+		require.Nil(t, ch.forwardRequest(write, time.Now(), nil))
+	}
+	for i := 1; i <= 3; i++ {
+		forwardWrite(int16(i))
+	}
+
+	// the read is forwarded before the paced writes and the paced writes don't hold the only dual writes worker
+	read := mockQueryFrame(t, "SELECT * FROM ks1.tb1")
+	read.Header.StreamId = 100
+	require.Nil(t, ch.forwardRequest(read, time.Now(), nil))
+	otherWrite := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+	otherWrite.Header.StreamId = 1
+	require.Nil(t, otherCh.forwardRequest(otherWrite, time.Now(), nil))
+	select {
+	case f := <-otherOriginWriteQueue:
+		require.Equal(t, otherWrite.Header.StreamId, f.Header.StreamId)
+	case <-time.After(50 * time.Millisecond):
+		require.Fail(t, "write of the other client connection was delayed")
+	}
+	var sentWrites []int16
+	for readSent := false; !readSent; {
+		select {
+		case f := <-originWriteQueue:
+			if f.Header.StreamId == read.Header.StreamId {
+				readSent = true
+			} else {
+				sentWrites = append(sentWrites, f.Header.StreamId)
+			}
+		case <-time.After(50 * time.Millisecond):
+			require.Fail(t, "read was delayed")
+		}
+	}
+	require.LessOrEqual(t, len(sentWrites), 1)
+	cancelTestRequest(ch, read.Header.StreamId)
+
+	// a write that would wait for more than half of the request timeout fails right away
+	overloadedStreamId := int16(0)
+	for i := int16(4); i <= 10 && overloadedStreamId == 0; i++ {
+		forwardWrite(i)
+		select {
+		case f := <-clientWriteQueue:
+			require.Equal(t, primitive.OpCodeError, f.Header.OpCode)
+			overloadedStreamId = f.Header.StreamId
+		default:
+		}
+	}
+	require.NotEqual(t, int16(0), overloadedStreamId)
+
+	for len(sentWrites) < int(overloadedStreamId-1) {
+		select {
+		case f := <-originWriteQueue:
+			sentWrites = append(sentWrites, f.Header.StreamId)
+		case <-time.After(time.Second):
+			require.Fail(t, "paced write was not sent")
+		}
+	}
+	for i, streamId := range sentWrites {
+		require.Equal(t, int16(i+1), streamId)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+}
+
+// BenchmarkClientHandlerReadsDuringDualWritesBacklog measures how long the proxy takes to forward a read to ORIGIN
+// while the dual writes of the same client connection wait for the full write queue of a slow TARGET.
+func BenchmarkClientHandlerReadsDuringDualWritesBacklog(b *testing.B) {
+	workers := newDualWritesWorkers(4)
+	b.Cleanup(workers.Shutdown)
+	ch, originWriteQueue := newTestStalledTargetClientHandler(b, workers)
+
+	for i := 0; i < 100; i++ {
+		write := mockQueryFrame(b, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+		write.Header.StreamId = int16(i + 1)
+		require.Nil(b, ch.forwardRequest(write, time.Now(), nil))
+	}
+	for len(originWriteQueue) > 0 {
+		<-originWriteQueue
+	}
+
+	read := mockQueryFrame(b, "SELECT * FROM ks1.tb1")
+	read.Header.StreamId = 1000
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		require.Nil(b, ch.forwardRequest(read, time.Now(), nil))
+		<-originWriteQueue
+		cancelTestRequest(ch, read.Header.StreamId)
+	}
+	b.StopTimer()
+}
+
+// cancelTestRequest cancels the pending request with the provided stream id so that the stream id can be used again.
+func cancelTestRequest(ch *ClientHandler, streamId int16) {
+	holder := getOrCreateRequestContextHolder(ch.requestContextHolders, streamId)
+	reqCtx := holder.Get()
+	reqCtx.(*requestContextImpl).Cancel(nil)
+	_ = holder.Clear(reqCtx)
+}
@@ -0,0 +1,139 @@
+package zdmproxy
+
+import (
+	"crypto/sha256"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+type duplicateWriteKey [sha256.Size]byte
+
+// duplicateWrites sends a write only to ORIGIN if the same write was already sent to both clusters recently, when
+// ZDM_TARGET_DUPLICATE_WRITES_WINDOW_MS is set.
+//
+// Drivers send a write again when a request times out or when speculative executions are enabled and the proxy
+// forwards every attempt to both clusters. This is harmless for idempotent writes but non-idempotent writes (e.g.
+// counter updates or list appends) would be applied more than once on TARGET.
+//
+// Only non-idempotent writes (see isIdempotentRequest) with a client side timestamp are tracked because the driver
+// reuses the timestamp when it retries a request, so two writes with the same keyspace, body and timestamp are the
+// same write. The stream id and the client connection are not part of the key because retries can use different ones.
+//
+// A write that fails on TARGET is forgotten so that a retry of the client after the failure is sent to TARGET again.
+type duplicateWrites struct {
+	window      time.Duration
+	lock        *sync.Mutex
+	writes      map[duplicateWriteKey]time.Time
+	lastCleanup time.Time
+}
+
+// newDuplicateWrites returns nil if ZDM_TARGET_DUPLICATE_WRITES_WINDOW_MS is 0.
+func newDuplicateWrites(windowMs int) *duplicateWrites {
+	if windowMs <= 0 {
+		return nil
+	}
+	window := time.Duration(windowMs) * time.Millisecond
+	log.Infof("Duplicate write suppression is enabled, non-idempotent writes that are retried within %v "+
+		"are only sent to ORIGIN.", window)
+	return &duplicateWrites{
+		window:      window,
+		lock:        &sync.Mutex{},
+		writes:      make(map[duplicateWriteKey]time.Time),
+		lastCleanup: time.Now(),
+	}
+}
+
+// Apply returns a request info that sends the provided request only to ORIGIN if it is a duplicate of a tracked write
+// that was sent to both clusters within the window. The request must be the frame that the client sent, before any
+// query modifications, because those can differ between the attempts of the same write.
+//
+// The returned key is nil if the request is not tracked, otherwise it has to be passed to Forget if the write fails
+// on TARGET.
+func (recv *duplicateWrites) Apply(
+	request *frame.RawFrame, frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	overrides *idempotencyOverrides, proxyMetrics *metrics.ProxyMetrics) (RequestInfo, *duplicateWriteKey) {
+	if recv == nil || requestInfo.GetForwardDecision() != forwardToBoth || !hasClientTimestamp(frameContext) {
+		return requestInfo, nil
+	}
+
+	idempotent, err := isIdempotentRequest(request, requestInfo, currentKeyspace, overrides)
+	if err != nil {
+		log.Debugf("Could not check if write is idempotent for duplicate write suppression: %v", err)
+		return requestInfo, nil
+	}
+	if idempotent {
+		return requestInfo, nil
+	}
+
+	key := getDuplicateWriteKey(request, currentKeyspace)
+	if !recv.record(key, time.Now()) {
+		return requestInfo, &key
+	}
+
+	log.Debugf("Sending duplicate write only to ORIGIN (%v).", request.Header)
+	proxyMetrics.SuppressedDuplicateWrites.Add(1)
+	return newOriginOnlyRequestInfo(requestInfo), nil
+}
+
+// Forget removes a write so that the next attempt of the same write is sent to both clusters.
+func (recv *duplicateWrites) Forget(key *duplicateWriteKey) {
+	if recv == nil || key == nil {
+		return
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	delete(recv.writes, *key)
+}
+
+// record returns true if the write was already recorded within the window, otherwise it records it.
+func (recv *duplicateWrites) record(key duplicateWriteKey, now time.Time) bool {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+
+	if now.Sub(recv.lastCleanup) >= recv.window {
+		for k, recordedTime := range recv.writes {
+			if now.Sub(recordedTime) >= recv.window {
+				delete(recv.writes, k)
+			}
+		}
+		recv.lastCleanup = now
+	}
+
+	if recordedTime, ok := recv.writes[key]; ok && now.Sub(recordedTime) < recv.window {
+		return true
+	}
+	recv.writes[key] = now
+	return false
+}
+
+func getDuplicateWriteKey(request *frame.RawFrame, currentKeyspace string) duplicateWriteKey {
+	hash := sha256.New()
+	hash.Write([]byte(currentKeyspace))
+	hash.Write([]byte{0, byte(request.Header.Version), byte(request.Header.OpCode)})
+	hash.Write(request.Body)
+	var key duplicateWriteKey
+	copy(key[:], hash.Sum(nil))
+	return key
+}
+
+// hasClientTimestamp returns true if the provided QUERY, EXECUTE or BATCH request has a client side timestamp.
+func hasClientTimestamp(frameContext *frameDecodeContext) bool {
+	decodedFrame, err := frameContext.GetOrDecodeFrame()
+	if err != nil {
+		return false
+	}
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		return msg.Options != nil && msg.Options.DefaultTimestamp != nil
+	case *message.Execute:
+		return msg.Options != nil && msg.Options.DefaultTimestamp != nil
+	case *message.Batch:
+		return msg.DefaultTimestamp != nil
+	default:
+		return false
+	}
+}
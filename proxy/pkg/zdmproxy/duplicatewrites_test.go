@@ -0,0 +1,107 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDuplicateWrites_Apply(t *testing.T) {
+	counterUpdate := "UPDATE ks1.tb1 SET c = c + 1 WHERE a = 1"
+	tests := []struct {
+		name             string
+		first            *frame.RawFrame
+		second           *frame.RawFrame
+		expectedTracked  bool
+		expectedDecision forwardDecision
+	}{
+		{"retry", mockTimestampQueryFrame(t, counterUpdate, 1, 100), mockTimestampQueryFrame(t, counterUpdate, 2, 100),
+			true, forwardToOrigin},
+		{"different timestamp", mockTimestampQueryFrame(t, counterUpdate, 1, 100), mockTimestampQueryFrame(t, counterUpdate, 2, 101),
+			true, forwardToBoth},
+		{"no timestamp", mockQueryFrame(t, counterUpdate), mockQueryFrame(t, counterUpdate),
+			false, forwardToBoth},
+		{"idempotent", mockTimestampQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)", 1, 100),
+			mockTimestampQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)", 2, 100), false, forwardToBoth},
+		{"lwt batch", mockTimestampBatchFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1) IF NOT EXISTS", 100),
+			mockTimestampBatchFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1) IF NOT EXISTS", 100), true, forwardToOrigin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicateWrites := newDuplicateWrites(1000)
+			requestInfo, key := applyDuplicateWrites(t, duplicateWrites, tt.first)
+			require.Equal(t, forwardToBoth, requestInfo.GetForwardDecision())
+			require.Equal(t, tt.expectedTracked, key != nil)
+
+			requestInfo, key = applyDuplicateWrites(t, duplicateWrites, tt.second)
+			require.Equal(t, tt.expectedDecision, requestInfo.GetForwardDecision())
+			require.Equal(t, tt.expectedTracked && tt.expectedDecision == forwardToBoth, key != nil)
+		})
+	}
+}
+
+func TestDuplicateWrites_Forget(t *testing.T) {
+	duplicateWrites := newDuplicateWrites(1000)
+	f := mockTimestampQueryFrame(t, "UPDATE ks1.tb1 SET l = l + [1] WHERE a = 1", 1, 100)
+	_, key := applyDuplicateWrites(t, duplicateWrites, f)
+	require.NotNil(t, key)
+
+	duplicateWrites.Forget(key)
+	requestInfo, key := applyDuplicateWrites(t, duplicateWrites, f)
+	require.Equal(t, forwardToBoth, requestInfo.GetForwardDecision())
+	require.NotNil(t, key)
+
+	requestInfo, _ = applyDuplicateWrites(t, duplicateWrites, f)
+	require.Equal(t, forwardToOrigin, requestInfo.GetForwardDecision())
+}
+
+func TestDuplicateWrites_Window(t *testing.T) {
+	duplicateWrites := newDuplicateWrites(1000)
+	now := time.Now()
+	key1 := getDuplicateWriteKey(mockTimestampQueryFrame(t, "UPDATE ks1.tb1 SET c = c + 1 WHERE a = 1", 1, 100), "")
+	key2 := getDuplicateWriteKey(mockTimestampQueryFrame(t, "UPDATE ks1.tb1 SET c = c + 1 WHERE a = 2", 1, 100), "")
+
+	require.False(t, duplicateWrites.record(key1, now))
+	require.True(t, duplicateWrites.record(key1, now.Add(999*time.Millisecond)))
+	require.False(t, duplicateWrites.record(key1, now.Add(1500*time.Millisecond)))
+	require.Len(t, duplicateWrites.writes, 1)
+
+	require.False(t, duplicateWrites.record(key2, now.Add(3*time.Second)))
+	require.Len(t, duplicateWrites.writes, 1)
+}
+
+func TestDuplicateWrites_Disabled(t *testing.T) {
+	duplicateWrites := newDuplicateWrites(0)
+	requestInfo := NewGenericRequestInfo(forwardToBoth, false, true)
+	f := mockTimestampQueryFrame(t, "UPDATE ks1.tb1 SET c = c + 1 WHERE a = 1", 1, 100)
+	actual, key := duplicateWrites.Apply(f, NewFrameDecodeContext(f), requestInfo, "", nil, newFakeProxyMetrics())
+	require.Same(t, requestInfo, actual)
+	require.Nil(t, key)
+	duplicateWrites.Forget(key)
+}
+
+func applyDuplicateWrites(
+	t *testing.T, duplicateWrites *duplicateWrites, f *frame.RawFrame) (RequestInfo, *duplicateWriteKey) {
+	context := NewFrameDecodeContext(f)
+	requestInfo, err := buildRequestInfo(
+		context, nil, NewPreparedStatementCache(0), newFakeMetricHandler(), "", common.ClusterTypeOrigin,
+		nil, nil, nil, false, true, false, nil)
+	require.Nil(t, err)
+	return duplicateWrites.Apply(f, context, requestInfo, "", nil, newFakeProxyMetrics())
+}
+
+func mockTimestampQueryFrame(t *testing.T, query string, streamId int16, timestamp int64) *frame.RawFrame {
+	f := mockFrame(t, &message.Query{Query: query, Options: &message.QueryOptions{DefaultTimestamp: &timestamp}},
+		primitive.ProtocolVersion4)
+	f.Header.StreamId = streamId
+	return f
+}
+
+func mockTimestampBatchFrame(t *testing.T, query string, timestamp int64) *frame.RawFrame {
+	return mockFrame(t, &message.Batch{Children: []*message.BatchChild{{Query: query}}, DefaultTimestamp: &timestamp},
+		primitive.ProtocolVersion4)
+}
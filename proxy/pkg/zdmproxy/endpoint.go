@@ -3,6 +3,8 @@ package zdmproxy
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
+	"strconv"
 )
 
 type Endpoint interface {
@@ -19,7 +21,7 @@ type DefaultEndpoint struct {
 
 func NewDefaultEndpoint(addr string, port int, tlsConfig *tls.Config) *DefaultEndpoint {
 	return &DefaultEndpoint{
-		socketEndpoint: fmt.Sprintf("%s:%d", addr, port),
+		socketEndpoint: net.JoinHostPort(addr, strconv.Itoa(port)),
 		tlsConfig:      tlsConfig,
 	}
 }
@@ -0,0 +1,34 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestDefaultEndpoint_Ipv6(t *testing.T) {
+	tests := []struct {
+		name           string
+		addr           string
+		socketEndpoint string
+	}{
+		{"ipv4", "127.0.0.1", "127.0.0.1:9042"},
+		{"ipv6", "::1", "[::1]:9042"},
+		{"ipv6 full", "fd00:0:0:0:0:0:0:2", "[fd00:0:0:0:0:0:0:2]:9042"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := NewDefaultEndpoint(tt.addr, 9042, nil)
+			require.Equal(t, tt.socketEndpoint, endpoint.GetSocketEndpoint())
+
+			addr, port, err := ParseEndpoint(endpoint)
+			require.Nil(t, err)
+			require.True(t, net.ParseIP(tt.addr).Equal(addr))
+			require.Equal(t, 9042, port)
+		})
+	}
+
+	_, _, err := ParseEndpoint(&DefaultEndpoint{socketEndpoint: "::1:9042"})
+	require.NotNil(t, err)
+}
@@ -32,8 +32,8 @@ func adaptConnErr(connectionAddr string, clientHandlerContext context.Context, e
 }
 
 // Simple function that writes a rawframe with a single call to writeToConnection
-func writeRawFrame(writer io.Writer, connectionAddr string, clientHandlerContext context.Context, frame *frame.RawFrame) error {
-	err := defaultCodec.EncodeRawFrame(frame, writer)
+func writeRawFrame(writer io.Writer, connectionAddr string, clientHandlerContext context.Context, frame *frame.RawFrame, framing *segmentFraming) error {
+	err := framing.WriteFrame(frame, writer)
 	return adaptConnErr(connectionAddr, clientHandlerContext, err)
 }
 
@@ -0,0 +1,54 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+)
+
+// frameDebugSampling logs the request and response frames of one in every N client connections (see
+// ZDM_LOG_FRAMES_SAMPLE_CONNECTIONS) at INFO level so that a production issue can be debugged without enabling DEBUG or
+// TRACE logging for every connection.
+type frameDebugSampling struct {
+	oneIn       uint64
+	connections uint64
+}
+
+// newFrameDebugSampling returns nil if oneIn is 0.
+func newFrameDebugSampling(oneIn int) *frameDebugSampling {
+	if oneIn <= 0 {
+		return nil
+	}
+	log.Infof("Logging the frames of one in %v client connections.", oneIn)
+	return &frameDebugSampling{oneIn: uint64(oneIn), connections: 0}
+}
+
+// NewConnection returns nil if the frames of the new client connection should not be logged.
+func (recv *frameDebugSampling) NewConnection(connectionAddr string) *connectionFrameDebugLog {
+	if recv == nil {
+		return nil
+	}
+	if (atomic.AddUint64(&recv.connections, 1)-1)%recv.oneIn != 0 {
+		return nil
+	}
+	log.Infof("Logging the frames of client connection %v.", connectionAddr)
+	return &connectionFrameDebugLog{connectionAddr: connectionAddr}
+}
+
+type connectionFrameDebugLog struct {
+	connectionAddr string
+}
+
+func (recv *connectionFrameDebugLog) LogRequest(f *frame.RawFrame) {
+	if recv == nil {
+		return
+	}
+	log.Infof("[%v] Request: %v, body length %v", recv.connectionAddr, f.Header, len(f.Body))
+}
+
+func (recv *connectionFrameDebugLog) LogResponse(f *frame.RawFrame) {
+	if recv == nil {
+		return
+	}
+	log.Infof("[%v] Response: %v, body length %v", recv.connectionAddr, f.Header, len(f.Body))
+}
@@ -0,0 +1,26 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFrameDebugSampling(t *testing.T) {
+	require.Nil(t, newFrameDebugSampling(0))
+	var nilSampling *frameDebugSampling
+	require.Nil(t, nilSampling.NewConnection("127.0.0.1:9042"))
+
+	sampling := newFrameDebugSampling(3)
+	var sampled []int
+	for i := 0; i < 7; i++ {
+		if sampling.NewConnection(fmt.Sprintf("127.0.0.1:%d", i)) != nil {
+			sampled = append(sampled, i)
+		}
+	}
+	require.Equal(t, []int{0, 3, 6}, sampled)
+
+	var nilLog *connectionFrameDebugLog
+	nilLog.LogRequest(mockQueryFrame(t, "SELECT * FROM tb1"))
+	nilLog.LogResponse(mockQueryFrame(t, "SELECT * FROM tb1"))
+}
@@ -0,0 +1,257 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"testing"
+)
+
+// The fuzz targets in this file run their seed corpus as part of the unit tests. To fuzz one of them, run e.g.:
+//
+//	go test ./proxy/pkg/zdmproxy -run '^$' -fuzz '^FuzzFrameReader$' -fuzztime 5m
+//
+// Failing inputs are written to testdata/fuzz/<target> and are replayed by the unit tests from then on.
+//
+// The message decoders of the protocol library allocate [bytes] and [long string] values and some collections with
+// the length declared in the frame body before checking that the body is that long. The client connector rejects the
+// requests with such lengths (see checkRequestBodyLengths) so FuzzBuildRequestInfo does the same, but FuzzDecodeResponse
+// quickly finds small frames that allocate gigabytes. Run it with a memory limit (e.g. ulimit -v) to get these reported
+// as crashes with a stack trace instead of the fuzzing process being killed.
+
+var fuzzSeedVersions = []primitive.ProtocolVersion{
+	primitive.ProtocolVersion3, primitive.ProtocolVersion4, primitive.ProtocolVersion5, primitive.ProtocolVersionDse2}
+
+const fuzzMaxBodyLength = 1024 * 1024
+
+var fuzzSeedPreparedId = []byte{0xd4, 0x1d, 0x8c, 0xd9, 0x8f, 0x00, 0xb2, 0x04, 0xe9, 0x80, 0x09, 0x98, 0xec, 0xf8, 0x42, 0x7e}
+
+var fuzzSeedQueries = []string{
+	"SELECT * FROM system.local WHERE key='local'",
+	"SELECT peer, rpc_address, data_center, rack, tokens FROM system.peers",
+	"SELECT * FROM system.peers_v2",
+	"SELECT keyspace_name, replication FROM system_schema.keyspaces",
+	"USE ks1",
+	"SELECT a, b FROM ks1.tb1 WHERE a = ? AND b > :b LIMIT 10",
+	"INSERT INTO ks1.tb1 (a, b, c) VALUES (?, now(), toTimestamp(now())) IF NOT EXISTS USING TTL 60",
+	"INSERT INTO tb1 JSON '{\"a\": 1}'",
+	"UPDATE ks1.counters SET c = c + 1 WHERE a = 1",
+	"UPDATE ks1.tb1 SET l = l + [1], m['k'] = 'v' WHERE a = 1 IF b = 2",
+	"DELETE m['k'] FROM ks1.tb1 WHERE a IN (1, 2)",
+	"BEGIN BATCH INSERT INTO ks1.tb1 (a) VALUES (1); DELETE FROM ks1.tb1 WHERE a = 2; APPLY BATCH",
+	"CREATE TABLE IF NOT EXISTS ks1.tb1 (a int PRIMARY KEY, b timeuuid, c timestamp)",
+}
+
+// fuzzSeedRequests returns the requests that a driver sends on a connection: the handshake, the control connection
+// queries and a mix of queries, prepared statements and batches.
+func fuzzSeedRequests() []message.Message {
+	msgs := []message.Message{
+		&message.Options{},
+		&message.Startup{Options: map[string]string{
+			"CQL_VERSION": "3.0.0", "DRIVER_NAME": "DataStax Java driver for Apache Cassandra(R)", "DRIVER_VERSION": "4.17.0"}},
+		&message.Startup{Options: map[string]string{"CQL_VERSION": "3.0.0", "COMPRESSION": "lz4"}},
+		&message.AuthResponse{Token: []byte("\x00cassandra\x00cassandra")},
+		&message.Register{EventTypes: []primitive.EventType{
+			primitive.EventTypeTopologyChange, primitive.EventTypeStatusChange, primitive.EventTypeSchemaChange}},
+		&message.Execute{
+			QueryId:          fuzzSeedPreparedId,
+			ResultMetadataId: fuzzSeedPreparedId,
+			Options: &message.QueryOptions{
+				Consistency:      primitive.ConsistencyLevelLocalQuorum,
+				PositionalValues: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewUnsetValue()},
+				PageSize:         5000,
+			},
+		},
+		&message.Batch{
+			Type: primitive.BatchTypeLogged,
+			Children: []*message.BatchChild{
+				{Query: "INSERT INTO ks1.tb1 (a, b) VALUES (?, now())", Values: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1})}},
+				{Id: fuzzSeedPreparedId, Values: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 2})}},
+			},
+			Consistency: primitive.ConsistencyLevelQuorum,
+		},
+	}
+	for _, query := range fuzzSeedQueries {
+		msgs = append(msgs,
+			&message.Query{Query: query, Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelLocalOne}},
+			&message.Prepare{Query: query})
+	}
+	return msgs
+}
+
+// fuzzSeedResponses returns the responses that a cluster sends to the requests of fuzzSeedRequests.
+func fuzzSeedResponses() []message.Message {
+	return []message.Message{
+		&message.Supported{Options: map[string][]string{"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"snappy", "lz4"}}},
+		&message.Ready{},
+		&message.Authenticate{Authenticator: "org.apache.cassandra.auth.PasswordAuthenticator"},
+		&message.AuthSuccess{},
+		&message.VoidResult{},
+		&message.SetKeyspaceResult{Keyspace: "ks1"},
+		&message.RowsResult{
+			Metadata: &message.RowsMetadata{
+				ColumnCount: 2,
+				Columns: []*message.ColumnMetadata{
+					{Keyspace: "system", Table: "local", Name: "key", Type: datatype.Varchar},
+					{Keyspace: "system", Table: "local", Name: "tokens", Type: datatype.NewSet(datatype.Varchar)},
+				},
+				PagingState: []byte{0x01, 0x02},
+			},
+			Data: message.RowSet{{[]byte("local"), []byte{0, 0, 0, 1, 0, 0, 0, 1, '0'}}, {[]byte("other"), nil}},
+		},
+		&message.PreparedResult{
+			PreparedQueryId:  fuzzSeedPreparedId,
+			ResultMetadataId: fuzzSeedPreparedId,
+			VariablesMetadata: &message.VariablesMetadata{
+				PkIndices: []uint16{0},
+				Columns:   []*message.ColumnMetadata{{Keyspace: "ks1", Table: "tb1", Name: "a", Type: datatype.Int}},
+			},
+			ResultMetadata: &message.RowsMetadata{ColumnCount: 1},
+		},
+		&message.SchemaChangeResult{
+			ChangeType: primitive.SchemaChangeTypeCreated, Target: primitive.SchemaChangeTargetTable, Keyspace: "ks1", Object: "tb1"},
+		&message.SchemaChangeEvent{
+			ChangeType: primitive.SchemaChangeTypeCreated, Target: primitive.SchemaChangeTargetKeyspace, Keyspace: "ks1"},
+		&message.TopologyChangeEvent{
+			ChangeType: primitive.TopologyChangeTypeNewNode, Address: &primitive.Inet{Addr: []byte{127, 0, 0, 2}, Port: 9042}},
+		&message.Unprepared{ErrorMessage: "Unprepared", Id: fuzzSeedPreparedId},
+		&message.ReadTimeout{
+			ErrorMessage: "Operation timed out", Consistency: primitive.ConsistencyLevelQuorum, Received: 1, BlockFor: 2},
+		&message.WriteTimeout{
+			ErrorMessage: "Operation timed out", Consistency: primitive.ConsistencyLevelQuorum, Received: 1, BlockFor: 2,
+			WriteType: primitive.WriteTypeBatchLog},
+		&message.Overloaded{ErrorMessage: "Overloaded"},
+		&message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version (5)"},
+	}
+}
+
+// fuzzSeedFrames encodes the given messages with every protocol version that supports them.
+func fuzzSeedFrames(msgs []message.Message) [][]byte {
+	var encodedFrames [][]byte
+	for _, version := range fuzzSeedVersions {
+		for i, msg := range msgs {
+			buf := &bytes.Buffer{}
+			err := defaultCodec.EncodeFrame(frame.NewFrame(version, int16(i), msg), buf)
+			if err == nil {
+				encodedFrames = append(encodedFrames, buf.Bytes())
+			}
+		}
+	}
+	return encodedFrames
+}
+
+// fuzzReadFrame reads a frame from the given data like the client and cluster connectors do, the body length of the
+// frame is limited to fuzzMaxBodyLength.
+func fuzzReadFrame(data []byte) (*frame.RawFrame, error) {
+	reader := newFrameReader(bufio.NewReader(bytes.NewReader(data)), newSegmentFraming(newFrameCompression()), fuzzMaxBodyLength)
+	return reader.ReadFrame("127.0.0.1:9042", context.Background())
+}
+
+func FuzzBuildRequestInfo(f *testing.F) {
+	for _, encodedFrame := range fuzzSeedFrames(fuzzSeedRequests()) {
+		f.Add(encodedFrame)
+	}
+
+	psCache := NewPreparedStatementCache(0)
+	psCache.Store(
+		&message.PreparedResult{PreparedQueryId: fuzzSeedPreparedId},
+		&message.PreparedResult{PreparedQueryId: fuzzSeedPreparedId},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, true, false), nil, false, fuzzSeedQueries[6], ""))
+	mh := newFakeMetricHandler()
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rawFrame, err := fuzzReadFrame(data)
+		if err != nil || checkRequestBodyLengths(rawFrame) != nil {
+			return
+		}
+		requestInfo, err := buildRequestInfo(
+			NewFrameDecodeContext(rawFrame), []*statementReplacedTerms{}, psCache, mh, "ks1", common.ClusterTypeOrigin,
+			nil, nil, nil, false, true, false, timeUuidGenerator)
+		if err != nil {
+			return
+		}
+		_, _ = isIdempotentRequest(rawFrame, requestInfo, "ks1", nil)
+	})
+}
+
+func FuzzInspectCqlQuery(f *testing.F) {
+	for _, query := range fuzzSeedQueries {
+		f.Add(query)
+	}
+
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		queryInfo := inspectCqlQuery(query, "ks1", timeUuidGenerator)
+		queryInfo.isIdempotent()
+		queryInfo.getParsedStatements()
+	})
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	for _, encodedFrame := range fuzzSeedFrames(fuzzSeedResponses()) {
+		f.Add(encodedFrame)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rawFrame, err := fuzzReadFrame(data)
+		if err != nil {
+			return
+		}
+		_, _ = defaultCodec.ConvertFromRawFrame(rawFrame)
+		_, _ = summarizeReadResult(rawFrame)
+		_, _ = decodeError(rawFrame)
+	})
+}
+
+// FuzzFrameReader checks that the framing loop either returns a frame or an error for every input: each frame that
+// is returned consumes at least one frame header so the loop can't return more frames than there are bytes.
+func FuzzFrameReader(f *testing.F) {
+	requests := fuzzSeedFrames(fuzzSeedRequests())
+	f.Add(bytes.Join(requests, nil), false)
+	for _, encodedFrame := range requests {
+		f.Add(encodedFrame, false)
+	}
+
+	segmentFraming := newSegmentFraming(newFrameCompression())
+	segmentFraming.enabled = 1
+	for _, encodedFrame := range requests {
+		rawFrame, err := defaultCodec.DecodeRawFrame(bytes.NewReader(encodedFrame))
+		if err != nil || !protocolUsesSegments(rawFrame.Header.Version) {
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err = segmentFraming.WriteFrame(rawFrame, buf); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes(), true)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, segmentsEnabled bool) {
+		framing := newSegmentFraming(newFrameCompression())
+		if segmentsEnabled {
+			framing.enabled = 1
+		}
+		reader := newFrameReader(bufio.NewReader(bytes.NewReader(data)), framing, fuzzMaxBodyLength)
+		for i := 0; ; i++ {
+			if i > len(data)/frameHeaderLengthV2AndBelow {
+				t.Fatalf("frame reader returned %v frames out of %v bytes", i, len(data))
+			}
+			if _, err := reader.ReadFrame("127.0.0.1:9042", context.Background()); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,432 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The golden files in testdata/golden contain the bytes of the frames of goldenFrameCases for every protocol version
+// of goldenVersions, one frame per line ("<name> <hex>"). The tests check that the frames are decoded as expected and
+// that the proxy forwards them byte for byte when it decodes and encodes them again without modifying them. After
+// adding or changing a case, regenerate the files with:
+//
+//	go test ./proxy/pkg/zdmproxy -run '^TestGoldenFrames$' -update-golden
+//
+// and check the diff: existing lines must not change unless the encoding of that frame is meant to change.
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden frame files in testdata/golden")
+
+var goldenVersions = []primitive.ProtocolVersion{primitive.ProtocolVersion3, primitive.ProtocolVersion4}
+
+type goldenFrameCase struct {
+	name string
+	msg  message.Message
+	// multipleEntryMap is true when the message has a map with more than one entry: its entries are encoded in a
+	// random order so the encoding is not byte for byte reproducible
+	multipleEntryMap bool
+	// minVersion is the first protocol version that supports the message, 0 means all versions
+	minVersion primitive.ProtocolVersion
+}
+
+var goldenPreparedId = []byte{0x8f, 0x4a, 0x1e, 0x2b, 0x7c, 0x90, 0x11, 0xd3, 0x5a, 0x00, 0xee, 0x42, 0x19, 0x6b, 0xc0, 0x01}
+
+var goldenFrameCases = []goldenFrameCase{
+	// requests
+	{name: "startup", msg: &message.Startup{Options: map[string]string{"CQL_VERSION": "3.0.0"}}},
+	{name: "startup_driver", msg: &message.Startup{Options: map[string]string{
+		"CQL_VERSION": "3.0.0", "DRIVER_NAME": "DataStax Java driver for Apache Cassandra(R)", "DRIVER_VERSION": "4.17.0"}},
+		multipleEntryMap: true},
+	{name: "options", msg: &message.Options{}},
+	{name: "auth_response", msg: &message.AuthResponse{Token: []byte("\x00cassandra\x00cassandra")}},
+	{name: "register", msg: &message.Register{EventTypes: []primitive.EventType{
+		primitive.EventTypeTopologyChange, primitive.EventTypeStatusChange, primitive.EventTypeSchemaChange}}},
+	{name: "query_control", msg: &message.Query{
+		Query:   "SELECT peer, rpc_address, data_center, rack, tokens FROM system.peers",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}}},
+	{name: "query_use", msg: &message.Query{
+		Query:   "USE ks1",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}}},
+	{name: "query_positional_values", msg: &message.Query{
+		Query: "SELECT a, b FROM ks1.tb1 WHERE a = ? AND b > ? LIMIT 10",
+		Options: &message.QueryOptions{
+			Consistency:       primitive.ConsistencyLevelLocalQuorum,
+			PositionalValues:  []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewNullValue()},
+			SkipMetadata:      true,
+			PageSize:          5000,
+			PagingState:       []byte{0x04, 0x00, 0x00, 0x00, 0x01},
+			SerialConsistency: consistencyLevelPtr(primitive.ConsistencyLevelLocalSerial),
+			DefaultTimestamp:  int64Ptr(1718000000000000),
+		}}},
+	{name: "query_named_values", msg: &message.Query{
+		Query: "INSERT INTO ks1.tb1 (a, b) VALUES (:a, :b) IF NOT EXISTS",
+		Options: &message.QueryOptions{
+			Consistency:       primitive.ConsistencyLevelQuorum,
+			NamedValues:       map[string]*primitive.Value{"a": primitive.NewValue([]byte{0, 0, 0, 1})},
+			SerialConsistency: consistencyLevelPtr(primitive.ConsistencyLevelSerial),
+		}}},
+	{name: "prepare", msg: &message.Prepare{Query: "INSERT INTO ks1.tb1 (a, b, c) VALUES (?, ?, ?) USING TTL 60"}},
+	{name: "execute", msg: &message.Execute{
+		QueryId: goldenPreparedId,
+		Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelLocalQuorum,
+			PositionalValues: []*primitive.Value{
+				primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("value")), primitive.NewNullValue()},
+			DefaultTimestamp: int64Ptr(1718000000000000),
+		}}},
+	{name: "batch", msg: &message.Batch{
+		Type: primitive.BatchTypeLogged,
+		Children: []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb1 (a, b) VALUES (?, ?)",
+				Values: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("b"))}},
+			{Query: "DELETE FROM ks1.tb1 WHERE a = 2", Values: []*primitive.Value{}},
+			{Id: goldenPreparedId, Values: []*primitive.Value{
+				primitive.NewValue([]byte{0, 0, 0, 3}), primitive.NewValue([]byte("value")), primitive.NewNullValue()}},
+		},
+		Consistency:       primitive.ConsistencyLevelLocalQuorum,
+		SerialConsistency: consistencyLevelPtr(primitive.ConsistencyLevelLocalSerial),
+		DefaultTimestamp:  int64Ptr(1718000000000000),
+	}},
+	// responses
+	{name: "ready", msg: &message.Ready{}},
+	{name: "authenticate", msg: &message.Authenticate{Authenticator: "org.apache.cassandra.auth.PasswordAuthenticator"}},
+	{name: "auth_challenge", msg: &message.AuthChallenge{Token: []byte{0x01, 0x02, 0x03}}},
+	{name: "auth_success", msg: &message.AuthSuccess{Token: []byte{0x04}}},
+	{name: "supported", msg: &message.Supported{Options: map[string][]string{
+		"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"snappy", "lz4"}}}, multipleEntryMap: true},
+	{name: "result_void", msg: &message.VoidResult{}},
+	{name: "result_set_keyspace", msg: &message.SetKeyspaceResult{Keyspace: "ks1"}},
+	{name: "result_rows", msg: &message.RowsResult{
+		Metadata: &message.RowsMetadata{
+			ColumnCount: 3,
+			Columns: []*message.ColumnMetadata{
+				{Keyspace: "system", Table: "peers", Name: "peer", Type: datatype.Inet},
+				{Keyspace: "system", Table: "peers", Name: "data_center", Type: datatype.Varchar},
+				{Keyspace: "system", Table: "peers", Name: "tokens", Type: datatype.NewSet(datatype.Varchar)},
+			},
+			PagingState: []byte{0x04, 0x00, 0x00, 0x00, 0x02},
+		},
+		Data: message.RowSet{
+			{[]byte{127, 0, 0, 2}, []byte("dc1"), []byte{0, 0, 0, 1, 0, 0, 0, 2, '4', '2'}},
+			{[]byte{127, 0, 0, 3}, nil, nil},
+		}}},
+	{name: "result_rows_no_metadata", msg: &message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 2},
+		Data:     message.RowSet{{[]byte{0, 0, 0, 1}, []byte("b")}}}},
+	{name: "result_prepared", msg: &message.PreparedResult{
+		PreparedQueryId: goldenPreparedId,
+		VariablesMetadata: &message.VariablesMetadata{
+			Columns: []*message.ColumnMetadata{
+				{Keyspace: "ks1", Table: "tb1", Name: "a", Type: datatype.Int},
+				{Keyspace: "ks1", Table: "tb1", Name: "b", Type: datatype.Varchar},
+				{Keyspace: "ks1", Table: "tb1", Name: "c", Type: datatype.NewMap(datatype.Varchar, datatype.Timeuuid)},
+			},
+		},
+		ResultMetadata: &message.RowsMetadata{ColumnCount: 0}}},
+	{name: "result_prepared_pk_indices", msg: &message.PreparedResult{
+		PreparedQueryId: goldenPreparedId,
+		VariablesMetadata: &message.VariablesMetadata{
+			PkIndices: []uint16{0},
+			Columns:   []*message.ColumnMetadata{{Keyspace: "ks1", Table: "tb1", Name: "a", Type: datatype.Int}},
+		},
+		ResultMetadata: &message.RowsMetadata{ColumnCount: 0}},
+		minVersion: primitive.ProtocolVersion4},
+	{name: "result_schema_change", msg: &message.SchemaChangeResult{
+		ChangeType: primitive.SchemaChangeTypeCreated, Target: primitive.SchemaChangeTargetTable,
+		Keyspace: "ks1", Object: "tb1"}},
+	{name: "event_topology_change", msg: &message.TopologyChangeEvent{
+		ChangeType: primitive.TopologyChangeTypeNewNode, Address: &primitive.Inet{Addr: net.IPv4(127, 0, 0, 2), Port: 9042}}},
+	{name: "event_status_change", msg: &message.StatusChangeEvent{
+		ChangeType: primitive.StatusChangeTypeDown, Address: &primitive.Inet{Addr: net.IPv4(127, 0, 0, 3), Port: 9042}}},
+	{name: "event_schema_change", msg: &message.SchemaChangeEvent{
+		ChangeType: primitive.SchemaChangeTypeDropped, Target: primitive.SchemaChangeTargetKeyspace, Keyspace: "ks2"}},
+	{name: "error_server", msg: &message.ServerError{ErrorMessage: "Internal server error"}},
+	{name: "error_protocol", msg: &message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version (5)"}},
+	{name: "error_authentication", msg: &message.AuthenticationError{ErrorMessage: "Provided username and/or password are incorrect"}},
+	{name: "error_unavailable", msg: &message.Unavailable{
+		ErrorMessage: "Cannot achieve consistency level QUORUM", Consistency: primitive.ConsistencyLevelQuorum,
+		Required: 2, Alive: 1}},
+	{name: "error_overloaded", msg: &message.Overloaded{ErrorMessage: "Overloaded"}},
+	{name: "error_bootstrapping", msg: &message.IsBootstrapping{ErrorMessage: "Bootstrapping"}},
+	{name: "error_truncate", msg: &message.TruncateError{ErrorMessage: "Truncate failed"}},
+	{name: "error_write_timeout", msg: &message.WriteTimeout{
+		ErrorMessage: "Operation timed out", Consistency: primitive.ConsistencyLevelLocalQuorum, Received: 1, BlockFor: 2,
+		WriteType: primitive.WriteTypeBatchLog}},
+	{name: "error_read_timeout", msg: &message.ReadTimeout{
+		ErrorMessage: "Operation timed out", Consistency: primitive.ConsistencyLevelLocalQuorum, Received: 1, BlockFor: 2,
+		DataPresent: true}},
+	{name: "error_read_failure", msg: &message.ReadFailure{
+		ErrorMessage: "Operation failed", Consistency: primitive.ConsistencyLevelQuorum, Received: 1, BlockFor: 2,
+		NumFailures: 1}},
+	{name: "error_write_failure", msg: &message.WriteFailure{
+		ErrorMessage: "Operation failed", Consistency: primitive.ConsistencyLevelQuorum, Received: 1, BlockFor: 2,
+		NumFailures: 1, WriteType: primitive.WriteTypeSimple}},
+	{name: "error_function_failure", msg: &message.FunctionFailure{
+		ErrorMessage: "Function failed", Keyspace: "ks1", Function: "fn1", Arguments: []string{"int", "text"}}},
+	{name: "error_syntax", msg: &message.SyntaxError{ErrorMessage: "line 1:0 no viable alternative at input 'SELEC'"}},
+	{name: "error_unauthorized", msg: &message.Unauthorized{ErrorMessage: "User has no SELECT permission"}},
+	{name: "error_invalid", msg: &message.Invalid{ErrorMessage: "Undefined column name x"}},
+	{name: "error_config", msg: &message.ConfigError{ErrorMessage: "Cannot add a column"}},
+	{name: "error_already_exists", msg: &message.AlreadyExists{
+		ErrorMessage: "Cannot add existing table", Keyspace: "ks1", Table: "tb1"}},
+	{name: "error_unprepared", msg: &message.Unprepared{ErrorMessage: "Prepared query not found", Id: goldenPreparedId}},
+}
+
+// goldenFrame is a frame read from a golden file.
+type goldenFrame struct {
+	goldenFrameCase
+	version primitive.ProtocolVersion
+	encoded []byte
+}
+
+func (recv *goldenFrame) String() string {
+	return fmt.Sprintf("%v (%v)", recv.name, recv.version)
+}
+
+// goldenStreamId is the stream id of the golden frames, events use stream id -1.
+func goldenStreamId(msg message.Message) int16 {
+	if msg.GetOpCode() == primitive.OpCodeEvent {
+		return -1
+	}
+	return 42
+}
+
+func goldenFilePath(version primitive.ProtocolVersion) string {
+	return filepath.Join("testdata", "golden", fmt.Sprintf("frames_v%d.txt", version))
+}
+
+// loadGoldenFrames reads the golden files or writes them when -update-golden is set.
+func loadGoldenFrames(t *testing.T) []*goldenFrame {
+	var goldenFrames []*goldenFrame
+	for _, version := range goldenVersions {
+		path := goldenFilePath(version)
+		if *updateGolden {
+			writeGoldenFile(t, path, version)
+		}
+
+		content, err := os.ReadFile(path)
+		require.Nil(t, err, "run the test with -update-golden to create the golden files")
+		encodedFrames := make(map[string][]byte)
+		for _, line := range strings.Split(string(content), "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(line)
+			require.Len(t, fields, 2, "invalid line in %v: %v", path, line)
+			encodedFrames[fields[0]], err = hex.DecodeString(fields[1])
+			require.Nil(t, err, "invalid line in %v: %v", path, line)
+		}
+
+		for _, c := range goldenFrameCases {
+			if c.minVersion > version {
+				continue
+			}
+			encoded, ok := encodedFrames[c.name]
+			require.True(t, ok, "frame %v is missing in %v, run the test with -update-golden", c.name, path)
+			goldenFrames = append(goldenFrames, &goldenFrame{goldenFrameCase: c, version: version, encoded: encoded})
+			delete(encodedFrames, c.name)
+		}
+		require.Empty(t, encodedFrames, "%v has frames without test case", path)
+	}
+	return goldenFrames
+}
+
+// writeGoldenFile writes the frames of the test cases to a golden file, the existing frames that are still decoded as
+// expected are kept so that the frames with multiple entry maps don't change every time the file is written.
+func writeGoldenFile(t *testing.T, path string, version primitive.ProtocolVersion) {
+	existingFrames := make(map[string][]byte)
+	if content, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && !strings.HasPrefix(line, "#") {
+				existingFrames[fields[0]], _ = hex.DecodeString(fields[1])
+			}
+		}
+	}
+
+	lines := []string{fmt.Sprintf("# CQL frames encoded with protocol %v, see golden_test.go", version)}
+	names := make([]string, 0, len(goldenFrameCases))
+	encodedFrames := make(map[string][]byte)
+	for _, c := range goldenFrameCases {
+		if c.minVersion > version {
+			continue
+		}
+		if existing, ok := existingFrames[c.name]; ok {
+			decodedFrame, err := defaultCodec.DecodeFrame(bytes.NewReader(existing))
+			if err == nil && decodedFrame.Header.Version == version && reflect.DeepEqual(c.msg, decodedFrame.Body.Message) {
+				names = append(names, c.name)
+				encodedFrames[c.name] = existing
+				continue
+			}
+		}
+		buf := &bytes.Buffer{}
+		err := defaultCodec.EncodeFrame(frame.NewFrame(version, goldenStreamId(c.msg), c.msg), buf)
+		require.Nil(t, err, "could not encode %v with %v", c.name, version)
+		names = append(names, c.name)
+		encodedFrames[c.name] = buf.Bytes()
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s %x", name, encodedFrames[name]))
+	}
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.Nil(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+}
+
+// readGoldenFrame reads the golden frame like the client and cluster connectors read frames from their connection.
+func readGoldenFrame(t *testing.T, goldenFrame *goldenFrame) *frame.RawFrame {
+	reader := newFrameReader(
+		bufio.NewReader(bytes.NewReader(goldenFrame.encoded)), newSegmentFraming(newFrameCompression()), 256*1024*1024)
+	rawFrame, err := reader.ReadFrame("127.0.0.1:9042", context.Background())
+	require.Nil(t, err, goldenFrame.String())
+	return rawFrame
+}
+
+func encodeRawFrame(t *testing.T, rawFrame *frame.RawFrame) []byte {
+	buf := &bytes.Buffer{}
+	require.Nil(t, defaultCodec.EncodeRawFrame(rawFrame, buf))
+	return buf.Bytes()
+}
+
+// requireRoundTrip checks that the frame is encoded as the golden frame, the frames of messages with multiple entry
+// maps are decoded and compared instead.
+func requireRoundTrip(t *testing.T, goldenFrame *goldenFrame, rawFrame *frame.RawFrame, description string) {
+	encoded := encodeRawFrame(t, rawFrame)
+	if !goldenFrame.multipleEntryMap {
+		require.Equal(t, goldenFrame.encoded, encoded, "%v: %v", goldenFrame, description)
+		return
+	}
+	require.Equal(t, len(goldenFrame.encoded), len(encoded), "%v: %v", goldenFrame, description)
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(rawFrame)
+	require.Nil(t, err)
+	require.Equal(t, goldenFrame.msg, decodedFrame.Body.Message, "%v: %v", goldenFrame, description)
+}
+
+func TestGoldenFrames(t *testing.T) {
+	for _, goldenFrame := range loadGoldenFrames(t) {
+		t.Run(goldenFrame.String(), func(t *testing.T) {
+			rawFrame := readGoldenFrame(t, goldenFrame)
+			require.Equal(t, goldenFrame.version, rawFrame.Header.Version)
+			require.Equal(t, goldenFrame.msg.IsResponse(), rawFrame.Header.IsResponse)
+			require.Equal(t, goldenFrame.msg.GetOpCode(), rawFrame.Header.OpCode)
+			require.Equal(t, goldenStreamId(goldenFrame.msg), rawFrame.Header.StreamId)
+			requireRoundTrip(t, goldenFrame, rawFrame, "raw frame")
+
+			decodedFrame, err := defaultCodec.ConvertFromRawFrame(rawFrame)
+			require.Nil(t, err)
+			require.Equal(t, goldenFrame.msg, decodedFrame.Body.Message)
+
+			reencodedFrame, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+			require.Nil(t, err)
+			requireRoundTrip(t, goldenFrame, reencodedFrame, "decoded and encoded")
+
+			copiedFrame, err := defaultCodec.ConvertToRawFrame(decodedFrame.DeepCopy())
+			require.Nil(t, err)
+			requireRoundTrip(t, goldenFrame, copiedFrame, "decoded, copied and encoded")
+		})
+	}
+}
+
+// TestGoldenFrames_ProxyRewrites checks that the request and response rewrites of the proxy leave the golden frames
+// unchanged when there is nothing to rewrite, and that they only change the expected bytes otherwise.
+func TestGoldenFrames_ProxyRewrites(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	queryModifier := NewQueryModifier(timeUuidGenerator)
+	// none of the golden frames use these consistency levels
+	unusedMapping := newConsistencyLevelMapping(map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{
+		primitive.ConsistencyLevelEachQuorum: primitive.ConsistencyLevelAll,
+		primitive.ConsistencyLevelAny:        primitive.ConsistencyLevelOne,
+	})
+	usedMapping := newConsistencyLevelMapping(map[primitive.ConsistencyLevel]primitive.ConsistencyLevel{
+		primitive.ConsistencyLevelLocalQuorum: primitive.ConsistencyLevelQuorum,
+	})
+	// the tables of the golden frames are qualified so only the keyspace of USE requests could be affected
+	qualifier := newTableNameQualifier(true)
+
+	for _, goldenFrame := range loadGoldenFrames(t) {
+		t.Run(goldenFrame.String(), func(t *testing.T) {
+			rawFrame := readGoldenFrame(t, goldenFrame)
+
+			// stream ids are replaced on the way to the clusters and restored on the way back
+			newStreamIdFrame := setRawFrameStreamId(rawFrame, 1000)
+			require.Equal(t, int16(1000), newStreamIdFrame.Header.StreamId)
+			require.Equal(t, rawFrame.Body, newStreamIdFrame.Body)
+			requireRoundTrip(t, goldenFrame, setRawFrameStreamId(newStreamIdFrame, rawFrame.Header.StreamId), "stream id")
+
+			if goldenFrame.msg.IsResponse() {
+				return
+			}
+
+			if rawFrame.Header.OpCode == primitive.OpCodeQuery || rawFrame.Header.OpCode == primitive.OpCodePrepare ||
+				rawFrame.Header.OpCode == primitive.OpCodeBatch {
+				context, replacedTerms, err := queryModifier.replaceQueryString("ks1", NewFrameDecodeContext(rawFrame))
+				require.Nil(t, err)
+				require.Empty(t, replacedTerms)
+				requireRoundTrip(t, goldenFrame, context.GetRawFrame(), "query modifier")
+			}
+
+			mappedFrame, err := unusedMapping.Apply(rawFrame)
+			require.Nil(t, err)
+			requireRoundTrip(t, goldenFrame, mappedFrame, "unused consistency level mapping")
+
+			qualifiedFrame, err := qualifier.Apply(rawFrame, "ks1")
+			require.Nil(t, err)
+			requireRoundTrip(t, goldenFrame, qualifiedFrame, "table name qualifier")
+
+			if rawFrame.Header.OpCode == primitive.OpCodeExecute {
+				// the prepared id of the EXECUTE requests sent to target is replaced with the target prepared id
+				decodedFrame, err := defaultCodec.ConvertFromRawFrame(rawFrame)
+				require.Nil(t, err)
+				targetFrame := decodedFrame.DeepCopy()
+				targetFrame.Body.Message.(*message.Execute).QueryId = append([]byte{}, goldenPreparedId...)
+				targetRawFrame, err := defaultCodec.ConvertToRawFrame(targetFrame)
+				require.Nil(t, err)
+				requireRoundTrip(t, goldenFrame, targetRawFrame, "target prepared id")
+			}
+
+			// the consistency level is a [short] in v3 and v4, replacing it must only change these 2 bytes
+			mappedFrame, err = usedMapping.Apply(rawFrame)
+			require.Nil(t, err)
+			mappedEncoded := encodeRawFrame(t, mappedFrame)
+			require.Equal(t, len(goldenFrame.encoded), len(mappedEncoded))
+			var changedBytes []int
+			for i := range mappedEncoded {
+				if mappedEncoded[i] != goldenFrame.encoded[i] {
+					changedBytes = append(changedBytes, i)
+				}
+			}
+			if mappedFrame == rawFrame {
+				require.Empty(t, changedBytes)
+				return
+			}
+			require.Len(t, changedBytes, 1, "%v", changedBytes) // 0x0006 (LOCAL_QUORUM) to 0x0004 (QUORUM)
+			require.Equal(t, byte(primitive.ConsistencyLevelLocalQuorum), goldenFrame.encoded[changedBytes[0]])
+			require.Equal(t, byte(primitive.ConsistencyLevelQuorum), mappedEncoded[changedBytes[0]])
+			require.Equal(t, byte(0), mappedEncoded[changedBytes[0]-1])
+		})
+	}
+}
+
+func consistencyLevelPtr(consistency primitive.ConsistencyLevel) *primitive.ConsistencyLevel {
+	return &consistency
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
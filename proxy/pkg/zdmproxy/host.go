@@ -8,7 +8,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"net"
 	"strconv"
-	"strings"
 )
 
 type Host struct {
@@ -226,12 +225,11 @@ func ParseRpcAddress(isPeersV2 bool, row *ParsedRow, defaultPort int) (net.IP, i
 
 func ParseEndpoint(endpoint Endpoint) (net.IP, int, error) {
 	socketEndpoint := endpoint.GetSocketEndpoint()
-	parts := strings.Split(socketEndpoint, ":")
-	if len(parts) != 2 {
+	addr, portStr, err := net.SplitHostPort(socketEndpoint)
+	if err != nil {
 		return nil, -1, fmt.Errorf("invalid endpoint: %s", socketEndpoint)
 	}
-	addr := parts[0]
-	port, err := strconv.Atoi(parts[1])
+	port, err := strconv.Atoi(portStr)
 	if err != nil {
 		return nil, -1, fmt.Errorf("invalid endpoint: %s", socketEndpoint)
 	}
@@ -0,0 +1,267 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	parser "github.com/datastax/zdm-proxy/antlr"
+)
+
+// Non-deterministic CQL functions, a statement that calls them is not idempotent (except for the now() function calls
+// that the proxy replaces, see cqlListener.nowFunctionCalls).
+var nonDeterministicFunctions = map[string]bool{
+	nowFunctionName:    true,
+	"uuid":             true,
+	"currenttimeuuid":  true,
+	"currenttimestamp": true,
+	"currentdate":      true,
+	"currenttime":      true,
+}
+
+// isIdempotent returns false for statements that can have a different result if they are applied more than once:
+// lightweight transactions, counter updates, list appends and prepends, list element deletions, calls of
+// non-deterministic functions and statements that the proxy can not parse. The schema is not known so updates that
+// can be either counter or collection updates (e.g. "SET c = c + ?") are not idempotent.
+func (l *cqlListener) isIdempotent() bool {
+	switch l.statementType {
+	case statementTypeSelect, statementTypeUse:
+		return true
+	case statementTypeInsert, statementTypeUpdate, statementTypeDelete, statementTypeBatch:
+		return !l.nonIdempotent && !l.nowFunctionCalls
+	default:
+		return false
+	}
+}
+
+func (l *cqlListener) ExitInsertStatement(ctx *parser.InsertStatementContext) {
+	if ctx.K_IF() != nil {
+		l.nonIdempotent = true
+	}
+}
+
+func (l *cqlListener) ExitUpdateStatement(ctx *parser.UpdateStatementContext) {
+	if ctx.K_IF() != nil {
+		l.nonIdempotent = true
+	}
+}
+
+func (l *cqlListener) ExitDeleteStatement(ctx *parser.DeleteStatementContext) {
+	if ctx.K_IF() != nil {
+		l.nonIdempotent = true
+	}
+}
+
+func (l *cqlListener) ExitBatchStatement(ctx *parser.BatchStatementContext) {
+	if ctx.K_COUNTER() != nil {
+		l.nonIdempotent = true
+	}
+}
+
+// EnterUpdateOperation marks the statement as non-idempotent for additions that are not known to be set or map
+// additions (counter increments, list appends and prepends) and for removals that are not known to be collection
+// removals (counter decrements).
+func (l *cqlListener) EnterUpdateOperation(ctx *parser.UpdateOperationContext) {
+	operator := ""
+	for _, child := range ctx.GetChildren() {
+		if terminal, ok := child.(antlr.TerminalNode); ok {
+			switch text := terminal.GetText(); text {
+			case "+", "-", "+=", "-=":
+				operator = text[:1]
+			}
+		}
+	}
+	if operator == "" {
+		return // assignments, including list elements, map entries and UDT fields
+	}
+	terms := ctx.AllTerm()
+	if len(terms) != 1 {
+		l.nonIdempotent = true
+		return
+	}
+	switch getCollectionLiteralType(terms[0]) {
+	case setOrMapLiteral:
+	case listLiteral:
+		if operator == "+" {
+			l.nonIdempotent = true
+		}
+	default:
+		l.nonIdempotent = true
+	}
+}
+
+// EnterDeleteOperation marks the statement as non-idempotent for deletions of list elements (by index). Deletions of
+// elements with a string key are map entry deletions.
+func (l *cqlListener) EnterDeleteOperation(ctx *parser.DeleteOperationContext) {
+	termCtx := ctx.Term()
+	if termCtx == nil {
+		return
+	}
+	literal, ok := unwrapTypeCast(termCtx).GetChild(0).(*parser.LiteralContext)
+	if !ok || literal.PrimitiveLiteral() == nil || literal.PrimitiveLiteral().(*parser.PrimitiveLiteralContext).STRING_LITERAL() == nil {
+		l.nonIdempotent = true
+	}
+}
+
+func (l *cqlListener) EnterFunctionCall(ctx *parser.FunctionCallContext) {
+	fCall := extractFunctionCall(ctx)
+	if fCall.keyspace != "" && fCall.keyspace != systemKeyspaceName || !nonDeterministicFunctions[fCall.name] {
+		return
+	}
+	if fCall.isNow() && l.isExtractedFunctionCall(fCall) {
+		return // see nowFunctionCalls
+	}
+	l.nonIdempotent = true
+}
+
+// isExtractedFunctionCall returns true if the provided function call is a term of a parsed statement, i.e. if it
+// is replaced by the query modifier. Listener methods of statements are called before the ones of their terms.
+func (l *cqlListener) isExtractedFunctionCall(fCall *functionCall) bool {
+	for _, parsedStmt := range l.parsedStatements {
+		for _, t := range parsedStmt.terms {
+			if t != nil && t.functionCall != nil && t.functionCall.startIndex == fCall.startIndex {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type collectionLiteralType int
+
+const (
+	notCollectionLiteral = collectionLiteralType(iota)
+	listLiteral
+	setOrMapLiteral
+)
+
+func getCollectionLiteralType(termCtx parser.ITermContext) collectionLiteralType {
+	literal, ok := unwrapTypeCast(termCtx).GetChild(0).(*parser.LiteralContext)
+	if !ok || literal.CollectionLiteral() == nil {
+		return notCollectionLiteral
+	}
+	switch literal.CollectionLiteral().GetChild(0).(type) {
+	case *parser.ListLiteralContext:
+		return listLiteral
+	default:
+		return setOrMapLiteral
+	}
+}
+
+func unwrapTypeCast(termCtx parser.ITermContext) parser.ITermContext {
+	for {
+		typeCast, ok := termCtx.GetChild(0).(*parser.TypeCastContext)
+		if !ok {
+			return termCtx
+		}
+		termCtx = typeCast.Term()
+	}
+}
+
+// idempotencyOverrides overrides the idempotency of the statements on some keyspaces or tables, see
+// ZDM_IDEMPOTENCY_OVERRIDES. A table entry takes precedence over the entry of its keyspace.
+type idempotencyOverrides struct {
+	overrides map[string]bool
+}
+
+// newIdempotencyOverrides returns nil if there are no overrides.
+func newIdempotencyOverrides(overrides map[string]bool) *idempotencyOverrides {
+	if len(overrides) == 0 {
+		return nil
+	}
+	return &idempotencyOverrides{overrides: overrides}
+}
+
+// Get returns the idempotency of the statements on the provided table if it is overridden. A nil object doesn't
+// override anything.
+func (recv *idempotencyOverrides) Get(keyspace string, table string) (bool, bool) {
+	if recv == nil || keyspace == "" {
+		return false, false
+	}
+	if table != "" {
+		if idempotent, ok := recv.overrides[keyspace+"."+table]; ok {
+			return idempotent, true
+		}
+	}
+	idempotent, ok := recv.overrides[keyspace]
+	return idempotent, ok
+}
+
+func (recv *idempotencyOverrides) isIdempotentStatement(queryInfo QueryInfo) bool {
+	if idempotent, ok := recv.Get(queryInfo.getApplicableKeyspace(), queryInfo.getTableName()); ok {
+		return idempotent
+	}
+	return queryInfo.isIdempotent()
+}
+
+// isIdempotentRequest returns true if the provided QUERY, EXECUTE or BATCH request can be sent again without
+// changing its result, i.e. if every statement of the request is idempotent (see QueryInfo.isIdempotent) or overridden
+// as idempotent. Overrides only apply to the statements of BATCH requests, not to CQL BATCH statements.
+func isIdempotentRequest(
+	f *frame.RawFrame, requestInfo RequestInfo, currentKeyspace string, overrides *idempotencyOverrides) (bool, error) {
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return false, fmt.Errorf("could not decode %v request: %w", f.Header.OpCode, err)
+	}
+
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		keyspace := currentKeyspace
+		if msg.Options != nil && msg.Options.Keyspace != "" {
+			keyspace = msg.Options.Keyspace
+		}
+		return overrides.isIdempotentStatement(inspectCqlQuery(msg.Query, keyspace, nil)), nil
+	case *message.Execute:
+		executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo)
+		if !ok {
+			return false, fmt.Errorf("unexpected request info for EXECUTE request: %v", requestInfo)
+		}
+		return isIdempotentPreparedStatement(executeRequestInfo.GetPreparedData(), currentKeyspace, overrides), nil
+	case *message.Batch:
+		if msg.Type == primitive.BatchTypeCounter {
+			return false, nil
+		}
+		keyspace := currentKeyspace
+		if msg.Keyspace != "" {
+			keyspace = msg.Keyspace
+		}
+		var preparedDataByStmtIdx map[int]PreparedData
+		if batchRequestInfo, ok := requestInfo.(*BatchRequestInfo); ok {
+			preparedDataByStmtIdx = batchRequestInfo.GetPreparedDataByStmtIdx()
+		}
+		for idx, child := range msg.Children {
+			var idempotent bool
+			if preparedData, ok := preparedDataByStmtIdx[idx]; ok {
+				idempotent = isIdempotentPreparedStatement(preparedData, keyspace, overrides)
+			} else if len(child.Query) > 0 {
+				idempotent = overrides.isIdempotentStatement(inspectCqlQuery(child.Query, keyspace, nil))
+			}
+			if !idempotent {
+				return false, nil
+			}
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// isIdempotentPreparedStatement inspects the query of the PREPARE request that was sent to the clusters. The now()
+// function calls that the proxy replaced (see PrepareRequestInfo.GetReplacedTerms) are bind markers in this query and
+// the proxy binds the same generated value on both clusters, so they don't make EXECUTE requests non-idempotent.
+func isIdempotentPreparedStatement(
+	preparedData PreparedData, currentKeyspace string, overrides *idempotencyOverrides) bool {
+	return overrides.isIdempotentStatement(inspectPreparedStatement(preparedData, currentKeyspace))
+}
+
+// inspectPreparedStatement inspects the query of the PREPARE request of a prepared statement, with the keyspace of the
+// PREPARE request if it has one.
+func inspectPreparedStatement(preparedData PreparedData, currentKeyspace string) QueryInfo {
+	prepareRequestInfo := preparedData.GetPrepareRequestInfo()
+	keyspace := currentKeyspace
+	if prepareRequestInfo.GetKeyspace() != "" {
+		keyspace = prepareRequestInfo.GetKeyspace()
+	}
+	return inspectCqlQuery(prepareRequestInfo.GetQuery(), keyspace, nil)
+}
@@ -0,0 +1,142 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		idempotent bool
+	}{
+		{"select", "SELECT * FROM ks1.tb1 WHERE a = 1", true},
+		{"use", "USE ks1", true},
+		{"insert", "INSERT INTO ks1.tb1 (a, b) VALUES (1, 'b')", true},
+		{"insert json", "INSERT INTO ks1.tb1 JSON '{\"a\": 1}'", true},
+		{"insert if not exists", "INSERT INTO ks1.tb1 (a, b) VALUES (1, 'b') IF NOT EXISTS", false},
+		{"insert json if not exists", "INSERT INTO ks1.tb1 JSON '{\"a\": 1}' IF NOT EXISTS", false},
+		{"update", "UPDATE ks1.tb1 SET b = 'b' WHERE a = 1", true},
+		{"update if", "UPDATE ks1.tb1 SET b = 'b' WHERE a = 1 IF b = 'a'", false},
+		{"update if exists", "UPDATE ks1.tb1 SET b = 'b' WHERE a = 1 IF EXISTS", false},
+		{"counter increment", "UPDATE ks1.tb1 SET c = c + 1 WHERE a = 1", false},
+		{"counter decrement", "UPDATE ks1.tb1 SET c -= 1 WHERE a = 1", false},
+		{"bind marker addition", "UPDATE ks1.tb1 SET c = c + ? WHERE a = ?", false},
+		{"list append", "UPDATE ks1.tb1 SET l = l + [1] WHERE a = 1", false},
+		{"list prepend", "UPDATE ks1.tb1 SET l = [1] + l WHERE a = 1", false},
+		{"list removal", "UPDATE ks1.tb1 SET l = l - [1] WHERE a = 1", true},
+		{"list element", "UPDATE ks1.tb1 SET l[0] = 1 WHERE a = 1", true},
+		{"set addition", "UPDATE ks1.tb1 SET s = s + {1} WHERE a = 1", true},
+		{"set addition with type cast", "UPDATE ks1.tb1 SET s += (set<int>){1} WHERE a = 1", true},
+		{"map addition", "UPDATE ks1.tb1 SET m = m + {'k': 1} WHERE a = 1", true},
+		{"map entry", "UPDATE ks1.tb1 SET m['k'] = 1 WHERE a = 1", true},
+		{"udt field", "UPDATE ks1.tb1 SET u.f = 1 WHERE a = 1", true},
+		{"delete", "DELETE FROM ks1.tb1 WHERE a = 1", true},
+		{"delete if exists", "DELETE FROM ks1.tb1 WHERE a = 1 IF EXISTS", false},
+		{"delete map entry", "DELETE m['k'] FROM ks1.tb1 WHERE a = 1", true},
+		{"delete list element", "DELETE l[0] FROM ks1.tb1 WHERE a = 1", false},
+		{"uuid", "INSERT INTO ks1.tb1 (a, b) VALUES (uuid(), 'b')", false},
+		{"now", "INSERT INTO ks1.tb1 (a, b) VALUES (now(), 'b')", false},
+		{"now argument", "INSERT INTO ks1.tb1 (a, b) VALUES (1, toTimestamp(now()))", false},
+		{"deterministic function", "INSERT INTO ks1.tb1 (a, b) VALUES (1, toTimestamp(?))", true},
+		{"batch", "BEGIN BATCH INSERT INTO ks1.tb1 (a) VALUES (1); UPDATE ks1.tb1 SET b = 'b' WHERE a = 1; APPLY BATCH", true},
+		{"batch with list append",
+			"BEGIN BATCH INSERT INTO ks1.tb1 (a) VALUES (1); UPDATE ks1.tb1 SET l = l + [1] WHERE a = 1; APPLY BATCH", false},
+		{"counter batch", "BEGIN COUNTER BATCH UPDATE ks1.tb1 SET c = c + 1 WHERE a = 1; APPLY BATCH", false},
+		{"ddl", "CREATE TABLE ks1.tb1 (a int PRIMARY KEY)", false},
+		{"unparseable", "INSERT INTO", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.idempotent, inspectCqlQuery(tt.query, "", nil).isIdempotent())
+		})
+	}
+}
+
+func TestIsIdempotentReplacedNowFunctionCalls(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	queryInfo := inspectCqlQuery("INSERT INTO ks1.tb1 (a, b) VALUES (now(), 'b')", "", timeUuidGenerator)
+	require.False(t, queryInfo.isIdempotent())
+	replacedQueryInfo, _ := queryInfo.replaceNowFunctionCallsWithLiteral()
+	require.True(t, replacedQueryInfo.isIdempotent())
+}
+
+func TestIsIdempotentRequest_ReplacedNowFunctionCalls(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	prepareFrame := mockFrame(t, &message.Prepare{Query: "INSERT INTO ks1.tb1 (a, b) VALUES (now(), ?)"}, primitive.ProtocolVersion4)
+	buildPreparedData := func(replaceCqlFunctions bool) PreparedData {
+		context := NewFrameDecodeContext(prepareFrame)
+		var replacedTerms []*statementReplacedTerms
+		if replaceCqlFunctions {
+			context, replacedTerms, err = NewQueryModifier(timeUuidGenerator).replaceQueryString("ks1", context)
+			require.Nil(t, err)
+		}
+		requestInfo, err := buildRequestInfo(context, replacedTerms, nil, nil, "ks1", common.ClusterTypeOrigin,
+			nil, nil, nil, false, false, false, timeUuidGenerator)
+		require.Nil(t, err)
+		prepareRequestInfo, ok := requestInfo.(*PrepareRequestInfo)
+		require.True(t, ok)
+		require.Equal(t, replaceCqlFunctions, len(prepareRequestInfo.GetReplacedTerms()) > 0)
+		return NewPreparedData(
+			&message.PreparedResult{PreparedQueryId: []byte("origin")},
+			&message.PreparedResult{PreparedQueryId: []byte("target")},
+			prepareRequestInfo)
+	}
+
+	executeFrame := mockFrame(t, &message.Execute{QueryId: []byte("target")}, primitive.ProtocolVersion4)
+	idempotent, err := isIdempotentRequest(executeFrame, NewExecuteRequestInfo(buildPreparedData(true)), "ks1", nil)
+	require.Nil(t, err)
+	require.True(t, idempotent)
+
+	idempotent, err = isIdempotentRequest(executeFrame, NewExecuteRequestInfo(buildPreparedData(false)), "ks1", nil)
+	require.Nil(t, err)
+	require.False(t, idempotent)
+}
+
+func TestIsIdempotentRequest(t *testing.T) {
+	preparedData := NewPreparedData(
+		&message.PreparedResult{PreparedQueryId: []byte("origin")},
+		&message.PreparedResult{PreparedQueryId: []byte("target")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false,
+			"UPDATE tb1 SET c = c + ? WHERE a = ?", ""))
+	overrides := newIdempotencyOverrides(map[string]bool{"ks2": true, "ks2.tb2": false})
+
+	tests := []struct {
+		name        string
+		msg         message.Message
+		requestInfo RequestInfo
+		overrides   *idempotencyOverrides
+		idempotent  bool
+	}{
+		{"query", &message.Query{Query: "INSERT INTO tb1 (a) VALUES (1)"}, nil, nil, true},
+		{"non-idempotent query", &message.Query{Query: "UPDATE tb1 SET l = l + [1] WHERE a = 1"}, nil, nil, false},
+		{"keyspace override", &message.Query{Query: "UPDATE ks2.tb1 SET l = l + [1] WHERE a = 1"}, nil, overrides, true},
+		{"current keyspace override", &message.Query{Query: "UPDATE tb1 SET l = l + [1] WHERE a = 1",
+			Options: &message.QueryOptions{Keyspace: "ks2"}}, nil, overrides, true},
+		{"table override", &message.Query{Query: "INSERT INTO ks2.tb2 (a) VALUES (1)"}, nil, overrides, false},
+		{"execute", &message.Execute{QueryId: []byte("target")}, NewExecuteRequestInfo(preparedData), nil, false},
+		{"execute with override", &message.Execute{QueryId: []byte("target")}, NewExecuteRequestInfo(preparedData),
+			newIdempotencyOverrides(map[string]bool{"ks1.tb1": true}), true},
+		{"batch", &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO tb1 (a) VALUES (1)"}, {Query: "DELETE FROM tb1 WHERE a = 1"}}}, nil, nil, true},
+		{"batch with prepared statement", &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO tb1 (a) VALUES (1)"}, {Id: []byte("target")}}},
+			NewBatchRequestInfo(map[int]PreparedData{1: preparedData}), nil, false},
+		{"counter batch", &message.Batch{Type: primitive.BatchTypeCounter, Children: []*message.BatchChild{
+			{Query: "INSERT INTO tb1 (a) VALUES (1)"}}}, nil, overrides, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, tt.msg, primitive.ProtocolVersion4)
+			idempotent, err := isIdempotentRequest(f, tt.requestInfo, "ks1", tt.overrides)
+			require.Nil(t, err)
+			require.Equal(t, tt.idempotent, idempotent)
+		})
+	}
+}
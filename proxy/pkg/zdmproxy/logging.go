@@ -0,0 +1,122 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+)
+
+const (
+	MigrationPhaseDryRun         = "DRY_RUN"
+	MigrationPhaseDualWrites     = "DUAL_WRITES"
+	MigrationPhaseAsyncDualReads = "ASYNC_DUAL_READS"
+	MigrationPhaseReadsOnTarget  = "READS_ON_TARGET"
+)
+
+// ProxyInstanceLogField is the log field with the name of the proxy instance (ZDM_PROXY_INSTANCE_NAME).
+const ProxyInstanceLogField = "proxy_instance"
+
+var lastClientConnectionId uint64
+
+// ConfigureLogFormat sets the logrus formatter of ZDM_LOG_FORMAT. With the JSON format, every log line also has the
+// migration_phase, primary_cluster and read_mode fields. Every log line has the proxy_instance field if
+// ZDM_PROXY_INSTANCE_NAME is set, regardless of the format.
+func ConfigureLogFormat(conf *config.Config) error {
+	formatter, err := conf.ParseLogFormat()
+	if err != nil {
+		return err
+	}
+	log.SetFormatter(formatter)
+
+	fields := log.Fields{}
+	if instanceName := conf.GetProxyInstanceName(); instanceName != "" {
+		fields[ProxyInstanceLogField] = instanceName
+	}
+	if _, ok := formatter.(*log.JSONFormatter); ok {
+		primaryCluster, err := conf.ParsePrimaryCluster()
+		if err != nil {
+			return err
+		}
+		readMode, err := conf.ParseReadMode()
+		if err != nil {
+			return err
+		}
+		fields["migration_phase"] = getMigrationPhase(conf.DryRun, primaryCluster, readMode)
+		fields["primary_cluster"] = string(primaryCluster)
+		fields["read_mode"] = readMode.String()
+	}
+	if len(fields) > 0 {
+		log.AddHook(&staticFieldsHook{fields: fields})
+	}
+	return nil
+}
+
+func getMigrationPhase(dryRun bool, primaryCluster common.ClusterType, readMode common.ReadMode) string {
+	if dryRun {
+		return MigrationPhaseDryRun
+	}
+	if primaryCluster == common.ClusterTypeTarget {
+		return MigrationPhaseReadsOnTarget
+	}
+	if readMode == common.ReadModeDualAsyncOnSecondary {
+		return MigrationPhaseAsyncDualReads
+	}
+	return MigrationPhaseDualWrites
+}
+
+// staticFieldsHook adds the same fields to every log line, fields set by the caller take precedence.
+type staticFieldsHook struct {
+	fields log.Fields
+}
+
+func (recv *staticFieldsHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (recv *staticFieldsHook) Fire(entry *log.Entry) error {
+	for key, value := range recv.fields {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	}
+	return nil
+}
+
+// newClientConnectionLogFields returns the fields that correlate the log lines of a client connection.
+func newClientConnectionLogFields(clientAddress string) log.Fields {
+	return log.Fields{
+		"client_address": clientAddress,
+		"connection_id":  atomic.AddUint64(&lastClientConnectionId, 1),
+	}
+}
+
+// connectionLogger returns a logger with the client_address and connection_id fields of the client connection. It
+// allocates an entry for every call so the DEBUG and TRACE lines, which are logged for every request, don't use it.
+func (ch *ClientHandler) connectionLogger() *log.Entry {
+	return log.WithFields(ch.logFields)
+}
+
+// requestLogger returns a logger with the fields of the client connection, the stream id of the provided request and
+// the keyspace and table of its statement when they are known.
+func (ch *ClientHandler) requestLogger(f *frame.RawFrame, keyspace string, table string) *log.Entry {
+	entry := ch.connectionLogger().WithField("stream_id", f.Header.StreamId)
+	if keyspace != "" {
+		entry = entry.WithField("keyspace", keyspace)
+	}
+	if table != "" {
+		entry = entry.WithField("table", table)
+	}
+	return entry
+}
+
+// getStatementKeyspaceAndTable returns the keyspace and table of the statement of the provided request if it was already
+// inspected and it has a single statement.
+func getStatementKeyspaceAndTable(frameContext *frameDecodeContext) (string, string) {
+	if frameContext == nil || len(frameContext.statementsQueryData) != 1 {
+		return "", ""
+	}
+	queryData := frameContext.statementsQueryData[0].queryData
+	return queryData.getApplicableKeyspace(), queryData.getTableName()
+}
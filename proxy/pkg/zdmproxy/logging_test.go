@@ -0,0 +1,85 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetMigrationPhase(t *testing.T) {
+	require.Equal(t, MigrationPhaseDualWrites,
+		getMigrationPhase(false, common.ClusterTypeOrigin, common.ReadModePrimaryOnly))
+	require.Equal(t, MigrationPhaseAsyncDualReads,
+		getMigrationPhase(false, common.ClusterTypeOrigin, common.ReadModeDualAsyncOnSecondary))
+	require.Equal(t, MigrationPhaseReadsOnTarget,
+		getMigrationPhase(false, common.ClusterTypeTarget, common.ReadModeDualAsyncOnSecondary))
+	require.Equal(t, MigrationPhaseDryRun,
+		getMigrationPhase(true, common.ClusterTypeOrigin, common.ReadModePrimaryOnly))
+}
+
+func TestStaticFieldsHook(t *testing.T) {
+	hook := &staticFieldsHook{fields: log.Fields{"migration_phase": MigrationPhaseDualWrites, "read_mode": "PRIMARY_ONLY"}}
+	entry := log.WithField("read_mode", "overridden")
+	require.Nil(t, hook.Fire(entry))
+	require.Equal(t, MigrationPhaseDualWrites, entry.Data["migration_phase"])
+	require.Equal(t, "overridden", entry.Data["read_mode"])
+}
+
+func TestConfigureLogFormat_ProxyInstance(t *testing.T) {
+	formatter := log.StandardLogger().Formatter
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer func() {
+		log.SetFormatter(formatter)
+		log.StandardLogger().ReplaceHooks(hooks)
+	}()
+
+	conf := config.New()
+	conf.LogFormat = config.LogFormatText
+	conf.ProxyInstanceName = " proxy-1 "
+	require.Nil(t, ConfigureLogFormat(conf))
+	entry := log.NewEntry(log.StandardLogger())
+	require.Nil(t, log.StandardLogger().Hooks[log.InfoLevel][0].Fire(entry))
+	require.Equal(t, "proxy-1", entry.Data[ProxyInstanceLogField])
+	require.NotContains(t, entry.Data, "migration_phase")
+
+	log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	conf.ProxyInstanceName = ""
+	require.Nil(t, ConfigureLogFormat(conf))
+	require.Empty(t, log.StandardLogger().Hooks[log.InfoLevel])
+}
+
+func TestClientHandler_RequestLogger(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	ch := &ClientHandler{logFields: newClientConnectionLogFields("127.0.0.1:9042")}
+	other := newClientConnectionLogFields("127.0.0.1:9043")
+	require.NotEqual(t, ch.logFields["connection_id"], other["connection_id"])
+
+	frameContext := &frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO tb1 (a) VALUES (1)")}
+	keyspace, table := getStatementKeyspaceAndTable(frameContext)
+	require.Equal(t, "", keyspace)
+	require.Equal(t, "", table)
+	_, err = frameContext.GetOrInspectStatement("ks1", timeUuidGenerator)
+	require.Nil(t, err)
+	keyspace, table = getStatementKeyspaceAndTable(frameContext)
+	require.Equal(t, "ks1", keyspace)
+	require.Equal(t, "tb1", table)
+
+	entry := ch.requestLogger(frameContext.GetRawFrame(), keyspace, table)
+	require.Equal(t, "127.0.0.1:9042", entry.Data["client_address"])
+	require.Equal(t, ch.logFields["connection_id"], entry.Data["connection_id"])
+	require.Equal(t, frameContext.GetRawFrame().Header.StreamId, entry.Data["stream_id"])
+	require.Equal(t, "ks1", entry.Data["keyspace"])
+	require.Equal(t, "tb1", entry.Data["table"])
+
+	entry = ch.requestLogger(frameContext.GetRawFrame(), "", "")
+	require.NotContains(t, entry.Data, "keyspace")
+	require.NotContains(t, entry.Data, "table")
+
+	entry = ch.connectionLogger()
+	require.Equal(t, "127.0.0.1:9042", entry.Data["client_address"])
+	require.Equal(t, ch.logFields["connection_id"], entry.Data["connection_id"])
+	require.NotContains(t, entry.Data, "stream_id")
+}
@@ -16,6 +16,7 @@ import (
 	"math/rand"
 	"net"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -32,18 +33,36 @@ type ZdmProxy struct {
 
 	timeUuidGenerator TimeUuidGenerator
 
-	primaryCluster    common.ClusterType
-	readMode          common.ReadMode
-	systemQueriesMode common.SystemQueriesMode
+	primaryClusterKeyspaceOverrides map[string]common.ClusterType
+	tableFilter                     *tableFilter
+	writePolicies                   *tableWritePolicies
+	requestTimeouts                 *tableRequestTimeouts
+	dualWritesResponseCluster       common.ClusterType
+	writeJournal                    *writeJournal
+	idempotencyOverrides            *idempotencyOverrides
+	clientAcl                       *clientAcl
+	trafficCapture                  *trafficCapture
+	dryRun                          *dryRun
+	duplicateWrites                 *duplicateWrites
+	targetWritePacer                *targetWritePacer
+	targetConsistencyLevelMapping   *consistencyLevelMapping
+	unparseableRequests             *UnparseableRequests
+	topStatements                   *TopStatements
+	frameDebugSampling              *frameDebugSampling
+	listeners                       []*config.ListenerConfig
+	systemQueriesMode               common.SystemQueriesMode
+
+	originPassword *PasswordProvider
+	targetPassword *PasswordProvider
 
 	proxyRand *rand.Rand
 
 	lock *sync.RWMutex
 
-	// Listener that enables the proxy to listen for clients on the port specified in the configuration
-	clientListener net.Listener
-	listenerLock   *sync.Mutex
-	listenerClosed bool
+	// Listeners that enable the proxy to listen for clients on the ports specified in the configuration
+	clientListeners []net.Listener
+	listenerLock    *sync.Mutex
+	listenerClosed  bool
 
 	PreparedStatementCache *PreparedStatementCache
 
@@ -60,22 +79,30 @@ type ZdmProxy struct {
 	targetBuckets []float64
 	asyncBuckets  []float64
 
+	proxyOverheadBuckets []float64
+
 	activeClients int32
 
 	requestResponseNumWorkers int
+	dualWritesNumWorkers      int
 	readNumWorkers            int
 	writeNumWorkers           int
 	listenerNumWorkers        int
 
 	requestResponseScheduler *Scheduler
+	dualWritesWorkers        *dualWritesWorkers
 	writeScheduler           *Scheduler
 	readScheduler            *Scheduler
 	listenerScheduler        *Scheduler
 
 	clientHandlersShutdownRequestCtx      context.Context
 	clientHandlersShutdownRequestCancelFn context.CancelFunc
+	clientHandlersForceShutdownCtx        context.Context
+	clientHandlersForceShutdownCancelFn   context.CancelFunc
 	globalClientHandlersWg                *sync.WaitGroup
 
+	shuttingDown int32
+
 	metricHandler *metrics.MetricHandler
 }
 
@@ -166,12 +193,15 @@ func (p *ZdmProxy) Start(ctx context.Context) error {
 	log.Infof("Initialized target control connection. Cluster Name: %v, Hosts: %v, Assigned Hosts: %v.",
 		p.targetControlConn.GetClusterName(), targetHosts, targetAssignedHosts)
 
-	err = p.acceptConnectionsFromClients(p.Conf.ProxyListenAddress, p.Conf.ProxyListenPort, serverSideTlsConfig)
-	if err != nil {
-		return err
-	}
+	for _, listener := range p.listeners {
+		err = p.acceptConnectionsFromClients(p.Conf.ProxyListenAddress, listener, serverSideTlsConfig)
+		if err != nil {
+			return err
+		}
 
-	log.Infof("Proxy connected and ready to accept queries on %v:%d", p.Conf.ProxyListenAddress, p.Conf.ProxyListenPort)
+		log.Infof("Proxy connected and ready to accept queries on %v (primary cluster: %v, read mode: %v).",
+			net.JoinHostPort(p.Conf.ProxyListenAddress, strconv.Itoa(listener.Port)), listener.PrimaryCluster, listener.ReadMode)
+	}
 	return nil
 }
 
@@ -216,6 +246,7 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 		parsedOriginContactPoints,
 		p.Conf.OriginPort,
 		p.Conf.OriginConnectionTimeoutMs,
+		p.Conf.ProxyTcpKeepAliveMs,
 		common.ClusterTypeOrigin,
 		p.Conf.OriginLocalDatacenter,
 		ctx)
@@ -237,6 +268,7 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 		parsedTargetContactPoints,
 		p.Conf.TargetPort,
 		p.Conf.TargetConnectionTimeoutMs,
+		p.Conf.ProxyTcpKeepAliveMs,
 		common.ClusterTypeTarget,
 		p.Conf.TargetLocalDatacenter,
 		ctx)
@@ -249,7 +281,7 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 
 	originControlConn := NewControlConn(
 		p.controlConnShutdownCtx, p.Conf.OriginPort, p.originConnectionConfig,
-		p.Conf.OriginUsername, p.Conf.OriginPassword, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
+		p.Conf.OriginUsername, p.originPassword, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
 
 	if err := originControlConn.Start(p.controlConnShutdownWg, ctx); err != nil {
 		return fmt.Errorf("failed to initialize origin control connection: %w", err)
@@ -261,7 +293,7 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 
 	targetControlConn := NewControlConn(
 		p.controlConnShutdownCtx, p.Conf.TargetPort, p.targetConnectionConfig,
-		p.Conf.TargetUsername, p.Conf.TargetPassword, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
+		p.Conf.TargetUsername, p.targetPassword, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
 
 	if err := targetControlConn.Start(p.controlConnShutdownWg, ctx); err != nil {
 		return fmt.Errorf("failed to initialize target control connection: %w", err)
@@ -285,7 +317,8 @@ func (p *ZdmProxy) initializeMetricHandler() error {
 
 	var metricFactory metrics.MetricFactory
 	if p.Conf.MetricsEnabled {
-		metricFactory = prommetrics.NewPrometheusMetricFactory(prometheus.DefaultRegisterer, p.Conf.MetricsPrefix)
+		registerer := prommetrics.NewProxyInstanceRegisterer(prometheus.DefaultRegisterer, p.Conf.GetProxyInstanceName())
+		metricFactory = prommetrics.NewPrometheusMetricFactory(registerer, p.Conf.MetricsPrefix)
 	} else {
 		metricFactory = noopmetrics.NewNoopMetricFactory()
 	}
@@ -312,26 +345,111 @@ func (p *ZdmProxy) initializeGlobalStructures() error {
 	maxProcs := runtime.GOMAXPROCS(0)
 
 	var err error
-	p.readMode, err = p.Conf.ParseReadMode()
+	p.listeners, err = p.Conf.ParseProxyListeners()
+	if err != nil {
+		return err
+	}
+
+	p.primaryClusterKeyspaceOverrides, err = p.Conf.ParsePrimaryClusterKeyspaceOverrides()
 	if err != nil {
 		return err
 	}
 
-	p.primaryCluster, err = p.Conf.ParsePrimaryCluster()
+	includedTables, err := p.Conf.ParseIncludedTables()
 	if err != nil {
 		return err
 	}
 
+	excludedTables, err := p.Conf.ParseExcludedTables()
+	if err != nil {
+		return err
+	}
+	p.tableFilter = newTableFilter(includedTables, excludedTables)
+
+	writePolicies, err := p.Conf.ParseTableWritePolicies()
+	if err != nil {
+		return err
+	}
+	dualWritesFailures, err := p.Conf.ParseDualWritesFailures()
+	if err != nil {
+		return err
+	}
+	p.writePolicies = newTableWritePolicies(writePolicies, dualWritesFailures)
+
+	requestTimeouts, err := p.Conf.ParseTableRequestTimeouts()
+	if err != nil {
+		return err
+	}
+	p.requestTimeouts = newTableRequestTimeouts(requestTimeouts)
+
+	p.dualWritesResponseCluster, err = p.Conf.ParseDualWritesResponseCluster()
+	if err != nil {
+		return err
+	}
+
+	clientAllowList, err := p.Conf.ParseProxyClientAllowList()
+	if err != nil {
+		return err
+	}
+
+	clientDenyList, err := p.Conf.ParseProxyClientDenyList()
+	if err != nil {
+		return err
+	}
+	p.clientAcl = newClientAcl(clientAllowList, clientDenyList)
+
+	p.dryRun = newDryRun(p.Conf.DryRun)
+	p.duplicateWrites = newDuplicateWrites(p.Conf.TargetDuplicateWritesWindowMs)
+	p.targetWritePacer = newTargetWritePacer(p.Conf.TargetWritesAdaptivePacing, time.Now)
+
+	targetConsistencyLevels, err := p.Conf.ParseTargetConsistencyLevelMapping()
+	if err != nil {
+		return err
+	}
+	p.targetConsistencyLevelMapping = newConsistencyLevelMapping(targetConsistencyLevels)
+
+	p.unparseableRequests = NewUnparseableRequests()
+	p.topStatements = NewTopStatements(p.Conf.MetricsTopStatements)
+	p.frameDebugSampling = newFrameDebugSampling(p.Conf.LogFramesSampleConnections)
+
+	p.trafficCapture, err = newTrafficCapture(p.Conf.ProxyCaptureFile, p.Conf.ProxyCaptureSamplePercent)
+	if err != nil {
+		return err
+	}
+
+	p.writeJournal, err = newWriteJournal(p.Conf.DualWritesJournalFile)
+	if err != nil {
+		return err
+	}
+
+	idempotencyOverrides, err := p.Conf.ParseIdempotencyOverrides()
+	if err != nil {
+		return err
+	}
+	p.idempotencyOverrides = newIdempotencyOverrides(idempotencyOverrides)
+
 	p.systemQueriesMode, err = p.Conf.ParseSystemQueriesMode()
 	if err != nil {
 		return err
 	}
 
+	p.originPassword, err = NewPasswordProvider(common.ClusterTypeOrigin, p.Conf.ParseOriginPassword)
+	if err != nil {
+		return err
+	}
+
+	p.targetPassword, err = NewPasswordProvider(common.ClusterTypeTarget, p.Conf.ParseTargetPassword)
+	if err != nil {
+		return err
+	}
+
 	defaultReadWorkers := maxProcs * 8
 	defaultWriteWorkers := maxProcs * 4
-	if p.readMode == common.ReadModeDualAsyncOnSecondary {
-		defaultReadWorkers = maxProcs * 12
-		defaultWriteWorkers = maxProcs * 6
+	for _, listener := range p.listeners {
+		if listener.ReadMode == common.ReadModeDualAsyncOnSecondary {
+			defaultReadWorkers = maxProcs * 12
+			defaultWriteWorkers = maxProcs * 6
+		}
 	}
 
 	p.requestResponseNumWorkers = p.Conf.RequestResponseMaxWorkers
@@ -343,6 +461,15 @@ func (p *ZdmProxy) initializeGlobalStructures() error {
 	}
 	log.Infof("Using %d request / response workers.", p.requestResponseNumWorkers)
 
+	p.dualWritesNumWorkers = p.Conf.DualWritesMaxWorkers
+	if p.dualWritesNumWorkers == -1 {
+		p.dualWritesNumWorkers = maxProcs * 4 // default
+	} else if p.dualWritesNumWorkers <= 0 {
+		log.Warnf("Invalid number of dual writes workers %d, using GOMAXPROCS * 4 (%d).", p.dualWritesNumWorkers, maxProcs*4)
+		p.dualWritesNumWorkers = maxProcs * 4
+	}
+	log.Infof("Using %d dual writes workers.", p.dualWritesNumWorkers)
+
 	p.writeNumWorkers = p.Conf.WriteMaxWorkers
 	if p.writeNumWorkers == -1 {
 		p.writeNumWorkers = defaultWriteWorkers // default
@@ -371,6 +498,7 @@ func (p *ZdmProxy) initializeGlobalStructures() error {
 	log.Infof("Using %d listener workers.", p.listenerNumWorkers)
 
 	p.requestResponseScheduler = NewScheduler(p.requestResponseNumWorkers)
+	p.dualWritesWorkers = newDualWritesWorkers(p.dualWritesNumWorkers)
 	p.writeScheduler = NewScheduler(p.writeNumWorkers)
 	p.readScheduler = NewScheduler(p.readNumWorkers)
 	p.listenerScheduler = NewScheduler(p.listenerNumWorkers)
@@ -380,8 +508,9 @@ func (p *ZdmProxy) initializeGlobalStructures() error {
 
 	p.globalClientHandlersWg = &sync.WaitGroup{}
 	p.clientHandlersShutdownRequestCtx, p.clientHandlersShutdownRequestCancelFn = context.WithCancel(context.Background())
+	p.clientHandlersForceShutdownCtx, p.clientHandlersForceShutdownCancelFn = context.WithCancel(context.Background())
 
-	p.PreparedStatementCache = NewPreparedStatementCache()
+	p.PreparedStatementCache = NewPreparedStatementCache(p.Conf.ProxyMaxPreparedStatements)
 
 	p.controlConnShutdownCtx, p.controlConnCancelFn = context.WithCancel(context.Background())
 	p.controlConnShutdownWg = &sync.WaitGroup{}
@@ -409,31 +538,38 @@ func (p *ZdmProxy) initializeGlobalStructures() error {
 		log.Infof("Parsed Async latency buckets: %v", p.asyncBuckets)
 	}
 
+	p.proxyOverheadBuckets, err = p.Conf.ParseProxyOverheadBuckets()
+	if err != nil {
+		return fmt.Errorf("failed to parse proxy overhead buckets: %w", err)
+	} else {
+		log.Infof("Parsed proxy overhead buckets: %v", p.proxyOverheadBuckets)
+	}
+
 	p.activeClients = 0
 	return nil
 }
 
-// acceptConnectionsFromClients creates a listener on the passed in port argument, and every connection
+// acceptConnectionsFromClients creates a listener on the port of the passed in listener configuration, and every connection
 // that is received over that port instantiates a ClientHandler that then takes over managing that connection
-func (p *ZdmProxy) acceptConnectionsFromClients(address string, port int, serverSideTlsConfig *tls.Config) error {
+func (p *ZdmProxy) acceptConnectionsFromClients(address string, listener *config.ListenerConfig, serverSideTlsConfig *tls.Config) error {
 
 	protocol := "tcp"
-	listenAddr := fmt.Sprintf("%s:%d", address, port)
-
-	var l net.Listener
-	var err error
-	if serverSideTlsConfig == nil {
-		l, err = net.Listen(protocol, listenAddr)
-	} else {
-		l, err = tls.Listen(protocol, listenAddr, serverSideTlsConfig)
-	}
+	port := listener.Port
+	listenAddr := net.JoinHostPort(address, strconv.Itoa(port))
 
+	listenConfig := net.ListenConfig{KeepAlive: time.Duration(p.Conf.ProxyTcpKeepAliveMs) * time.Millisecond}
+	l, err := listenConfig.Listen(context.Background(), protocol, listenAddr)
 	if err != nil {
 		return err
 	}
 
 	p.listenerLock.Lock()
-	p.clientListener = l
+	if p.listenerClosed {
+		p.listenerLock.Unlock()
+		_ = l.Close()
+		return ShutdownErr
+	}
+	p.clientListeners = append(p.clientListeners, l)
 	p.listenerLock.Unlock()
 
 	p.listenerShutdownWg.Add(1)
@@ -471,6 +607,7 @@ func (p *ZdmProxy) acceptConnectionsFromClients(address string, port int, server
 				log.Warnf(
 					"Refusing client connection from %v because max clients threshold has been hit (%v).",
 					conn.RemoteAddr(), p.Conf.ProxyMaxClientConnections)
+				p.metricHandler.GetProxyMetrics().RejectedClientConnections.Add(1)
 				err = conn.Close()
 				if err != nil {
 					log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
@@ -479,12 +616,32 @@ func (p *ZdmProxy) acceptConnectionsFromClients(address string, port int, server
 			}
 
 			atomic.AddInt32(&p.activeClients, 1)
-			log.Infof("Accepted connection from %v", conn.RemoteAddr())
+
+			rejectClientConnection := func(conn net.Conn, err error) {
+				log.Warnf("Closing client connection from %v: %v", conn.RemoteAddr(), err)
+				p.metricHandler.GetProxyMetrics().RejectedClientConnections.Add(1)
+				_ = conn.Close()
+				atomic.AddInt32(&p.activeClients, -1)
+			}
 
 			wg.Add(1)
-			p.listenerScheduler.Schedule(func() {
-				defer wg.Done()
-				p.handleNewConnection(conn)
+			p.readProxyProtocolHeaderAsync(conn, func(conn net.Conn, err error) {
+				if err != nil {
+					defer wg.Done()
+					rejectClientConnection(conn, err)
+					return
+				}
+				p.listenerScheduler.Schedule(func() {
+					defer wg.Done()
+					clientConn, err := p.prepareClientConnection(conn, serverSideTlsConfig)
+					if err != nil {
+						rejectClientConnection(conn, err)
+						return
+					}
+					log.Infof("Accepted connection from %v", clientConn.RemoteAddr())
+					p.metricHandler.GetProxyMetrics().OpenedClientConnections.Add(1)
+					p.handleNewConnection(clientConn, listener)
+				})
 			})
 		}
 	}()
@@ -492,11 +649,44 @@ func (p *ZdmProxy) acceptConnectionsFromClients(address string, port int, server
 	return nil
 }
 
+// readProxyProtocolHeaderAsync calls onConn with the connection once its PROXY protocol header is read if
+// ZDM_PROXY_ENABLE_PROXY_PROTOCOL is true, or right away otherwise. The header is read on a goroutine of the connection
+// so that connections that don't send it (e.g. idle clients) don't hold a listener worker until the read times out
+// and don't delay the connections accepted after them.
+func (p *ZdmProxy) readProxyProtocolHeaderAsync(conn net.Conn, onConn func(net.Conn, error)) {
+	if !p.Conf.ProxyEnableProxyProtocol {
+		onConn(conn, nil)
+		return
+	}
+	go func() {
+		proxyProtocolConn, err := readProxyProtocolHeader(conn)
+		if err != nil {
+			onConn(conn, err)
+			return
+		}
+		onConn(proxyProtocolConn, nil)
+	}()
+}
+
+// prepareClientConnection checks the client address against the allow and deny lists and sets up TLS (if enabled) on
+// a connection that was just accepted.
+func (p *ZdmProxy) prepareClientConnection(conn net.Conn, serverSideTlsConfig *tls.Config) (net.Conn, error) {
+	if !p.clientAcl.IsAllowed(conn.RemoteAddr()) {
+		return nil, fmt.Errorf("client address %v is not allowed by ZDM_PROXY_CLIENT_ALLOW_LIST or ZDM_PROXY_CLIENT_DENY_LIST",
+			conn.RemoteAddr())
+	}
+	if serverSideTlsConfig != nil {
+		conn = tls.Server(conn, serverSideTlsConfig)
+	}
+	return conn, nil
+}
+
 // handleNewConnection creates the client handler and connectors for the new client connection
-func (p *ZdmProxy) handleNewConnection(clientConn net.Conn) {
+func (p *ZdmProxy) handleNewConnection(clientConn net.Conn, listener *config.ListenerConfig) {
 
 	errFunc := func(e error) {
 		log.Errorf("Client Handler could not be created: %v", e)
+		p.metricHandler.GetProxyMetrics().ClientConnectionsClosedByError.Add(1)
 		clientConn.Close()
 		atomic.AddInt32(&p.activeClients, -1)
 	}
@@ -551,22 +741,41 @@ func (p *ZdmProxy) handleNewConnection(clientConn net.Conn) {
 		p.Conf,
 		p.TopologyConfig,
 		p.Conf.TargetUsername,
-		p.Conf.TargetPassword,
+		p.targetPassword.Get(),
 		p.Conf.OriginUsername,
-		p.Conf.OriginPassword,
+		p.originPassword.Get(),
 		p.PreparedStatementCache,
 		p.metricHandler,
 		p.globalClientHandlersWg,
 		p.requestResponseScheduler,
+		p.dualWritesWorkers,
 		p.readScheduler,
 		p.writeScheduler,
 		p.requestResponseNumWorkers,
 		p.clientHandlersShutdownRequestCtx,
+		p.clientHandlersForceShutdownCtx,
 		originHost,
 		targetHost,
 		p.timeUuidGenerator,
-		p.readMode,
-		p.primaryCluster,
+		listener.ReadMode,
+		listener.PrimaryCluster,
+		listener.MaxClientRequestsPerSecond,
+		listener.Port,
+		p.primaryClusterKeyspaceOverrides,
+		p.tableFilter,
+		p.writePolicies,
+		p.requestTimeouts,
+		p.dualWritesResponseCluster,
+		p.writeJournal,
+		p.idempotencyOverrides,
+		p.dryRun,
+		p.duplicateWrites,
+		p.targetWritePacer,
+		p.targetConsistencyLevelMapping,
+		p.unparseableRequests,
+		p.topStatements,
+		p.trafficCapture.NewConnection(),
+		p.frameDebugSampling.NewConnection(clientConn.RemoteAddr().String()),
 		p.systemQueriesMode)
 
 	if err != nil {
@@ -578,15 +787,23 @@ func (p *ZdmProxy) handleNewConnection(clientConn net.Conn) {
 	clientHandler.run(&p.activeClients)
 }
 
-func (p *ZdmProxy) Shutdown() {
+// DrainTimeoutErr is returned by ZdmProxy.Shutdown when the client connections were not drained within the shutdown
+// grace period (ZDM_PROXY_SHUTDOWN_GRACE_PERIOD_MS) and had to be closed with requests still in flight.
+var DrainTimeoutErr = errors.New("client connections were not drained within the shutdown grace period")
+
+// Shutdown stops accepting client connections, drains the open client connections (new requests are rejected and the
+// in flight requests finish) and releases the resources of the proxy. The client connections that are still open after
+// the shutdown grace period are closed and DrainTimeoutErr is returned.
+func (p *ZdmProxy) Shutdown() error {
 	log.Info("Initiating proxy shutdown...")
+	atomic.StoreInt32(&p.shuttingDown, 1)
 
 	log.Debug("Requesting shutdown of the client listener...")
 	p.listenerLock.Lock()
 	if !p.listenerClosed {
 		p.listenerClosed = true
-		if p.clientListener != nil {
-			p.clientListener.Close()
+		for _, l := range p.clientListeners {
+			l.Close()
 		}
 	}
 	p.listenerLock.Unlock()
@@ -596,6 +813,16 @@ func (p *ZdmProxy) Shutdown() {
 	log.Debug("Requesting shutdown of the client handlers...")
 	p.clientHandlersShutdownRequestCancelFn()
 
+	var drainErr error
+	gracePeriod := time.Duration(p.Conf.ProxyShutdownGracePeriodMs) * time.Millisecond
+	log.Infof("Draining %d client connections (grace period: %v)...", atomic.LoadInt32(&p.activeClients), gracePeriod)
+	if !waitGroupWithTimeout(p.globalClientHandlersWg, gracePeriod) {
+		log.Warnf("%d client connections were not drained within the shutdown grace period (%v), closing them.",
+			atomic.LoadInt32(&p.activeClients), gracePeriod)
+		drainErr = DrainTimeoutErr
+	}
+	p.clientHandlersForceShutdownCancelFn()
+
 	log.Debug("Waiting until all client handlers are done...")
 	p.globalClientHandlersWg.Wait()
 
@@ -607,10 +834,15 @@ func (p *ZdmProxy) Shutdown() {
 
 	log.Debug("Shutting down the schedulers and metrics handler...")
 	p.requestResponseScheduler.Shutdown()
+	p.dualWritesWorkers.Shutdown()
 	p.writeScheduler.Shutdown()
 	p.readScheduler.Shutdown()
 	p.listenerScheduler.Shutdown()
 
+	p.trafficCapture.Close()
+	p.writeJournal.Close()
+	p.dryRun.LogReport()
+
 	p.lock.Lock()
 	if p.metricHandler != nil {
 		err := p.metricHandler.UnregisterAllMetrics()
@@ -621,6 +853,40 @@ func (p *ZdmProxy) Shutdown() {
 	p.lock.Unlock()
 
 	log.Info("Proxy shutdown complete.")
+	return drainErr
+}
+
+// IsShuttingDown returns true once Shutdown was called, i.e. while the client connections are drained.
+func (p *ZdmProxy) IsShuttingDown() bool {
+	return atomic.LoadInt32(&p.shuttingDown) == 1
+}
+
+// waitGroupWithTimeout waits until the wait group is done and returns false if the timeout elapsed first, it waits
+// indefinitely if the timeout is 0.
+func waitGroupWithTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	if timeout <= 0 {
+		wg.Wait()
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (p *ZdmProxy) GetUnparseableRequests() *UnparseableRequests {
+	return p.unparseableRequests
+}
+
+func (p *ZdmProxy) GetTopStatements() *TopStatements {
+	return p.topStatements
 }
 
 func (p *ZdmProxy) GetOriginControlConn() *ControlConn {
@@ -737,6 +1003,21 @@ func (p *ZdmProxy) CreateProxyMetrics(metricFactory metrics.MetricFactory) (*met
 		return nil, err
 	}
 
+	proxyReadsOriginOverhead, err := metricFactory.GetOrCreateHistogram(metrics.ProxyReadsOriginOverhead, p.proxyOverheadBuckets)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyReadsTargetOverhead, err := metricFactory.GetOrCreateHistogram(metrics.ProxyReadsTargetOverhead, p.proxyOverheadBuckets)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyWritesOverhead, err := metricFactory.GetOrCreateHistogram(metrics.ProxyWritesOverhead, p.proxyOverheadBuckets)
+	if err != nil {
+		return nil, err
+	}
+
 	inFlightReadsOrigin, err := metricFactory.GetOrCreateGauge(metrics.InFlightReadsOrigin)
 	if err != nil {
 		return nil, err
@@ -759,21 +1040,118 @@ func (p *ZdmProxy) CreateProxyMetrics(metricFactory metrics.MetricFactory) (*met
 		return nil, err
 	}
 
+	openedClientConnections, err := metricFactory.GetOrCreateCounter(metrics.OpenedClientConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	rejectedClientConnections, err := metricFactory.GetOrCreateCounter(metrics.RejectedClientConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConnectionsClosedByClient, err := metricFactory.GetOrCreateCounter(metrics.ClientConnectionsClosedByClient)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConnectionsClosedByIdleTimeout, err := metricFactory.GetOrCreateCounter(metrics.ClientConnectionsClosedByIdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConnectionsClosedByShutdown, err := metricFactory.GetOrCreateCounter(metrics.ClientConnectionsClosedByShutdown)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConnectionsClosedByError, err := metricFactory.GetOrCreateCounter(metrics.ClientConnectionsClosedByError)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConnectionDuration, err := metricFactory.GetOrCreateHistogram(
+		metrics.ClientConnectionDuration, clientConnectionDurationBucketsSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	dualReadsMatches, err := metricFactory.GetOrCreateCounter(metrics.DualReadsMatches)
+	if err != nil {
+		return nil, err
+	}
+
+	dualReadsMismatches, err := metricFactory.GetOrCreateCounter(metrics.DualReadsMismatches)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitedRequests, err := metricFactory.GetOrCreateCounter(metrics.RateLimitedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	dryRunSkippedWrites, err := metricFactory.GetOrCreateCounter(metrics.DryRunSkippedWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressedDuplicateWrites, err := metricFactory.GetOrCreateCounter(metrics.SuppressedDuplicateWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWritePacingRate, err := metricFactory.GetOrCreateGaugeFunc(metrics.TargetWritePacingRate, func() float64 {
+		return p.targetWritePacer.GetRate()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unrecognizedStatements, err := metricFactory.GetOrCreateCounter(metrics.UnrecognizedStatements)
+	if err != nil {
+		return nil, err
+	}
+
+	requestDecodeErrors, err := metricFactory.GetOrCreateCounter(metrics.RequestDecodeErrors)
+	if err != nil {
+		return nil, err
+	}
+
 	proxyMetrics := &metrics.ProxyMetrics{
-		FailedReadsOrigin:        failedReadsOrigin,
-		FailedReadsTarget:        failedReadsTarget,
-		FailedWritesOnOrigin:     failedWritesOnOrigin,
-		FailedWritesOnTarget:     failedWritesOnTarget,
-		FailedWritesOnBoth:       failedWritesOnBoth,
-		PSCacheSize:              psCacheSize,
-		PSCacheMissCount:         psCacheMissCount,
-		ProxyReadsOriginDuration: proxyReadsOriginDuration,
-		ProxyReadsTargetDuration: proxyReadsTargetDuration,
-		ProxyWritesDuration:      proxyWritesDuration,
-		InFlightReadsOrigin:      inFlightReadsOrigin,
-		InFlightReadsTarget:      inFlightReadsTarget,
-		InFlightWrites:           inFlightWrites,
-		OpenClientConnections:    openClientConnections,
+		FailedReadsOrigin:         failedReadsOrigin,
+		FailedReadsTarget:         failedReadsTarget,
+		FailedWritesOnOrigin:      failedWritesOnOrigin,
+		FailedWritesOnTarget:      failedWritesOnTarget,
+		FailedWritesOnBoth:        failedWritesOnBoth,
+		PSCacheSize:               psCacheSize,
+		PSCacheMissCount:          psCacheMissCount,
+		ProxyReadsOriginDuration:  proxyReadsOriginDuration,
+		ProxyReadsTargetDuration:  proxyReadsTargetDuration,
+		ProxyWritesDuration:       proxyWritesDuration,
+		ProxyReadsOriginOverhead:  proxyReadsOriginOverhead,
+		ProxyReadsTargetOverhead:  proxyReadsTargetOverhead,
+		ProxyWritesOverhead:       proxyWritesOverhead,
+		InFlightReadsOrigin:       inFlightReadsOrigin,
+		InFlightReadsTarget:       inFlightReadsTarget,
+		InFlightWrites:            inFlightWrites,
+		OpenClientConnections:     openClientConnections,
+		DualReadsMatches:          dualReadsMatches,
+		DualReadsMismatches:       dualReadsMismatches,
+		RateLimitedRequests:       rateLimitedRequests,
+		DryRunSkippedWrites:       dryRunSkippedWrites,
+		SuppressedDuplicateWrites: suppressedDuplicateWrites,
+		TargetWritePacingRate:     targetWritePacingRate,
+		UnrecognizedStatements:    unrecognizedStatements,
+		RequestDecodeErrors:       requestDecodeErrors,
+
+		OpenedClientConnections:              openedClientConnections,
+		RejectedClientConnections:            rejectedClientConnections,
+		ClientConnectionsClosedByClient:      clientConnectionsClosedByClient,
+		ClientConnectionsClosedByIdleTimeout: clientConnectionsClosedByIdleTimeout,
+		ClientConnectionsClosedByShutdown:    clientConnectionsClosedByShutdown,
+		ClientConnectionsClosedByError:       clientConnectionsClosedByError,
+		ClientConnectionDuration:             clientConnectionDuration,
 	}
 
 	return proxyMetrics, nil
@@ -0,0 +1,162 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const proxyProtocolHeaderTimeout = 5 * time.Second
+
+var (
+	proxyProtocolV1Prefix    = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+)
+
+const (
+	proxyProtocolV1MaxLength = 107
+
+	proxyProtocolV2HeaderLength = 16
+	proxyProtocolV2CmdLocal     = 0x0
+	proxyProtocolV2CmdProxy     = 0x1
+	proxyProtocolV2FamilyInet   = 0x1
+	proxyProtocolV2FamilyInet6  = 0x2
+)
+
+// proxyProtocolConn is a client connection accepted behind a load balancer that sends a PROXY protocol header.
+// RemoteAddr returns the address of the client that the load balancer received the connection from.
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (recv *proxyProtocolConn) Read(b []byte) (int, error) {
+	return recv.reader.Read(b)
+}
+
+func (recv *proxyProtocolConn) RemoteAddr() net.Addr {
+	return recv.remoteAddr
+}
+
+// readProxyProtocolHeader reads the PROXY protocol (v1 or v2) header that a load balancer sends at the start
+// of a connection. The returned connection uses the source address from the header as its remote address
+// unless the header doesn't carry a TCP source address (e.g. health checks of the load balancer).
+func readProxyProtocolHeader(conn net.Conn) (net.Conn, error) {
+	err := conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	var sourceAddr net.Addr
+	prefix, err := reader.Peek(len(proxyProtocolV1Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("could not read PROXY protocol header: %w", err)
+	}
+	if bytes.Equal(prefix, proxyProtocolV1Prefix) {
+		sourceAddr, err = readProxyProtocolV1Header(reader)
+	} else {
+		sourceAddr, err = readProxyProtocolV2Header(reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	if sourceAddr == nil {
+		sourceAddr = conn.RemoteAddr()
+	}
+	return &proxyProtocolConn{Conn: conn, reader: reader, remoteAddr: sourceAddr}, nil
+}
+
+func readProxyProtocolV1Header(reader *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyProtocolV1MaxLength)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, errors.New("PROXY protocol v1 header is too long")
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("could not read PROXY protocol v1 header: %w", err)
+		}
+		line = append(line, b)
+	}
+
+	// PROXY <TCP4|TCP6|UNKNOWN> <src ip> <dst ip> <src port> <dst port>
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY protocol v1 header: %q", line)
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source address in PROXY protocol v1 header: %v", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source port in PROXY protocol v1 header: %v", fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyProtocolV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, proxyProtocolV2HeaderLength)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, fmt.Errorf("could not read PROXY protocol v2 header: %w", err)
+	}
+	if !bytes.Equal(header[:len(proxyProtocolV2Signature)], proxyProtocolV2Signature) {
+		return nil, errors.New("connection does not start with a PROXY protocol header")
+	}
+
+	version := header[12] >> 4
+	command := header[12] & 0x0F
+	family := header[13] >> 4
+	addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	_, err = io.ReadFull(reader, addresses)
+	if err != nil {
+		return nil, fmt.Errorf("could not read PROXY protocol v2 addresses: %w", err)
+	}
+
+	if version != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version: %v", version)
+	}
+	switch command {
+	case proxyProtocolV2CmdLocal:
+		return nil, nil
+	case proxyProtocolV2CmdProxy:
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol v2 command: %v", command)
+	}
+
+	// source address, destination address, source port, destination port
+	var ipLength int
+	switch family {
+	case proxyProtocolV2FamilyInet:
+		ipLength = net.IPv4len
+	case proxyProtocolV2FamilyInet6:
+		ipLength = net.IPv6len
+	default:
+		return nil, nil
+	}
+	if len(addresses) < 2*ipLength+4 {
+		return nil, fmt.Errorf("PROXY protocol v2 addresses are too short (%v bytes)", len(addresses))
+	}
+	ip := net.IP(addresses[:ipLength])
+	port := binary.BigEndian.Uint16(addresses[2*ipLength : 2*ipLength+2])
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
@@ -0,0 +1,104 @@
+package zdmproxy
+
+import (
+	"encoding/binary"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func buildProxyProtocolV2Header(command byte, family byte, addresses []byte) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family<<4|0x1, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestReadProxyProtocolHeader(t *testing.T) {
+	ipv4Addresses := []byte{10, 0, 0, 1, 10, 0, 0, 2, 0x23, 0x82, 0x23, 0x52}
+	ipv6Addresses := append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...), 0x23, 0x82, 0x23, 0x52)
+
+	tests := []struct {
+		name         string
+		header       []byte
+		expectedAddr string
+		expectedErr  bool
+	}{
+		{"v1 TCP4", []byte("PROXY TCP4 10.0.0.1 10.0.0.2 9090 9042\r\n"), "10.0.0.1:9090", false},
+		{"v1 TCP6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 9090 9042\r\n"), "[2001:db8::1]:9090", false},
+		{"v1 UNKNOWN", []byte("PROXY UNKNOWN\r\n"), "pipe", false},
+		{"v1 invalid address", []byte("PROXY TCP4 invalid 10.0.0.2 9090 9042\r\n"), "", true},
+		{"v2 IPv4", buildProxyProtocolV2Header(proxyProtocolV2CmdProxy, proxyProtocolV2FamilyInet, ipv4Addresses), "10.0.0.1:9090", false},
+		{"v2 IPv6", buildProxyProtocolV2Header(proxyProtocolV2CmdProxy, proxyProtocolV2FamilyInet6, ipv6Addresses), "[2001:db8::1]:9090", false},
+		{"v2 LOCAL", buildProxyProtocolV2Header(proxyProtocolV2CmdLocal, 0, nil), "pipe", false},
+		{"v2 truncated addresses", buildProxyProtocolV2Header(proxyProtocolV2CmdProxy, proxyProtocolV2FamilyInet, ipv4Addresses[:4]), "", true},
+		{"no header", []byte("\x04\x00\x00\x00\x05\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConn, clientConn := net.Pipe()
+			defer serverConn.Close()
+			defer clientConn.Close()
+
+			payload := []byte("cql frame")
+			go func() {
+				_, _ = clientConn.Write(append(append([]byte{}, tt.header...), payload...))
+			}()
+
+			conn, err := readProxyProtocolHeader(serverConn)
+			if tt.expectedErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedAddr, conn.RemoteAddr().String())
+
+			read := make([]byte, len(payload))
+			_, err = io.ReadFull(conn, read)
+			require.Nil(t, err)
+			require.Equal(t, payload, read)
+		})
+	}
+}
+
+func TestReadProxyProtocolHeaderAsync_IdleConnection(t *testing.T) {
+	p := &ZdmProxy{Conf: &config.Config{ProxyEnableProxyProtocol: true}}
+	listenerScheduler := NewScheduler(1)
+	defer listenerScheduler.Shutdown()
+	accepted := make(chan net.Conn, 2)
+	accept := func(conn net.Conn) {
+		p.readProxyProtocolHeaderAsync(conn, func(conn net.Conn, err error) {
+			if err != nil {
+				// the idle connection is closed at the end of the test
+				return
+			}
+			listenerScheduler.Schedule(func() {
+				accepted <- conn
+			})
+		})
+	}
+
+	// a client that doesn't send the header doesn't hold the only listener worker
+	idleServerConn, idleClientConn := net.Pipe()
+	defer idleServerConn.Close()
+	defer idleClientConn.Close()
+	accept(idleServerConn)
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	go func() {
+		_, _ = clientConn.Write([]byte("PROXY TCP4 10.0.0.1 10.0.0.2 9090 9042\r\n"))
+	}()
+	accept(serverConn)
+
+	select {
+	case conn := <-accepted:
+		require.Equal(t, "10.0.0.1:9090", conn.RemoteAddr().String())
+	case <-time.After(time.Second):
+		require.Fail(t, "connection was not accepted while another connection didn't send the PROXY protocol header")
+	}
+}
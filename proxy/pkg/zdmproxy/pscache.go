@@ -6,27 +6,46 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	log "github.com/sirupsen/logrus"
 	"sync"
+	"sync/atomic"
 )
 
+// PreparedStatementCache keeps the prepared statements of both clusters so that EXECUTE requests can be routed and
+// their prepared ids translated.
+//
+// If maxSize is greater than 0, the least recently used entry is evicted when a new statement is stored in a full cache.
+// An EXECUTE request for an evicted statement gets an UNPREPARED response so the driver prepares the statement again.
+// Entries of intercepted statements (system.local and system.peers queries) are not evicted nor counted in maxSize.
+//
+// Lookups only take the read lock so that concurrent EXECUTE requests don't contend on the cache, they record the use
+// of an entry with an atomic stamp which makes the eviction order approximate when entries are used concurrently.
 type PreparedStatementCache struct {
+	clock int64 // last stamp of lastUsed, first field to be 64-bit aligned for atomic operations
+
 	cache map[string]PreparedData // Map containing the prepared queries (raw bytes) keyed on prepareId
 	index map[string]string       // Map that can be used as an index to look up origin prepareIds by target prepareId
 
 	interceptedCache map[string]PreparedData // Map containing the prepared queries for intercepted requests
 
+	maxSize  int
+	lastUsed map[string]*int64 // stamp of the last use of each entry keyed on origin prepareId, only used if maxSize > 0
+
 	lock *sync.RWMutex
 }
 
-func NewPreparedStatementCache() *PreparedStatementCache {
+// NewPreparedStatementCache returns a cache with the provided maximum number of entries, 0 means that the cache
+// is unbounded.
+func NewPreparedStatementCache(maxSize int) *PreparedStatementCache {
 	return &PreparedStatementCache{
 		cache:            make(map[string]PreparedData),
 		index:            make(map[string]string),
 		interceptedCache: make(map[string]PreparedData),
+		maxSize:          maxSize,
+		lastUsed:         make(map[string]*int64),
 		lock:             &sync.RWMutex{},
 	}
 }
 
-func (psc PreparedStatementCache) GetPreparedStatementCacheSize() float64 {
+func (psc *PreparedStatementCache) GetPreparedStatementCacheSize() float64 {
 	psc.lock.RLock()
 	defer psc.lock.RUnlock()
 
@@ -42,8 +61,13 @@ func (psc *PreparedStatementCache) Store(
 	psc.lock.Lock()
 	defer psc.lock.Unlock()
 
+	if previous, ok := psc.cache[originPrepareIdStr]; ok {
+		delete(psc.index, string(previous.GetTargetPreparedId()))
+	}
 	psc.cache[originPrepareIdStr] = NewPreparedData(originPreparedResult, targetPreparedResult, prepareRequestInfo)
 	psc.index[targetPrepareIdStr] = originPrepareIdStr
+	psc.markUsed(originPrepareIdStr)
+	psc.evict()
 
 	log.Debugf("Storing PS cache entry: {OriginPreparedId=%v, TargetPreparedId: %v, RequestInfo: %v}",
 		hex.EncodeToString(originPreparedResult.PreparedQueryId), hex.EncodeToString(targetPreparedResult.PreparedQueryId), prepareRequestInfo)
@@ -65,7 +89,9 @@ func (psc *PreparedStatementCache) Get(originPreparedId []byte) (PreparedData, b
 	psc.lock.RLock()
 	defer psc.lock.RUnlock()
 	data, ok := psc.cache[string(originPreparedId)]
-	if !ok {
+	if ok {
+		psc.markUsed(string(originPreparedId))
+	} else {
 		data, ok = psc.interceptedCache[string(originPreparedId)]
 	}
 	return data, ok
@@ -91,9 +117,51 @@ func (psc *PreparedStatementCache) GetByTargetPreparedId(targetPreparedId []byte
 	return data, true
 }
 
+// markUsed records the use of the provided entry, the read lock must be held by the caller and the write lock if the
+// entry was just stored.
+func (psc *PreparedStatementCache) markUsed(originPrepareIdStr string) {
+	if psc.maxSize <= 0 {
+		return
+	}
+	stamp := atomic.AddInt64(&psc.clock, 1)
+	if lastUsed, ok := psc.lastUsed[originPrepareIdStr]; ok {
+		atomic.StoreInt64(lastUsed, stamp)
+		return
+	}
+	psc.lastUsed[originPrepareIdStr] = &stamp
+}
+
+// evict removes the least recently used entries until the size of the cache is within maxSize, the write lock must be
+// held by the caller.
+func (psc *PreparedStatementCache) evict() {
+	if psc.maxSize <= 0 {
+		return
+	}
+	for len(psc.cache) > psc.maxSize {
+		evicted, oldest, found := "", int64(0), false
+		for originPrepareIdStr, lastUsed := range psc.lastUsed {
+			if stamp := atomic.LoadInt64(lastUsed); !found || stamp < oldest {
+				evicted, oldest, found = originPrepareIdStr, stamp, true
+			}
+		}
+		if !found {
+			return
+		}
+		delete(psc.lastUsed, evicted)
+		data, ok := psc.cache[evicted]
+		if !ok {
+			continue
+		}
+		delete(psc.cache, evicted)
+		delete(psc.index, string(data.GetTargetPreparedId()))
+		log.Debugf("Evicted PS cache entry: %v", data)
+	}
+}
+
 type PreparedData interface {
 	GetOriginPreparedId() []byte
 	GetTargetPreparedId() []byte
+	GetTargetResultMetadataId() []byte
 	GetPrepareRequestInfo() *PrepareRequestInfo
 	GetOriginVariablesMetadata() *message.VariablesMetadata
 	GetTargetVariablesMetadata() *message.VariablesMetadata
@@ -102,6 +170,7 @@ type PreparedData interface {
 type preparedDataImpl struct {
 	originPreparedId        []byte
 	targetPreparedId        []byte
+	targetResultMetadataId  []byte
 	prepareRequestInfo      *PrepareRequestInfo
 	originVariablesMetadata *message.VariablesMetadata
 	targetVariablesMetadata *message.VariablesMetadata
@@ -113,6 +182,7 @@ func NewPreparedData(
 	return &preparedDataImpl{
 		originPreparedId:        originPreparedResult.PreparedQueryId,
 		targetPreparedId:        targetPreparedResult.PreparedQueryId,
+		targetResultMetadataId:  targetPreparedResult.ResultMetadataId,
 		prepareRequestInfo:      prepareRequestInfo,
 		originVariablesMetadata: originPreparedResult.VariablesMetadata,
 		targetVariablesMetadata: targetPreparedResult.VariablesMetadata,
@@ -127,6 +197,10 @@ func (recv *preparedDataImpl) GetTargetPreparedId() []byte {
 	return recv.targetPreparedId
 }
 
+func (recv *preparedDataImpl) GetTargetResultMetadataId() []byte {
+	return recv.targetResultMetadataId
+}
+
 func (recv *preparedDataImpl) GetPrepareRequestInfo() *PrepareRequestInfo {
 	return recv.prepareRequestInfo
 }
@@ -0,0 +1,86 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestPreparedStatementCache_Eviction(t *testing.T) {
+	psCache := NewPreparedStatementCache(2)
+	store := func(id string) {
+		psCache.Store(
+			&message.PreparedResult{PreparedQueryId: []byte("origin_" + id)},
+			&message.PreparedResult{PreparedQueryId: []byte("target_" + id)},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "SELECT "+id, ""))
+	}
+
+	store("1")
+	store("2")
+	_, ok := psCache.Get([]byte("origin_1"))
+	require.True(t, ok)
+
+	store("3")
+	require.Equal(t, float64(2), psCache.GetPreparedStatementCacheSize())
+	_, ok = psCache.Get([]byte("origin_2"))
+	require.False(t, ok)
+	_, ok = psCache.GetByTargetPreparedId([]byte("target_2"))
+	require.False(t, ok)
+	for _, id := range []string{"1", "3"} {
+		_, ok = psCache.Get([]byte("origin_" + id))
+		require.True(t, ok, id)
+		_, ok = psCache.GetByTargetPreparedId([]byte("target_" + id))
+		require.True(t, ok, id)
+	}
+
+	psCache.StoreIntercepted(
+		&message.PreparedResult{PreparedQueryId: []byte("intercepted")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToNone, false, false), nil, false, "SELECT * FROM system.local", ""))
+	_, ok = psCache.Get([]byte("origin_1"))
+	require.True(t, ok)
+	_, ok = psCache.Get([]byte("origin_3"))
+	require.True(t, ok)
+}
+
+func TestPreparedStatementCache_Unbounded(t *testing.T) {
+	psCache := NewPreparedStatementCache(0)
+	for i := 0; i < 100; i++ {
+		psCache.Store(
+			&message.PreparedResult{PreparedQueryId: []byte(fmt.Sprintf("origin_%v", i))},
+			&message.PreparedResult{PreparedQueryId: []byte(fmt.Sprintf("target_%v", i))},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "SELECT 1", ""))
+	}
+	require.Equal(t, float64(100), psCache.GetPreparedStatementCacheSize())
+}
+
+func TestPreparedStatementCache_ConcurrentGet(t *testing.T) {
+	psCache := NewPreparedStatementCache(10)
+	store := func(id int) {
+		psCache.Store(
+			&message.PreparedResult{PreparedQueryId: []byte(fmt.Sprintf("origin_%v", id))},
+			&message.PreparedResult{PreparedQueryId: []byte(fmt.Sprintf("target_%v", id))},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "SELECT 1", ""))
+	}
+	store(0)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				_, ok := psCache.Get([]byte("origin_0"))
+				require.True(t, ok)
+			}
+		}()
+	}
+	for i := 1; i < 100; i++ {
+		store(i)
+		_, ok := psCache.Get([]byte("origin_0"))
+		require.True(t, ok)
+	}
+	wg.Wait()
+	require.Equal(t, float64(10), psCache.GetPreparedStatementCacheSize())
+}
@@ -0,0 +1,91 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// tableNameQualifier qualifies the table names of the statements sent to the target cluster with the keyspace of the
+// client connection (see ZDM_TARGET_QUALIFY_TABLE_NAMES) so that these statements don't depend on the keyspace of the
+// target connection, which is set by forwarding the client's USE requests.
+//
+// The table names of QUERY, PREPARE and BATCH requests are qualified, EXECUTE requests use the prepared id returned
+// by the target cluster for the qualified PREPARE request. Statements that the proxy can not parse (e.g. DDL
+// statements) are not modified.
+type tableNameQualifier struct {
+}
+
+// newTableNameQualifier returns nil if the feature is disabled.
+func newTableNameQualifier(enabled bool) *tableNameQualifier {
+	if !enabled {
+		return nil
+	}
+	return &tableNameQualifier{}
+}
+
+// Apply returns a copy of the provided request with its table names qualified or the provided request if there is
+// nothing to qualify. The keyspace of the request (protocol v5) has precedence over the keyspace of the connection.
+func (recv *tableNameQualifier) Apply(f *frame.RawFrame, currentKeyspace string) (*frame.RawFrame, error) {
+	if recv == nil || f == nil {
+		return f, nil
+	}
+	switch f.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodePrepare, primitive.OpCodeBatch:
+	default:
+		return f, nil
+	}
+
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %v request to qualify table names: %w", f.Header.OpCode, err)
+	}
+
+	qualified := false
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		keyspace := currentKeyspace
+		if msg.Options != nil && msg.Options.Keyspace != "" {
+			keyspace = msg.Options.Keyspace
+		}
+		qualified = qualifyQueryTableNames(&msg.Query, keyspace)
+	case *message.Prepare:
+		keyspace := currentKeyspace
+		if msg.Keyspace != "" {
+			keyspace = msg.Keyspace
+		}
+		qualified = qualifyQueryTableNames(&msg.Query, keyspace)
+	case *message.Batch:
+		keyspace := currentKeyspace
+		if msg.Keyspace != "" {
+			keyspace = msg.Keyspace
+		}
+		for _, child := range msg.Children {
+			if len(child.Query) > 0 {
+				qualified = qualifyQueryTableNames(&child.Query, keyspace) || qualified
+			}
+		}
+	}
+	if !qualified {
+		return f, nil
+	}
+
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert %v request with qualified table names to raw frame: %w",
+			f.Header.OpCode, err)
+	}
+	return newRawFrame, nil
+}
+
+func qualifyQueryTableNames(query *string, keyspace string) bool {
+	if keyspace == "" {
+		return false
+	}
+	newQuery, qualified := inspectCqlQuery(*query, keyspace, nil).qualifyTableNames(keyspace)
+	if qualified {
+		*query = newQuery
+	}
+	return qualified
+}
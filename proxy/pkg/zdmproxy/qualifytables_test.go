@@ -0,0 +1,74 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTableNameQualifier_Apply(t *testing.T) {
+	qualifier := newTableNameQualifier(true)
+
+	tests := []struct {
+		name            string
+		msg             message.Message
+		expectedQueries []string
+		qualified       bool
+	}{
+		{"select", &message.Query{Query: "SELECT * FROM tb1 WHERE a = 1"},
+			[]string{`SELECT * FROM "ks1".tb1 WHERE a = 1`}, true},
+		{"qualified select", &message.Query{Query: "SELECT * FROM ks2.tb1 WHERE a = 1"},
+			[]string{"SELECT * FROM ks2.tb1 WHERE a = 1"}, false},
+		{"quoted table", &message.Query{Query: `UPDATE "Tb1" SET b = 'é' WHERE a = 1`},
+			[]string{`UPDATE "ks1"."Tb1" SET b = 'é' WHERE a = 1`}, true},
+		{"insert json", &message.Query{Query: `INSERT INTO tb1 JSON '{"a": 1}'`},
+			[]string{`INSERT INTO "ks1".tb1 JSON '{"a": 1}'`}, true},
+		{"unparseable", &message.Query{Query: "CREATE TABLE tb1 (a int PRIMARY KEY)"},
+			[]string{"CREATE TABLE tb1 (a int PRIMARY KEY)"}, false},
+		{"prepare", &message.Prepare{Query: "DELETE FROM tb1 WHERE a = ?"},
+			[]string{`DELETE FROM "ks1".tb1 WHERE a = ?`}, true},
+		{"batch", &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO tb1 (a) VALUES (1)"},
+			{Id: []byte{1}},
+			{Query: "INSERT INTO ks2.tb2 (a) VALUES (1)"}}},
+			[]string{`INSERT INTO "ks1".tb1 (a) VALUES (1)`, "", "INSERT INTO ks2.tb2 (a) VALUES (1)"}, true},
+		{"execute", &message.Execute{QueryId: []byte{1}, Options: &message.QueryOptions{}}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, tt.msg, primitive.ProtocolVersion4)
+			actual, err := qualifier.Apply(f, "ks1")
+			require.Nil(t, err)
+			if !tt.qualified {
+				require.Same(t, f, actual)
+			}
+
+			decoded, err := defaultCodec.ConvertFromRawFrame(actual)
+			require.Nil(t, err)
+			var queries []string
+			switch msg := decoded.Body.Message.(type) {
+			case *message.Query:
+				queries = []string{msg.Query}
+			case *message.Prepare:
+				queries = []string{msg.Query}
+			case *message.Batch:
+				for _, child := range msg.Children {
+					queries = append(queries, child.Query)
+				}
+			}
+			require.Equal(t, tt.expectedQueries, queries)
+		})
+	}
+
+	f := mockFrame(t, &message.Query{Query: "SELECT * FROM tb1"}, primitive.ProtocolVersion4)
+	actual, err := qualifier.Apply(f, "")
+	require.Nil(t, err)
+	require.Same(t, f, actual)
+
+	var disabled *tableNameQualifier
+	require.Nil(t, newTableNameQualifier(false))
+	actual, err = disabled.Apply(f, "ks1")
+	require.Nil(t, err)
+	require.Same(t, f, actual)
+}
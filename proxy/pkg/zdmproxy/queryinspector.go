@@ -86,6 +86,15 @@ type QueryInfo interface {
 	// This will always be false for non-INSERT statements or batches not containing INSERT statements.
 	hasNowFunctionCalls() bool
 
+	// Returns the query with its unqualified table names (i.e. without a keyspace) qualified with the provided
+	// keyspace and true, or the query and false if all the table names are already qualified. This is only supported
+	// for queries returned by inspectCqlQuery, not for queries with replaced function calls.
+	qualifyTableNames(keyspace string) (string, bool)
+
+	// Whether the statement can be applied more than once with the same result, see isIdempotent in idempotency.go.
+	// Statements that the proxy can not parse are never idempotent.
+	isIdempotent() bool
+
 	replaceNowFunctionCallsWithLiteral() (QueryInfo, []*term)
 	replaceNowFunctionCallsWithPositionalBindMarkers() (QueryInfo, []*term)
 	replaceNowFunctionCallsWithNamedBindMarkers() (QueryInfo, []*term)
@@ -107,9 +116,81 @@ func inspectCqlQuery(query string, currentKeyspace string, timeUuidGenerator Tim
 		requestKeyspace:   currentKeyspace,
 	}
 	antlr.ParseTreeWalkerDefault.Walk(listener, cqlParser.CqlStatement())
+	if listener.statementType == statementTypeOther {
+		lexer.SetInputStream(antlr.NewInputStream(query))
+		listener.inspectInsertJsonStatement(lexer.GetAllTokens())
+	}
 	return listener
 }
 
+// inspectInsertJsonStatement recognizes INSERT INTO [keyspace.]table JSON ... statements, which are not part of the
+// simplified CQL grammar, from the tokens of the query. Only the statement type, keyspace and table are extracted:
+// the values of a JSON INSERT are a single string literal (or bind marker) so there are no function calls to replace.
+func (l *cqlListener) inspectInsertJsonStatement(tokens []antlr.Token) {
+	defaultChannelTokens := make([]antlr.Token, 0, len(tokens))
+	for _, token := range tokens {
+		if token.GetChannel() == antlr.TokenDefaultChannel {
+			defaultChannelTokens = append(defaultChannelTokens, token)
+		}
+	}
+	tokens = defaultChannelTokens
+
+	if len(tokens) < 4 ||
+		tokens[0].GetTokenType() != parser.SimplifiedCqlLexerK_INSERT ||
+		tokens[1].GetTokenType() != parser.SimplifiedCqlLexerK_INTO {
+		return
+	}
+
+	var keyspaceToken antlr.Token
+	tableToken := tokens[2]
+	jsonIdx := 3
+	if tokens[3].GetText() == "." {
+		if len(tokens) < 6 {
+			return
+		}
+		keyspaceToken = tokens[2]
+		tableToken = tokens[4]
+		jsonIdx = 5
+	}
+	if tokens[jsonIdx].GetTokenType() != parser.SimplifiedCqlLexerK_JSON ||
+		!isIdentifierToken(tableToken) || (keyspaceToken != nil && !isIdentifierToken(keyspaceToken)) {
+		return
+	}
+
+	l.statementType = statementTypeInsert
+	for _, token := range tokens[jsonIdx:] {
+		if token.GetTokenType() == parser.SimplifiedCqlLexerK_IF {
+			l.nonIdempotent = true
+		}
+	}
+	if keyspaceToken != nil {
+		l.keyspaceName = extractIdentifierToken(keyspaceToken)
+	} else {
+		l.unqualifiedTableNames = append(l.unqualifiedTableNames, tableToken.GetStart())
+	}
+	l.tableName = extractIdentifierToken(tableToken)
+	l.parsedStatements = append(l.parsedStatements, &parsedStatement{
+		statementIndex: l.currentBatchChildIndex,
+		statementType:  statementTypeInsert,
+	})
+	l.currentBatchChildIndex++
+}
+
+// isIdentifierToken returns true for quoted and unquoted identifiers and for keywords (which are valid identifiers
+// if they are unreserved).
+func isIdentifierToken(token antlr.Token) bool {
+	switch token.GetTokenType() {
+	case parser.SimplifiedCqlLexerQUOTED_IDENTIFIER, parser.SimplifiedCqlLexerUNQUOTED_IDENTIFIER:
+		return true
+	}
+	text := token.GetText()
+	if text == "" {
+		return false
+	}
+	first := text[0]
+	return (first >= 'a' && first <= 'z') || (first >= 'A' && first <= 'Z')
+}
+
 type functionCall struct {
 	keyspace   string
 	name       string
@@ -319,6 +400,13 @@ type cqlListener struct {
 	namedBindMarkers      bool
 	nowFunctionCalls      bool
 
+	// Set for LWTs, counter and list updates and calls of non-deterministic functions other than the now() function
+	// calls that can be replaced (see nowFunctionCalls)
+	nonIdempotent bool
+
+	// Positions (in runes) of the table names that are not qualified with a keyspace
+	unqualifiedTableNames []int
+
 	// internal counters
 	currentPositionalIndex int
 	currentBatchChildIndex int
@@ -376,6 +464,26 @@ func (l *cqlListener) hasNowFunctionCalls() bool {
 	return l.nowFunctionCalls
 }
 
+func (l *cqlListener) qualifyTableNames(keyspace string) (string, bool) {
+	if len(l.unqualifiedTableNames) == 0 {
+		return l.query, false
+	}
+	prefix := []rune(fmt.Sprintf("\"%v\".", strings.ReplaceAll(keyspace, "\"", "\"\"")))
+	query := []rune(l.query)
+	newQuery := make([]rune, 0, len(query)+len(prefix)*len(l.unqualifiedTableNames))
+	previous := 0
+	for _, position := range l.unqualifiedTableNames {
+		if position < previous || position > len(query) {
+			return l.query, false
+		}
+		newQuery = append(newQuery, query[previous:position]...)
+		newQuery = append(newQuery, prefix...)
+		previous = position
+	}
+	newQuery = append(newQuery, query[previous:]...)
+	return string(newQuery), true
+}
+
 func (l *cqlListener) EnterCqlStatement(ctx *parser.CqlStatementContext) {
 	if ctx.GetChildCount() == 0 {
 		return
@@ -530,6 +638,7 @@ func (l *cqlListener) EnterTableName(ctx *parser.TableNameContext) {
 	if qualifiedId.GetChildCount() == 1 {
 		identifierContext := qualifiedId.GetChild(0).(*parser.IdentifierContext)
 		l.tableName = extractIdentifier(identifierContext)
+		l.unqualifiedTableNames = append(l.unqualifiedTableNames, identifierContext.GetStart().GetStart())
 	} else {
 		// 3 children: keyspaceName, token DOT, identifier
 		keyspaceNameContext := qualifiedId.GetChild(0)
@@ -803,6 +912,16 @@ func extractIdentifier(identifierContext *parser.IdentifierContext) string {
 	}
 }
 
+// Returns the identifier of the token in its internal form, see extractIdentifier.
+func extractIdentifierToken(token antlr.Token) string {
+	identifier := token.GetText()
+	if token.GetTokenType() != parser.SimplifiedCqlLexerQUOTED_IDENTIFIER {
+		return strings.ToLower(identifier)
+	}
+	identifier = identifier[1 : len(identifier)-1]
+	return strings.ReplaceAll(identifier, "\"\"", "\"")
+}
+
 func (l *cqlListener) replaceFunctionCalls(replacementFunc func(query string, functionCall *functionCall) (string, replacementType)) (QueryInfo, []*term) {
 	if !l.hasNowFunctionCalls() {
 		return l, make([]*term, 0)
@@ -900,6 +1019,7 @@ func (l *cqlListener) shallowClone() *cqlListener {
 		positionalBindMarkers:     l.positionalBindMarkers,
 		namedBindMarkers:          l.namedBindMarkers,
 		nowFunctionCalls:          l.nowFunctionCalls,
+		nonIdempotent:             l.nonIdempotent,
 		currentPositionalIndex:    l.currentPositionalIndex,
 		currentBatchChildIndex:    l.currentBatchChildIndex,
 		timeUuidGenerator:         l.timeUuidGenerator,
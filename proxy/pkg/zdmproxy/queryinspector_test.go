@@ -202,6 +202,27 @@ func TestInspectCqlQuery(t *testing.T) {
 			"MyKeyspace",
 			"MyTable",
 		},
+		{
+			"JSON INSERT",
+			"INSERT INTO table1 JSON '{\"foo\": 1}'",
+			statementTypeInsert,
+			"",
+			"table1",
+		},
+		{
+			"qualified JSON INSERT",
+			"insert into \"MyKeyspace\" . \"My\"\"Table\" json ? DEFAULT UNSET USING TTL ?",
+			statementTypeInsert,
+			"MyKeyspace",
+			"My\"Table",
+		},
+		{
+			"JSON INSERT with unreserved keywords",
+			"/* comment */ INSERT INTO json.ttl JSON $$ {} $$ IF NOT EXISTS;",
+			statementTypeInsert,
+			"json",
+			"ttl",
+		},
 		// UPDATE
 		{
 			"simple UPDATE",
@@ -255,10 +276,17 @@ func TestInspectCqlQuery(t *testing.T) {
 			"ks1",
 			"table3",
 		},
+		{
+			"UPDATE USING TTL bind marker",
+			"UPDATE \"ks1\".table1 USING TTL ? SET foo = ? WHERE bar = ?",
+			statementTypeUpdate,
+			"ks1",
+			"table1",
+		},
 		// UNRECOGNIZED
 		{
-			"INSERT JSON",
-			"INSERT INTO table1 JSON '{}'",
+			"incomplete INSERT JSON",
+			"INSERT INTO JSON '{}'",
 			statementTypeOther,
 			"",
 			"",
@@ -247,3 +247,27 @@ func contains(s []int, e int) bool {
 	}
 	return false
 }
+
+func TestReplaceQueryString_CustomPayload(t *testing.T) {
+	customPayload := map[string][]byte{"key": {1, 2, 3}}
+	f := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{
+		Query:   "INSERT INTO blah (a, b) VALUES (now(), 1)",
+		Options: &message.QueryOptions{},
+	})
+	f.SetCustomPayload(customPayload)
+	rawFrame, err := defaultCodec.ConvertToRawFrame(f)
+	require.Nil(t, err)
+
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	newContext, statementsReplacedTerms, err := NewQueryModifier(timeUuidGenerator).replaceQueryString(
+		"", &frameDecodeContext{frame: rawFrame})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(statementsReplacedTerms))
+
+	newFrame, err := defaultCodec.ConvertFromRawFrame(newContext.GetRawFrame())
+	require.Nil(t, err)
+	require.NotEqual(t, f.Body.Message, newFrame.Body.Message)
+	require.Equal(t, customPayload, newFrame.Body.CustomPayload)
+	require.True(t, newFrame.Header.Flags.Contains(primitive.HeaderFlagCustomPayload))
+}
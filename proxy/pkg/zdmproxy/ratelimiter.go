@@ -0,0 +1,51 @@
+package zdmproxy
+
+import (
+	"time"
+)
+
+// requestRateLimiter is a token bucket that limits the number of requests per second of a client connection.
+// The bucket holds up to one second worth of requests so short bursts are allowed.
+//
+// It is not safe for concurrent use, requests of a client connection are handled by a single goroutine.
+type requestRateLimiter struct {
+	requestsPerSecond float64
+	tokens            float64
+	lastRefill        time.Time
+	now               func() time.Time
+}
+
+// newRequestRateLimiter returns nil if requestsPerSecond is not positive, a nil limiter allows every request.
+func newRequestRateLimiter(requestsPerSecond int, now func() time.Time) *requestRateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return &requestRateLimiter{
+		requestsPerSecond: float64(requestsPerSecond),
+		tokens:            float64(requestsPerSecond),
+		lastRefill:        now(),
+		now:               now,
+	}
+}
+
+func (recv *requestRateLimiter) Allow() bool {
+	if recv == nil {
+		return true
+	}
+
+	now := recv.now()
+	elapsed := now.Sub(recv.lastRefill)
+	if elapsed > 0 {
+		recv.tokens += elapsed.Seconds() * recv.requestsPerSecond
+		if recv.tokens > recv.requestsPerSecond {
+			recv.tokens = recv.requestsPerSecond
+		}
+		recv.lastRefill = now
+	}
+
+	if recv.tokens < 1 {
+		return false
+	}
+	recv.tokens--
+	return true
+}
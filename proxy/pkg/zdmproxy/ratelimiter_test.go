@@ -0,0 +1,36 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRequestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRequestRateLimiter(10, func() time.Time { return now })
+
+	for i := 0; i < 10; i++ {
+		require.True(t, limiter.Allow())
+	}
+	require.False(t, limiter.Allow())
+
+	now = now.Add(100 * time.Millisecond)
+	require.True(t, limiter.Allow())
+	require.False(t, limiter.Allow())
+
+	// tokens don't accumulate above one second worth of requests
+	now = now.Add(time.Minute)
+	for i := 0; i < 10; i++ {
+		require.True(t, limiter.Allow())
+	}
+	require.False(t, limiter.Allow())
+}
+
+func TestRequestRateLimiter_Disabled(t *testing.T) {
+	limiter := newRequestRateLimiter(0, time.Now)
+	require.Nil(t, limiter)
+	for i := 0; i < 100; i++ {
+		require.True(t, limiter.Allow())
+	}
+}
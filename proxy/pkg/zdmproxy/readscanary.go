@@ -0,0 +1,59 @@
+package zdmproxy
+
+import (
+	"math/rand"
+)
+
+// isTargetReadsCanaryCandidate returns true if the request is a read on a user table that is sent to ORIGIN, system
+// queries and the PREPARE requests of reads are not candidates.
+func isTargetReadsCanaryCandidate(requestInfo RequestInfo) bool {
+	return requestInfo.GetForwardDecision() == forwardToOrigin &&
+		requestInfo.ShouldAlsoBeSentAsync() &&
+		isDualRead(requestInfo)
+}
+
+// routeReadToTarget returns a request info that sends the provided read to TARGET instead of ORIGIN.
+// The read is not sent to the async connector because it is connected to TARGET as well.
+func routeReadToTarget(requestInfo RequestInfo) RequestInfo {
+	targetRequestInfo := NewGenericRequestInfo(forwardToTarget, false, requestInfo.ShouldBeTrackedInMetrics())
+	if executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo); ok {
+		return NewExecuteRequestInfoWithBaseRequestInfo(executeRequestInfo.GetPreparedData(), targetRequestInfo)
+	}
+	return targetRequestInfo
+}
+
+// targetReadsCanary decides which reads are sent to TARGET while ORIGIN is the primary cluster based on
+// ZDM_TARGET_READS_CANARY_PERCENT. The decision is made for each read or, with ZDM_TARGET_READS_CANARY_PER_CONNECTION,
+// once for all the reads of a client connection.
+type targetReadsCanary struct {
+	percent            int
+	selectedConnection bool
+	perConnection      bool
+}
+
+func newTargetReadsCanary(percent int, perConnection bool) *targetReadsCanary {
+	return &targetReadsCanary{
+		percent:            percent,
+		selectedConnection: perConnection && isSelectedByCanary(percent),
+		perConnection:      perConnection,
+	}
+}
+
+func (recv *targetReadsCanary) Apply(requestInfo RequestInfo) RequestInfo {
+	if recv.percent <= 0 || !isTargetReadsCanaryCandidate(requestInfo) {
+		return requestInfo
+	}
+
+	selected := recv.selectedConnection
+	if !recv.perConnection {
+		selected = isSelectedByCanary(recv.percent)
+	}
+	if !selected {
+		return requestInfo
+	}
+	return routeReadToTarget(requestInfo)
+}
+
+func isSelectedByCanary(percent int) bool {
+	return percent >= 100 || (percent > 0 && rand.Intn(100) < percent)
+}
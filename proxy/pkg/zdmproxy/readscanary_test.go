@@ -0,0 +1,50 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTargetReadsCanary(t *testing.T) {
+	read := NewGenericRequestInfo(forwardToOrigin, true, true)
+	systemRead := NewGenericRequestInfo(forwardToOrigin, false, false)
+	write := NewGenericRequestInfo(forwardToBoth, false, true)
+	prepare := NewPrepareRequestInfo(read, nil, false, "SELECT * FROM ks.tb", "")
+	execute := NewExecuteRequestInfo(NewPreparedData(
+		&message.PreparedResult{PreparedQueryId: []byte{1}},
+		&message.PreparedResult{PreparedQueryId: []byte{2}},
+		prepare))
+
+	for _, perConnection := range []bool{false, true} {
+		none := newTargetReadsCanary(0, perConnection)
+		all := newTargetReadsCanary(100, perConnection)
+
+		require.Equal(t, read, none.Apply(read))
+		require.Equal(t, execute, none.Apply(execute))
+
+		require.Equal(t, NewGenericRequestInfo(forwardToTarget, false, true), all.Apply(read))
+		require.Equal(t, systemRead, all.Apply(systemRead))
+		require.Equal(t, write, all.Apply(write))
+		require.Equal(t, prepare, all.Apply(prepare))
+
+		canaryExecute := all.Apply(execute)
+		require.IsType(t, &ExecuteRequestInfo{}, canaryExecute)
+		require.Equal(t, forwardToTarget, canaryExecute.GetForwardDecision())
+		require.False(t, canaryExecute.ShouldAlsoBeSentAsync())
+		require.True(t, canaryExecute.ShouldBeTrackedInMetrics())
+		require.Equal(t, execute.GetPreparedData(), canaryExecute.(*ExecuteRequestInfo).GetPreparedData())
+		require.Equal(t, forwardToOrigin, execute.GetForwardDecision())
+	}
+}
+
+func TestTargetReadsCanary_PerConnection(t *testing.T) {
+	read := NewGenericRequestInfo(forwardToOrigin, true, true)
+	for i := 0; i < 20; i++ {
+		canary := newTargetReadsCanary(50, true)
+		expected := canary.Apply(read).GetForwardDecision()
+		for j := 0; j < 20; j++ {
+			require.Equal(t, expected, canary.Apply(read).GetForwardDecision())
+		}
+	}
+}
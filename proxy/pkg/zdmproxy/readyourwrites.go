@@ -0,0 +1,103 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"sync"
+)
+
+// readYourWrites delays the reads that a client connection sends to the target cluster until the writes that the client
+// connection sent before them are done (see ZDM_TARGET_READ_YOUR_WRITES). Without it, a read that is routed to the
+// target cluster (primary cluster, canary reads or keyspace overrides) right after a write can be sent to the target
+// cluster before the write, e.g. when the client doesn't wait for the write response, and return stale data.
+//
+// A write is done when the proxy got both responses or when it timed out. The delayed reads are not sent from a request
+// worker that waits for the writes because the write responses are processed by the same workers, they are sent by the
+// worker that completes the last of these writes instead. A delayed read that timed out while it was waiting is not
+// sent.
+type readYourWrites struct {
+	lock          sync.Mutex
+	lastWrite     uint64
+	pendingWrites map[uint64]bool
+	pendingReads  []*pendingTargetRead
+}
+
+type pendingTargetRead struct {
+	lastWrite uint64
+	send      func()
+}
+
+// newReadYourWrites returns nil if the feature is disabled.
+func newReadYourWrites(enabled bool) *readYourWrites {
+	if !enabled {
+		return nil
+	}
+	return &readYourWrites{pendingWrites: make(map[uint64]bool)}
+}
+
+// BeginWrite returns the sequence number of a new write that has to be passed to EndWrite once the write is done.
+func (recv *readYourWrites) BeginWrite() uint64 {
+	if recv == nil {
+		return 0
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.lastWrite++
+	recv.pendingWrites[recv.lastWrite] = true
+	return recv.lastWrite
+}
+
+// EndWrite marks a write as done and sends the reads that were waiting for it (and for the writes before it).
+func (recv *readYourWrites) EndWrite(write uint64) {
+	if recv == nil || write == 0 {
+		return
+	}
+	recv.lock.Lock()
+	delete(recv.pendingWrites, write)
+	oldestPendingWrite := recv.lastWrite + 1
+	for pendingWrite := range recv.pendingWrites {
+		if pendingWrite < oldestPendingWrite {
+			oldestPendingWrite = pendingWrite
+		}
+	}
+	// reads are ordered by lastWrite so the reads that can be sent are always the first ones
+	ready := 0
+	for ready < len(recv.pendingReads) && recv.pendingReads[ready].lastWrite < oldestPendingWrite {
+		ready++
+	}
+	readyReads := recv.pendingReads[:ready]
+	recv.pendingReads = recv.pendingReads[ready:]
+	recv.lock.Unlock()
+
+	for _, read := range readyReads {
+		read.send()
+	}
+}
+
+// SendRead calls send right away if there are no pending writes, otherwise send is called once the writes that are
+// pending now are done.
+func (recv *readYourWrites) SendRead(send func()) {
+	if recv == nil {
+		send()
+		return
+	}
+	recv.lock.Lock()
+	if len(recv.pendingWrites) == 0 {
+		recv.lock.Unlock()
+		send()
+		return
+	}
+	recv.pendingReads = append(recv.pendingReads, &pendingTargetRead{lastWrite: recv.lastWrite, send: send})
+	recv.lock.Unlock()
+}
+
+// isReadYourWritesRequest returns true for the requests that are tracked as writes (when sent to both clusters) or
+// delayed as reads (when sent to the target cluster only).
+func isReadYourWritesRequest(f *frame.RawFrame) bool {
+	switch f.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	var sent []string
+	read := func(name string) func() {
+		return func() { sent = append(sent, name) }
+	}
+
+	var disabled *readYourWrites
+	require.Nil(t, newReadYourWrites(false))
+	require.Equal(t, uint64(0), disabled.BeginWrite())
+	disabled.EndWrite(0)
+	disabled.SendRead(read("disabled"))
+	require.Equal(t, []string{"disabled"}, sent)
+
+	sent = nil
+	tracker := newReadYourWrites(true)
+	tracker.SendRead(read("no writes"))
+	require.Equal(t, []string{"no writes"}, sent)
+
+	write1 := tracker.BeginWrite()
+	write2 := tracker.BeginWrite()
+	tracker.SendRead(read("after write 2"))
+	write3 := tracker.BeginWrite()
+	tracker.SendRead(read("after write 3"))
+	require.Equal(t, []string{"no writes"}, sent)
+
+	// write 1 is still pending
+	tracker.EndWrite(write2)
+	require.Equal(t, []string{"no writes"}, sent)
+
+	// writes 1 and 2 are done, write 3 is pending
+	tracker.EndWrite(write1)
+	require.Equal(t, []string{"no writes", "after write 2"}, sent)
+
+	tracker.EndWrite(write3)
+	require.Equal(t, []string{"no writes", "after write 2", "after write 3"}, sent)
+
+	tracker.SendRead(read("no pending writes"))
+	require.Equal(t, []string{"no writes", "after write 2", "after write 3", "no pending writes"}, sent)
+}
@@ -99,6 +99,18 @@ type requestContextImpl struct {
 	lock                  *sync.Mutex
 	startTime             time.Time
 	customResponseChannel chan *customResponse
+	dualReadComparison    *dualReadComparison
+	statement             string // only set when the top statements are tracked
+	keyspace              string // keyspace and table of the statement for the log fields, if known
+	table                 string
+	targetWrite           uint64          // only set when ZDM_TARGET_READ_YOUR_WRITES is enabled
+	journalRequest        *frame.RawFrame // TARGET request and keyspace of writes whose TARGET failures are journaled
+	journalKeyspace       string
+	duplicateWriteKey     *duplicateWriteKey // only set for writes tracked by ZDM_TARGET_DUPLICATE_WRITES_WINDOW_MS
+	targetReprepare       *targetReprepare   // only set for EXECUTE and BATCH requests sent to both clusters
+	receivedTime          time.Time          // when the request was received from the client, see getProxyOverheadBegin
+	sentTime              time.Time          // when the request was sent to the cluster connectors
+	responseTime          time.Time          // when the last cluster response was received
 }
 
 func NewRequestContext(req *frame.RawFrame, requestInfo RequestInfo, startTime time.Time, customResponseChannel chan *customResponse) *requestContextImpl {
@@ -112,6 +124,7 @@ func NewRequestContext(req *frame.RawFrame, requestInfo RequestInfo, startTime t
 		lock:                  &sync.Mutex{},
 		startTime:             startTime,
 		customResponseChannel: customResponseChannel,
+		dualReadComparison:    nil,
 	}
 }
 
@@ -213,8 +226,32 @@ func (recv *requestContextImpl) SetResponse(nodeMetrics *metrics.NodeMetrics, f
 	return finished
 }
 
+// markSent records the time the request is sent to the cluster connectors, returns false if the request is not pending
+// anymore, i.e. it timed out or was canceled before it was sent, in which case it must not be sent.
+func (recv *requestContextImpl) markSent(now time.Time) bool {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.state != RequestPending {
+		return false
+	}
+	recv.sentTime = now
+	return true
+}
+
+// getProxyOverheadBegin returns the time from which the latency added by the proxy is tracked: the time the request
+// was received from the client plus the time spent waiting for the cluster responses. Returns false if the request
+// did not get all of its responses.
+func (recv *requestContextImpl) getProxyOverheadBegin() (time.Time, bool) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.state != RequestDone || recv.receivedTime.IsZero() || recv.sentTime.IsZero() {
+		return time.Time{}, false
+	}
+	return recv.receivedTime.Add(recv.responseTime.Sub(recv.sentTime)), true
+}
+
 func isWriteStatement(req RequestInfo) bool {
-	return req.GetForwardDecision() == forwardToBoth
+	return req.GetForwardDecision() == forwardToBoth || isSingleClusterWrite(req)
 }
 
 func (recv *requestContextImpl) updateInternalState(f *frame.RawFrame, cluster common.ClusterType) (state int, updated bool) {
@@ -253,30 +290,35 @@ func (recv *requestContextImpl) updateInternalState(f *frame.RawFrame, cluster c
 
 	if done {
 		recv.state = RequestDone
+		recv.responseTime = time.Now()
 	}
 
 	return recv.state, true
 }
 
 type asyncRequestContextImpl struct {
-	state            int
-	timer            *time.Timer
-	lock             *sync.Mutex
-	requestStreamId  int16
-	expectedResponse bool
-	startTime        time.Time
-	requestInfo      RequestInfo
+	state              int
+	timer              *time.Timer
+	lock               *sync.Mutex
+	requestStreamId    int16
+	expectedResponse   bool
+	startTime          time.Time
+	requestInfo        RequestInfo
+	dualReadComparison *dualReadComparison
 }
 
-func NewAsyncRequestContext(requestInfo RequestInfo, streamId int16, expectedResponse bool, startTime time.Time) *asyncRequestContextImpl {
+func NewAsyncRequestContext(
+	requestInfo RequestInfo, streamId int16, expectedResponse bool, startTime time.Time,
+	dualReadComparison *dualReadComparison) *asyncRequestContextImpl {
 	return &asyncRequestContextImpl{
-		state:            RequestPending,
-		timer:            nil,
-		lock:             &sync.Mutex{},
-		requestStreamId:  streamId,
-		expectedResponse: expectedResponse,
-		startTime:        startTime,
-		requestInfo:      requestInfo,
+		state:              RequestPending,
+		timer:              nil,
+		lock:               &sync.Mutex{},
+		requestStreamId:    streamId,
+		expectedResponse:   expectedResponse,
+		startTime:          startTime,
+		requestInfo:        requestInfo,
+		dualReadComparison: dualReadComparison,
 	}
 }
 
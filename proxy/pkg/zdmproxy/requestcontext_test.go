@@ -0,0 +1,97 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGetProxyOverheadBegin(t *testing.T) {
+	request := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+	response := mockFrame(t, &message.VoidResult{}, primitive.ProtocolVersion4)
+	nodeMetrics := &metrics.NodeMetrics{
+		OriginMetrics: &metrics.NodeMetricsInstance{ClientTimeouts: newFakeCounter(), WriteDurations: newFakeHistogram()},
+		TargetMetrics: &metrics.NodeMetricsInstance{ClientTimeouts: newFakeCounter(), WriteDurations: newFakeHistogram()},
+	}
+	receivedTime := time.Now().Add(-10 * time.Millisecond)
+
+	reqCtx := NewRequestContext(request, NewGenericRequestInfo(forwardToBoth, false, true), time.Now(), nil)
+	reqCtx.receivedTime = receivedTime
+	reqCtx.sentTime = receivedTime.Add(time.Millisecond)
+	_, ok := reqCtx.getProxyOverheadBegin()
+	require.False(t, ok)
+
+	require.False(t, reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeOrigin, ClusterConnectorTypeOrigin))
+	_, ok = reqCtx.getProxyOverheadBegin()
+	require.False(t, ok)
+
+	require.True(t, reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeTarget, ClusterConnectorTypeTarget))
+	begin, ok := reqCtx.getProxyOverheadBegin()
+	require.True(t, ok)
+	require.Equal(t, receivedTime.Add(reqCtx.responseTime.Sub(reqCtx.sentTime)), begin)
+	require.True(t, begin.After(receivedTime))
+
+	timedOutCtx := NewRequestContext(request, NewGenericRequestInfo(forwardToOrigin, false, true), time.Now(), nil)
+	timedOutCtx.receivedTime = receivedTime
+	timedOutCtx.sentTime = receivedTime
+	require.True(t, timedOutCtx.SetTimeout(nodeMetrics, request))
+	_, ok = timedOutCtx.getProxyOverheadBegin()
+	require.False(t, ok)
+}
+
+func TestMarkSent(t *testing.T) {
+	request := mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)")
+	nodeMetrics := &metrics.NodeMetrics{
+		OriginMetrics: &metrics.NodeMetricsInstance{ClientTimeouts: newFakeCounter()},
+		TargetMetrics: &metrics.NodeMetricsInstance{ClientTimeouts: newFakeCounter()},
+	}
+	now := time.Now()
+
+	reqCtx := NewRequestContext(request, NewGenericRequestInfo(forwardToBoth, false, true), now, nil)
+	require.True(t, reqCtx.markSent(now))
+	require.Equal(t, now, reqCtx.sentTime)
+
+	timedOutCtx := NewRequestContext(request, NewGenericRequestInfo(forwardToBoth, false, true), now, nil)
+	require.True(t, timedOutCtx.SetTimeout(nodeMetrics, request))
+	require.False(t, timedOutCtx.markSent(now))
+	require.True(t, timedOutCtx.sentTime.IsZero())
+
+	canceledCtx := NewRequestContext(request, NewGenericRequestInfo(forwardToBoth, false, true), now, nil)
+	require.True(t, canceledCtx.Cancel(nodeMetrics))
+	require.False(t, canceledCtx.markSent(now))
+}
+
+func TestIsWriteStatement(t *testing.T) {
+	prepared := func(base RequestInfo) PreparedData {
+		return NewPreparedData(&message.PreparedResult{}, &message.PreparedResult{}, NewPrepareRequestInfo(base, nil, false, "", ""))
+	}
+	tests := []struct {
+		name        string
+		requestInfo RequestInfo
+		write       bool
+	}{
+		{"read", NewGenericRequestInfo(forwardToOrigin, true, true), false},
+		{"dual write", NewGenericRequestInfo(forwardToBoth, false, true), true},
+		{"excluded table read", NewExcludedTableRequestInfo(), false},
+		{"excluded table write", NewExcludedTableWriteRequestInfo(), true},
+		{"origin only write", newOriginOnlyRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true)), true},
+		{"write policy origin", NewTableWritePolicyRequestInfo(forwardToOrigin, writeFailuresFatal), true},
+		{"write policy target", NewTableWritePolicyRequestInfo(forwardToTarget, writeFailuresFatal), true},
+		{"origin only batch", NewOriginOnlyBatchRequestInfo(nil), true},
+		{"execute read", NewExecuteRequestInfo(prepared(NewGenericRequestInfo(forwardToTarget, true, true))), false},
+		{"execute excluded table write", NewExecuteRequestInfo(prepared(NewExcludedTableWriteRequestInfo())), true},
+		{"execute origin only write", newOriginOnlyRequestInfo(
+			NewExecuteRequestInfo(prepared(NewGenericRequestInfo(forwardToBoth, false, true)))), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.write, isWriteStatement(tt.requestInfo))
+			require.Equal(t, tt.write && tt.requestInfo.GetForwardDecision() != forwardToBoth,
+				isSingleClusterWrite(tt.requestInfo))
+		})
+	}
+}
@@ -43,6 +43,62 @@ func (recv *GenericRequestInfo) String() string {
 		recv.forwardDecision, recv.shouldAlsoBeSentAsync, recv.trackMetrics)
 }
 
+// ExcludedTableRequestInfo is used for requests on tables that are out of the scope of the migration
+// (see ZDM_INCLUDED_TABLES and ZDM_EXCLUDED_TABLES), these are only sent to ORIGIN.
+type ExcludedTableRequestInfo struct {
+	*baseRequestInfo
+	write bool
+}
+
+func NewExcludedTableRequestInfo() *ExcludedTableRequestInfo {
+	return &ExcludedTableRequestInfo{baseRequestInfo: newBaseRequestInfo(forwardToOrigin, false, true)}
+}
+
+// NewExcludedTableWriteRequestInfo is used for writes on tables that are out of the scope of the migration so that
+// they are tracked with the write metrics.
+func NewExcludedTableWriteRequestInfo() *ExcludedTableRequestInfo {
+	return &ExcludedTableRequestInfo{baseRequestInfo: newBaseRequestInfo(forwardToOrigin, false, true), write: true}
+}
+
+func (recv *ExcludedTableRequestInfo) String() string {
+	return fmt.Sprintf("ExcludedTableRequestInfo{write: %v}", recv.write)
+}
+
+// ExcludedKeyspaceUseRequestInfo is used for USE requests of a keyspace that is out of the scope of the migration. They
+// are sent to both clusters (and to the async connector) like other USE requests so that the keyspace of the cluster
+// connections is the same but the keyspace might not exist on TARGET so the ORIGIN response is always returned.
+type ExcludedKeyspaceUseRequestInfo struct {
+	*baseRequestInfo
+}
+
+func NewExcludedKeyspaceUseRequestInfo() *ExcludedKeyspaceUseRequestInfo {
+	return &ExcludedKeyspaceUseRequestInfo{baseRequestInfo: newBaseRequestInfo(forwardToBoth, true, true)}
+}
+
+func (recv *ExcludedKeyspaceUseRequestInfo) String() string {
+	return "ExcludedKeyspaceUseRequestInfo{}"
+}
+
+// TableWritePolicyRequestInfo is used for writes on tables with a write policy (see ZDM_TABLE_WRITE_POLICIES) that
+// changes the clusters that the write is sent to or how failures are handled.
+type TableWritePolicyRequestInfo struct {
+	*baseRequestInfo
+	failures writeFailures
+}
+
+func NewTableWritePolicyRequestInfo(decision forwardDecision, failures writeFailures) *TableWritePolicyRequestInfo {
+	return &TableWritePolicyRequestInfo{baseRequestInfo: newBaseRequestInfo(decision, false, true), failures: failures}
+}
+
+func (recv *TableWritePolicyRequestInfo) String() string {
+	return fmt.Sprintf("TableWritePolicyRequestInfo{forwardDecision: %v, failures: %v}", recv.forwardDecision, recv.failures)
+}
+
+// GetWriteFailures returns how failures on the cluster that is not the primary cluster are handled.
+func (recv *TableWritePolicyRequestInfo) GetWriteFailures() writeFailures {
+	return recv.failures
+}
+
 type PrepareRequestInfo struct {
 	baseRequestInfo           RequestInfo
 	replacedTerms             []*term
@@ -90,6 +146,13 @@ func (recv *PrepareRequestInfo) GetForwardDecision() forwardDecision {
 	if recv.GetBaseRequestInfo().GetForwardDecision() == forwardToNone {
 		return forwardToNone // intercepted queries
 	}
+	if _, excluded := recv.GetBaseRequestInfo().(*ExcludedTableRequestInfo); excluded {
+		return forwardToOrigin // the table might not exist on TARGET
+	}
+	if writePolicyRequestInfo, ok := recv.GetBaseRequestInfo().(*TableWritePolicyRequestInfo); ok &&
+		writePolicyRequestInfo.GetForwardDecision() == forwardToOrigin {
+		return forwardToOrigin // the write is never sent to TARGET
+	}
 	return forwardToBoth // always send PREPARE to both, use origin's ID
 }
 
@@ -106,19 +169,33 @@ func (recv *PrepareRequestInfo) ContainsPositionalMarkers() bool {
 }
 
 type ExecuteRequestInfo struct {
-	preparedData PreparedData
+	preparedData    PreparedData
+	baseRequestInfo RequestInfo // overrides the base request info of the prepared statement if not nil
 }
 
 func NewExecuteRequestInfo(preparedData PreparedData) *ExecuteRequestInfo {
 	return &ExecuteRequestInfo{preparedData: preparedData}
 }
 
+// NewExecuteRequestInfoWithBaseRequestInfo is used when a bound statement has to be forwarded differently
+// than what was decided when the statement was prepared (e.g. reads that are sent to TARGET by the reads canary).
+func NewExecuteRequestInfoWithBaseRequestInfo(preparedData PreparedData, baseRequestInfo RequestInfo) *ExecuteRequestInfo {
+	return &ExecuteRequestInfo{preparedData: preparedData, baseRequestInfo: baseRequestInfo}
+}
+
 func (recv *ExecuteRequestInfo) String() string {
 	return fmt.Sprintf("ExecuteRequestInfo{PreparedData: %v}", recv.preparedData)
 }
 
+func (recv *ExecuteRequestInfo) getBaseRequestInfo() RequestInfo {
+	if recv.baseRequestInfo != nil {
+		return recv.baseRequestInfo
+	}
+	return recv.preparedData.GetPrepareRequestInfo().GetBaseRequestInfo()
+}
+
 func (recv *ExecuteRequestInfo) GetForwardDecision() forwardDecision {
-	return recv.preparedData.GetPrepareRequestInfo().GetBaseRequestInfo().GetForwardDecision()
+	return recv.getBaseRequestInfo().GetForwardDecision()
 }
 
 func (recv *ExecuteRequestInfo) GetPreparedData() PreparedData {
@@ -126,11 +203,11 @@ func (recv *ExecuteRequestInfo) GetPreparedData() PreparedData {
 }
 
 func (recv *ExecuteRequestInfo) ShouldAlsoBeSentAsync() bool {
-	return recv.preparedData.GetPrepareRequestInfo().GetBaseRequestInfo().ShouldAlsoBeSentAsync()
+	return recv.getBaseRequestInfo().ShouldAlsoBeSentAsync()
 }
 
 func (recv *ExecuteRequestInfo) ShouldBeTrackedInMetrics() bool {
-	return recv.preparedData.GetPrepareRequestInfo().GetBaseRequestInfo().ShouldBeTrackedInMetrics()
+	return recv.getBaseRequestInfo().ShouldBeTrackedInMetrics()
 }
 
 // InterceptedRequestInfo on its own means that this intercepted request is a QUERY request.
@@ -165,17 +242,44 @@ func (recv *InterceptedRequestInfo) GetParsedSelectClause() *selectClause {
 
 type BatchRequestInfo struct {
 	preparedDataByStmtIdx map[int]PreparedData
+	originOnly            bool
+	targetOnly            bool
+	failures              writeFailures
 }
 
 func NewBatchRequestInfo(preparedDataByStmtIdx map[int]PreparedData) *BatchRequestInfo {
 	return &BatchRequestInfo{preparedDataByStmtIdx: preparedDataByStmtIdx}
 }
 
+// NewOriginOnlyBatchRequestInfo is used for batches that are only sent to ORIGIN: batches where every statement is on
+// a table that is out of the scope of the migration or batches that are not sent to TARGET because of ZDM_DRY_RUN.
+func NewOriginOnlyBatchRequestInfo(preparedDataByStmtIdx map[int]PreparedData) *BatchRequestInfo {
+	return &BatchRequestInfo{preparedDataByStmtIdx: preparedDataByStmtIdx, originOnly: true}
+}
+
+// NewTableWritePolicyBatchRequestInfo is used for batches where every statement is on a table with a write policy
+// (see ZDM_TABLE_WRITE_POLICIES) that sends writes to the same cluster(s) or handles failures as best effort.
+func NewTableWritePolicyBatchRequestInfo(
+	preparedDataByStmtIdx map[int]PreparedData, decision forwardDecision, failures writeFailures) *BatchRequestInfo {
+	return &BatchRequestInfo{
+		preparedDataByStmtIdx: preparedDataByStmtIdx,
+		originOnly:            decision == forwardToOrigin,
+		targetOnly:            decision == forwardToTarget,
+		failures:              failures,
+	}
+}
+
 func (recv *BatchRequestInfo) String() string {
 	return fmt.Sprintf("BatchRequestInfo{PreparedDataByStmtIdx: %v}", recv.preparedDataByStmtIdx)
 }
 
 func (recv *BatchRequestInfo) GetForwardDecision() forwardDecision {
+	if recv.originOnly {
+		return forwardToOrigin
+	}
+	if recv.targetOnly {
+		return forwardToTarget
+	}
 	return forwardToBoth // always send BATCH to both, use origin's prepared IDs
 }
 
@@ -187,6 +291,56 @@ func (recv *BatchRequestInfo) ShouldBeTrackedInMetrics() bool {
 	return true
 }
 
+func (recv *BatchRequestInfo) GetWriteFailures() writeFailures {
+	return recv.failures
+}
+
 func (recv *BatchRequestInfo) GetPreparedDataByStmtIdx() map[int]PreparedData {
 	return recv.preparedDataByStmtIdx
 }
+
+// OriginOnlyWriteRequestInfo is used for writes that would be sent to both clusters but are only sent to ORIGIN (see
+// ZDM_DRY_RUN and ZDM_TARGET_DUPLICATE_WRITES_WINDOW_MS).
+type OriginOnlyWriteRequestInfo struct {
+	*baseRequestInfo
+}
+
+func NewOriginOnlyWriteRequestInfo(trackMetrics bool) *OriginOnlyWriteRequestInfo {
+	return &OriginOnlyWriteRequestInfo{baseRequestInfo: newBaseRequestInfo(forwardToOrigin, false, trackMetrics)}
+}
+
+func (recv *OriginOnlyWriteRequestInfo) String() string {
+	return fmt.Sprintf("OriginOnlyWriteRequestInfo{trackMetrics=%v}", recv.trackMetrics)
+}
+
+// newOriginOnlyRequestInfo returns a request info that sends the write of the provided request info only to ORIGIN.
+func newOriginOnlyRequestInfo(requestInfo RequestInfo) RequestInfo {
+	switch castedRequestInfo := requestInfo.(type) {
+	case *ExecuteRequestInfo:
+		return NewExecuteRequestInfoWithBaseRequestInfo(
+			castedRequestInfo.GetPreparedData(), NewOriginOnlyWriteRequestInfo(true))
+	case *BatchRequestInfo:
+		return NewOriginOnlyBatchRequestInfo(castedRequestInfo.GetPreparedDataByStmtIdx())
+	default:
+		return NewOriginOnlyWriteRequestInfo(requestInfo.ShouldBeTrackedInMetrics())
+	}
+}
+
+// isSingleClusterWrite returns true for the writes that are only sent to ORIGIN or only sent to TARGET, these are
+// tracked with the write metrics like the writes that are sent to both clusters.
+func isSingleClusterWrite(requestInfo RequestInfo) bool {
+	switch castedRequestInfo := requestInfo.(type) {
+	case *ExcludedTableRequestInfo:
+		return castedRequestInfo.write
+	case *OriginOnlyWriteRequestInfo:
+		return true
+	case *TableWritePolicyRequestInfo:
+		return castedRequestInfo.GetForwardDecision() != forwardToBoth
+	case *BatchRequestInfo:
+		return castedRequestInfo.GetForwardDecision() != forwardToBoth
+	case *ExecuteRequestInfo:
+		return isSingleClusterWrite(castedRequestInfo.getBaseRequestInfo())
+	default:
+		return false
+	}
+}
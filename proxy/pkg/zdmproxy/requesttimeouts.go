@@ -0,0 +1,106 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// tableRequestTimeouts overrides the request timeout (ZDM_PROXY_REQUEST_TIMEOUT_MS) of the requests on some keyspaces
+// or tables, see ZDM_TABLE_REQUEST_TIMEOUTS_MS. A table entry takes precedence over the entry of its keyspace.
+type tableRequestTimeouts struct {
+	timeouts map[string]time.Duration
+}
+
+// newTableRequestTimeouts returns nil if there are no overrides.
+func newTableRequestTimeouts(timeoutsMs map[string]int) *tableRequestTimeouts {
+	if len(timeoutsMs) == 0 {
+		return nil
+	}
+	timeouts := make(map[string]time.Duration, len(timeoutsMs))
+	for name, timeoutMs := range timeoutsMs {
+		timeouts[name] = time.Duration(timeoutMs) * time.Millisecond
+	}
+	return &tableRequestTimeouts{timeouts: timeouts}
+}
+
+// Get returns the request timeout of the provided table if it is overridden. A nil object doesn't override anything.
+func (recv *tableRequestTimeouts) Get(keyspace string, table string) (time.Duration, bool) {
+	if recv == nil || keyspace == "" {
+		return 0, false
+	}
+	if table != "" {
+		if timeout, ok := recv.timeouts[keyspace+"."+table]; ok {
+			return timeout, true
+		}
+	}
+	timeout, ok := recv.timeouts[keyspace]
+	return timeout, ok
+}
+
+// getRequestTimeout returns the timeout of the provided QUERY, EXECUTE or BATCH request, i.e. the longest timeout of
+// the tables of its statements that have an override or defaultTimeout if none of them has one. Other requests and
+// requests that can't be inspected use defaultTimeout.
+func (recv *tableRequestTimeouts) getRequestTimeout(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	defaultTimeout time.Duration) time.Duration {
+	if recv == nil {
+		return defaultTimeout
+	}
+	var timeout time.Duration
+	found := false
+	add := func(queryInfo QueryInfo) {
+		if statementTimeout, ok := recv.Get(queryInfo.getApplicableKeyspace(), queryInfo.getTableName()); ok {
+			if !found || statementTimeout > timeout {
+				timeout = statementTimeout
+			}
+			found = true
+		}
+	}
+
+	addStatements := func() bool {
+		statementsQueryData, err := frameContext.GetOrInspectAllStatements(currentKeyspace, nil)
+		if err != nil {
+			log.Debugf("Could not inspect request to get its timeout: %v", err)
+			return false
+		}
+		for _, statementQueryData := range statementsQueryData {
+			add(statementQueryData.queryData)
+		}
+		return true
+	}
+
+	decodedFrame, err := frameContext.GetOrDecodeFrame()
+	if err != nil {
+		log.Debugf("Could not decode request to get its timeout: %v", err)
+		return defaultTimeout
+	}
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		if !addStatements() {
+			return defaultTimeout
+		}
+	case *message.Execute:
+		if executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo); ok {
+			add(inspectPreparedStatement(executeRequestInfo.GetPreparedData(), currentKeyspace))
+		}
+	case *message.Batch:
+		if !addStatements() {
+			return defaultTimeout
+		}
+		keyspace := currentKeyspace
+		if msg.Keyspace != "" {
+			keyspace = msg.Keyspace
+		}
+		if batchRequestInfo, ok := requestInfo.(*BatchRequestInfo); ok {
+			for _, preparedData := range batchRequestInfo.GetPreparedDataByStmtIdx() {
+				add(inspectPreparedStatement(preparedData, keyspace))
+			}
+		}
+	}
+
+	if !found {
+		return defaultTimeout
+	}
+	return timeout
+}
@@ -0,0 +1,53 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTableRequestTimeouts(t *testing.T) {
+	preparedData := NewPreparedData(
+		&message.PreparedResult{PreparedQueryId: []byte("origin")},
+		&message.PreparedResult{PreparedQueryId: []byte("target")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false,
+			"INSERT INTO tb2 (a) VALUES (?)", ""))
+	timeouts := newTableRequestTimeouts(map[string]int{"ks1": 2000, "ks1.tb2": 30000, "ks2.tb1": 500})
+	defaultTimeout := 10 * time.Second
+
+	tests := []struct {
+		name        string
+		msg         message.Message
+		requestInfo RequestInfo
+		timeouts    *tableRequestTimeouts
+		expected    time.Duration
+	}{
+		{"no overrides", &message.Query{Query: "SELECT * FROM tb1"}, nil, nil, defaultTimeout},
+		{"keyspace override", &message.Query{Query: "SELECT * FROM tb1"}, nil, timeouts, 2 * time.Second},
+		{"table override", &message.Query{Query: "INSERT INTO ks1.tb2 (a) VALUES (1)"}, nil, timeouts, 30 * time.Second},
+		{"query keyspace override", &message.Query{Query: "SELECT * FROM tb1",
+			Options: &message.QueryOptions{Keyspace: "ks2"}}, nil, timeouts, 500 * time.Millisecond},
+		{"table without override", &message.Query{Query: "SELECT * FROM ks2.tb2"}, nil, timeouts, defaultTimeout},
+		{"not a statement on a table", &message.Query{Query: "USE ks2"}, nil, timeouts, defaultTimeout},
+		{"execute", &message.Execute{QueryId: []byte("target"), ResultMetadataId: []byte("metadata")},
+			NewExecuteRequestInfo(preparedData), timeouts, 30 * time.Second},
+		{"batch uses the longest timeout", &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO ks2.tb1 (a) VALUES (1)"}, {Query: "INSERT INTO tb1 (a) VALUES (1)"}}}, nil, timeouts,
+			2 * time.Second},
+		{"batch with prepared statement", &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO ks2.tb1 (a) VALUES (1)"}, {Id: []byte("target")}}},
+			NewBatchRequestInfo(map[int]PreparedData{1: preparedData}), timeouts, 30 * time.Second},
+		{"batch without override", &message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO ks2.tb2 (a) VALUES (1)"}}}, nil, timeouts, defaultTimeout},
+		{"other request", &message.Options{}, nil, timeouts, defaultTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, tt.msg, primitive.ProtocolVersion5)
+			timeout := tt.timeouts.getRequestTimeout(NewFrameDecodeContext(f), tt.requestInfo, "ks1", defaultTimeout)
+			require.Equal(t, tt.expected, timeout)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/compression/lz4"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/go-cassandra-native-protocol/segment"
+	lz4block "github.com/pierrec/lz4/v4"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"sync/atomic"
+)
+
+const (
+	frameHeaderLengthV2AndBelow = 8
+	frameHeaderLengthV3AndAbove = 9
+)
+
+var (
+	defaultSegmentCodec = segment.NewCodec()
+	lz4SegmentCodec     = segment.NewCodecWithCompression(segmentLz4Compressor{})
+)
+
+// segmentLz4Compressor compresses segment payloads with LZ4.
+//
+// The LZ4 block format does not carry the decompressed length so decompression uses a destination buffer
+// of the maximum segment payload length instead of guessing it from the compression ratio.
+type segmentLz4Compressor struct {
+	lz4.Compressor
+}
+
+func (c segmentLz4Compressor) Decompress(source io.Reader, dest io.Writer) error {
+	compressedPayload, err := io.ReadAll(source)
+	if err != nil {
+		return fmt.Errorf("cannot read compressed payload: %w", err)
+	}
+
+	decompressedPayload := make([]byte, segment.MaxPayloadLength)
+	written, err := lz4block.UncompressBlock(compressedPayload, decompressedPayload)
+	if err != nil {
+		return fmt.Errorf("cannot decompress payload: %w", err)
+	}
+
+	_, err = dest.Write(decompressedPayload[:written])
+	return err
+}
+
+// segmentFraming keeps track of the outer framing format used on a single connection.
+//
+// Protocol v5 wraps frames in segments (with CRC checksums and, optionally, LZ4 compression) but only after
+// the handshake: the STARTUP request and the READY or AUTHENTICATE response still use the legacy framing format
+// and every frame that comes after them on the same connection is sent in one or more segments.
+type segmentFraming struct {
+	enabled     int32
+	compression *frameCompression
+}
+
+func newSegmentFraming(compression *frameCompression) *segmentFraming {
+	return &segmentFraming{
+		enabled:     0,
+		compression: compression,
+	}
+}
+
+func (recv *segmentFraming) IsEnabled() bool {
+	return atomic.LoadInt32(&recv.enabled) == 1
+}
+
+// Update switches this connection to segment framing if the provided frame is the protocol v5 handshake response
+// (READY or AUTHENTICATE).
+func (recv *segmentFraming) Update(f *frame.RawFrame) {
+	if !f.Header.IsResponse || !protocolUsesSegments(f.Header.Version) {
+		return
+	}
+
+	switch f.Header.OpCode {
+	case primitive.OpCodeReady, primitive.OpCodeAuthenticate:
+		if atomic.CompareAndSwapInt32(&recv.enabled, 0, 1) {
+			log.Debugf("Switching to segment framing after receiving %v (compression: %v).",
+				f.Header.OpCode, recv.compression.GetAlgorithm())
+		}
+	}
+}
+
+func (recv *segmentFraming) getCodec() segment.Codec {
+	if recv.compression.GetAlgorithm() == primitive.CompressionLz4 {
+		return lz4SegmentCodec
+	}
+	return defaultSegmentCodec
+}
+
+// WriteFrame encodes the provided frame and writes it to dest. If segment framing is enabled then the frame is written
+// in a single self-contained segment or, if it doesn't fit in one, in multiple segments that are not self-contained.
+func (recv *segmentFraming) WriteFrame(f *frame.RawFrame, dest io.Writer) error {
+	if !recv.IsEnabled() {
+		return defaultCodec.EncodeRawFrame(f, dest)
+	}
+
+	encodedFrame := bytes.NewBuffer(make([]byte, 0, frameHeaderLengthV3AndAbove+len(f.Body)))
+	err := defaultCodec.EncodeRawFrame(f, encodedFrame)
+	if err != nil {
+		return err
+	}
+
+	codec := recv.getCodec()
+	payload := encodedFrame.Bytes()
+	selfContained := len(payload) <= segment.MaxPayloadLength
+	for len(payload) > 0 {
+		length := len(payload)
+		if length > segment.MaxPayloadLength {
+			length = segment.MaxPayloadLength
+		}
+		err = codec.EncodeSegment(&segment.Segment{
+			Header:  &segment.Header{IsSelfContained: selfContained},
+			Payload: &segment.Payload{UncompressedData: payload[:length]},
+		}, dest)
+		if err != nil {
+			return fmt.Errorf("cannot encode segment: %w", err)
+		}
+		payload = payload[length:]
+	}
+	return nil
+}
+
+// frameReader reads frames from a connection, unwrapping them from segments after segment framing is enabled.
+//
+// When maxBodyLength is positive, the body length declared in each frame header is checked before the body is
+// buffered and frames with a larger (or a negative) body length are rejected with an invalidFrameLengthError.
+type frameReader struct {
+	reader        *bufio.Reader
+	framing       *segmentFraming
+	payload       *bytes.Buffer
+	maxBodyLength int
+}
+
+func newFrameReader(reader *bufio.Reader, framing *segmentFraming, maxBodyLength int) *frameReader {
+	return &frameReader{
+		reader:        reader,
+		framing:       framing,
+		payload:       &bytes.Buffer{},
+		maxBodyLength: maxBodyLength,
+	}
+}
+
+func (recv *frameReader) ReadFrame(connectionAddr string, clientHandlerContext context.Context) (*frame.RawFrame, error) {
+	if recv.payload.Len() == 0 {
+		// the peer only sends segments after the handshake response is out, block until there is data to read
+		// before checking which framing format is in use
+		versionByte, err := recv.reader.Peek(1)
+		if err != nil {
+			return nil, adaptConnErr(connectionAddr, clientHandlerContext, err)
+		}
+
+		if !recv.framing.IsEnabled() {
+			// unsupported versions are left to the codec which returns the error that triggers a protocol downgrade
+			version := primitive.ProtocolVersion(versionByte[0] & 0x7F)
+			if version.IsSupported() {
+				header, err := recv.reader.Peek(frameHeaderLength(version))
+				if err != nil {
+					return nil, adaptConnErr(connectionAddr, clientHandlerContext, err)
+				}
+				if err = recv.checkBodyLength(header); err != nil {
+					return nil, err
+				}
+			}
+
+			f, err := readRawFrame(recv.reader, connectionAddr, clientHandlerContext)
+			if err != nil {
+				return nil, err
+			}
+			recv.framing.Update(f)
+			return f, nil
+		}
+
+		recv.payload.Reset()
+	}
+
+	codec := recv.framing.getCodec()
+	for !containsFullFrame(recv.payload.Bytes()) {
+		s, err := codec.DecodeSegment(recv.reader)
+		if err != nil {
+			return nil, adaptConnErr(connectionAddr, clientHandlerContext, fmt.Errorf("cannot decode segment: %w", err))
+		}
+		recv.payload.Write(s.Payload.UncompressedData)
+		if recv.payload.Len() >= frameHeaderLengthV3AndAbove {
+			// stop accumulating segments as soon as the header of a frame that is too large is available
+			if err = recv.checkBodyLength(recv.payload.Bytes()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f, err := defaultCodec.DecodeRawFrame(recv.payload)
+	if err != nil {
+		return nil, adaptConnErr(connectionAddr, clientHandlerContext, err)
+	}
+	return f, nil
+}
+
+// checkBodyLength returns an invalidFrameLengthError if the frame header at the start of the given data declares a
+// negative body length or a body length above the maximum of this reader.
+func (recv *frameReader) checkBodyLength(data []byte) error {
+	version := primitive.ProtocolVersion(data[0] & 0x7F)
+	headerLength := frameHeaderLength(version)
+	bodyLength := int32(binary.BigEndian.Uint32(data[headerLength-4 : headerLength]))
+	if bodyLength >= 0 && (recv.maxBodyLength <= 0 || int(bodyLength) <= recv.maxBodyLength) {
+		return nil
+	}
+
+	var streamId int16
+	if version >= primitive.ProtocolVersion3 {
+		streamId = int16(binary.BigEndian.Uint16(data[2:4]))
+	} else {
+		streamId = int16(int8(data[2]))
+	}
+	return &invalidFrameLengthError{
+		version:       version,
+		streamId:      streamId,
+		bodyLength:    bodyLength,
+		maxBodyLength: recv.maxBodyLength,
+	}
+}
+
+// invalidFrameLengthError is returned by frameReader when a frame header declares a body length that is negative or
+// above the maximum frame size. The body of the frame is not read so the connection can't be used anymore.
+type invalidFrameLengthError struct {
+	version       primitive.ProtocolVersion
+	streamId      int16
+	bodyLength    int32
+	maxBodyLength int
+}
+
+func (e *invalidFrameLengthError) Error() string {
+	if e.bodyLength < 0 {
+		return fmt.Sprintf("invalid body length %d in header of frame (version %v, stream id %d)",
+			e.bodyLength, e.version, e.streamId)
+	}
+	return fmt.Sprintf("body length %d in header of frame (version %v, stream id %d) exceeds the maximum frame size of %d bytes",
+		e.bodyLength, e.version, e.streamId, e.maxBodyLength)
+}
+
+// ProtocolError returns the PROTOCOL_ERROR message that is sent to the client before its connection is closed.
+func (e *invalidFrameLengthError) ProtocolError() *message.ProtocolError {
+	return &message.ProtocolError{ErrorMessage: fmt.Sprintf("Invalid or too large frame: %v", e.Error())}
+}
+
+func frameHeaderLength(version primitive.ProtocolVersion) int {
+	if version >= primitive.ProtocolVersion3 {
+		return frameHeaderLengthV3AndAbove
+	}
+	return frameHeaderLengthV2AndBelow
+}
+
+func containsFullFrame(data []byte) bool {
+	if len(data) < frameHeaderLengthV3AndAbove {
+		return false
+	}
+	bodyLength := binary.BigEndian.Uint32(data[frameHeaderLengthV3AndAbove-4 : frameHeaderLengthV3AndAbove])
+	return uint64(len(data)) >= uint64(frameHeaderLengthV3AndAbove)+uint64(bodyLength)
+}
+
+func protocolUsesSegments(version primitive.ProtocolVersion) bool {
+	return version == primitive.ProtocolVersion5
+}
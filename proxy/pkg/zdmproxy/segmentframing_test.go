@@ -0,0 +1,238 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/go-cassandra-native-protocol/segment"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestSegmentFraming_SwitchAfterHandshakeResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  primitive.ProtocolVersion
+		msg      message.Message
+		expected bool
+	}{
+		{"v5 READY", primitive.ProtocolVersion5, &message.Ready{}, true},
+		{"v5 AUTHENTICATE", primitive.ProtocolVersion5, &message.Authenticate{Authenticator: "authenticator"}, true},
+		{"v5 SUPPORTED", primitive.ProtocolVersion5, &message.Supported{}, false},
+		{"v4 READY", primitive.ProtocolVersion4, &message.Ready{}, false},
+		{"DSE v2 READY", primitive.ProtocolVersionDse2, &message.Ready{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			framing := newSegmentFraming(newFrameCompression())
+			f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(tt.version, 0, tt.msg))
+			require.Nil(t, err)
+			framing.Update(f)
+			require.Equal(t, tt.expected, framing.IsEnabled())
+		})
+	}
+}
+
+func TestSegmentFraming_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		queryLength int
+	}{
+		{"uncompressed small frame", "", 10},
+		{"uncompressed large frame", "", segment.MaxPayloadLength * 2},
+		{"lz4 small frame", "lz4", 10},
+		{"lz4 large frame", "lz4", segment.MaxPayloadLength * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compression := newFrameCompression()
+			startup := buildStartupRawFrame(t, tt.compression)
+			startup.Header.Version = primitive.ProtocolVersion5
+			require.Nil(t, compression.SetFromStartup(startup))
+
+			writerFraming := newSegmentFraming(compression)
+			readerFraming := newSegmentFraming(compression)
+
+			ready, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion5, 0, &message.Ready{}))
+			require.Nil(t, err)
+			query, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion5, 1, &message.Query{
+				Query:   "SELECT * FROM ks.tb WHERE key = '" + strings.Repeat("a", tt.queryLength) + "'",
+				Options: &message.QueryOptions{},
+			}))
+			require.Nil(t, err)
+
+			buf := &bytes.Buffer{}
+			require.Nil(t, writerFraming.WriteFrame(ready, buf))
+			writerFraming.Update(ready)
+			require.True(t, writerFraming.IsEnabled())
+			readyLength := buf.Len()
+			require.Nil(t, writerFraming.WriteFrame(query, buf))
+			require.NotEqual(t, frameHeaderLengthV3AndAbove+len(query.Body), buf.Len()-readyLength)
+
+			reader := newFrameReader(bufio.NewReader(buf), readerFraming, 0)
+			readReady, err := reader.ReadFrame("127.0.0.1:9042", context.Background())
+			require.Nil(t, err)
+			require.Equal(t, primitive.OpCodeReady, readReady.Header.OpCode)
+			require.True(t, readerFraming.IsEnabled())
+
+			readQuery, err := reader.ReadFrame("127.0.0.1:9042", context.Background())
+			require.Nil(t, err)
+			require.Equal(t, query.Header, readQuery.Header)
+			require.Equal(t, query.Body, readQuery.Body)
+			require.Equal(t, 0, buf.Len())
+		})
+	}
+}
+
+func TestFrameReader_MultipleFramesInSegment(t *testing.T) {
+	framing := newSegmentFraming(newFrameCompression())
+	framing.enabled = 1
+
+	payload := &bytes.Buffer{}
+	for i := int16(0); i < 3; i++ {
+		f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion5, i, &message.Options{}))
+		require.Nil(t, err)
+		require.Nil(t, defaultCodec.EncodeRawFrame(f, payload))
+	}
+
+	buf := &bytes.Buffer{}
+	err := defaultSegmentCodec.EncodeSegment(&segment.Segment{
+		Header:  &segment.Header{IsSelfContained: true},
+		Payload: &segment.Payload{UncompressedData: payload.Bytes()},
+	}, buf)
+	require.Nil(t, err)
+
+	reader := newFrameReader(bufio.NewReader(buf), framing, 0)
+	for i := int16(0); i < 3; i++ {
+		f, err := reader.ReadFrame("127.0.0.1:9042", context.Background())
+		require.Nil(t, err)
+		require.Equal(t, i, f.Header.StreamId)
+		require.Equal(t, primitive.OpCodeOptions, f.Header.OpCode)
+	}
+}
+
+func TestFrameReader_CorruptedSegment(t *testing.T) {
+	framing := newSegmentFraming(newFrameCompression())
+	framing.enabled = 1
+
+	f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion5, 0, &message.Options{}))
+	require.Nil(t, err)
+	buf := &bytes.Buffer{}
+	require.Nil(t, framing.WriteFrame(f, buf))
+	corrupted := buf.Bytes()
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	reader := newFrameReader(bufio.NewReader(bytes.NewReader(corrupted)), framing, 0)
+	_, err = reader.ReadFrame("127.0.0.1:9042", context.Background())
+	require.NotNil(t, err)
+}
+
+func TestFrameReader_InvalidBodyLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       primitive.ProtocolVersion
+		streamId      int16
+		bodyLength    uint32
+		maxBodyLength int
+	}{
+		{"v4 too large", primitive.ProtocolVersion4, 5, 256 * 1024 * 1024, 16 * 1024 * 1024},
+		{"v4 negative", primitive.ProtocolVersion4, 5, 0xFFFFFFFF, 0},
+		{"v2 too large", primitive.ProtocolVersion2, -3, 1024, 100},
+		{"DSE v2 too large", primitive.ProtocolVersionDse2, 300, 1024, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(tt.version, tt.streamId, &message.Options{}))
+			require.Nil(t, err)
+			buf := &bytes.Buffer{}
+			require.Nil(t, defaultCodec.EncodeRawFrame(f, buf))
+			headerLength := frameHeaderLength(tt.version)
+			header := buf.Bytes()[:headerLength]
+			binary.BigEndian.PutUint32(header[headerLength-4:], tt.bodyLength)
+
+			// only the header is sent, the reader must not wait for the body
+			reader := newFrameReader(bufio.NewReader(bytes.NewReader(header)), newSegmentFraming(newFrameCompression()), tt.maxBodyLength)
+			_, err = reader.ReadFrame("127.0.0.1:9042", context.Background())
+			var frameLengthErr *invalidFrameLengthError
+			require.True(t, errors.As(err, &frameLengthErr), err)
+			require.Equal(t, tt.version, frameLengthErr.version)
+			require.Equal(t, tt.streamId, frameLengthErr.streamId)
+			require.Equal(t, int32(tt.bodyLength), frameLengthErr.bodyLength)
+		})
+	}
+}
+
+func TestFrameReader_MaxBodyLength(t *testing.T) {
+	f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{
+		Query:   "SELECT * FROM ks.tb",
+		Options: &message.QueryOptions{},
+	}))
+	require.Nil(t, err)
+
+	for _, maxBodyLength := range []int{0, len(f.Body)} {
+		buf := &bytes.Buffer{}
+		require.Nil(t, defaultCodec.EncodeRawFrame(f, buf))
+		reader := newFrameReader(bufio.NewReader(buf), newSegmentFraming(newFrameCompression()), maxBodyLength)
+		readFrame, err := reader.ReadFrame("127.0.0.1:9042", context.Background())
+		require.Nil(t, err)
+		require.Equal(t, f.Body, readFrame.Body)
+	}
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, defaultCodec.EncodeRawFrame(f, buf))
+	reader := newFrameReader(bufio.NewReader(buf), newSegmentFraming(newFrameCompression()), len(f.Body)-1)
+	_, err = reader.ReadFrame("127.0.0.1:9042", context.Background())
+	var frameLengthErr *invalidFrameLengthError
+	require.True(t, errors.As(err, &frameLengthErr), err)
+}
+
+func TestFrameReader_InvalidBodyLengthInSegment(t *testing.T) {
+	tests := []struct {
+		name       string
+		bodyLength uint32
+	}{
+		{"too large", 256 * 1024 * 1024},
+		{"negative", 0xFFFFFFFF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			framing := newSegmentFraming(newFrameCompression())
+			framing.enabled = 1
+
+			f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion5, 7, &message.Options{}))
+			require.Nil(t, err)
+			payload := &bytes.Buffer{}
+			require.Nil(t, defaultCodec.EncodeRawFrame(f, payload))
+			binary.BigEndian.PutUint32(payload.Bytes()[frameHeaderLengthV3AndAbove-4:], tt.bodyLength)
+
+			buf := &bytes.Buffer{}
+			err = defaultSegmentCodec.EncodeSegment(&segment.Segment{
+				Header:  &segment.Header{IsSelfContained: false},
+				Payload: &segment.Payload{UncompressedData: payload.Bytes()},
+			}, buf)
+			require.Nil(t, err)
+
+			// the reader must not wait for more segments
+			reader := newFrameReader(bufio.NewReader(buf), framing, 16*1024*1024)
+			_, err = reader.ReadFrame("127.0.0.1:9042", context.Background())
+			var frameLengthErr *invalidFrameLengthError
+			require.True(t, errors.As(err, &frameLengthErr), err)
+			require.Equal(t, primitive.ProtocolVersion5, frameLengthErr.version)
+			require.Equal(t, int16(7), frameLengthErr.streamId)
+		})
+	}
+}
+
+func TestCheckProtocolVersion(t *testing.T) {
+	require.Nil(t, checkProtocolVersion(primitive.ProtocolVersion4, false))
+	require.Nil(t, checkProtocolVersion(primitive.ProtocolVersionDse2, false))
+	require.NotNil(t, checkProtocolVersion(primitive.ProtocolVersion5, false))
+	require.Nil(t, checkProtocolVersion(primitive.ProtocolVersion5, true))
+}
@@ -0,0 +1,73 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+// sessionKeyspace tracks the keyspace of the cluster connections of a client connection, i.e. the keyspace of the last
+// USE request that was sent to both clusters.
+//
+// Writes are queued on the dual writes workers and sent to the clusters later, so a USE request that the client sends
+// while a write is queued can be sent to the clusters before the write, which would then be executed on the new
+// keyspace. Each queued write keeps the keyspace that the client connection had when the write was received and, when
+// the keyspace of the cluster connections is different by the time the write is sent, the table names of the write
+// are qualified with that keyspace (see tableNameQualifier).
+//
+// USE requests are queued with the writes of the client connection (see dualWritesQueue) so the keyspace can't change
+// while a write is being sent.
+type sessionKeyspace struct {
+	lock     sync.Mutex
+	keyspace string
+}
+
+func newSessionKeyspace() *sessionKeyspace {
+	return &sessionKeyspace{}
+}
+
+// OnUseSent is called before a USE request of the client is sent to both clusters.
+func (recv *sessionKeyspace) OnUseSent(keyspace string) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.keyspace = keyspace
+}
+
+func (recv *sessionKeyspace) Get() string {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return recv.keyspace
+}
+
+// applySessionKeyspace is called by the dual writes workers before a request is sent to both clusters with the keyspace
+// of the client connection when the request was received. It returns the origin and target requests that have to be
+// sent, with their table names qualified if the keyspace of the cluster connections changed since then. EXECUTE
+// requests are not modified because prepared statements don't depend on the keyspace of the connection.
+func (ch *ClientHandler) applySessionKeyspace(
+	frameContext *frameDecodeContext, originRequest *frame.RawFrame, targetRequest *frame.RawFrame,
+	keyspace string) (*frame.RawFrame, *frame.RawFrame) {
+	if useKeyspace, isUse := getUseStatementKeyspace(frameContext); isUse {
+		ch.sessionKeyspace.OnUseSent(useKeyspace)
+		return originRequest, targetRequest
+	}
+
+	sessionKeyspace := ch.sessionKeyspace.Get()
+	if keyspace == "" || keyspace == sessionKeyspace {
+		return originRequest, targetRequest
+	}
+
+	log.Debugf("Keyspace of the cluster connections changed from %v to %v while request %v was queued, "+
+		"qualifying its table names.", keyspace, sessionKeyspace, frameContext.GetRawFrame().Header)
+	qualifier := &tableNameQualifier{}
+	qualifiedOriginRequest, err := qualifier.Apply(originRequest, keyspace)
+	if err != nil {
+		log.Warnf("Could not qualify the table names of queued request: %v", err)
+		return originRequest, targetRequest
+	}
+	qualifiedTargetRequest, err := qualifier.Apply(targetRequest, keyspace)
+	if err != nil {
+		log.Warnf("Could not qualify the table names of queued request: %v", err)
+		return originRequest, targetRequest
+	}
+	return qualifiedOriginRequest, qualifiedTargetRequest
+}
@@ -0,0 +1,47 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClientHandler_ApplySessionKeyspace(t *testing.T) {
+	ch := &ClientHandler{sessionKeyspace: newSessionKeyspace()}
+	apply := func(f *frameDecodeContext, keyspace string) (*message.Query, *message.Query) {
+		_, err := f.GetOrInspectStatement(keyspace, nil)
+		require.Nil(t, err)
+		originRequest, targetRequest := ch.applySessionKeyspace(f, f.GetRawFrame(), f.GetRawFrame(), keyspace)
+		decodedOrigin, err := defaultCodec.ConvertFromRawFrame(originRequest)
+		require.Nil(t, err)
+		decodedTarget, err := defaultCodec.ConvertFromRawFrame(targetRequest)
+		require.Nil(t, err)
+		return decodedOrigin.Body.Message.(*message.Query), decodedTarget.Body.Message.(*message.Query)
+	}
+
+	// write received and sent on ks1
+	apply(&frameDecodeContext{frame: mockQueryFrame(t, "USE ks1")}, "")
+	require.Equal(t, "ks1", ch.sessionKeyspace.Get())
+	origin, target := apply(&frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO tb1 (a) VALUES (1)")}, "ks1")
+	require.Equal(t, "INSERT INTO tb1 (a) VALUES (1)", origin.Query)
+	require.Equal(t, "INSERT INTO tb1 (a) VALUES (1)", target.Query)
+
+	// USE ks2 sent before a write received on ks1
+	apply(&frameDecodeContext{frame: mockQueryFrame(t, "USE ks2")}, "ks1")
+	require.Equal(t, "ks2", ch.sessionKeyspace.Get())
+	origin, target = apply(&frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO tb1 (a) VALUES (1)")}, "ks1")
+	require.Equal(t, "INSERT INTO \"ks1\".tb1 (a) VALUES (1)", origin.Query)
+	require.Equal(t, "INSERT INTO \"ks1\".tb1 (a) VALUES (1)", target.Query)
+
+	// qualified tables and writes received on the current keyspace are not modified
+	origin, _ = apply(&frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO ks3.tb1 (a) VALUES (1)")}, "ks1")
+	require.Equal(t, "INSERT INTO ks3.tb1 (a) VALUES (1)", origin.Query)
+	origin, _ = apply(&frameDecodeContext{frame: mockQueryFrame(t, "INSERT INTO tb1 (a) VALUES (1)")}, "ks2")
+	require.Equal(t, "INSERT INTO tb1 (a) VALUES (1)", origin.Query)
+
+	execute := &frameDecodeContext{frame: mockFrame(t, &message.Execute{QueryId: []byte("id")}, primitive.ProtocolVersion4)}
+	originRequest, targetRequest := ch.applySessionKeyspace(execute, execute.GetRawFrame(), execute.GetRawFrame(), "ks1")
+	require.Same(t, execute.GetRawFrame(), originRequest)
+	require.Same(t, execute.GetRawFrame(), targetRequest)
+}
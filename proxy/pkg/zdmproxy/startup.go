@@ -115,8 +115,10 @@ func (ch *ClientHandler) handleSecondaryHandshakeStartup(
 				NewGenericRequestInfo(forwardToSecondary, asyncConnector, false),
 				ch.LoadCurrentKeyspace(),
 				overallRequestStartTime,
+				overallRequestStartTime,
 				channel,
-				requestTimeout)
+				requestTimeout,
+				nil)
 
 			if err != nil {
 				return fmt.Errorf("unable to send secondary (%v) handshake frame to %v: %w", logIdentifier, clusterAddress, err)
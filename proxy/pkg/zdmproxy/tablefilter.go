@@ -0,0 +1,76 @@
+package zdmproxy
+
+import (
+	"strings"
+)
+
+// tableFilter decides which tables are in the scope of the migration based on ZDM_INCLUDED_TABLES and
+// ZDM_EXCLUDED_TABLES. Requests on tables that are out of scope are only sent to ORIGIN.
+//
+// Entries are either a keyspace name (every table of the keyspace) or a keyspace.table name. Names are matched
+// against the internal form of CQL identifiers: lower case for unquoted identifiers, exact case for quoted ones.
+type tableFilter struct {
+	included map[string]bool
+	excluded map[string]bool
+}
+
+func newTableFilter(included []string, excluded []string) *tableFilter {
+	if len(included) == 0 && len(excluded) == 0 {
+		return nil
+	}
+	toSet := func(entries []string) map[string]bool {
+		set := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			set[entry] = true
+		}
+		return set
+	}
+	return &tableFilter{
+		included: toSet(included),
+		excluded: toSet(excluded),
+	}
+}
+
+// IsExcluded returns true if the provided table is out of the scope of the migration. System tables are never excluded.
+// A nil filter doesn't exclude any table.
+func (recv *tableFilter) IsExcluded(keyspace string, table string) bool {
+	if recv == nil || keyspace == "" || isSystemKeyspace(keyspace) {
+		return false
+	}
+	qualifiedTable := keyspace + "." + table
+	if recv.excluded[keyspace] || (table != "" && recv.excluded[qualifiedTable]) {
+		return true
+	}
+	if len(recv.included) == 0 || recv.included[keyspace] {
+		return false
+	}
+	return table == "" || !recv.included[qualifiedTable]
+}
+
+// IsKeyspaceExcluded returns true if every table of the provided keyspace is out of the scope of the migration.
+func (recv *tableFilter) IsKeyspaceExcluded(keyspace string) bool {
+	if recv == nil || keyspace == "" || isSystemKeyspace(keyspace) {
+		return false
+	}
+	if recv.excluded[keyspace] {
+		return true
+	}
+	if len(recv.included) == 0 || recv.included[keyspace] {
+		return false
+	}
+	for entry := range recv.included {
+		if strings.HasPrefix(entry, keyspace+".") {
+			return false
+		}
+	}
+	return true
+}
+
+func isExcludedStatement(queryInfo QueryInfo, tableFilter *tableFilter) bool {
+	switch queryInfo.getStatementType() {
+	case statementTypeSelect, statementTypeInsert, statementTypeUpdate, statementTypeDelete:
+		return tableFilter.IsExcluded(queryInfo.getApplicableKeyspace(), queryInfo.getTableName())
+	default:
+		return false
+	}
+}
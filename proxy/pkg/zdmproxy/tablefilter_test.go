@@ -0,0 +1,35 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTableFilter(t *testing.T) {
+	tests := []struct {
+		name             string
+		filter           *tableFilter
+		keyspace         string
+		table            string
+		expectedExcluded bool
+		expectedKsExcl   bool
+	}{
+		{"no filter", newTableFilter(nil, nil), "ks1", "tb1", false, false},
+		{"excluded keyspace", newTableFilter(nil, []string{"ks1"}), "ks1", "tb1", true, true},
+		{"excluded table", newTableFilter(nil, []string{"ks1.tb1"}), "ks1", "tb1", true, false},
+		{"other table of keyspace with excluded table", newTableFilter(nil, []string{"ks1.tb1"}), "ks1", "tb2", false, false},
+		{"included keyspace", newTableFilter([]string{"ks1"}, nil), "ks1", "tb1", false, false},
+		{"not included keyspace", newTableFilter([]string{"ks1"}, nil), "ks2", "tb1", true, true},
+		{"included table", newTableFilter([]string{"ks1.tb1"}, nil), "ks1", "tb1", false, false},
+		{"not included table", newTableFilter([]string{"ks1.tb1"}, nil), "ks1", "tb2", true, false},
+		{"excluded table of included keyspace", newTableFilter([]string{"ks1"}, []string{"ks1.tb1"}), "ks1", "tb1", true, false},
+		{"system keyspace", newTableFilter([]string{"ks1"}, []string{"system"}), "system", "local", false, false},
+		{"case sensitive", newTableFilter(nil, []string{"ks1"}), "KS1", "tb1", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedExcluded, tt.filter.IsExcluded(tt.keyspace, tt.table))
+			require.Equal(t, tt.expectedKsExcl, tt.filter.IsKeyspaceExcluded(tt.keyspace))
+		})
+	}
+}
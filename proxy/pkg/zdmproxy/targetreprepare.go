@@ -0,0 +1,129 @@
+package zdmproxy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// targetReprepare is set on the request context of the EXECUTE and BATCH requests sent to both clusters so that a
+// statement that is not prepared on TARGET anymore (e.g. after a restart of the TARGET node) is prepared again by the
+// proxy instead of returning the UNPREPARED error to the client, which would make the driver prepare the statement
+// again and retry the write on both clusters although it was already applied on ORIGIN.
+//
+// When TARGET returns UNPREPARED, the PREPARE request of the statement is sent to TARGET with the stream id of the
+// client request and the TARGET request is sent again once the PREPARE response is received. This is only attempted
+// once per request, if it fails the UNPREPARED response is handled as usual.
+type targetReprepare struct {
+	request    *frame.RawFrame // TARGET request that is sent again once the statement is prepared
+	keyspace   string          // keyspace of the client connection when the request was received
+	unprepared *frame.RawFrame // UNPREPARED response of TARGET, set when the PREPARE request was sent
+	attempted  bool
+}
+
+// setTargetReprepare is called before the provided TARGET request of a write is sent to both clusters.
+func (recv *requestContextImpl) setTargetReprepare(request *frame.RawFrame, keyspace string) {
+	switch request.Header.OpCode {
+	case primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.targetReprepare = &targetReprepare{request: request, keyspace: keyspace}
+}
+
+// handleTargetReprepare is called with the TARGET responses of the requests sent to both clusters. It returns true if
+// the response was handled by sending a request to TARGET, the response of that request is then received with the
+// stream id of the client request. Otherwise it returns the response that has to be set on the request context.
+func (ch *ClientHandler) handleTargetReprepare(
+	reqCtx *requestContextImpl, response *frame.RawFrame) (*frame.RawFrame, bool) {
+	reqCtx.lock.Lock()
+	reprepare := reqCtx.targetReprepare
+	if reqCtx.state != RequestPending || reprepare == nil {
+		reqCtx.lock.Unlock()
+		return response, false
+	}
+
+	var request *frame.RawFrame
+	if reprepare.unprepared != nil {
+		// response of the PREPARE request
+		unprepared := reprepare.unprepared
+		reqCtx.targetReprepare = nil
+		reqCtx.lock.Unlock()
+		if response.Header.OpCode != primitive.OpCodeResult {
+			log.Warnf("Could not prepare statement again on %v after an UNPREPARED response: %v.",
+				common.ClusterTypeTarget, response.Header)
+			return unprepared, false
+		}
+		request = reprepare.request
+		log.Debugf("Sending request with stream id %v again to %v after preparing its statement.",
+			reqCtx.request.Header.StreamId, common.ClusterTypeTarget)
+		if err := ch.targetCassandraConnector.sendRequestToCluster(request); err != nil {
+			log.Warnf("Could not send request to %v again after preparing its statement: %v.",
+				common.ClusterTypeTarget, err)
+			return unprepared, false
+		}
+		return nil, true
+	}
+
+	if reprepare.attempted || !isUnpreparedResponse(response) {
+		reqCtx.lock.Unlock()
+		return response, false
+	}
+	reprepare.attempted = true
+	request, err := ch.newTargetReprepareRequest(reqCtx.request.Header.StreamId, reprepare, response)
+	if err != nil {
+		reqCtx.targetReprepare = nil
+		reqCtx.lock.Unlock()
+		log.Warnf("Could not prepare statement again on %v after an UNPREPARED response: %v.",
+			common.ClusterTypeTarget, err)
+		return response, false
+	}
+	reprepare.unprepared = response
+	reqCtx.lock.Unlock()
+
+	log.Debugf("Preparing statement again on %v for request with stream id %v.",
+		common.ClusterTypeTarget, request.Header.StreamId)
+	if err = ch.targetCassandraConnector.sendRequestToCluster(request); err != nil {
+		reqCtx.lock.Lock()
+		reqCtx.targetReprepare = nil
+		reqCtx.lock.Unlock()
+		log.Warnf("Could not prepare statement again on %v: %v.", common.ClusterTypeTarget, err)
+		return response, false
+	}
+	return nil, true
+}
+
+// newTargetReprepareRequest returns the PREPARE request of the statement of the provided UNPREPARED response, with
+// the table names qualified if ZDM_TARGET_QUALIFY_TABLE_NAMES is enabled.
+func (ch *ClientHandler) newTargetReprepareRequest(
+	streamId int16, reprepare *targetReprepare, unpreparedResponse *frame.RawFrame) (*frame.RawFrame, error) {
+	errorMsg, err := decodeErrorResult(unpreparedResponse)
+	if err != nil {
+		return nil, err
+	}
+	unprepared, ok := errorMsg.(*message.Unprepared)
+	if !ok {
+		return nil, fmt.Errorf("expected UNPREPARED error but got %v", errorMsg)
+	}
+	preparedData, ok := ch.preparedStatementCache.GetByTargetPreparedId(unprepared.Id)
+	if !ok {
+		return nil, fmt.Errorf("could not find prepared data for %v prepared id %v",
+			common.ClusterTypeTarget, hex.EncodeToString(unprepared.Id))
+	}
+	prepareRequestInfo := preparedData.GetPrepareRequestInfo()
+	prepareFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(
+		reprepare.request.Header.Version, streamId, &message.Prepare{
+			Query:    prepareRequestInfo.GetQuery(),
+			Keyspace: prepareRequestInfo.GetKeyspace(),
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("could not convert PREPARE request to raw frame: %w", err)
+	}
+	return ch.targetTableNameQualifier.Apply(prepareFrame, reprepare.keyspace)
+}
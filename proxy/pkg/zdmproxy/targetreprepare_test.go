@@ -0,0 +1,94 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClientHandler_HandleTargetReprepare(t *testing.T) {
+	psCache := NewPreparedStatementCache(0)
+	psCache.Store(
+		&message.PreparedResult{PreparedQueryId: []byte("origin")}, &message.PreparedResult{PreparedQueryId: []byte("target")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false,
+			"INSERT INTO tb1 (a) VALUES (?)", ""))
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	writeQueue := make(chan *frame.RawFrame, 10)
+	ch := &ClientHandler{
+		preparedStatementCache:   psCache,
+		targetTableNameQualifier: newTableNameQualifier(true),
+		targetCassandraConnector: &ClusterConnector{
+			writeCoalescer: &writeCoalescer{connection: clientConn, writeQueue: writeQueue},
+		},
+	}
+
+	execute := mockFrame(t, &message.Execute{QueryId: []byte("target")}, primitive.ProtocolVersion4)
+	execute.Header.StreamId = 5
+	unprepared := mockFrame(t, &message.Unprepared{ErrorMessage: "unprepared", Id: []byte("target")}, primitive.ProtocolVersion4)
+	prepared := mockFrame(t, &message.PreparedResult{PreparedQueryId: []byte("target")}, primitive.ProtocolVersion4)
+	void := mockFrame(t, &message.VoidResult{}, primitive.ProtocolVersion4)
+	newRequestContext := func() *requestContextImpl {
+		reqCtx := NewRequestContext(execute, NewGenericRequestInfo(forwardToBoth, false, true), time.Now(), nil)
+		reqCtx.setTargetReprepare(execute, "ks1")
+		return reqCtx
+	}
+
+	// statement prepared again and request sent again
+	reqCtx := newRequestContext()
+	response, handled := ch.handleTargetReprepare(reqCtx, unprepared)
+	require.True(t, handled)
+	require.Nil(t, response)
+	decoded, err := defaultCodec.ConvertFromRawFrame(<-writeQueue)
+	require.Nil(t, err)
+	require.Equal(t, int16(5), decoded.Header.StreamId)
+	require.Equal(t, &message.Prepare{Query: "INSERT INTO \"ks1\".tb1 (a) VALUES (?)"}, decoded.Body.Message)
+	_, handled = ch.handleTargetReprepare(reqCtx, prepared)
+	require.True(t, handled)
+	require.Same(t, execute, <-writeQueue)
+	response, handled = ch.handleTargetReprepare(reqCtx, void)
+	require.False(t, handled)
+	require.Same(t, void, response)
+
+	// only attempted once
+	reqCtx = newRequestContext()
+	_, handled = ch.handleTargetReprepare(reqCtx, unprepared)
+	require.True(t, handled)
+	<-writeQueue
+	_, handled = ch.handleTargetReprepare(reqCtx, prepared)
+	require.True(t, handled)
+	<-writeQueue
+	response, handled = ch.handleTargetReprepare(reqCtx, unprepared)
+	require.False(t, handled)
+	require.Same(t, unprepared, response)
+
+	// PREPARE failed, the UNPREPARED response is returned
+	reqCtx = newRequestContext()
+	_, handled = ch.handleTargetReprepare(reqCtx, unprepared)
+	require.True(t, handled)
+	<-writeQueue
+	response, handled = ch.handleTargetReprepare(reqCtx, mockFrame(
+		t, &message.ServerError{ErrorMessage: "error"}, primitive.ProtocolVersion4))
+	require.False(t, handled)
+	require.Same(t, unprepared, response)
+
+	// unknown prepared id, other responses and other requests are not handled
+	reqCtx = newRequestContext()
+	otherUnprepared := mockFrame(t, &message.Unprepared{ErrorMessage: "unprepared", Id: []byte("other")}, primitive.ProtocolVersion4)
+	response, handled = ch.handleTargetReprepare(reqCtx, otherUnprepared)
+	require.False(t, handled)
+	require.Same(t, otherUnprepared, response)
+	response, handled = ch.handleTargetReprepare(newRequestContext(), void)
+	require.False(t, handled)
+	require.Same(t, void, response)
+	query := NewRequestContext(execute, NewGenericRequestInfo(forwardToBoth, false, true), time.Now(), nil)
+	query.setTargetReprepare(mockQueryFrame(t, "INSERT INTO ks1.tb1 (a) VALUES (1)"), "ks1")
+	_, handled = ch.handleTargetReprepare(query, unprepared)
+	require.False(t, handled)
+	require.Empty(t, writeQueue)
+}
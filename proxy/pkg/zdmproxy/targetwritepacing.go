@@ -0,0 +1,176 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	targetWritePacingInterval      = time.Second
+	targetWritePacingMinRate       = 10.0
+	targetWritePacingDecrease      = 0.5
+	targetWritePacingIncreaseSteps = 20
+	targetWritePacingMaxDelay      = 0.5 // fraction of the time left before the request times out
+)
+
+// targetWritePacer slows down the writes sent to both clusters when TARGET throttles them (see
+// ZDM_TARGET_WRITES_ADAPTIVE_PACING), with an additive increase / multiplicative decrease of the pacing rate.
+//
+// Writes are not paced until TARGET returns an OVERLOADED error (or an error about a rate limit) for a write. The
+// pacing rate is then half of the rate of writes of the last interval and it is halved again, at most once per
+// interval, while TARGET keeps throttling writes. Every interval without throttling errors increases the rate by a
+// twentieth of the rate before throttling started and writes are not paced anymore once that rate is reached again.
+//
+// The delay of a write is reserved by the request worker before the write is queued (see dualWritesQueue), the write
+// and the writes queued after it on the same client connection are sent once the delay is over without holding a
+// worker in the meantime. The rate applies to every client connection of the proxy. A write is not delayed for more
+// than half of the time left before it times out, it fails right away with an OVERLOADED error instead.
+type targetWritePacer struct {
+	lock            *sync.Mutex
+	now             func() time.Time
+	rate            float64 // writes per second, 0 when writes are not paced
+	unpacedRate     float64 // rate of writes when throttling started
+	windowStart     time.Time
+	windowWrites    int64
+	lastWindowRate  float64
+	windowThrottled bool
+	lastDecrease    time.Time
+	nextWrite       time.Time
+}
+
+// newTargetWritePacer returns nil if ZDM_TARGET_WRITES_ADAPTIVE_PACING is disabled, a nil pacer doesn't pace writes.
+func newTargetWritePacer(enabled bool, now func() time.Time) *targetWritePacer {
+	if !enabled {
+		return nil
+	}
+	return &targetWritePacer{
+		lock:        &sync.Mutex{},
+		now:         now,
+		windowStart: now(),
+	}
+}
+
+// Reserve records a write and returns how long it has to wait before it is sent, or false if the delay would be more
+// than half of the time left before the provided deadline, i.e. when the request times out, in which case the write is
+// not recorded and must not be sent. A nil pacer doesn't delay writes.
+func (recv *targetWritePacer) Reserve(deadline time.Time) (time.Duration, bool) {
+	if recv == nil {
+		return 0, true
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	now := recv.now()
+	recv.advance(now)
+	if recv.rate <= 0 {
+		recv.windowWrites++
+		return 0, true
+	}
+	if recv.nextWrite.Before(now) {
+		recv.nextWrite = now
+	}
+	delay := recv.nextWrite.Sub(now)
+	if delay > 0 && float64(delay) > float64(deadline.Sub(now))*targetWritePacingMaxDelay {
+		return 0, false
+	}
+	recv.windowWrites++
+	recv.nextWrite = recv.nextWrite.Add(time.Duration(float64(time.Second) / recv.rate))
+	return delay, true
+}
+
+// OnTargetResponse is called with the TARGET response of every write sent to both clusters.
+func (recv *targetWritePacer) OnTargetResponse(response *frame.RawFrame) {
+	if recv == nil || response == nil || !isThrottlingResponse(response) {
+		return
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	now := recv.now()
+	recv.advance(now)
+	recv.windowThrottled = true
+	if recv.rate > 0 && now.Sub(recv.lastDecrease) < targetWritePacingInterval {
+		return
+	}
+
+	rate := recv.rate
+	if rate <= 0 {
+		recv.unpacedRate = recv.lastWindowRate
+		if windowRate := float64(recv.windowWrites); windowRate > recv.unpacedRate {
+			recv.unpacedRate = windowRate
+		}
+		if recv.unpacedRate < targetWritePacingMinRate {
+			recv.unpacedRate = targetWritePacingMinRate
+		}
+		rate = recv.unpacedRate
+	}
+	recv.rate = rate * targetWritePacingDecrease
+	if recv.rate < targetWritePacingMinRate {
+		recv.rate = targetWritePacingMinRate
+	}
+	recv.lastDecrease = now
+	log.Warnf("%v is throttling writes, pacing the writes sent to both clusters to %.0f per second.",
+		common.ClusterTypeTarget, recv.rate)
+}
+
+// advance starts a new interval if the current one is over and increases the rate if the previous intervals didn't
+// have throttling errors.
+func (recv *targetWritePacer) advance(now time.Time) {
+	elapsed := now.Sub(recv.windowStart)
+	if elapsed < targetWritePacingInterval {
+		return
+	}
+	recv.lastWindowRate = float64(recv.windowWrites) / elapsed.Seconds()
+	if recv.rate > 0 && !recv.windowThrottled {
+		intervals := float64(elapsed / targetWritePacingInterval)
+		recv.rate += intervals * recv.unpacedRate / targetWritePacingIncreaseSteps
+		if recv.rate >= recv.unpacedRate {
+			recv.rate = 0
+			log.Infof("%v is not throttling writes anymore, writes sent to both clusters are not paced.",
+				common.ClusterTypeTarget)
+		}
+	}
+	recv.windowStart = now
+	recv.windowWrites = 0
+	recv.windowThrottled = false
+}
+
+// GetRate returns the current pacing rate in writes per second, 0 if writes are not paced.
+func (recv *targetWritePacer) GetRate() float64 {
+	if recv == nil {
+		return 0
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.advance(recv.now())
+	return recv.rate
+}
+
+// isThrottlingResponse returns true for OVERLOADED errors and for errors whose message is about a rate limit, which is
+// how some managed clusters report that a request was throttled.
+func isThrottlingResponse(response *frame.RawFrame) bool {
+	if response.Header.OpCode != primitive.OpCodeError {
+		return false
+	}
+	errorMsg, err := decodeErrorResult(response)
+	if err != nil {
+		log.Debugf("Could not decode error response: %v", err)
+		return false
+	}
+	return errorMsg.GetErrorCode() == primitive.ErrorCodeOverloaded ||
+		strings.Contains(strings.ToLower(errorMsg.GetErrorMessage()), "rate limit")
+}
+
+// isPacedWrite returns true for the QUERY, EXECUTE and BATCH requests sent to both clusters, PREPARE requests and
+// the other requests of the client connection setup are not paced.
+func isPacedWrite(f *frame.RawFrame) bool {
+	switch f.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+		return true
+	default:
+		return false
+	}
+}
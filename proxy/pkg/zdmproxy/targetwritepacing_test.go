@@ -0,0 +1,120 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTargetWritePacer(t *testing.T) {
+	now := time.Unix(0, 0)
+	pacer := newTargetWritePacer(true, func() time.Time { return now })
+	overloaded := mockResultFrame(t, &message.Overloaded{ErrorMessage: "overloaded"})
+	reserve := func() time.Duration {
+		delay, ok := pacer.Reserve(now.Add(time.Minute))
+		require.True(t, ok)
+		return delay
+	}
+
+	// 400 writes per second without throttling are not paced
+	for i := 0; i < 400; i++ {
+		require.Equal(t, time.Duration(0), reserve())
+	}
+	now = now.Add(time.Second)
+	pacer.OnTargetResponse(mockResultFrame(t, &message.VoidResult{}))
+	require.Equal(t, 0.0, pacer.GetRate())
+
+	// multiplicative decrease, at most once per interval
+	pacer.OnTargetResponse(overloaded)
+	require.Equal(t, 200.0, pacer.GetRate())
+	pacer.OnTargetResponse(overloaded)
+	require.Equal(t, 200.0, pacer.GetRate())
+	require.Equal(t, time.Duration(0), reserve())
+	require.Equal(t, 5*time.Millisecond, reserve())
+	require.Equal(t, 10*time.Millisecond, reserve())
+
+	now = now.Add(time.Second)
+	pacer.OnTargetResponse(mockResultFrame(t, &message.ServerError{ErrorMessage: "Rate limit reached"}))
+	require.Equal(t, 100.0, pacer.GetRate())
+
+	// additive increase after intervals without throttling errors
+	now = now.Add(time.Second)
+	require.Equal(t, 100.0, pacer.GetRate())
+	now = now.Add(time.Second)
+	require.Equal(t, 120.0, pacer.GetRate())
+	now = now.Add(3 * time.Second)
+	require.Equal(t, 180.0, pacer.GetRate())
+
+	// writes are not paced anymore once the rate before throttling is reached
+	now = now.Add(20 * time.Second)
+	require.Equal(t, 0.0, pacer.GetRate())
+	require.Equal(t, time.Duration(0), reserve())
+}
+
+func TestTargetWritePacer_MinRate(t *testing.T) {
+	now := time.Unix(0, 0)
+	pacer := newTargetWritePacer(true, func() time.Time { return now })
+	overloaded := mockResultFrame(t, &message.Overloaded{ErrorMessage: "overloaded"})
+	for i := 0; i < 10; i++ {
+		pacer.OnTargetResponse(overloaded)
+		now = now.Add(time.Second)
+	}
+	require.Equal(t, targetWritePacingMinRate, pacer.GetRate())
+}
+
+func TestIsThrottlingResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *frame.RawFrame
+		expected bool
+	}{
+		{"overloaded", mockResultFrame(t, &message.Overloaded{ErrorMessage: "overloaded"}), true},
+		{"rate limit", mockResultFrame(t, &message.ServerError{ErrorMessage: "Rate limit reached"}), true},
+		{"server error", mockResultFrame(t, &message.ServerError{ErrorMessage: "error"}), false},
+		{"write timeout", mockResultFrame(t, &message.WriteTimeout{
+			Consistency: primitive.ConsistencyLevelOne, WriteType: primitive.WriteTypeSimple}), false},
+		{"void", mockResultFrame(t, &message.VoidResult{}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, isThrottlingResponse(tt.response))
+		})
+	}
+}
+
+func TestTargetWritePacer_Disabled(t *testing.T) {
+	pacer := newTargetWritePacer(false, time.Now)
+	require.Nil(t, pacer)
+	pacer.OnTargetResponse(mockResultFrame(t, &message.Overloaded{ErrorMessage: "overloaded"}))
+	require.Equal(t, 0.0, pacer.GetRate())
+	delay, ok := pacer.Reserve(time.Now())
+	require.True(t, ok)
+	require.Equal(t, time.Duration(0), delay)
+}
+
+func TestTargetWritePacer_MaxDelay(t *testing.T) {
+	now := time.Unix(0, 0)
+	pacer := newTargetWritePacer(true, func() time.Time { return now })
+	for i := 0; i < 20; i++ {
+		_, ok := pacer.Reserve(now)
+		require.True(t, ok)
+	}
+	now = now.Add(time.Second)
+	pacer.OnTargetResponse(mockResultFrame(t, &message.Overloaded{ErrorMessage: "overloaded"}))
+	require.Equal(t, targetWritePacingMinRate, pacer.GetRate())
+
+	// writes are delayed by 100ms, they fail when the delay is more than half of the time left before the deadline
+	for i := 0; i < 3; i++ {
+		delay, ok := pacer.Reserve(now.Add(time.Second))
+		require.True(t, ok)
+		require.Equal(t, time.Duration(i)*100*time.Millisecond, delay)
+	}
+	_, ok := pacer.Reserve(now.Add(500 * time.Millisecond))
+	require.False(t, ok)
+	delay, ok := pacer.Reserve(now.Add(600 * time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, 300*time.Millisecond, delay)
+}
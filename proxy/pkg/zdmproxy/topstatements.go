@@ -0,0 +1,254 @@
+package zdmproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	parser "github.com/datastax/zdm-proxy/antlr"
+	log "github.com/sirupsen/logrus"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	maxTrackedStatements        = 1000
+	maxNormalizedQueryCacheSize = 1000
+	otherStatementsFingerprint  = "other"
+	otherStatementsQuery        = "<other statements>"
+)
+
+// StatementStats are the counters of the statements with the same fingerprint.
+type StatementStats struct {
+	Fingerprint     string  `json:"fingerprint"`
+	Statement       string  `json:"statement"`
+	Requests        int64   `json:"requests"`
+	Writes          int64   `json:"writes"`
+	FailedWrites    int64   `json:"failed_writes"`
+	FailedWriteRate float64 `json:"failed_write_rate"`
+}
+
+// TopStatementsReport is the JSON document returned by the /debug/top-statements endpoint.
+type TopStatementsReport struct {
+	ByRequests        []*StatementStats `json:"by_requests"`
+	ByFailedWriteRate []*StatementStats `json:"by_failed_write_rate"`
+}
+
+// TopStatements counts the QUERY, EXECUTE and BATCH requests by statement fingerprint (see ZDM_METRICS_TOP_STATEMENTS)
+// so that the statements with the most requests and the highest rate of failed writes on either cluster can be listed
+// on the http server (/debug/top-statements).
+//
+// At most maxTrackedStatements fingerprints are tracked, the requests of any other statement are counted together.
+type TopStatements struct {
+	lock            *sync.Mutex
+	size            int
+	stats           map[string]*StatementStats
+	normalizedCache map[string]string
+}
+
+// NewTopStatements returns nil if size is 0.
+func NewTopStatements(size int) *TopStatements {
+	if size <= 0 {
+		return nil
+	}
+	return &TopStatements{
+		lock:            &sync.Mutex{},
+		size:            size,
+		stats:           make(map[string]*StatementStats),
+		normalizedCache: make(map[string]string),
+	}
+}
+
+// Record counts a request with the provided statement, write requests are requests that were sent to both clusters.
+func (recv *TopStatements) Record(statement string, write bool, failedWrite bool) {
+	if recv == nil || statement == "" {
+		return
+	}
+
+	recv.lock.Lock()
+	normalized, ok := recv.normalizedCache[statement]
+	recv.lock.Unlock()
+	if !ok {
+		normalized = normalizeQuery(statement)
+	}
+
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if !ok {
+		if len(recv.normalizedCache) >= maxNormalizedQueryCacheSize {
+			recv.normalizedCache = make(map[string]string)
+		}
+		recv.normalizedCache[statement] = normalized
+	}
+
+	fingerprint := getQueryFingerprint(normalized)
+	stats, ok := recv.stats[fingerprint]
+	if !ok {
+		if len(recv.stats) >= maxTrackedStatements {
+			fingerprint = otherStatementsFingerprint
+			normalized = otherStatementsQuery
+			stats, ok = recv.stats[fingerprint]
+		}
+		if !ok {
+			stats = &StatementStats{Fingerprint: fingerprint, Statement: normalized}
+			recv.stats[fingerprint] = stats
+		}
+	}
+	stats.Requests++
+	if write {
+		stats.Writes++
+		if failedWrite {
+			stats.FailedWrites++
+		}
+	}
+}
+
+// GetReport returns copies of the top statements by number of requests and by failed write rate.
+func (recv *TopStatements) GetReport() *TopStatementsReport {
+	recv.lock.Lock()
+	all := make([]*StatementStats, 0, len(recv.stats))
+	writes := make([]*StatementStats, 0, len(recv.stats))
+	for _, stats := range recv.stats {
+		statsCopy := *stats
+		if statsCopy.Writes > 0 {
+			statsCopy.FailedWriteRate = float64(statsCopy.FailedWrites) / float64(statsCopy.Writes)
+			writes = append(writes, &statsCopy)
+		}
+		all = append(all, &statsCopy)
+	}
+	recv.lock.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Requests != all[j].Requests {
+			return all[i].Requests > all[j].Requests
+		}
+		return all[i].Fingerprint < all[j].Fingerprint
+	})
+	sort.Slice(writes, func(i, j int) bool {
+		if writes[i].FailedWriteRate != writes[j].FailedWriteRate {
+			return writes[i].FailedWriteRate > writes[j].FailedWriteRate
+		}
+		if writes[i].FailedWrites != writes[j].FailedWrites {
+			return writes[i].FailedWrites > writes[j].FailedWrites
+		}
+		return writes[i].Fingerprint < writes[j].Fingerprint
+	})
+	if len(all) > recv.size {
+		all = all[:recv.size]
+	}
+	if len(writes) > recv.size {
+		writes = writes[:recv.size]
+	}
+	return &TopStatementsReport{ByRequests: all, ByFailedWriteRate: writes}
+}
+
+func (recv *TopStatements) GetHttpHandler() http.Handler {
+	return TopStatementsHandler(recv)
+}
+
+// TopStatementsHandler returns a JSON report of the top statements. The lists are empty if topStatements is nil
+// (i.e. the proxy is not running or ZDM_METRICS_TOP_STATEMENTS is 0).
+func TopStatementsHandler(topStatements *TopStatements) http.Handler {
+	return http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.NotFound(rsp, req)
+			return
+		}
+
+		report := &TopStatementsReport{ByRequests: []*StatementStats{}, ByFailedWriteRate: []*StatementStats{}}
+		if topStatements != nil {
+			report = topStatements.GetReport()
+		}
+		bytes, err := json.Marshal(report)
+		if err != nil {
+			log.Errorf("Could not encode top statements: %v", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		rsp.Header().Set("Content-Type", "application/json")
+		rsp.WriteHeader(http.StatusOK)
+		rsp.Write(bytes)
+	})
+}
+
+// normalizeQuery returns the query with its literals replaced by "?", keywords in upper case and a single space
+// between tokens. Comments are removed.
+func normalizeQuery(query string) string {
+	lexer := lexerPool.Get().(*parser.SimplifiedCqlLexer)
+	defer lexerPool.Put(lexer)
+	lexer.SetInputStream(antlr.NewInputStream(query))
+	tokens := lexer.GetAllTokens()
+
+	normalizedTokens := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token.GetChannel() != antlr.TokenDefaultChannel {
+			continue
+		}
+		switch token.GetTokenType() {
+		case parser.SimplifiedCqlLexerWS, parser.SimplifiedCqlLexerCOMMENT, parser.SimplifiedCqlLexerMULTILINE_COMMENT:
+		case parser.SimplifiedCqlLexerSTRING_LITERAL, parser.SimplifiedCqlLexerINTEGER, parser.SimplifiedCqlLexerFLOAT,
+			parser.SimplifiedCqlLexerBOOLEAN, parser.SimplifiedCqlLexerDURATION, parser.SimplifiedCqlLexerHEXNUMBER,
+			parser.SimplifiedCqlLexerUUID:
+			normalizedTokens = append(normalizedTokens, "?")
+		case parser.SimplifiedCqlLexerQUOTED_IDENTIFIER, parser.SimplifiedCqlLexerUNQUOTED_IDENTIFIER:
+			normalizedTokens = append(normalizedTokens, token.GetText())
+		default:
+			if isIdentifierToken(token) {
+				normalizedTokens = append(normalizedTokens, strings.ToUpper(token.GetText())) // keyword
+			} else {
+				normalizedTokens = append(normalizedTokens, token.GetText())
+			}
+		}
+	}
+	return strings.Join(normalizedTokens, " ")
+}
+
+func getQueryFingerprint(normalizedQuery string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(normalizedQuery))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// getStatementForTopStatements returns the statement of QUERY and EXECUTE requests and the statements of BATCH requests
+// separated by "; ", an empty string is returned for other requests.
+func getStatementForTopStatements(frameContext *frameDecodeContext, requestInfo RequestInfo) string {
+	switch frameContext.GetRawFrame().Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeBatch, primitive.OpCodeExecute:
+	default:
+		return ""
+	}
+
+	if executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo); ok {
+		return executeRequestInfo.GetPreparedData().GetPrepareRequestInfo().GetQuery()
+	}
+
+	decodedFrame, err := frameContext.GetOrDecodeFrame()
+	if err != nil {
+		return ""
+	}
+	switch typedMsg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		return typedMsg.Query
+	case *message.Batch:
+		var preparedDataByStmtIdx map[int]PreparedData
+		if batchRequestInfo, ok := requestInfo.(*BatchRequestInfo); ok {
+			preparedDataByStmtIdx = batchRequestInfo.GetPreparedDataByStmtIdx()
+		}
+		statements := make([]string, 0, len(typedMsg.Children))
+		for idx, child := range typedMsg.Children {
+			if preparedData, ok := preparedDataByStmtIdx[idx]; ok {
+				statements = append(statements, preparedData.GetPrepareRequestInfo().GetQuery())
+			} else {
+				statements = append(statements, child.Query)
+			}
+		}
+		return "BATCH " + strings.Join(statements, "; ")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,105 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"insert", "insert into ks1.tb1 (a, b) values (1, 'x')",
+			"INSERT INTO ks1 . tb1 ( a , b ) VALUES ( ? , ? )"},
+		{"select with literals", "SELECT * FROM tb1 WHERE id = 5d8f3a1e-0c8b-4b6f-9d3c-8a1b2c3d4e5f AND c > 1.5",
+			"SELECT * FROM tb1 WHERE id = ? AND c > ?"},
+		{"bind markers are kept", "UPDATE tb1 SET a = ? WHERE b = :b",
+			"UPDATE tb1 SET a = ? WHERE b = : b"},
+		{"whitespace and comments", "SELECT   a\n FROM tb1 -- comment\n WHERE b = 0x01",
+			"SELECT a FROM tb1 WHERE b = ?"},
+		{"quoted identifiers", `SELECT "A" FROM "Ks"."Tb" WHERE b = true`,
+			`SELECT "A" FROM "Ks" . "Tb" WHERE b = ?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, normalizeQuery(tt.query))
+		})
+	}
+
+	require.Equal(t,
+		getQueryFingerprint(normalizeQuery("INSERT INTO tb1 (a) VALUES (1)")),
+		getQueryFingerprint(normalizeQuery("insert into tb1 (a) values (2)")))
+	require.NotEqual(t,
+		getQueryFingerprint(normalizeQuery("INSERT INTO tb1 (a) VALUES (1)")),
+		getQueryFingerprint(normalizeQuery("INSERT INTO tb2 (a) VALUES (1)")))
+}
+
+func TestTopStatements(t *testing.T) {
+	require.Nil(t, NewTopStatements(0))
+	var nilTopStatements *TopStatements
+	nilTopStatements.Record("SELECT * FROM tb1", false, false)
+
+	topStatements := NewTopStatements(2)
+	for i := 0; i < 5; i++ {
+		topStatements.Record(fmt.Sprintf("SELECT * FROM tb1 WHERE a = %d", i), false, false)
+	}
+	for i := 0; i < 4; i++ {
+		topStatements.Record(fmt.Sprintf("INSERT INTO tb1 (a) VALUES (%d)", i), true, i == 0)
+	}
+	topStatements.Record("DELETE FROM tb1 WHERE a = 1", true, true)
+	topStatements.Record("", true, true)
+
+	report := topStatements.GetReport()
+	require.Equal(t, 2, len(report.ByRequests))
+	require.Equal(t, "SELECT * FROM tb1 WHERE a = ?", report.ByRequests[0].Statement)
+	require.Equal(t, int64(5), report.ByRequests[0].Requests)
+	require.Equal(t, int64(0), report.ByRequests[0].Writes)
+	require.Equal(t, "INSERT INTO tb1 ( a ) VALUES ( ? )", report.ByRequests[1].Statement)
+	require.Equal(t, int64(4), report.ByRequests[1].Requests)
+
+	require.Equal(t, 2, len(report.ByFailedWriteRate))
+	require.Equal(t, "DELETE FROM tb1 WHERE a = ?", report.ByFailedWriteRate[0].Statement)
+	require.Equal(t, 1.0, report.ByFailedWriteRate[0].FailedWriteRate)
+	require.Equal(t, "INSERT INTO tb1 ( a ) VALUES ( ? )", report.ByFailedWriteRate[1].Statement)
+	require.Equal(t, int64(1), report.ByFailedWriteRate[1].FailedWrites)
+	require.Equal(t, 0.25, report.ByFailedWriteRate[1].FailedWriteRate)
+}
+
+func TestTopStatements_MaxTrackedStatements(t *testing.T) {
+	topStatements := NewTopStatements(maxTrackedStatements + 1)
+	for i := 0; i < maxTrackedStatements+10; i++ {
+		topStatements.Record(fmt.Sprintf("SELECT * FROM tb%d", i), false, false)
+	}
+
+	report := topStatements.GetReport()
+	require.Equal(t, maxTrackedStatements+1, len(report.ByRequests))
+	require.Equal(t, otherStatementsQuery, report.ByRequests[0].Statement)
+	require.Equal(t, int64(10), report.ByRequests[0].Requests)
+}
+
+func TestGetStatementForTopStatements(t *testing.T) {
+	prepareRequestInfo := NewPrepareRequestInfo(
+		NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "INSERT INTO tb1 (a) VALUES (?)", "")
+	preparedData := NewPreparedData(&message.PreparedResult{}, &message.PreparedResult{}, prepareRequestInfo)
+
+	query := &frameDecodeContext{frame: mockQueryFrame(t, "SELECT * FROM tb1")}
+	require.Equal(t, "SELECT * FROM tb1", getStatementForTopStatements(query, NewGenericRequestInfo(forwardToOrigin, false, true)))
+
+	execute := &frameDecodeContext{frame: mockFrame(t, &message.Execute{QueryId: []byte("id")}, primitive.ProtocolVersion4)}
+	require.Equal(t, "INSERT INTO tb1 (a) VALUES (?)", getStatementForTopStatements(execute, NewExecuteRequestInfo(preparedData)))
+
+	batch := &frameDecodeContext{frame: mockFrame(t, &message.Batch{Children: []*message.BatchChild{
+		{Query: "DELETE FROM tb1 WHERE a = 1"},
+		{Id: []byte("id")},
+	}}, primitive.ProtocolVersion4)}
+	require.Equal(t, "BATCH DELETE FROM tb1 WHERE a = 1; INSERT INTO tb1 (a) VALUES (?)",
+		getStatementForTopStatements(batch, NewBatchRequestInfo(map[int]PreparedData{1: preparedData})))
+
+	options := &frameDecodeContext{frame: mockFrame(t, &message.Options{}, primitive.ProtocolVersion4)}
+	require.Equal(t, "", getStatementForTopStatements(options, NewGenericRequestInfo(forwardToBoth, true, false)))
+}
@@ -0,0 +1,153 @@
+package zdmproxy
+
+import (
+	"encoding/json"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxRecentUnparseableRequests     = 100
+	maxUnparseableRequestQueryLength = 1024
+
+	UnparseableReasonUnrecognizedStatement = "unrecognized_statement"
+	UnparseableReasonDecodeError           = "decode_error"
+)
+
+// UnparseableRequest is a request that the proxy could not fully inspect.
+type UnparseableRequest struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Reason      string    `json:"reason"`
+	OpCode      string    `json:"opcode"`
+	Query       string    `json:"query,omitempty"` // with its literals replaced by "?", see normalizeQuery
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// UnparseableRequests keeps the most recent requests that the proxy could not fully inspect so that they can be listed
+// on the http server (/debug/unparseable-requests) and counts them in proxy_unparseable_requests_total.
+//
+// Requests with statements that the CQL parser of the proxy doesn't recognize (e.g. DDL statements) are still forwarded
+// (to both clusters, like writes) but the features that rely on inspecting the statement (system query routing, CQL
+// function replacement, table filters) don't apply to them. Requests that can not be decoded are not forwarded at all.
+type UnparseableRequests struct {
+	lock   *sync.Mutex
+	recent []*UnparseableRequest
+	next   int
+}
+
+func NewUnparseableRequests() *UnparseableRequests {
+	return &UnparseableRequests{
+		lock:   &sync.Mutex{},
+		recent: make([]*UnparseableRequest, 0, maxRecentUnparseableRequests),
+		next:   0,
+	}
+}
+
+// RecordUnrecognizedStatements records the statements of the provided request that were inspected and not recognized
+// by the CQL parser. QUERY and PREPARE requests are always inspected, the child statements of a BATCH are only inspected
+// when a feature needs them (e.g. table filters).
+//
+// The literals of the statements are not recorded because the http server is not authenticated and unrecognized
+// statements include statements with credentials, e.g. CREATE ROLE ... WITH PASSWORD = '...'.
+func (recv *UnparseableRequests) RecordUnrecognizedStatements(
+	frameContext *frameDecodeContext, proxyMetrics *metrics.ProxyMetrics) {
+	if recv == nil || frameContext.statementsQueryData == nil {
+		return
+	}
+	for _, stmtQueryData := range frameContext.statementsQueryData {
+		if stmtQueryData.queryData.getStatementType() != statementTypeOther {
+			continue
+		}
+		proxyMetrics.UnrecognizedStatements.Add(1)
+		normalizedQuery := normalizeQuery(stmtQueryData.queryData.getQuery())
+		recv.add(&UnparseableRequest{
+			Timestamp:   time.Now(),
+			Reason:      UnparseableReasonUnrecognizedStatement,
+			OpCode:      frameContext.GetRawFrame().Header.OpCode.String(),
+			Query:       truncateUnparseableQuery(normalizedQuery),
+			Fingerprint: getQueryFingerprint(normalizedQuery),
+		})
+	}
+}
+
+// RecordDecodeError records a request that could not be decoded or inspected.
+func (recv *UnparseableRequests) RecordDecodeError(
+	opCode primitive.OpCode, err error, proxyMetrics *metrics.ProxyMetrics) {
+	if recv == nil {
+		return
+	}
+	proxyMetrics.RequestDecodeErrors.Add(1)
+	recv.add(&UnparseableRequest{
+		Timestamp: time.Now(),
+		Reason:    UnparseableReasonDecodeError,
+		OpCode:    opCode.String(),
+		Error:     err.Error(),
+	})
+}
+
+func (recv *UnparseableRequests) add(request *UnparseableRequest) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if len(recv.recent) < maxRecentUnparseableRequests {
+		recv.recent = append(recv.recent, request)
+	} else {
+		recv.recent[recv.next] = request
+	}
+	recv.next = (recv.next + 1) % maxRecentUnparseableRequests
+}
+
+// GetRecent returns the most recent unparseable requests, oldest first.
+func (recv *UnparseableRequests) GetRecent() []*UnparseableRequest {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recent := make([]*UnparseableRequest, 0, len(recv.recent))
+	if len(recv.recent) == maxRecentUnparseableRequests {
+		recent = append(recent, recv.recent[recv.next:]...)
+		recent = append(recent, recv.recent[:recv.next]...)
+	} else {
+		recent = append(recent, recv.recent...)
+	}
+	return recent
+}
+
+func (recv *UnparseableRequests) GetHttpHandler() http.Handler {
+	return UnparseableRequestsHandler(recv)
+}
+
+// UnparseableRequestsHandler returns a JSON list of the most recent unparseable requests. The list is empty
+// if unparseableRequests is nil (i.e. the proxy is not running).
+func UnparseableRequestsHandler(unparseableRequests *UnparseableRequests) http.Handler {
+	return http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.NotFound(rsp, req)
+			return
+		}
+
+		recent := make([]*UnparseableRequest, 0)
+		if unparseableRequests != nil {
+			recent = unparseableRequests.GetRecent()
+		}
+		bytes, err := json.Marshal(recent)
+		if err != nil {
+			log.Errorf("Could not encode unparseable requests: %v", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		rsp.Header().Set("Content-Type", "application/json")
+		rsp.WriteHeader(http.StatusOK)
+		rsp.Write(bytes)
+	})
+}
+
+func truncateUnparseableQuery(query string) string {
+	if len(query) <= maxUnparseableRequestQueryLength {
+		return query
+	}
+	return query[:maxUnparseableRequestQueryLength] + "..."
+}
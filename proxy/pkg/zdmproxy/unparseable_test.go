@@ -0,0 +1,70 @@
+package zdmproxy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUnparseableRequests_RecordUnrecognizedStatements(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		query    string
+		recorded string
+	}{
+		{"insert", "INSERT INTO ks1.tb1 (a) VALUES (1)", ""},
+		{"select", "SELECT * FROM ks1.tb1", ""},
+		{"use", "USE ks1", ""},
+		{"insert json", "INSERT INTO ks1.tb1 JSON '{}'", ""},
+		{"create table", "CREATE TABLE ks1.tb1 (a int PRIMARY KEY)", "CREATE TABLE ks1 . tb1 ( a INT PRIMARY KEY )"},
+		{"create role", "CREATE ROLE r1 WITH PASSWORD = 's3cr3t' AND LOGIN = true",
+			"CREATE ROLE r1 WITH PASSWORD = ? AND LOGIN = ?"},
+		{"alter role", "ALTER ROLE r1 WITH PASSWORD = $$s3cr3t$$", "ALTER ROLE r1 WITH PASSWORD = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &frameDecodeContext{frame: mockQueryFrame(t, tt.query)}
+			_, err := f.GetOrInspectStatement("", timeUuidGenerator)
+			require.Nil(t, err)
+
+			unparseableRequests := NewUnparseableRequests()
+			unparseableRequests.RecordUnrecognizedStatements(f, newFakeProxyMetrics())
+			recent := unparseableRequests.GetRecent()
+			if tt.recorded == "" {
+				require.Empty(t, recent)
+				return
+			}
+			require.Equal(t, 1, len(recent))
+			require.Equal(t, UnparseableReasonUnrecognizedStatement, recent[0].Reason)
+			require.Equal(t, primitive.OpCodeQuery.String(), recent[0].OpCode)
+			require.Equal(t, tt.recorded, recent[0].Query)
+			require.Equal(t, getQueryFingerprint(tt.recorded), recent[0].Fingerprint)
+		})
+	}
+}
+
+func TestUnparseableRequests_GetRecent(t *testing.T) {
+	unparseableRequests := NewUnparseableRequests()
+	for i := 0; i < maxRecentUnparseableRequests+10; i++ {
+		unparseableRequests.RecordDecodeError(primitive.OpCodeQuery, errors.New(fmt.Sprintf("%v", i)), newFakeProxyMetrics())
+	}
+
+	recent := unparseableRequests.GetRecent()
+	require.Equal(t, maxRecentUnparseableRequests, len(recent))
+	for i, request := range recent {
+		require.Equal(t, UnparseableReasonDecodeError, request.Reason)
+		require.Equal(t, fmt.Sprintf("%v", i+10), request.Error)
+	}
+}
+
+func TestUnparseableRequests_Nil(t *testing.T) {
+	var unparseableRequests *UnparseableRequests
+	f := &frameDecodeContext{frame: mockQueryFrame(t, "CREATE TABLE ks1.tb1 (a int PRIMARY KEY)")}
+	unparseableRequests.RecordUnrecognizedStatements(f, newFakeProxyMetrics())
+	unparseableRequests.RecordDecodeError(primitive.OpCodeQuery, errors.New("test"), newFakeProxyMetrics())
+}
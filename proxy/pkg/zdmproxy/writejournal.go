@@ -0,0 +1,263 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/capture"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeJournal records the writes that failed on TARGET but were not returned to the client because of the JOURNAL
+// failures (see ZDM_DUAL_WRITES_FAILURES and ZDM_TABLE_WRITE_POLICIES) to ZDM_DUAL_WRITES_JOURNAL_FILE so that they can
+// be sent to TARGET again later with tools/zdm-replay.
+//
+// The journal is a capture file (see ZDM_PROXY_CAPTURE_FILE) with a single connection per protocol version so that
+// the replay tool sends the writes one at a time in the order in which they were journaled. Every write is recorded
+// as it was sent to TARGET, preceded by a USE request with the keyspace of the client connection and by the PREPARE
+// requests of its prepared statements, so it doesn't depend on the writes journaled before it. Records are appended
+// to the file if it already exists.
+type writeJournal struct {
+	lock        sync.Mutex
+	writer      *capture.Writer
+	connections map[primitive.ProtocolVersion]bool
+	failed      bool
+}
+
+// newWriteJournal returns nil if the journal is disabled.
+func newWriteJournal(path string) (*writeJournal, error) {
+	if path == "" {
+		return nil, nil
+	}
+	writer, err := capture.NewAppendWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Journaling the writes that fail on %v to %v.", common.ClusterTypeTarget, path)
+	return &writeJournal{
+		writer:      writer,
+		connections: make(map[primitive.ProtocolVersion]bool),
+		failed:      false,
+	}, nil
+}
+
+// Write adds the provided requests to the journal, the last request is the write and the other requests are the
+// requests that the write depends on.
+func (recv *writeJournal) Write(requests []*frame.RawFrame) {
+	if recv == nil || len(requests) == 0 {
+		return
+	}
+	version := requests[len(requests)-1].Header.Version
+	connectionId := uint64(version)
+	now := time.Now()
+
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	var err error
+	if !recv.connections[version] {
+		var startup *frame.RawFrame
+		startup, err = defaultCodec.ConvertToRawFrame(frame.NewFrame(version, 0, message.NewStartup()))
+		if err == nil {
+			err = recv.writer.Write(&capture.Record{Timestamp: now, ConnectionId: connectionId, Frame: startup})
+		}
+		recv.connections[version] = err == nil
+	}
+	for _, request := range requests {
+		if err != nil {
+			break
+		}
+		err = recv.writer.Write(&capture.Record{Timestamp: now, ConnectionId: connectionId, Frame: request})
+	}
+	if err != nil && !recv.failed {
+		recv.failed = true
+		log.Errorf("Could not write to the write journal, the journal is incomplete: %v", err)
+	}
+}
+
+func (recv *writeJournal) Close() {
+	if recv == nil {
+		return
+	}
+	err := recv.writer.Close()
+	if err != nil {
+		log.Warnf("Error while closing write journal: %v", err)
+	}
+}
+
+// setJournaledWriteTimestamp returns the provided ORIGIN and TARGET requests of a write whose TARGET failures are
+// journaled with a client side timestamp, the time at which the proxy received the write, if the write doesn't have
+// one. Without it a journaled write would get the time of the replay as timestamp and would overwrite the writes that
+// were applied on both clusters after it. A timestamp set with USING TIMESTAMP takes precedence over the client side
+// timestamp. Protocol v2 requests can't have a client side timestamp so they are returned unchanged (and their TARGET
+// failures are not journaled).
+func setJournaledWriteTimestamp(
+	originRequest *frame.RawFrame, targetRequest *frame.RawFrame, receivedTime time.Time) (*frame.RawFrame, *frame.RawFrame, error) {
+	timestamp := receivedTime.UnixNano() / int64(time.Microsecond)
+	originRequest, err := setDefaultTimestamp(originRequest, timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetRequest, err = setDefaultTimestamp(targetRequest, timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return originRequest, targetRequest, nil
+}
+
+// setDefaultTimestamp returns a copy of the provided QUERY, EXECUTE or BATCH request with the provided client side
+// timestamp or the provided request if it already has one.
+func setDefaultTimestamp(f *frame.RawFrame, timestamp int64) (*frame.RawFrame, error) {
+	if f.Header.Version < primitive.ProtocolVersion3 {
+		return f, nil
+	}
+	switch f.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return f, nil
+	}
+
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %v request to set its timestamp: %w", f.Header.OpCode, err)
+	}
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		if msg.Options == nil {
+			msg.Options = &message.QueryOptions{}
+		}
+		if msg.Options.DefaultTimestamp != nil {
+			return f, nil
+		}
+		msg.Options.DefaultTimestamp = &timestamp
+	case *message.Execute:
+		if msg.Options == nil {
+			msg.Options = &message.QueryOptions{}
+		}
+		if msg.Options.DefaultTimestamp != nil {
+			return f, nil
+		}
+		msg.Options.DefaultTimestamp = &timestamp
+	case *message.Batch:
+		if msg.DefaultTimestamp != nil {
+			return f, nil
+		}
+		msg.DefaultTimestamp = &timestamp
+	default:
+		return f, nil
+	}
+
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert %v request with timestamp to raw frame: %w", f.Header.OpCode, err)
+	}
+	return newRawFrame, nil
+}
+
+// journalTargetWrite adds the TARGET request of the provided write to the journal if it failed or timed out on TARGET,
+// its failures are journaled, the ORIGIN response was returned to the client, the write is idempotent and it has a
+// client side timestamp (see setJournaledWriteTimestamp). A write that timed out can still be applied on TARGET later,
+// which is fine because only idempotent writes are journaled.
+func (ch *ClientHandler) journalTargetWrite(reqCtx *requestContextImpl, responseClusterType common.ClusterType) {
+	if reqCtx.journalRequest == nil || responseClusterType != common.ClusterTypeOrigin {
+		return
+	}
+	if reqCtx.targetResponse != nil &&
+		(isResponseSuccessful(reqCtx.targetResponse) || isUnpreparedResponse(reqCtx.targetResponse)) {
+		return
+	}
+
+	// journaled writes are sent to TARGET again so writes that are not idempotent are not journaled
+	idempotent, err := isIdempotentRequest(
+		reqCtx.journalRequest, reqCtx.requestInfo, reqCtx.journalKeyspace, ch.idempotencyOverrides)
+	if err != nil {
+		log.Errorf("Could not journal write that failed on %v: %v", common.ClusterTypeTarget, err)
+		return
+	}
+	if !idempotent {
+		log.Warnf("Not journaling write that failed on %v because it is not idempotent (%v), "+
+			"see ZDM_IDEMPOTENCY_OVERRIDES.", common.ClusterTypeTarget, reqCtx.journalRequest.Header)
+		return
+	}
+
+	if !hasClientTimestamp(NewFrameDecodeContext(reqCtx.journalRequest)) {
+		log.Warnf("Not journaling write that failed on %v because it doesn't have a client side timestamp (%v), "+
+			"a replay would overwrite the writes applied after it.", common.ClusterTypeTarget, reqCtx.journalRequest.Header)
+		return
+	}
+
+	requests, err := ch.getWriteJournalRequests(reqCtx.journalRequest, reqCtx.journalKeyspace, reqCtx.requestInfo)
+	if err != nil {
+		log.Errorf("Could not journal write that failed on %v: %v", common.ClusterTypeTarget, err)
+		return
+	}
+	log.Debugf("Journaling write that failed on %v (%v).", common.ClusterTypeTarget, reqCtx.journalRequest.Header)
+	ch.writeJournal.Write(requests)
+}
+
+// getWriteJournalRequests returns the requests that have to be journaled for the provided TARGET request: a USE
+// request if the client connection has a keyspace, the PREPARE requests of its prepared statements and the request.
+func (ch *ClientHandler) getWriteJournalRequests(
+	request *frame.RawFrame, keyspace string, requestInfo RequestInfo) ([]*frame.RawFrame, error) {
+	version := request.Header.Version
+	requests := make([]*frame.RawFrame, 0, 3)
+	if keyspace != "" {
+		useFrame, err := newUseRawFrame(version, keyspace)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, useFrame)
+	}
+
+	var preparedStatements []PreparedData
+	switch typedRequestInfo := requestInfo.(type) {
+	case *ExecuteRequestInfo:
+		preparedStatements = append(preparedStatements, typedRequestInfo.GetPreparedData())
+	case *BatchRequestInfo:
+		stmtIndexes := make([]int, 0, len(typedRequestInfo.GetPreparedDataByStmtIdx()))
+		for stmtIdx := range typedRequestInfo.GetPreparedDataByStmtIdx() {
+			stmtIndexes = append(stmtIndexes, stmtIdx)
+		}
+		sort.Ints(stmtIndexes)
+		for _, stmtIdx := range stmtIndexes {
+			preparedStatements = append(preparedStatements, typedRequestInfo.GetPreparedDataByStmtIdx()[stmtIdx])
+		}
+	}
+	prepared := make(map[string]bool)
+	for _, preparedData := range preparedStatements {
+		targetPreparedId := string(preparedData.GetTargetPreparedId())
+		if prepared[targetPreparedId] {
+			continue
+		}
+		prepared[targetPreparedId] = true
+		prepareRequestInfo := preparedData.GetPrepareRequestInfo()
+		prepareFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(version, 0, &message.Prepare{
+			Query:    prepareRequestInfo.GetQuery(),
+			Keyspace: prepareRequestInfo.GetKeyspace(),
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("could not convert PREPARE request to raw frame: %w", err)
+		}
+		// statements are prepared on TARGET with qualified table names when ZDM_TARGET_QUALIFY_TABLE_NAMES is enabled
+		prepareFrame, err = ch.targetTableNameQualifier.Apply(prepareFrame, keyspace)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, prepareFrame)
+	}
+	return append(requests, request), nil
+}
+
+// newUseRawFrame returns a USE request for the provided keyspace.
+func newUseRawFrame(version primitive.ProtocolVersion, keyspace string) (*frame.RawFrame, error) {
+	useFrame := frame.NewFrame(version, 0, &message.Query{
+		Query:   fmt.Sprintf("USE \"%v\"", strings.ReplaceAll(keyspace, "\"", "\"\"")),
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	})
+	return defaultCodec.ConvertToRawFrame(useFrame)
+}
@@ -0,0 +1,242 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/capture"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetWriteJournalRequests(t *testing.T) {
+	preparedData := NewPreparedData(
+		&message.PreparedResult{PreparedQueryId: []byte("origin")},
+		&message.PreparedResult{PreparedQueryId: []byte("target")},
+		NewPrepareRequestInfo(NewTableWritePolicyRequestInfo(forwardToBoth, writeFailuresJournal), nil, false,
+			"INSERT INTO tb1 (a) VALUES (?)", ""))
+
+	tests := []struct {
+		name        string
+		qualify     bool
+		requestInfo RequestInfo
+		keyspace    string
+		expected    []message.Message
+	}{
+		{"query without keyspace", false, NewTableWritePolicyRequestInfo(forwardToBoth, writeFailuresJournal), "",
+			[]message.Message{
+				&message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)", Options: &message.QueryOptions{}}}},
+		{"execute", false, NewExecuteRequestInfo(preparedData), "ks1",
+			[]message.Message{
+				&message.Query{Query: "USE \"ks1\"", Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}},
+				&message.Prepare{Query: "INSERT INTO tb1 (a) VALUES (?)"},
+				&message.Execute{QueryId: []byte("target"), Options: &message.QueryOptions{}}}},
+		{"execute with qualified table names", true, NewExecuteRequestInfo(preparedData), "ks1",
+			[]message.Message{
+				&message.Query{Query: "USE \"ks1\"", Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}},
+				&message.Prepare{Query: "INSERT INTO \"ks1\".tb1 (a) VALUES (?)"},
+				&message.Execute{QueryId: []byte("target"), Options: &message.QueryOptions{}}}},
+		{"batch", false, NewTableWritePolicyBatchRequestInfo(
+			map[int]PreparedData{0: preparedData, 1: preparedData}, forwardToBoth, writeFailuresJournal), "ks1",
+			[]message.Message{
+				&message.Query{Query: "USE \"ks1\"", Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}},
+				&message.Prepare{Query: "INSERT INTO tb1 (a) VALUES (?)"},
+				&message.Batch{Children: []*message.BatchChild{
+					{Id: []byte("target"), Values: []*primitive.Value{}},
+					{Id: []byte("target"), Values: []*primitive.Value{}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{targetTableNameQualifier: newTableNameQualifier(tt.qualify)}
+			f := mockFrame(t, tt.expected[len(tt.expected)-1], primitive.ProtocolVersion4)
+			requests, err := ch.getWriteJournalRequests(f, tt.keyspace, tt.requestInfo)
+			require.Nil(t, err)
+			require.Equal(t, len(tt.expected), len(requests))
+			for i, expected := range tt.expected {
+				decoded, err := defaultCodec.ConvertFromRawFrame(requests[i])
+				require.Nil(t, err)
+				require.Equal(t, expected, decoded.Body.Message)
+			}
+			require.Same(t, f, requests[len(requests)-1])
+		})
+	}
+}
+
+func TestWriteJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.bin")
+	journal, err := newWriteJournal(path)
+	require.Nil(t, err)
+	ch := &ClientHandler{writeJournal: journal}
+
+	timestamp := int64(1)
+	write := mockFrame(t, &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)",
+		Options: &message.QueryOptions{DefaultTimestamp: &timestamp}}, primitive.ProtocolVersion4)
+	void := mockFrame(t, &message.VoidResult{}, primitive.ProtocolVersion4)
+	writeTimeout := mockFrame(t, &message.WriteTimeout{
+		ErrorMessage: "write timeout", Consistency: primitive.ConsistencyLevelQuorum, WriteType: primitive.WriteTypeSimple},
+		primitive.ProtocolVersion4)
+	unprepared := mockFrame(t, &message.Unprepared{ErrorMessage: "unprepared", Id: []byte{1}}, primitive.ProtocolVersion4)
+	newRequestContext := func(journal bool, targetResponse *frame.RawFrame) *requestContextImpl {
+		reqCtx := NewRequestContext(
+			write, NewTableWritePolicyRequestInfo(forwardToBoth, writeFailuresJournal), time.Now(), nil)
+		if journal {
+			reqCtx.journalRequest = write
+		}
+		reqCtx.originResponse = void
+		reqCtx.targetResponse = targetResponse
+		return reqCtx
+	}
+
+	ch.journalTargetWrite(newRequestContext(true, writeTimeout), common.ClusterTypeOrigin)
+	ch.journalTargetWrite(newRequestContext(true, void), common.ClusterTypeOrigin)
+	ch.journalTargetWrite(newRequestContext(true, unprepared), common.ClusterTypeOrigin)
+	ch.journalTargetWrite(newRequestContext(true, writeTimeout), common.ClusterTypeTarget)
+	ch.journalTargetWrite(newRequestContext(false, writeTimeout), common.ClusterTypeOrigin)
+	nonIdempotentReqCtx := newRequestContext(true, writeTimeout)
+	nonIdempotentReqCtx.journalRequest = mockFrame(
+		t, &message.Query{Query: "UPDATE ks1.tb1 SET l = l + [1] WHERE a = 1"}, primitive.ProtocolVersion4)
+	ch.journalTargetWrite(nonIdempotentReqCtx, common.ClusterTypeOrigin)
+	withoutTimestampReqCtx := newRequestContext(true, writeTimeout)
+	withoutTimestampReqCtx.journalRequest = mockFrame(
+		t, &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"}, primitive.ProtocolVersion4)
+	ch.journalTargetWrite(withoutTimestampReqCtx, common.ClusterTypeOrigin)
+	ch.journalTargetWrite(newRequestContext(true, writeTimeout), common.ClusterTypeOrigin)
+	ch.journalTargetWrite(newRequestContext(true, nil), common.ClusterTypeOrigin) // timed out on TARGET
+	journal.Close()
+
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+	reader, err := capture.NewReader(file)
+	require.Nil(t, err)
+	var opCodes []primitive.OpCode
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		require.Equal(t, uint64(primitive.ProtocolVersion4), record.ConnectionId)
+		opCodes = append(opCodes, record.Frame.Header.OpCode)
+	}
+	require.Equal(t, []primitive.OpCode{primitive.OpCodeStartup, primitive.OpCodeQuery, primitive.OpCodeQuery, primitive.OpCodeQuery}, opCodes)
+}
+
+func TestSetJournaledWriteTimestamp(t *testing.T) {
+	receivedTime := time.UnixMicro(1000)
+	receivedTimestamp := int64(1000)
+	timestamp := int64(10)
+	tests := []struct {
+		name     string
+		request  message.Message
+		version  primitive.ProtocolVersion
+		expected *int64
+	}{
+		{"query", &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"}, primitive.ProtocolVersion4, &receivedTimestamp},
+		{"query with timestamp", &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)",
+			Options: &message.QueryOptions{DefaultTimestamp: &timestamp}}, primitive.ProtocolVersion4, &timestamp},
+		{"execute", &message.Execute{QueryId: []byte("id")}, primitive.ProtocolVersion4, &receivedTimestamp},
+		{"batch", &message.Batch{Children: []*message.BatchChild{{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"}}},
+			primitive.ProtocolVersion4, &receivedTimestamp},
+		{"protocol v2", &message.Query{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"}, primitive.ProtocolVersion2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, tt.request, tt.version)
+			originRequest, targetRequest, err := setJournaledWriteTimestamp(f, f, receivedTime)
+			require.Nil(t, err)
+			for _, request := range []*frame.RawFrame{originRequest, targetRequest} {
+				require.Equal(t, tt.expected != nil, hasClientTimestamp(NewFrameDecodeContext(request)))
+				if tt.expected == nil || *tt.expected == timestamp {
+					require.Same(t, f, request)
+				} else {
+					require.Equal(t, *tt.expected, getTestRequestTimestamp(t, request, 0))
+				}
+			}
+		})
+	}
+}
+
+func TestWriteJournal_ReplayDoesNotOverwriteNewerWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.bin")
+	journal, err := newWriteJournal(path)
+	require.Nil(t, err)
+	ch := &ClientHandler{writeJournal: journal}
+	requestInfo := NewTableWritePolicyRequestInfo(forwardToBoth, writeFailuresJournal)
+
+	// the value and timestamp of a row on TARGET, the write with the highest timestamp wins
+	var targetValue string
+	var targetTimestamp int64
+	applyOnTarget := func(request *frame.RawFrame, serverTimestamp int64) {
+		decoded, err := defaultCodec.ConvertFromRawFrame(request)
+		require.Nil(t, err)
+		query := decoded.Body.Message.(*message.Query)
+		if timestamp := getTestRequestTimestamp(t, request, serverTimestamp); timestamp >= targetTimestamp {
+			targetValue, targetTimestamp = query.Query, timestamp
+		}
+	}
+
+	// write A fails on TARGET at t1 and is journaled
+	writeA := mockFrame(t, &message.Query{Query: "UPDATE ks1.tb1 SET b = 'A' WHERE a = 1"}, primitive.ProtocolVersion4)
+	_, targetRequestA, err := setJournaledWriteTimestamp(writeA, writeA, time.UnixMicro(1))
+	require.Nil(t, err)
+	reqCtx := NewRequestContext(writeA, requestInfo, time.Now(), nil)
+	reqCtx.journalRequest = targetRequestA
+	reqCtx.originResponse = mockFrame(t, &message.VoidResult{}, primitive.ProtocolVersion4)
+	reqCtx.targetResponse = mockFrame(t, &message.Overloaded{ErrorMessage: "overloaded"}, primitive.ProtocolVersion4)
+	ch.journalTargetWrite(reqCtx, common.ClusterTypeOrigin)
+	journal.Close()
+
+	// write B succeeds on both clusters at t2
+	writeB := mockFrame(t, &message.Query{Query: "UPDATE ks1.tb1 SET b = 'B' WHERE a = 1"}, primitive.ProtocolVersion4)
+	_, targetRequestB, err := setJournaledWriteTimestamp(writeB, writeB, time.UnixMicro(2))
+	require.Nil(t, err)
+	applyOnTarget(targetRequestB, 2)
+
+	// write A is replayed at t3
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+	reader, err := capture.NewReader(file)
+	require.Nil(t, err)
+	var replayed []*frame.RawFrame
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		if record.Frame.Header.OpCode == primitive.OpCodeQuery {
+			replayed = append(replayed, record.Frame)
+		}
+	}
+	require.Equal(t, 1, len(replayed))
+	applyOnTarget(replayed[0], 3)
+	require.Equal(t, "UPDATE ks1.tb1 SET b = 'B' WHERE a = 1", targetValue)
+	require.Equal(t, int64(2), targetTimestamp)
+}
+
+// getTestRequestTimestamp returns the client side timestamp of the provided request or the provided server timestamp
+// if it doesn't have one.
+func getTestRequestTimestamp(t *testing.T, request *frame.RawFrame, serverTimestamp int64) int64 {
+	decoded, err := defaultCodec.ConvertFromRawFrame(request)
+	require.Nil(t, err)
+	var timestamp *int64
+	switch msg := decoded.Body.Message.(type) {
+	case *message.Query:
+		timestamp = msg.Options.DefaultTimestamp
+	case *message.Execute:
+		timestamp = msg.Options.DefaultTimestamp
+	case *message.Batch:
+		timestamp = msg.DefaultTimestamp
+	}
+	if timestamp == nil {
+		return serverTimestamp
+	}
+	return *timestamp
+}
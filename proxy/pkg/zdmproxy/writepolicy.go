@@ -0,0 +1,176 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+)
+
+// tableWritePolicies decides, based on ZDM_TABLE_WRITE_POLICIES and ZDM_DUAL_WRITES_FAILURES, which clusters the
+// writes on a table are sent to and whether failures on the cluster that is not the primary cluster are returned to
+// the client.
+//
+// Entries are either a keyspace name (every table of the keyspace) or a keyspace.table name, a table entry takes
+// precedence over the entry of its keyspace. Names are matched like the entries of ZDM_EXCLUDED_TABLES.
+type tableWritePolicies struct {
+	policies        map[string]config.TableWritePolicy
+	defaultFailures writeFailures
+}
+
+// writeFailures is how failures on the cluster that is not the primary cluster are handled for a write sent to both
+// clusters.
+type writeFailures int
+
+const (
+	writeFailuresFatal      = writeFailures(iota) // failures are returned to the client
+	writeFailuresBestEffort                       // failures are only logged and counted in the failed writes metrics
+	writeFailuresJournal                          // like best effort and failed TARGET writes are added to the journal
+)
+
+func (recv writeFailures) String() string {
+	switch recv {
+	case writeFailuresFatal:
+		return config.WriteFailuresFatal
+	case writeFailuresBestEffort:
+		return config.WriteFailuresBestEffort
+	case writeFailuresJournal:
+		return config.WriteFailuresJournal
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", int(recv))
+	}
+}
+
+func parseWriteFailures(failures string, defaultFailures writeFailures) writeFailures {
+	switch failures {
+	case config.WriteFailuresFatal:
+		return writeFailuresFatal
+	case config.WriteFailuresBestEffort:
+		return writeFailuresBestEffort
+	case config.WriteFailuresJournal:
+		return writeFailuresJournal
+	default:
+		return defaultFailures
+	}
+}
+
+// newTableWritePolicies returns nil if there are no policies and failures are fatal by default.
+func newTableWritePolicies(policies map[string]config.TableWritePolicy, defaultFailures string) *tableWritePolicies {
+	parsedDefaultFailures := parseWriteFailures(defaultFailures, writeFailuresFatal)
+	if len(policies) == 0 && parsedDefaultFailures == writeFailuresFatal {
+		return nil
+	}
+	return &tableWritePolicies{policies: policies, defaultFailures: parsedDefaultFailures}
+}
+
+// Get returns the write policy of the provided table. System tables never have a write policy.
+// A nil object doesn't have any policy.
+func (recv *tableWritePolicies) Get(keyspace string, table string) (config.TableWritePolicy, bool) {
+	if recv == nil || keyspace == "" || isSystemKeyspace(keyspace) {
+		return config.TableWritePolicy{}, false
+	}
+	if table != "" {
+		if policy, ok := recv.policies[keyspace+"."+table]; ok {
+			return policy, true
+		}
+	}
+	policy, ok := recv.policies[keyspace]
+	return policy, ok
+}
+
+// getRequestInfo returns the request info of the provided statement if it is a write on a table with a policy that
+// changes the default behavior (i.e. anything other than fatal writes on both clusters), nil otherwise.
+// Writes on tables without a policy use the failures of ZDM_DUAL_WRITES_FAILURES.
+func (recv *tableWritePolicies) getRequestInfo(queryInfo QueryInfo) *TableWritePolicyRequestInfo {
+	if recv == nil || !isWriteQuery(queryInfo) {
+		return nil
+	}
+	keyspace := queryInfo.getApplicableKeyspace()
+	if keyspace == "" || isSystemKeyspace(keyspace) {
+		return nil
+	}
+	policy, ok := recv.Get(keyspace, queryInfo.getTableName())
+	if !ok {
+		policy = config.TableWritePolicy{Clusters: config.WritePolicyBoth}
+	}
+	switch policy.Clusters {
+	case config.WritePolicyOrigin:
+		return NewTableWritePolicyRequestInfo(forwardToOrigin, writeFailuresFatal)
+	case config.WritePolicyTarget:
+		return NewTableWritePolicyRequestInfo(forwardToTarget, writeFailuresFatal)
+	default:
+		failures := parseWriteFailures(policy.Failures, recv.defaultFailures)
+		if failures == writeFailuresFatal {
+			return nil
+		}
+		return NewTableWritePolicyRequestInfo(forwardToBoth, failures)
+	}
+}
+
+func isWriteQuery(queryInfo QueryInfo) bool {
+	switch queryInfo.getStatementType() {
+	case statementTypeInsert, statementTypeUpdate, statementTypeDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// batchWritePolicy combines the write policies of the statements of a BATCH. A batch is only sent to a single cluster
+// if all of its statements are sent to that cluster and its failures are only handled as best effort if all of its
+// statements are best effort writes (journaled if any of them is), otherwise the batch is sent to both clusters.
+type batchWritePolicy struct {
+	decision   forwardDecision
+	failures   writeFailures
+	empty      bool
+	originOnly bool // true if any statement is only sent to ORIGIN, see MixedBatchError
+}
+
+func newBatchWritePolicy() *batchWritePolicy {
+	return &batchWritePolicy{decision: forwardToBoth, failures: writeFailuresBestEffort, empty: true}
+}
+
+func (recv *batchWritePolicy) add(requestInfo RequestInfo) {
+	decision := forwardToBoth
+	failures := writeFailuresFatal
+	switch typedRequestInfo := requestInfo.(type) {
+	case *ExcludedTableRequestInfo:
+		decision = forwardToOrigin
+	case *TableWritePolicyRequestInfo:
+		decision = typedRequestInfo.GetForwardDecision()
+		failures = typedRequestInfo.GetWriteFailures()
+	}
+	if decision == forwardToOrigin {
+		recv.originOnly = true
+	}
+	if recv.empty {
+		recv.decision = decision
+		recv.empty = false
+	} else if recv.decision != decision {
+		recv.decision = forwardToBoth
+	}
+	if recv.failures == writeFailuresFatal || failures == writeFailuresFatal {
+		recv.failures = writeFailuresFatal
+	} else if failures == writeFailuresJournal {
+		recv.failures = writeFailuresJournal
+	}
+}
+
+// isBestEffortWrite returns true if failures of the provided request on the cluster that is not the primary cluster
+// should not be returned to the client.
+func isBestEffortWrite(requestInfo RequestInfo) bool {
+	return getWriteFailures(requestInfo) != writeFailuresFatal
+}
+
+// getWriteFailures returns how failures of the provided request on the cluster that is not the primary cluster are
+// handled.
+func getWriteFailures(requestInfo RequestInfo) writeFailures {
+	switch typedRequestInfo := requestInfo.(type) {
+	case *TableWritePolicyRequestInfo:
+		return typedRequestInfo.GetWriteFailures()
+	case *BatchRequestInfo:
+		return typedRequestInfo.GetWriteFailures()
+	case *ExecuteRequestInfo:
+		return getWriteFailures(typedRequestInfo.getBaseRequestInfo())
+	default:
+		return writeFailuresFatal
+	}
+}
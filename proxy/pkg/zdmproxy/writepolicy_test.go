@@ -0,0 +1,189 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestTableWritePolicies() *tableWritePolicies {
+	return newTableWritePolicies(map[string]config.TableWritePolicy{
+		"ks1":     {Clusters: config.WritePolicyOrigin},
+		"ks1.tb2": {Clusters: config.WritePolicyBoth, Failures: config.WriteFailuresBestEffort},
+		"ks1.tb4": {Clusters: config.WritePolicyBoth, Failures: config.WriteFailuresJournal},
+		"ks2.tb1": {Clusters: config.WritePolicyTarget},
+		"ks2.tb2": {Clusters: config.WritePolicyBoth},
+		"system":  {Clusters: config.WritePolicyOrigin},
+	}, config.WriteFailuresFatal)
+}
+
+func TestTableWritePolicies_Get(t *testing.T) {
+	require.Nil(t, newTableWritePolicies(nil, config.WriteFailuresFatal))
+	require.NotNil(t, newTableWritePolicies(nil, config.WriteFailuresBestEffort))
+	var nilPolicies *tableWritePolicies
+	_, ok := nilPolicies.Get("ks1", "tb1")
+	require.False(t, ok)
+
+	policies := newTestTableWritePolicies()
+	policy, ok := policies.Get("ks1", "tb1")
+	require.True(t, ok)
+	require.Equal(t, config.TableWritePolicy{Clusters: config.WritePolicyOrigin}, policy)
+	policy, ok = policies.Get("ks1", "tb2")
+	require.True(t, ok)
+	require.Equal(t, config.TableWritePolicy{Clusters: config.WritePolicyBoth, Failures: config.WriteFailuresBestEffort}, policy)
+	_, ok = policies.Get("ks2", "tb3")
+	require.False(t, ok)
+	_, ok = policies.Get("system", "local")
+	require.False(t, ok)
+}
+
+func TestGetRequestInfoFromQueryInfo_TableWritePolicies(t *testing.T) {
+	policies := newTestTableWritePolicies()
+	tests := []struct {
+		name     string
+		query    string
+		keyspace string
+		decision forwardDecision
+		failures writeFailures
+		policy   bool
+	}{
+		{"origin only keyspace", "INSERT INTO ks1.tb1 (a) VALUES (1)", "", forwardToOrigin, writeFailuresFatal, true},
+		{"origin only current keyspace", "UPDATE tb1 SET a = 1 WHERE b = 2", "ks1", forwardToOrigin, writeFailuresFatal, true},
+		{"best effort table", "DELETE FROM ks1.tb2 WHERE a = 1", "", forwardToBoth, writeFailuresBestEffort, true},
+		{"journal table", "DELETE FROM ks1.tb4 WHERE a = 1", "", forwardToBoth, writeFailuresJournal, true},
+		{"target only table", "INSERT INTO ks2.tb1 (a) VALUES (1)", "", forwardToTarget, writeFailuresFatal, true},
+		{"fatal writes on both clusters", "INSERT INTO ks2.tb2 (a) VALUES (1)", "", forwardToBoth, writeFailuresFatal, false},
+		{"table without policy", "INSERT INTO ks2.tb3 (a) VALUES (1)", "", forwardToBoth, writeFailuresFatal, false},
+		{"read", "SELECT * FROM ks1.tb1", "", forwardToOrigin, writeFailuresFatal, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+			require.Nil(t, err)
+			f := mockQueryFrame(t, tt.query)
+			queryInfo := inspectCqlQuery(tt.query, tt.keyspace, timeUuidGenerator)
+			actual := getRequestInfoFromQueryInfo(f, common.ClusterTypeOrigin, nil, nil, policies, false, true, queryInfo)
+			require.Equal(t, tt.decision, actual.GetForwardDecision())
+			if tt.policy {
+				require.Equal(t, NewTableWritePolicyRequestInfo(tt.decision, tt.failures), actual)
+			} else {
+				require.IsType(t, &GenericRequestInfo{}, actual)
+			}
+			require.Equal(t, tt.failures, getWriteFailures(actual))
+			require.Equal(t, tt.failures != writeFailuresFatal, isBestEffortWrite(actual))
+		})
+	}
+
+	excluded := getRequestInfoFromQueryInfo(
+		mockQueryFrame(t, "INSERT INTO ks2.tb1 (a) VALUES (1)"), common.ClusterTypeOrigin, nil,
+		newTableFilter(nil, []string{"ks2"}), policies, false, true,
+		inspectCqlQuery("INSERT INTO ks2.tb1 (a) VALUES (1)", "", nil))
+	require.Equal(t, NewExcludedTableWriteRequestInfo(), excluded)
+
+	prepareOrigin := NewPrepareRequestInfo(
+		NewTableWritePolicyRequestInfo(forwardToOrigin, writeFailuresFatal), nil, false, "INSERT INTO ks1.tb1 (a) VALUES (?)", "")
+	require.Equal(t, forwardToOrigin, prepareOrigin.GetForwardDecision())
+	prepareTarget := NewPrepareRequestInfo(
+		NewTableWritePolicyRequestInfo(forwardToTarget, writeFailuresFatal), nil, false, "INSERT INTO ks2.tb1 (a) VALUES (?)", "")
+	require.Equal(t, forwardToBoth, prepareTarget.GetForwardDecision())
+	require.True(t, isBestEffortWrite(NewExecuteRequestInfo(NewPreparedData(
+		&message.PreparedResult{}, &message.PreparedResult{},
+		NewPrepareRequestInfo(NewTableWritePolicyRequestInfo(forwardToBoth, writeFailuresBestEffort), nil, false, "", "")))))
+}
+
+func TestBuildRequestInfo_TableWritePoliciesBatch(t *testing.T) {
+	policies := newTestTableWritePolicies()
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	psCache := NewPreparedStatementCache(0)
+	psCache.Store(
+		&message.PreparedResult{PreparedQueryId: []byte("target")},
+		&message.PreparedResult{PreparedQueryId: []byte("target")},
+		NewPrepareRequestInfo(
+			NewTableWritePolicyRequestInfo(forwardToTarget, writeFailuresFatal), nil, false, "INSERT INTO ks2.tb1 (a) VALUES (?)", ""))
+
+	tests := []struct {
+		name     string
+		children []*message.BatchChild
+		decision forwardDecision // forwardToNone if the batch is rejected
+		failures writeFailures
+	}{
+		{"origin only", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"},
+			{Query: "INSERT INTO ks1.tb3 (a) VALUES (1)"},
+		}, forwardToOrigin, writeFailuresFatal},
+		{"target only", []*message.BatchChild{
+			{Query: "INSERT INTO ks2.tb1 (a) VALUES (1)"},
+			{Id: []byte("target")},
+		}, forwardToTarget, writeFailuresFatal},
+		{"best effort", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb2 (a) VALUES (1)"},
+			{Query: "DELETE FROM ks1.tb2 WHERE a = 1"},
+		}, forwardToBoth, writeFailuresBestEffort},
+		{"mixed policies", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb1 (a) VALUES (1)"},
+			{Query: "INSERT INTO ks2.tb1 (a) VALUES (1)"},
+		}, forwardToNone, writeFailuresFatal},
+		{"target only and table without policy", []*message.BatchChild{
+			{Query: "INSERT INTO ks2.tb1 (a) VALUES (1)"},
+			{Query: "INSERT INTO ks3.tb1 (a) VALUES (1)"},
+		}, forwardToBoth, writeFailuresFatal},
+		{"best effort and table without policy", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb2 (a) VALUES (1)"},
+			{Query: "INSERT INTO ks3.tb1 (a) VALUES (1)"},
+		}, forwardToBoth, writeFailuresFatal},
+		{"best effort and journal", []*message.BatchChild{
+			{Query: "INSERT INTO ks1.tb2 (a) VALUES (1)"},
+			{Query: "INSERT INTO ks1.tb4 (a) VALUES (1)"},
+		}, forwardToBoth, writeFailuresJournal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mockFrame(t, &message.Batch{Children: tt.children}, primitive.ProtocolVersion4)
+			actual, err := buildRequestInfo(
+				&frameDecodeContext{frame: f}, nil, psCache, newFakeMetricHandler(), "", common.ClusterTypeOrigin,
+				nil, nil, policies, false, true, false, timeUuidGenerator)
+			if tt.decision == forwardToNone {
+				require.IsType(t, &MixedBatchError{}, err)
+				return
+			}
+			require.Nil(t, err)
+			require.IsType(t, &BatchRequestInfo{}, actual)
+			require.Equal(t, tt.decision, actual.GetForwardDecision())
+			require.Equal(t, tt.failures, getWriteFailures(actual))
+		})
+	}
+}
+
+func TestTableWritePolicies_DefaultFailures(t *testing.T) {
+	policies := newTableWritePolicies(map[string]config.TableWritePolicy{
+		"ks1":     {Clusters: config.WritePolicyBoth},
+		"ks1.tb2": {Clusters: config.WritePolicyBoth, Failures: config.WriteFailuresFatal},
+		"ks2":     {Clusters: config.WritePolicyOrigin},
+	}, config.WriteFailuresJournal)
+	tests := []struct {
+		name     string
+		query    string
+		decision forwardDecision
+		failures writeFailures
+	}{
+		{"entry without failures", "INSERT INTO ks1.tb1 (a) VALUES (1)", forwardToBoth, writeFailuresJournal},
+		{"fatal entry", "INSERT INTO ks1.tb2 (a) VALUES (1)", forwardToBoth, writeFailuresFatal},
+		{"origin only entry", "INSERT INTO ks2.tb1 (a) VALUES (1)", forwardToOrigin, writeFailuresFatal},
+		{"table without policy", "INSERT INTO ks3.tb1 (a) VALUES (1)", forwardToBoth, writeFailuresJournal},
+		{"system table", "INSERT INTO system.tb1 (a) VALUES (1)", forwardToBoth, writeFailuresFatal},
+		{"table without keyspace", "INSERT INTO tb1 (a) VALUES (1)", forwardToBoth, writeFailuresFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := getRequestInfoFromQueryInfo(
+				mockQueryFrame(t, tt.query), common.ClusterTypeOrigin, nil, nil, policies, false, true,
+				inspectCqlQuery(tt.query, "", nil))
+			require.Equal(t, tt.decision, actual.GetForwardDecision())
+			require.Equal(t, tt.failures, getWriteFailures(actual))
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// zdm-loadgen sends a synthetic CQL workload (see proxy/pkg/workload) to a cluster or to a ZDM proxy and prints its
+// throughput and latencies. It is meant to load and soak test the proxy: running the same workload directly against a
+// cluster and through the proxy gives the overhead of the forwarding path, and the requests only depend on the flags
+// (including -seed) so the numbers of two runs can be compared.
+//
+// The workload writes to and reads from a single table with the schema
+// (pk bigint, ck bigint, value blob, PRIMARY KEY (pk, ck)), use -create-schema to create it.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/workload"
+	"github.com/rs/zerolog"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	address         = flag.String("address", "localhost:9042", "address (host:port) of the cluster or proxy")
+	username        = flag.String("username", "", "username used to authenticate, leave empty if authentication is not required")
+	password        = flag.String("password", "", "password used to authenticate")
+	protocolVersion = flag.Int("protocol-version", 4, "protocol version used by the connections")
+	keyspace        = flag.String("keyspace", "zdm_workload", "keyspace of the workload table")
+	table           = flag.String("table", "kv", "name of the workload table")
+	createSchema    = flag.Bool("create-schema", false, "create the keyspace (SimpleStrategy, RF 1) and table if they don't exist")
+	reads           = flag.Int("reads", 50, "percentage of the requests that are reads, the other requests are writes")
+	prepared        = flag.Int("prepared", 0, "percentage of the requests sent as prepared statements")
+	batches         = flag.Int("batches", 0, "percentage of the writes sent as unlogged batches")
+	batchSize       = flag.Int("batch-size", 5, "number of inserts per batch")
+	partitions      = flag.Int("partitions", 1000, "number of partitions that the requests are spread over")
+	valueSize       = flag.Int("value-size", 100, "size in bytes of the written values")
+	connections     = flag.Int("connections", 1, "number of connections")
+	concurrency     = flag.Int("concurrency", 1, "number of requests in flight per connection")
+	rate            = flag.Float64("rate", 0, "target throughput in requests per second, 0 sends requests as fast as possible")
+	duration        = flag.Duration("duration", 10*time.Second, "duration of the workload")
+	requests        = flag.Int64("requests", 0, "stop after sending this many requests, 0 means no limit")
+	seed            = flag.Int64("seed", 1, "seed of the generated requests")
+	consistency     = flag.String("consistency", "LOCAL_QUORUM", "consistency level of the requests")
+	stopOnError     = flag.Bool("stop-on-error", false, "stop the workload at the first request error")
+)
+
+func main() {
+	flag.Parse()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	consistencyLevel, ok := parseConsistencyLevel(*consistency)
+	if !ok {
+		log.Errorf("Invalid consistency level %v.", *consistency)
+		os.Exit(-1)
+	}
+	version := primitive.ProtocolVersion(*protocolVersion)
+	if *protocolVersion < 0 || *protocolVersion > 255 || !version.IsSupported() {
+		log.Errorf("Unsupported protocol version %v.", *protocolVersion)
+		os.Exit(-1)
+	}
+
+	conf := workload.NewConfig()
+	conf.Keyspace = *keyspace
+	conf.Table = *table
+	conf.CreateSchemaIfNeeded = *createSchema
+	conf.ReadPercent = *reads
+	conf.PreparedPercent = *prepared
+	conf.BatchPercent = *batches
+	conf.BatchSize = *batchSize
+	conf.Partitions = *partitions
+	conf.ValueSize = *valueSize
+	conf.Connections = *connections
+	conf.ConcurrencyPerConn = *concurrency
+	conf.RequestsPerSecond = *rate
+	conf.Duration = *duration
+	conf.Requests = *requests
+	conf.Seed = *seed
+	conf.ProtocolVersion = version
+	conf.Consistency = consistencyLevel
+	conf.StopOnFirstError = *stopOnError
+	if err := conf.Validate(); err != nil {
+		log.Errorf("Invalid workload: %v", err)
+		os.Exit(-1)
+	}
+
+	var credentials *client.AuthCredentials
+	if *username != "" {
+		credentials = &client.AuthCredentials{Username: *username, Password: *password}
+	}
+
+	// Ctrl-C stops the workload early, the result of the requests sent so far is still printed
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	log.Infof("Sending workload to %v.", *address)
+	result, err := workload.Run(ctx, client.NewCqlClient(*address, credentials), conf)
+	if err != nil {
+		log.Errorf("Workload failed: %v", err)
+		os.Exit(-1)
+	}
+	log.Infof("Result: %v", result)
+	if result.FirstError != nil {
+		log.Warnf("First request error: %v", result.FirstError)
+	}
+}
+
+var consistencyLevels = map[string]primitive.ConsistencyLevel{
+	"ANY":          primitive.ConsistencyLevelAny,
+	"ONE":          primitive.ConsistencyLevelOne,
+	"TWO":          primitive.ConsistencyLevelTwo,
+	"THREE":        primitive.ConsistencyLevelThree,
+	"QUORUM":       primitive.ConsistencyLevelQuorum,
+	"ALL":          primitive.ConsistencyLevelAll,
+	"LOCAL_QUORUM": primitive.ConsistencyLevelLocalQuorum,
+	"EACH_QUORUM":  primitive.ConsistencyLevelEachQuorum,
+	"LOCAL_ONE":    primitive.ConsistencyLevelLocalOne,
+}
+
+func parseConsistencyLevel(name string) (primitive.ConsistencyLevel, bool) {
+	level, ok := consistencyLevels[strings.ToUpper(strings.TrimSpace(name))]
+	return level, ok
+}
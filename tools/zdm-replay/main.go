@@ -0,0 +1,187 @@
+// zdm-replay sends the client requests recorded by the ZDM proxy (see ZDM_PROXY_CAPTURE_FILE) to a cluster. It also
+// replays the write journal of the proxy (see ZDM_DUAL_WRITES_JOURNAL_FILE).
+//
+// Every captured client connection is replayed on its own connection, in the order in which the requests were captured
+// and with the same delays between them (scaled by -speed). Requests of the same connection are sent one at a time,
+// i.e. a request is only sent after the response to the previous one is received, so heavily pipelined connections
+// are replayed more slowly than they were captured.
+//
+// The captured STARTUP request only provides the protocol version: the handshake is done with the credentials provided
+// with -username and -password because the proxy doesn't capture AUTH_RESPONSE requests. REGISTER requests are not
+// replayed.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/capture"
+	"github.com/rs/zerolog"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	captureFile = flag.String("file", "", "path of the capture file written by the proxy")
+	address     = flag.String("address", "localhost:9042", "address (host:port) of the cluster to replay the requests on")
+	username    = flag.String("username", "", "username used to authenticate, leave empty if the cluster doesn't require authentication")
+	password    = flag.String("password", "", "password used to authenticate")
+	speed       = flag.Float64("speed", 1, "replay speed relative to the captured traffic, 0 replays the requests as fast as possible")
+)
+
+var codec = frame.NewRawCodec()
+
+type replayStats struct {
+	sent        int64
+	errors      int64
+	skipped     int64
+	connections int64
+}
+
+func main() {
+	flag.Parse()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	if *captureFile == "" {
+		log.Error("The path of the capture file is required (-file).")
+		os.Exit(-1)
+	}
+	if *speed < 0 {
+		log.Errorf("Invalid replay speed %v, it must not be negative.", *speed)
+		os.Exit(-1)
+	}
+
+	file, err := os.Open(*captureFile)
+	if err != nil {
+		log.Errorf("Could not open capture file: %v", err)
+		os.Exit(-1)
+	}
+	defer file.Close()
+
+	reader, err := capture.NewReader(file)
+	if err != nil {
+		log.Errorf("Could not read capture file %v: %v", *captureFile, err)
+		os.Exit(-1)
+	}
+
+	var credentials *client.AuthCredentials
+	if *username != "" {
+		credentials = &client.AuthCredentials{Username: *username, Password: *password}
+	}
+
+	stats := &replayStats{}
+	err = replay(reader, client.NewCqlClient(*address, credentials), stats)
+	log.Infof("Replayed %v requests on %v connections (%v errors, %v requests skipped).",
+		stats.sent, stats.connections, stats.errors, stats.skipped)
+	if err != nil {
+		log.Errorf("Replay stopped because of an error: %v", err)
+		os.Exit(-1)
+	}
+}
+
+func replay(reader *capture.Reader, cqlClient *client.CqlClient, stats *replayStats) error {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	connections := make(map[uint64]chan *capture.Record)
+	defer func() {
+		for _, records := range connections {
+			close(records)
+		}
+	}()
+
+	var captureStart time.Time
+	replayStart := time.Now()
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if captureStart.IsZero() {
+			captureStart = record.Timestamp
+		}
+		if *speed > 0 {
+			offset := time.Duration(float64(record.Timestamp.Sub(captureStart)) / *speed)
+			time.Sleep(time.Until(replayStart.Add(offset)))
+		}
+
+		records, ok := connections[record.ConnectionId]
+		if !ok {
+			records = make(chan *capture.Record, 1024)
+			connections[record.ConnectionId] = records
+			wg.Add(1)
+			go func(connectionId uint64) {
+				defer wg.Done()
+				replayConnection(connectionId, records, cqlClient, stats)
+			}(record.ConnectionId)
+		}
+		records <- record
+	}
+}
+
+func replayConnection(connectionId uint64, records <-chan *capture.Record, cqlClient *client.CqlClient, stats *replayStats) {
+	var conn *client.CqlClientConnection
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	for record := range records {
+		switch record.Frame.Header.OpCode {
+		case primitive.OpCodeStartup:
+			if conn != nil {
+				atomic.AddInt64(&stats.skipped, 1)
+				continue
+			}
+			var err error
+			conn, err = cqlClient.ConnectAndInit(context.Background(), record.Frame.Header.Version, client.ManagedStreamId)
+			if err != nil {
+				log.Warnf("Could not open connection to replay captured connection %v: %v", connectionId, err)
+				conn = nil
+				continue
+			}
+			atomic.AddInt64(&stats.connections, 1)
+			continue
+		case primitive.OpCodeRegister, primitive.OpCodeAuthResponse:
+			atomic.AddInt64(&stats.skipped, 1)
+			continue
+		}
+
+		if conn == nil {
+			// requests sent before the handshake (OPTIONS) or on a connection that could not be opened
+			atomic.AddInt64(&stats.skipped, 1)
+			continue
+		}
+
+		f, err := codec.ConvertFromRawFrame(record.Frame)
+		if err != nil {
+			log.Warnf("Could not decode captured request %v of connection %v: %v", record.Frame.Header, connectionId, err)
+			atomic.AddInt64(&stats.skipped, 1)
+			continue
+		}
+		f.Header.StreamId = client.ManagedStreamId
+
+		response, err := conn.SendAndReceive(f)
+		atomic.AddInt64(&stats.sent, 1)
+		if err != nil {
+			log.Warnf("Could not replay request %v of connection %v: %v", f.Header.OpCode, connectionId, err)
+			atomic.AddInt64(&stats.errors, 1)
+			continue
+		}
+		if errMsg, ok := response.Body.Message.(message.Error); ok {
+			log.Debugf("Replayed request %v of connection %v returned an error: %v", f.Header.OpCode, connectionId, errMsg)
+			atomic.AddInt64(&stats.errors, 1)
+		}
+	}
+}